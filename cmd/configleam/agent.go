@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateSpec is a template rendered into a destination file
+type templateSpec struct {
+	source      string
+	destination string
+	mode        fs.FileMode
+	tmpl        *template.Template
+}
+
+// templatesFlag collects the '-template <source>:<destination>[:<mode>]' flags
+type templatesFlag []*templateSpec
+
+func (t *templatesFlag) String() string {
+	return ""
+}
+
+func (t *templatesFlag) Set(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected <source>:<destination>[:<mode>], got '%s'", value)
+	}
+
+	spec := &templateSpec{source: parts[0], destination: parts[1], mode: 0644}
+	if len(parts) == 3 {
+		mode, err := strconv.ParseUint(parts[2], 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid file mode '%s' of template '%s': %v", parts[2], parts[0], err)
+		}
+		spec.mode = fs.FileMode(mode)
+	}
+
+	*t = append(*t, spec)
+	return nil
+}
+
+// templateData is what the templates are rendered with
+type templateData struct {
+	Env     string
+	Version string
+	Groups  map[string]interface{}
+	Globals map[string]interface{}
+}
+
+var templateFuncs = template.FuncMap{
+	"toJSON": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"toYAML": func(v interface{}) (string, error) {
+		b, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(b), "\n"), err
+	},
+	"default": func(fallback, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return fallback
+		}
+		return v
+	},
+	"quote": strconv.Quote,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// runAgent renders templates into files from the whole configuration of an environment, read through the API,
+// and renders them again whenever the configuration changes, reloading the application when a file changed.
+// It is meant to run as a sidecar sharing a volume, or with -once as an init container.
+func runAgent(args []string) error {
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: configleam agent -url <url> -key <access-key> -env <env> -template <source>:<destination>[:<mode>]... [flags]")
+		flags.PrintDefaults()
+	}
+
+	var remote remoteFlags
+	remote.registerEnv(flags)
+	var templates templatesFlag
+	flags.Var(&templates, "template", "template rendered into a file as <source>:<destination>[:<mode>], the mode defaulting to 0644, repeated for every template")
+	once := flags.Bool("once", false, "render the templates once and exit, e.g. as an init container")
+	pidFile := flags.String("reload-pid-file", "", "file holding the pid of the process signaled when a file changed")
+	reloadSignal := flags.String("reload-signal", "HUP", "signal sent to the process of -reload-pid-file")
+	reloadURL := flags.String("reload-url", "", "URL receiving a POST request when a file changed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(templates) == 0 {
+		return errors.New("at least one -template is required")
+	}
+
+	sig, err := parseSignal(*reloadSignal)
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range templates {
+		spec.tmpl, err = template.New(filepath.Base(spec.source)).Option("missingkey=error").Funcs(templateFuncs).ParseFiles(spec.source)
+		if err != nil {
+			return fmt.Errorf("error parsing template '%s': %v", spec.source, err)
+		}
+	}
+
+	cl, err := remote.client(true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, version, err := cl.Config(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the application is started after the first rendering, it is not reloaded
+	_, err = renderTemplates(templates, newTemplateData(remote.env, version, cfg))
+	if err != nil {
+		return err
+	}
+	if *once {
+		return nil
+	}
+
+	// only the latest configuration matters when several changes arrive while rendering
+	type change struct {
+		cfg     map[string]interface{}
+		version string
+	}
+	changes := make(chan change, 1)
+	cl.OnChange(func(cfg map[string]interface{}, version string) {
+		select {
+		case <-changes:
+		default:
+		}
+		changes <- change{cfg, version}
+	})
+
+	go cl.Watch(ctx, func(err error) { log.Println("Error watching configuration updates:", err) })
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case c := <-changes:
+			log.Printf("Rendering templates with version '%s' of '%s' environment", c.version, remote.env)
+
+			changed, err := renderTemplates(templates, newTemplateData(remote.env, c.version, c.cfg))
+			if err != nil {
+				// the files keep the previous configuration until the templates render again,
+				// except those written before a write failed, which the application is still reloaded for
+				log.Println("Error rendering templates:", err)
+			}
+			if !changed {
+				continue
+			}
+
+			err = reload(ctx, *pidFile, sig, *reloadURL)
+			if err != nil {
+				log.Println("Error reloading application:", err)
+			}
+		}
+	}
+}
+
+func newTemplateData(env, version string, cfg map[string]interface{}) templateData {
+	data := templateData{Env: env, Version: version, Groups: map[string]interface{}{}, Globals: map[string]interface{}{}}
+	if groups, ok := cfg["groups"].(map[string]interface{}); ok {
+		data.Groups = groups
+	}
+	if globals, ok := cfg["globals"].(map[string]interface{}); ok {
+		data.Globals = globals
+	}
+	return data
+}
+
+// renderTemplates renders every template before writing any file, so either all the files get the new
+// configuration or none does, and reports whether any file content changed
+func renderTemplates(templates []*templateSpec, data templateData) (bool, error) {
+	rendered := make([][]byte, len(templates))
+	for i, spec := range templates {
+		var b bytes.Buffer
+		err := spec.tmpl.Execute(&b, data)
+		if err != nil {
+			return false, fmt.Errorf("error rendering template '%s': %v", spec.source, err)
+		}
+		rendered[i] = b.Bytes()
+	}
+
+	changed := false
+	for i, spec := range templates {
+		current, err := os.ReadFile(spec.destination)
+		if err == nil && bytes.Equal(current, rendered[i]) {
+			continue
+		}
+
+		err = writeFileAtomic(spec.destination, rendered[i], spec.mode)
+		if err != nil {
+			return changed, err
+		}
+
+		log.Printf("Rendered '%s' into '%s'", spec.source, spec.destination)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// writeFileAtomic replaces the file with a renamed temporary file, so readers never see a partial content
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating file '%s': %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing file '%s': %v", path, err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("error replacing file '%s': %v", path, err)
+	}
+	return nil
+}
+
+// reload signals the process of the pid file and sends a POST request to the reload URL, when configured
+func reload(ctx context.Context, pidFile string, sig os.Signal, reloadURL string) error {
+	if pidFile != "" {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return fmt.Errorf("error reading pid file '%s': %v", pidFile, err)
+		}
+
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("invalid pid in file '%s': %v", pidFile, err)
+		}
+
+		process, err := os.FindProcess(pid)
+		if err == nil {
+			err = process.Signal(sig)
+		}
+		if err != nil {
+			return fmt.Errorf("error sending %v to process %d: %v", sig, pid, err)
+		}
+
+		log.Printf("Sent %v to process %d", sig, pid)
+	}
+
+	if reloadURL != "" {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, reloadURL, nil)
+		if err != nil {
+			return fmt.Errorf("error creating reload request: %v", err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("error sending reload request to '%s': %v", reloadURL, err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("reload request to '%s' responded with status %d", reloadURL, res.StatusCode)
+		}
+
+		log.Printf("Sent reload request to '%s'", reloadURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *AgentTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+func TestAgentTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentTestSuite))
+}
+
+// template parses the text as the template of a spec rendered into the destination, within the test directory
+func (suite *AgentTestSuite) template(text, destination string) *templateSpec {
+	tmpl, err := template.New(destination).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	suite.Require().NoError(err)
+	return &templateSpec{source: destination + ".tmpl", destination: filepath.Join(suite.dir, destination), mode: 0644, tmpl: tmpl}
+}
+
+func (suite *AgentTestSuite) data(host string) templateData {
+	return newTemplateData("dev", "v1.0.0", map[string]interface{}{
+		"globals": map[string]interface{}{"database": map[string]interface{}{"host": host}},
+	})
+}
+
+func (suite *AgentTestSuite) TestTemplatesFlagSet() {
+	testCases := []struct {
+		name         string
+		value        string
+		expectedSpec templateSpec
+		expectError  bool
+	}{
+		{"default mode", "app.tmpl:/etc/app.conf", templateSpec{source: "app.tmpl", destination: "/etc/app.conf", mode: 0644}, false},
+		{"octal mode", "app.tmpl:/etc/app.conf:0600", templateSpec{source: "app.tmpl", destination: "/etc/app.conf", mode: 0600}, false},
+		{"mode without leading zero", "app.tmpl:/etc/app.conf:755", templateSpec{source: "app.tmpl", destination: "/etc/app.conf", mode: 0755}, false},
+		{"non octal mode", "app.tmpl:/etc/app.conf:0800", templateSpec{}, true},
+		{"named mode", "app.tmpl:/etc/app.conf:rw", templateSpec{}, true},
+		{"missing destination", "app.tmpl", templateSpec{}, true},
+		{"empty destination", "app.tmpl:", templateSpec{}, true},
+		{"empty source", ":/etc/app.conf", templateSpec{}, true},
+		{"too many parts", "app.tmpl:/etc/app.conf:0644:extra", templateSpec{}, true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			var templates templatesFlag
+			err := templates.Set(tc.value)
+
+			if tc.expectError {
+				suite.Error(err)
+				suite.Empty(templates)
+				return
+			}
+			suite.NoError(err)
+			suite.Require().Len(templates, 1)
+			suite.Equal(tc.expectedSpec, *templates[0])
+		})
+	}
+}
+
+func (suite *AgentTestSuite) TestRenderTemplates() {
+	app := suite.template(`host={{ .Globals.database.host }} env={{ .Env }}`, "app.conf")
+	settings := suite.template(`{{ toJSON .Globals }}`, "app.json")
+	app.mode = 0600
+
+	changed, err := renderTemplates([]*templateSpec{app, settings}, suite.data("localhost"))
+	suite.NoError(err)
+	suite.True(changed)
+
+	content, err := os.ReadFile(app.destination)
+	suite.NoError(err)
+	suite.Equal("host=localhost env=dev", string(content))
+
+	info, err := os.Stat(app.destination)
+	suite.NoError(err)
+	suite.Equal(fs.FileMode(0600), info.Mode().Perm())
+
+	content, err = os.ReadFile(settings.destination)
+	suite.NoError(err)
+	suite.JSONEq(`{"database":{"host":"localhost"}}`, string(content))
+}
+
+func (suite *AgentTestSuite) TestRenderTemplatesUnchanged() {
+	app := suite.template(`host={{ .Globals.database.host }}`, "app.conf")
+
+	changed, err := renderTemplates([]*templateSpec{app}, suite.data("localhost"))
+	suite.NoError(err)
+	suite.True(changed)
+
+	info, err := os.Stat(app.destination)
+	suite.NoError(err)
+
+	changed, err = renderTemplates([]*templateSpec{app}, suite.data("localhost"))
+	suite.NoError(err)
+	suite.False(changed, "the same content must not be reported as a change")
+
+	unchanged, err := os.Stat(app.destination)
+	suite.NoError(err)
+	suite.True(os.SameFile(info, unchanged), "the file must not be replaced when its content is the same")
+
+	changed, err = renderTemplates([]*templateSpec{app}, suite.data("db.internal"))
+	suite.NoError(err)
+	suite.True(changed)
+}
+
+func (suite *AgentTestSuite) TestRenderTemplatesRendersAllBeforeWriting() {
+	app := suite.template(`host={{ .Globals.database.host }}`, "app.conf")
+	broken := suite.template(`port={{ .Globals.database.port }}`, "broken.conf")
+
+	changed, err := renderTemplates([]*templateSpec{app, broken}, suite.data("localhost"))
+	suite.Error(err)
+	suite.False(changed)
+
+	suite.NoFileExists(app.destination, "no file must be written when a template fails to render")
+	suite.NoFileExists(broken.destination)
+}
+
+func (suite *AgentTestSuite) TestRenderTemplatesPartialWrite() {
+	app := suite.template(`host={{ .Globals.database.host }}`, "app.conf")
+	unwritable := suite.template(`host={{ .Globals.database.host }}`, "missing/app.conf")
+
+	changed, err := renderTemplates([]*templateSpec{app, unwritable}, suite.data("localhost"))
+	suite.Error(err)
+	suite.True(changed, "the files written before the failure must be reported as a change")
+	suite.FileExists(app.destination)
+}
+
+func (suite *AgentTestSuite) TestWriteFileAtomic() {
+	path := filepath.Join(suite.dir, "app.conf")
+	suite.Require().NoError(os.WriteFile(path, []byte("previous"), 0644))
+
+	err := writeFileAtomic(path, []byte("next"), 0640)
+	suite.NoError(err)
+
+	content, err := os.ReadFile(path)
+	suite.NoError(err)
+	suite.Equal("next", string(content))
+
+	info, err := os.Stat(path)
+	suite.NoError(err)
+	suite.Equal(fs.FileMode(0640), info.Mode().Perm())
+
+	entries, err := os.ReadDir(suite.dir)
+	suite.NoError(err)
+	suite.Len(entries, 1, "no temporary file must be left behind")
+
+	err = writeFileAtomic(filepath.Join(suite.dir, "missing", "app.conf"), []byte("next"), 0644)
+	suite.Error(err)
+}
+
+func (suite *AgentTestSuite) TestReloadURL() {
+	testCases := []struct {
+		name        string
+		status      int
+		expectError bool
+	}{
+		{"success", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"server error", http.StatusInternalServerError, true},
+		{"not found", http.StatusNotFound, true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			var method string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			err := reload(context.Background(), "", syscall.SIGHUP, server.URL+"/-/reload")
+
+			suite.Equal(http.MethodPost, method)
+			if tc.expectError {
+				suite.ErrorContains(err, strconv.Itoa(tc.status))
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *AgentTestSuite) TestReloadPidFile() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+
+	pidFile := filepath.Join(suite.dir, "app.pid")
+	suite.Require().NoError(os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644))
+
+	err := reload(context.Background(), pidFile, syscall.SIGUSR1, "")
+	suite.NoError(err)
+
+	select {
+	case sig := <-signals:
+		suite.Equal(syscall.SIGUSR1, sig)
+	case <-time.After(5 * time.Second):
+		suite.Fail("the process of the pid file was not signaled")
+	}
+
+	suite.Require().NoError(os.WriteFile(pidFile, []byte("not-a-pid"), 0644))
+	suite.Error(reload(context.Background(), pidFile, syscall.SIGUSR1, ""))
+	suite.Error(reload(context.Background(), filepath.Join(suite.dir, "missing.pid"), syscall.SIGUSR1, ""))
+}
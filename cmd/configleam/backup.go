@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/raw-leak/configleam/config"
+	"github.com/raw-leak/configleam/internal/app/backup"
+	"github.com/raw-leak/configleam/internal/app/backup/service"
+	"github.com/raw-leak/configleam/internal/pkg/encryptor"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// runExport writes an archive of the configleam state of a project to a file or stdout
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write the archive to (default: stdout)")
+	projectName := flags.String("project", project.Default, "project to export (default: the default project)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := project.Validate(*projectName); err != nil {
+		return err
+	}
+
+	ctx := project.WithProject(context.Background(), *projectName)
+
+	encryptor, err := encryptor.NewEncryptor("")
+	if err != nil {
+		return err
+	}
+
+	backupSet, err := initBackup(ctx, encryptor)
+	if err != nil {
+		return err
+	}
+
+	archive, err := backupSet.Export(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("error creating archive file '%s': %v", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(archive)
+	if err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+
+	log.Printf("Exported %d envs, %d configs, %d secrets and %d access keys with checksum '%s'",
+		len(archive.Data.Envs), len(archive.Data.Config), len(archive.Data.Secrets), len(archive.Data.AccessKeys), archive.Checksum)
+
+	return nil
+}
+
+// runImport restores an archive read from a file or stdin into a project of the configured storage
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := flags.String("f", "", "file to read the archive from (default: stdin)")
+	dryRun := flags.Bool("dry-run", false, "only verify the archive without writing anything")
+	projectName := flags.String("project", project.Default, "project to import into (default: the default project)")
+	overrideProject := flags.Bool("override-project", false, "import the archive even if it was exported from another project")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := project.Validate(*projectName); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("error opening archive file '%s': %v", *input, err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	archive := &service.Archive{}
+	err := json.NewDecoder(r).Decode(archive)
+	if err != nil {
+		return fmt.Errorf("error reading archive: %v", err)
+	}
+
+	// verify before connecting, so a corrupted archive is reported even without a reachable storage
+	err = service.Verify(archive)
+	if err != nil {
+		return err
+	}
+
+	if !*overrideProject {
+		err = service.VerifyProject(archive, *projectName)
+		if err != nil {
+			return err
+		}
+	}
+
+	encryptor, err := encryptor.NewEncryptor("")
+	if err != nil {
+		return err
+	}
+
+	err = service.VerifyLookupKey(context.Background(), archive, encryptor)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		log.Printf("Archive '%s' is valid: %d envs, %d configs, %d secrets and %d access keys would be imported",
+			archive.Checksum, len(archive.Data.Envs), len(archive.Data.Config), len(archive.Data.Secrets), len(archive.Data.AccessKeys))
+		return nil
+	}
+
+	ctx := project.WithProject(context.Background(), *projectName)
+
+	backupSet, err := initBackup(ctx, encryptor)
+	if err != nil {
+		return err
+	}
+
+	_, err = backupSet.Import(ctx, archive, service.ImportOptions{OverrideProject: *overrideProject})
+	return err
+}
+
+func initBackup(ctx context.Context, encryptor *encryptor.Encryptor) (*backup.BackupSet, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return backup.Init(ctx, cfg, encryptor)
+}
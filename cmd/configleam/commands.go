@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+// runCommand runs one of the offline sub-commands against the configured storage,
+// or one of the sub-commands reading the configuration of a running Configleam through its API
+func runCommand(name string, args []string) error {
+	switch name {
+	case "export":
+		return runExport(args)
+	case "import":
+		return runImport(args)
+	case "migrate-namespace":
+		return runMigrateNamespace(args)
+	case "exec":
+		return runExec(args)
+	case "agent":
+		return runAgent(args)
+	default:
+		return fmt.Errorf("unknown command '%s', available commands: export, import, migrate-namespace, exec, agent", name)
+	}
+}
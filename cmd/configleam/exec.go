@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+)
+
+// forwardedSignals are relayed to the child process, which decides whether to exit on them
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+var signalNames = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// exitCodeError carries the exit code of a child process, the command exiting with it
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("child process exited with code %d", e.code)
+}
+
+func parseSignal(name string) (os.Signal, error) {
+	sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal '%s', supported signals: HUP, INT, QUIT, TERM, USR1, USR2", name)
+	}
+	return sig, nil
+}
+
+// runExec reads the configuration through the API, flattens it into environment variables and runs the command with them.
+// When watching, the command is restarted with the new variables, or signaled, whenever the configuration changes.
+func runExec(args []string) error {
+	flags := flag.NewFlagSet("exec", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: configleam exec -url <url> -key <access-key> -env <env> [flags] -- <command> [args...]")
+		flags.PrintDefaults()
+	}
+
+	var remote remoteFlags
+	remote.register(flags)
+	prefix := flags.String("prefix", "", "prefix of the environment variable names")
+	pristine := flags.Bool("pristine", false, "do not inherit the environment of configleam, only pass the configuration variables")
+	watch := flags.Bool("watch", false, "watch the configuration and restart or signal the command on change")
+	onChange := flags.String("on-change", "restart", "action on change when watching: 'restart' with the new variables or 'signal'")
+	reloadSignal := flags.String("signal", "HUP", "signal sent on change with -on-change=signal")
+	stopSignal := flags.String("stop-signal", "TERM", "signal stopping the command before a restart")
+	killTimeout := flags.Duration("kill-timeout", 30*time.Second, "time to wait for the command to stop before killing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	command := flags.Args()
+	if len(command) == 0 {
+		return errors.New("no command to run, e.g. 'configleam exec -env production -- ./server'")
+	}
+	if *onChange != "restart" && *onChange != "signal" {
+		return fmt.Errorf("unknown -on-change action '%s', expected 'restart' or 'signal'", *onChange)
+	}
+
+	reload, err := parseSignal(*reloadSignal)
+	if err != nil {
+		return err
+	}
+	stop, err := parseSignal(*stopSignal)
+	if err != nil {
+		return err
+	}
+
+	cl, err := remote.client(false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg, version, err := cl.Config(ctx)
+	if err != nil {
+		return err
+	}
+
+	env := func(cfg map[string]interface{}) []string {
+		vars, skipped := formatter.EnvVars(cfg, *prefix)
+		for _, s := range skipped {
+			log.Printf("Configuration key '%s' is not passed as an environment variable: %s", s.Key, s.Reason)
+		}
+
+		environ := []string{}
+		if !*pristine {
+			environ = os.Environ()
+		}
+		for name, value := range vars {
+			environ = append(environ, name+"="+value)
+		}
+		return environ
+	}
+
+	log.Printf("Running '%s' with version '%s' of '%s' environment", command[0], version, remote.env)
+
+	child, err := startChild(command, env(cfg))
+	if err != nil {
+		return err
+	}
+
+	// only the latest configuration matters when several changes arrive while the command is restarting
+	changes := make(chan map[string]interface{}, 1)
+	if *watch {
+		cl.OnChange(func(cfg map[string]interface{}, version string) {
+			log.Printf("Version '%s' of '%s' environment received", version, remote.env)
+			select {
+			case <-changes:
+			default:
+			}
+			changes <- cfg
+		})
+
+		go cl.Watch(ctx, func(err error) { log.Println("Error watching configuration updates:", err) })
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case err := <-child.done:
+			return childExitError(err)
+
+		case sig := <-signals:
+			_ = child.cmd.Process.Signal(sig)
+
+		case cfg := <-changes:
+			if *onChange == "signal" {
+				log.Printf("Sending %v to '%s'", reload, command[0])
+				_ = child.cmd.Process.Signal(reload)
+				continue
+			}
+
+			log.Printf("Restarting '%s' with the new configuration", command[0])
+			child.stop(stop, *killTimeout)
+
+			child, err = startChild(command, env(cfg))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// child is a running command, done receiving its exit once it exits
+type child struct {
+	cmd  *exec.Cmd
+	done chan error
+}
+
+func startChild(command []string, env []string) (*child, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("error starting '%s': %v", command[0], err)
+	}
+
+	c := &child{cmd: cmd, done: make(chan error, 1)}
+	go func() { c.done <- cmd.Wait() }()
+
+	return c, nil
+}
+
+// stop signals the child and waits for it to exit, killing it once the timeout is reached
+func (c *child) stop(sig os.Signal, timeout time.Duration) {
+	_ = c.cmd.Process.Signal(sig)
+
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		log.Printf("'%s' did not stop within %v, killing it", c.cmd.Path, timeout)
+		_ = c.cmd.Process.Kill()
+		<-c.done
+	}
+}
+
+// childExitError returns the exit code of the child as an error when it is not zero
+func childExitError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if code < 0 {
+			// killed by a signal
+			code = 1
+		}
+		return exitCodeError{code: code}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExecTestSuite struct {
+	suite.Suite
+}
+
+func TestExecTestSuite(t *testing.T) {
+	suite.Run(t, new(ExecTestSuite))
+}
+
+// run starts the shell script as a child and waits for its exit
+func (suite *ExecTestSuite) run(script string) error {
+	c, err := startChild([]string{"sh", "-c", script}, os.Environ())
+	suite.Require().NoError(err)
+	return <-c.done
+}
+
+func (suite *ExecTestSuite) TestParseSignal() {
+	testCases := []struct {
+		name           string
+		expectedSignal os.Signal
+		expectError    bool
+	}{
+		{"HUP", syscall.SIGHUP, false},
+		{"hup", syscall.SIGHUP, false},
+		{"SIGTERM", syscall.SIGTERM, false},
+		{"sigusr1", syscall.SIGUSR1, false},
+		{"INT", syscall.SIGINT, false},
+		{"KILL", nil, true},
+		{"", nil, true},
+		{"15", nil, true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			sig, err := parseSignal(tc.name)
+
+			if tc.expectError {
+				suite.Error(err)
+				return
+			}
+			suite.NoError(err)
+			suite.Equal(tc.expectedSignal, sig)
+		})
+	}
+}
+
+func (suite *ExecTestSuite) TestChildExitError() {
+	testCases := []struct {
+		name         string
+		script       string
+		expectedCode int
+	}{
+		{"success", "exit 0", 0},
+		{"failure", "exit 1", 1},
+		{"exit code forwarded", "exit 42", 42},
+		{"killed by a signal", "kill -KILL $$", 1},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			err := childExitError(suite.run(tc.script))
+
+			if tc.expectedCode == 0 {
+				suite.NoError(err)
+				return
+			}
+			suite.Equal(exitCodeError{code: tc.expectedCode}, err)
+		})
+	}
+}
+
+func (suite *ExecTestSuite) TestChildExitErrorNotAnExit() {
+	err := errors.New("error waiting for child")
+
+	suite.Equal(err, childExitError(err))
+}
+
+func (suite *ExecTestSuite) TestStartChildError() {
+	_, err := startChild([]string{"/nonexistent/command"}, nil)
+
+	suite.Error(err)
+}
+
+func (suite *ExecTestSuite) TestStopOnSignal() {
+	c, err := startChild([]string{"sh", "-c", "exec sleep 30"}, os.Environ())
+	suite.Require().NoError(err)
+
+	start := time.Now()
+	c.stop(syscall.SIGTERM, 10*time.Second)
+
+	suite.Less(time.Since(start), 5*time.Second, "a child exiting on the signal must not wait for the timeout")
+	suite.Equal(syscall.SIGTERM, c.cmd.ProcessState.Sys().(syscall.WaitStatus).Signal())
+}
+
+func (suite *ExecTestSuite) TestStopKillsAfterTimeout() {
+	c, err := startChild([]string{"sh", "-c", `trap "" TERM; while :; do sleep 0.1; done`}, os.Environ())
+	suite.Require().NoError(err)
+	// leaves the child time to ignore the signal before it is sent
+	time.Sleep(200 * time.Millisecond)
+
+	timeout := 300 * time.Millisecond
+	start := time.Now()
+	c.stop(syscall.SIGTERM, timeout)
+
+	suite.GreaterOrEqual(time.Since(start), timeout)
+	suite.Equal(syscall.SIGKILL, c.cmd.ProcessState.Sys().(syscall.WaitStatus).Signal())
+}
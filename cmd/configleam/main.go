@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -10,17 +11,37 @@ import (
 
 	"github.com/raw-leak/configleam/config"
 	"github.com/raw-leak/configleam/internal/app/access"
+	"github.com/raw-leak/configleam/internal/app/backup"
 	"github.com/raw-leak/configleam/internal/app/configuration"
+	cfgservice "github.com/raw-leak/configleam/internal/app/configuration/service"
 	"github.com/raw-leak/configleam/internal/app/dashboard"
+	"github.com/raw-leak/configleam/internal/app/kubesync"
 	"github.com/raw-leak/configleam/internal/app/notify"
+	"github.com/raw-leak/configleam/internal/app/reminder"
+	"github.com/raw-leak/configleam/internal/app/rollout"
+	"github.com/raw-leak/configleam/internal/app/rotation"
 	"github.com/raw-leak/configleam/internal/app/secrets"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
 	"github.com/raw-leak/configleam/internal/pkg/encryptor"
 	"github.com/raw-leak/configleam/internal/pkg/leaderelection"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/transport/grpcserver"
 	"github.com/raw-leak/configleam/internal/pkg/transport/httpserver"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			var exitErr exitCodeError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.code)
+			}
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatal(err.Error())
 	}
@@ -35,6 +56,11 @@ func run() error {
 		return err
 	}
 
+	err = cfg.StorageNamespace().Validate()
+	if err != nil {
+		return err
+	}
+
 	perms := permissions.New()
 	encryptor, err := encryptor.NewEncryptor("")
 	if err != nil {
@@ -56,18 +82,65 @@ func run() error {
 		return err
 	}
 
-	configurationSet, err := configuration.Init(ctx, cfg, secretsSet, notifySet)
+	kubeSyncSet, err := kubesync.Init(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	rolloutSet, err := rollout.Init(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	configurationSet, err := configuration.Init(ctx, cfg, secretsSet, cfgservice.Notifiers{notifySet, kubeSyncSet, rolloutSet})
 	if err != nil {
 		return err
 	}
 
-	dashboardSet, err := dashboard.Init(ctx, cfg, accessSet.AccessService, configurationSet.ConfigurationService)
+	err = accessSet.MigrateAccessKeys(ctx, append([]string{project.Default}, configurationSet.GetProjects(ctx)...))
+	if err != nil {
+		return err
+	}
+
+	rotationSet, err := rotation.Init(ctx, cfg, secretsSet, accessSet)
+	if err != nil {
+		return err
+	}
+
+	reminderSet, err := reminder.Init(ctx, cfg, secretsSet, notifySet)
+	if err != nil {
+		return err
+	}
+
+	backupSet, err := backup.Init(ctx, cfg, encryptor)
+	if err != nil {
+		return err
+	}
+
+	dashboardSet, err := dashboard.Init(ctx, cfg, accessSet.AccessService, configurationSet.ConfigurationService, secretsSet.SecretsService)
 	if err != nil {
 		return err
 	}
 
 	notifySet.RunLocal(ctx)
 
+	// the services below only run on the leader with leader election, they are run and shut down from here only
+	startLeading := func() {
+		configurationSet.Run(ctx)
+		kubeSyncSet.Run(ctx, configurationSet)
+		rolloutSet.Run(ctx, configurationSet)
+		rotationSet.Run(ctx, configurationSet)
+		reminderSet.Run(ctx, configurationSet)
+	}
+	stopLeading := func() {
+		configurationSet.Shutdown()
+		kubeSyncSet.Shutdown()
+		rolloutSet.Shutdown()
+		rotationSet.Shutdown()
+		reminderSet.Shutdown()
+	}
+
+	shutdownLeading := stopLeading
 	if bool(cfg.EnableLeaderElection) {
 		log.Println("Running with leader election")
 
@@ -83,32 +156,63 @@ func run() error {
 		elector, err := leaderelection.New(&leConfig, func() {
 			log.Println("Started leading, starting service...")
 			notifySet.RunGlobal(ctx)
-			configurationSet.Run(ctx)
+			startLeading()
 		}, func() {
 			log.Println("Stopped leading, shutting down service...")
 			notifySet.ShutdownGlobal()
-			configurationSet.Shutdown()
+			stopLeading()
 		})
 		if err != nil {
 			return err
 		}
 
-		go elector.Run(ctx)
+		electionCtx, cancelElection := context.WithCancel(ctx)
+		electionDone := make(chan struct{})
+		go func() {
+			defer close(electionDone)
+			elector.Run(electionCtx)
+		}()
+
+		// the elector stops leading once its context is canceled, whether it was leading or not
+		shutdownLeading = func() {
+			cancelElection()
+			<-electionDone
+		}
 	} else {
 		log.Println("Running without leader election")
-		configurationSet.Run(ctx)
+		startLeading()
 	}
 
-	httpServer := httpserver.NewHttpServer(configurationSet, secretsSet, accessSet, dashboardSet, notifySet, perms)
+	var certs auth.CertificateAuthorizer
+	if cfg.TlsClientPermissions != "" {
+		certPerms, err := access.LoadCertificatePermissions(cfg.TlsClientPermissions)
+		if err != nil {
+			return err
+		}
+		certs = certPerms
+	}
+
+	httpServer := httpserver.NewHttpServer(configurationSet, secretsSet, accessSet, dashboardSet, notifySet, backupSet, perms, certs)
 
 	errChan := make(chan error, 2)
 	go func(tls bool) {
-		if err := httpServer.ListenAndServe(cfg.Port, tls); err != nil && err != http.ErrServerClosed {
+		if err := httpServer.ListenAndServe(cfg.Port, tls, cfg.HttpTlsFiles()); err != nil && err != http.ErrServerClosed {
 			log.Println(err)
 			errChan <- err
 		}
 	}(bool(cfg.Tls))
 
+	// the gRPC server is only started when a port is configured
+	grpcServer := grpcserver.NewGrpcServer(configurationSet.ConfigurationService, notifySet.NotifyService, accessSet.AccessService, perms, certs)
+	if cfg.GrpcPort != "" {
+		go func(tls bool) {
+			if err := grpcServer.ListenAndServe(cfg.GrpcPort, tls, cfg.GrpcTlsFiles()); err != nil {
+				log.Println(err)
+				errChan <- err
+			}
+		}(bool(cfg.Tls))
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -116,14 +220,12 @@ func run() error {
 	case <-sigChan:
 		log.Println("Shutdown signal received")
 	case <-errChan:
-		log.Println("Received error from http server")
+		log.Println("Received error from server")
 	case <-ctx.Done():
 		log.Println("Context cancelled")
 	}
 
-	if !bool(cfg.EnableLeaderElection) {
-		configurationSet.Shutdown()
-	}
+	shutdownLeading()
 
 	notifySet.ShutdownLocal(ctx)
 
@@ -134,5 +236,12 @@ func run() error {
 
 	log.Println("HTTP server gracefully shutdown")
 
+	err = grpcServer.Shutdown(ctx)
+	if err != nil {
+		log.Fatal("gRPC server shutdown error:", err)
+	}
+
+	log.Println("gRPC server gracefully shutdown")
+
 	return nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/raw-leak/configleam/config"
+	"github.com/raw-leak/configleam/internal/app/configuration/service"
+	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	rds "github.com/raw-leak/configleam/internal/pkg/redis"
+)
+
+// runMigrateNamespace moves the keys stored under a previous namespace to the configured one,
+// including the keys of every project listed in the 'CG_PROJECTS_FILE'
+func runMigrateNamespace(args []string) error {
+	flags := flag.NewFlagSet("migrate-namespace", flag.ContinueOnError)
+	from := flags.String("from", namespace.Default, "namespace the existing keys are stored under")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Get()
+	if err != nil {
+		return err
+	}
+
+	source, target := namespace.Namespace(*from), cfg.StorageNamespace()
+	for _, ns := range []namespace.Namespace{source, target} {
+		if err := ns.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if source.Root() == target.Root() {
+		return errors.New("the source namespace is the same as the configured 'CG_NAMESPACE'")
+	}
+
+	projects := []string{project.Default}
+	if cfg.ProjectsFile != "" {
+		projectsCfg, err := service.LoadProjects(cfg.ProjectsFile)
+		if err != nil {
+			return err
+		}
+		for name := range projectsCfg {
+			projects = append(projects, name)
+		}
+	}
+
+	ctx := context.Background()
+	moved := 0
+
+	var renamePrefix func(ctx context.Context, from, to string) (int, error)
+
+	if cfg.RedisAddrs != "" {
+		redisCli, err := rds.New(ctx, rds.RedisConfig{
+			Addr:     cfg.RedisAddrs,
+			Password: cfg.RedisPassword,
+			Username: cfg.RedisUsername,
+			TLS:      bool(cfg.RedisTls),
+			TLSFiles: cfg.RedisTlsFiles(),
+		})
+		if err != nil {
+			return err
+		}
+		defer redisCli.Disconnect(ctx)
+
+		renamePrefix = redisCli.RenamePrefix
+	} else if len(cfg.EtcdAddrs) > 0 {
+		etcdCli, err := etcd.New(ctx, etcd.EtcdConfig{
+			EtcdAddrs:    cfg.EtcdAddrs,
+			EtcdUsername: cfg.EtcdUsername,
+			EtcdPassword: cfg.EtcdPassword,
+			TLS:          bool(cfg.EtcdTls),
+			TLSFiles:     cfg.EtcdTlsFiles(),
+		})
+		if err != nil {
+			return err
+		}
+		defer etcdCli.Disconnect(ctx)
+
+		renamePrefix = etcdCli.RenamePrefix
+	} else {
+		return errors.New("'REDIS_ADDRS' nor 'ETCD_ADDRS' has been provided")
+	}
+
+	for _, name := range projects {
+		n, err := renamePrefix(ctx, source.Project(name).Root(), target.Project(name).Root())
+		if err != nil {
+			return err
+		}
+		moved += n
+	}
+
+	log.Printf("Moved %d keys from '%s' namespace to '%s' namespace", moved, source.Root(), target.Root())
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/raw-leak/configleam/pkg/client"
+)
+
+// listFlag collects a flag that can be repeated or hold comma separated values
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// remoteFlags are the flags of the sub-commands reading the configuration from a running Configleam through its API,
+// the credentials defaulting to the CONFIGLEAM_URL, CONFIGLEAM_ACCESS_KEY and CONFIGLEAM_PROJECT variables
+type remoteFlags struct {
+	url          string
+	accessKey    string
+	project      string
+	env          string
+	groups       listFlag
+	globals      listFlag
+	selects      listFlag
+	fallbackFile string
+}
+
+// register registers the credentials and environment flags, along with the groups, globals and fields to read
+func (f *remoteFlags) register(flags *flag.FlagSet) {
+	f.registerEnv(flags)
+	flags.Var(&f.groups, "groups", "groups to read, repeated or comma separated")
+	flags.Var(&f.globals, "globals", "global keys to read, repeated or comma separated")
+	flags.Var(&f.selects, "select", "fields to read as dot paths or JSON Pointers, repeated or comma separated")
+}
+
+// registerEnv registers the credentials and environment flags only, for the sub-commands reading the whole environment
+func (f *remoteFlags) registerEnv(flags *flag.FlagSet) {
+	flags.StringVar(&f.url, "url", os.Getenv("CONFIGLEAM_URL"), "Configleam base URL (default: CONFIGLEAM_URL)")
+	flags.StringVar(&f.accessKey, "key", os.Getenv("CONFIGLEAM_ACCESS_KEY"), "access key (default: CONFIGLEAM_ACCESS_KEY)")
+	flags.StringVar(&f.project, "project", os.Getenv("CONFIGLEAM_PROJECT"), "project (default: CONFIGLEAM_PROJECT or the default project)")
+	flags.StringVar(&f.env, "env", "", "environment (required)")
+	flags.StringVar(&f.fallbackFile, "fallback-file", "", "file keeping the last configuration read, used while Configleam is unreachable")
+}
+
+// client creates the API client, reading the whole environment when wholeEnv is set
+func (f *remoteFlags) client(wholeEnv bool) (*client.Client, error) {
+	if f.url == "" || f.accessKey == "" || f.env == "" {
+		return nil, fmt.Errorf("-url, -key and -env are required")
+	}
+
+	return client.New(client.Options{
+		URL:          f.url,
+		AccessKey:    f.accessKey,
+		Project:      f.project,
+		Env:          f.env,
+		Groups:       f.groups,
+		Globals:      f.globals,
+		Select:       f.selects,
+		FallbackFile: f.fallbackFile,
+		WholeEnv:     wholeEnv,
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/raw-leak/configleam/pkg/client"
+)
+
+// envPermissionsFlag collects the permissions of an access key per environment,
+// e.g. "-env develop=readConfig,revealSecrets", repeated for every environment
+type envPermissionsFlag map[string]client.EnvPermissions
+
+func (f envPermissionsFlag) String() string {
+	return ""
+}
+
+func (f envPermissionsFlag) Set(value string) error {
+	env, ops, ok := strings.Cut(value, "=")
+	if !ok || env == "" || ops == "" {
+		return fmt.Errorf("expected <env>=<permission>[,<permission>...], got '%s'", value)
+	}
+
+	perms := f[env]
+	for _, op := range strings.Split(ops, ",") {
+		switch strings.TrimSpace(op) {
+		case "envAdminAccess":
+			perms.EnvAdminAccess = true
+		case "readConfig":
+			perms.ReadConfig = true
+		case "revealSecrets":
+			perms.RevealSecrets = true
+		case "cloneEnvironment":
+			perms.CloneEnvironment = true
+		case "createSecrets":
+			perms.CreateSecrets = true
+		case "accessDashboard":
+			perms.AccessDashboard = true
+		default:
+			return fmt.Errorf("unknown permission '%s', available permissions: envAdminAccess, readConfig, revealSecrets, cloneEnvironment, createSecrets, accessDashboard", op)
+		}
+	}
+	f[env] = perms
+
+	return nil
+}
+
+func (c *command) createAccessKey(args []string) error {
+	flags := flag.NewFlagSet("access create", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the access key")
+	admin := flags.Bool("admin", false, "grant global admin permissions")
+	expires := flags.Duration("expires", 0, "validity of the access key, e.g. 720h (default: no expiration)")
+	envs := envPermissionsFlag{}
+	flags.Var(envs, "env", "permissions on an environment as <env>=<permission>[,<permission>...], repeated for every environment")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*admin && len(envs) == 0 {
+		return fmt.Errorf("grant -admin or at least one -env permission")
+	}
+
+	req := client.AccessKeyRequest{Name: *name, GlobalAdmin: *admin, Envs: envs}
+	if *expires > 0 {
+		req.ExpDate = time.Now().Add(*expires).UTC()
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	created, err := cl.CreateAccessKey(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "The access key is only shown once, store it safely:")
+	fmt.Println(created.AccessKey)
+	return nil
+}
+
+func (c *command) listAccessKeys(args []string) error {
+	flags := flag.NewFlagSet("access list", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	keys, err := allAccessKeys(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	if *output == "json" {
+		return printJSON(keys)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKEY\tCREATED\tEXPIRES")
+	for _, key := range keys {
+		expires := "never"
+		if !key.ExpirationDate.IsZero() {
+			expires = key.ExpirationDate.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", valueOr(key.Name, "-"), key.MaskedKey, key.CreationDate.Format(time.RFC3339), expires)
+	}
+	return w.Flush()
+}
+
+func (c *command) revokeAccessKeys(args []string) error {
+	flags := flag.NewFlagSet("access revoke", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: configleamctl access revoke <id|name|masked key>...")
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	existing, err := allAccessKeys(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	keys, err := matchAccessKeys(existing, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	err = cl.RevokeAccessKeys(ctx, keys...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d access keys revoked\n", len(keys))
+	return nil
+}
+
+// matchAccessKeys returns the ids of the keys given by their id as listed with '-o json', by name or by their
+// masked key as listed, names and masked keys having to match a single key
+func matchAccessKeys(existing []client.AccessKeyMetadata, args []string) ([]string, error) {
+	keys := []string{}
+	for _, arg := range args {
+		matches := []string{}
+		for _, key := range existing {
+			// an empty argument would match every unnamed key
+			if arg != "" && (key.Key == arg || key.Name == arg || key.MaskedKey == arg) {
+				matches = append(matches, key.Key)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no access key matches '%s'", arg)
+		case 1:
+			keys = append(keys, matches[0])
+		default:
+			return nil, fmt.Errorf("%d access keys match '%s', revoke them by id", len(matches), arg)
+		}
+	}
+	return keys, nil
+}
+
+// allAccessKeys reads every page of the access keys of the project
+func allAccessKeys(ctx context.Context, cl *client.Client) ([]client.AccessKeyMetadata, error) {
+	keys := []client.AccessKeyMetadata{}
+	for page := 1; ; page++ {
+		res, err := cl.ListAccessKeys(ctx, page, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, res.Items...)
+		if page >= res.Pages {
+			return keys, nil
+		}
+	}
+}
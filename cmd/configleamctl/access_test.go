@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/raw-leak/configleam/pkg/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccessTestSuite struct {
+	suite.Suite
+	existing []client.AccessKeyMetadata
+}
+
+func (suite *AccessTestSuite) SetupTest() {
+	suite.existing = []client.AccessKeyMetadata{
+		{Key: "id-ci", Name: "ci", MaskedKey: "cfgl_abc****1234"},
+		{Key: "id-deploy-1", Name: "deploy", MaskedKey: "cfgl_def****5678"},
+		{Key: "id-deploy-2", Name: "deploy", MaskedKey: "cfgl_ghi****9012"},
+		{Key: "id-unnamed", MaskedKey: "cfgl_jkl****3456"},
+	}
+}
+
+func TestAccessTestSuite(t *testing.T) {
+	suite.Run(t, new(AccessTestSuite))
+}
+
+func (suite *AccessTestSuite) TestMatchAccessKeys() {
+	testCases := []struct {
+		name         string
+		args         []string
+		expectedKeys []string
+		expectError  string
+	}{
+		{"by id", []string{"id-ci"}, []string{"id-ci"}, ""},
+		{"by name", []string{"ci"}, []string{"id-ci"}, ""},
+		{"by masked key", []string{"cfgl_jkl****3456"}, []string{"id-unnamed"}, ""},
+		{"several keys", []string{"ci", "id-deploy-2", "cfgl_def****5678"}, []string{"id-ci", "id-deploy-2", "id-deploy-1"}, ""},
+		{"name of several keys", []string{"deploy"}, nil, "2 access keys match 'deploy'"},
+		{"unknown key", []string{"ci", "staging"}, nil, "no access key matches 'staging'"},
+		{"empty name never matches", []string{""}, nil, ""},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			keys, err := matchAccessKeys(suite.existing, tc.args)
+
+			if tc.expectedKeys == nil {
+				suite.Error(err)
+				if tc.expectError != "" {
+					suite.ErrorContains(err, tc.expectError)
+				}
+				return
+			}
+			suite.NoError(err)
+			suite.Equal(tc.expectedKeys, keys)
+		})
+	}
+}
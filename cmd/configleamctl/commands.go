@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/raw-leak/configleam/pkg/client"
+)
+
+// listFlag collects a flag that can be repeated or hold comma separated values
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// configFlags are the flags selecting the configuration to read
+type configFlags struct {
+	env     string
+	groups  listFlag
+	globals listFlag
+	selects listFlag
+	secrets string
+}
+
+func (f *configFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.env, "env", "", "environment (required)")
+	flags.Var(&f.groups, "groups", "groups to read, repeated or comma separated")
+	flags.Var(&f.globals, "globals", "global keys to read, repeated or comma separated")
+	flags.Var(&f.selects, "select", "fields to read as dot paths or JSON Pointers, repeated or comma separated")
+	flags.StringVar(&f.secrets, "secrets", "", "secrets mode: lenient, strict or masked, the one of the access key by default")
+}
+
+func (f *configFlags) options() (client.Options, error) {
+	if f.env == "" {
+		return client.Options{}, fmt.Errorf("-env is required")
+	}
+	return client.Options{Env: f.env, Groups: f.groups, Globals: f.globals, Select: f.selects, SecretMode: f.secrets}, nil
+}
+
+// signalContext is cancelled on SIGINT and SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// outputFlag registers the -o flag of the commands printing tables
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("o", "table", "output format: table or json")
+}
+
+func (c *command) get(args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	var cfg configFlags
+	cfg.register(flags)
+	format := flags.String("format", "json", "output format: json, yaml, dotenv, properties or toml")
+	version := flags.String("version", "", "version kept in the history to read (default: the applied one)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := cfg.options()
+	if err != nil {
+		return err
+	}
+	cl, err := c.client(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	body, err := cl.Format(ctx, *format, *version)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(body)
+	return err
+}
+
+func (c *command) watch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	var cfg configFlags
+	cfg.register(flags)
+	printConfig := flags.Bool("print", false, "print the whole configuration as JSON on every update")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := cfg.options()
+	if err != nil {
+		return err
+	}
+	cl, err := c.client(opts)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tENV\tVERSION")
+	w.Flush()
+
+	cl.OnChange(func(config map[string]interface{}, version string) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), cfg.env, version)
+		w.Flush()
+		if *printConfig {
+			printJSON(config)
+		}
+	})
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = cl.Watch(ctx, func(err error) { fmt.Fprintln(os.Stderr, "Error:", err) })
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (c *command) envs(args []string) error {
+	flags := flag.NewFlagSet("envs", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	envs, err := cl.ListEnvs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *output == "json" {
+		return printJSON(envs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tCLONE OF")
+	for _, env := range envs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", env.Name, env.Version, valueOr(env.Original, "-"))
+	}
+	return w.Flush()
+}
+
+func (c *command) clone(args []string) error {
+	flags := flag.NewFlagSet("clone", flag.ContinueOnError)
+	env := flags.String("env", "", "environment to clone (required)")
+	newEnv := flags.String("new", "", "name of the new environment (required)")
+	globalsFile := flags.String("f", "", "JSON or YAML file with the global keys to override, '-' for stdin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *env == "" || *newEnv == "" {
+		return fmt.Errorf("-env and -new are required")
+	}
+
+	globals := map[string]interface{}{}
+	if *globalsFile != "" {
+		var err error
+		globals, err = readValues(*globalsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	cl, err := c.client(client.Options{Env: *env})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = cl.Clone(ctx, *newEnv, globals)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Environment '%s' cloned into '%s'\n", *env, *newEnv)
+	return nil
+}
+
+func (c *command) delete(args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	env := flags.String("env", "", "cloned environment to delete (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *env == "" {
+		return fmt.Errorf("-env is required")
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = cl.DeleteClone(ctx, *env)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Environment '%s' deleted\n", *env)
+	return nil
+}
+
+func (c *command) setSecrets(args []string) error {
+	flags := flag.NewFlagSet("secrets set", flag.ContinueOnError)
+	env := flags.String("env", "", "environment (required)")
+	file := flags.String("f", "-", "JSON or YAML file with the secrets, '-' for stdin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *env == "" {
+		return fmt.Errorf("-env is required")
+	}
+
+	secrets, err := readValues(*file)
+	if err != nil {
+		return err
+	}
+
+	cl, err := c.client(client.Options{Env: *env})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = cl.UpsertSecrets(ctx, secrets)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d secrets set in environment '%s'\n", len(secrets), *env)
+	return nil
+}
+
+// readValues reads a JSON or YAML document of values from a file or, for '-', from stdin
+func readValues(path string) (map[string]interface{}, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading '%s': %v", path, err)
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding '%s', expected a JSON or YAML object: %v", path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,140 @@
+// Command configleamctl operates a Configleam installation through its HTTP API:
+// reading and watching configuration, cloning environments, setting secrets,
+// managing access keys and synchronizing the git repositories.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/raw-leak/configleam/pkg/client"
+)
+
+const usage = `Usage: configleamctl [-profile name] [-url url] [-key access-key] [-project name] <command> [flags]
+
+Commands:
+  get            print the configuration of an environment in any format
+  watch          print the configuration updates of an environment
+  envs           list the environments the access key can read
+  clone          clone an environment
+  delete         delete a cloned environment
+  secrets set    set the secrets of an environment from a file or stdin
+  access create  create an access key
+  access list    list the access keys of the project
+  access revoke  revoke access keys by id, name or masked key
+  sync           synchronize the project with its git repository
+  sync status    show the last synchronization of the project
+  profile        manage the profiles holding the url, access key and project
+
+Credentials are read from the profile, selected with -profile, CONFIGLEAM_PROFILE or the current one,
+and are overridden by the CONFIGLEAM_URL, CONFIGLEAM_ACCESS_KEY and CONFIGLEAM_PROJECT variables and the flags.
+Run 'configleamctl <command> -h' for the flags of a command.
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	profileName, overrides, args, err := parseGlobalFlags(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+
+	if args[0] == "profile" {
+		return runProfile(args[1:])
+	}
+
+	profile, err := resolveProfile(profileName, overrides)
+	if err != nil {
+		return err
+	}
+
+	cmd := &command{profile: profile}
+
+	switch args[0] {
+	case "get":
+		return cmd.get(args[1:])
+	case "watch":
+		return cmd.watch(args[1:])
+	case "envs":
+		return cmd.envs(args[1:])
+	case "clone":
+		return cmd.clone(args[1:])
+	case "delete":
+		return cmd.delete(args[1:])
+	case "secrets":
+		return subcommand("secrets", args[1:], map[string]func([]string) error{"set": cmd.setSecrets})
+	case "access":
+		return subcommand("access", args[1:], map[string]func([]string) error{
+			"create": cmd.createAccessKey,
+			"list":   cmd.listAccessKeys,
+			"revoke": cmd.revokeAccessKeys,
+		})
+	case "sync":
+		if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+			return subcommand("sync", args[1:], map[string]func([]string) error{"status": cmd.syncStatus})
+		}
+		return cmd.sync(args[1:])
+	default:
+		return fmt.Errorf("unknown command '%s', run 'configleamctl -h' for the available commands", args[0])
+	}
+}
+
+// parseGlobalFlags parses the flags preceding the command, the variables being the defaults of the flags,
+// and returns the selected profile, the credentials overriding it and the command with its arguments
+func parseGlobalFlags(args []string) (string, Profile, []string, error) {
+	flags := flag.NewFlagSet("configleamctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+
+	var overrides Profile
+	profileName := flags.String("profile", os.Getenv("CONFIGLEAM_PROFILE"), "profile to use (default: the current one)")
+	flags.StringVar(&overrides.URL, "url", os.Getenv("CONFIGLEAM_URL"), "Configleam base URL")
+	flags.StringVar(&overrides.AccessKey, "key", os.Getenv("CONFIGLEAM_ACCESS_KEY"), "access key")
+	flags.StringVar(&overrides.Project, "project", os.Getenv("CONFIGLEAM_PROJECT"), "project (default: the default project)")
+	if err := flags.Parse(args); err != nil {
+		return "", Profile{}, nil, err
+	}
+
+	return *profileName, overrides, flags.Args(), nil
+}
+
+// subcommand runs the sub-command named by the first argument
+func subcommand(name string, args []string, commands map[string]func([]string) error) error {
+	if len(args) > 0 {
+		if fn, ok := commands[args[0]]; ok {
+			return fn(args[1:])
+		}
+	}
+
+	names := make([]string, 0, len(commands))
+	for sub := range commands {
+		names = append(names, sub)
+	}
+	return fmt.Errorf("'%s' expects one of the sub-commands: %s", name, strings.Join(sortedStrings(names), ", "))
+}
+
+// command holds the resolved credentials every command creates its client with
+type command struct {
+	profile Profile
+}
+
+func (c *command) client(opts client.Options) (*client.Client, error) {
+	opts.URL = c.profile.URL
+	opts.AccessKey = c.profile.AccessKey
+	opts.Project = c.profile.Project
+	return client.New(opts)
+}
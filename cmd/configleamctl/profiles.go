@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+// Profile holds the credentials of a Configleam installation
+type Profile struct {
+	URL       string `json:"url"`
+	AccessKey string `json:"accessKey"`
+	Project   string `json:"project,omitempty"`
+}
+
+// profilesFile is the file the profiles are kept in, only readable by its owner as it holds access keys
+type profilesFile struct {
+	Current  string             `json:"current"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// profilesPath is CONFIGLEAM_CONFIG or 'configleam/profiles.json' within the user configuration directory
+func profilesPath() (string, error) {
+	if path := os.Getenv("CONFIGLEAM_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding the user configuration directory: %v", err)
+	}
+	return filepath.Join(dir, "configleam", "profiles.json"), nil
+}
+
+func loadProfiles() (*profilesFile, string, error) {
+	path, err := profilesPath()
+	if err != nil {
+		return nil, "", err
+	}
+
+	file := &profilesFile{Profiles: map[string]Profile{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return file, path, nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading profiles file '%s': %v", path, err)
+	}
+
+	err = json.Unmarshal(data, file)
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding profiles file '%s': %v", path, err)
+	}
+	if file.Profiles == nil {
+		file.Profiles = map[string]Profile{}
+	}
+
+	return file, path, nil
+}
+
+func (f *profilesFile) save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding profiles: %v", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("error creating profiles directory: %v", err)
+	}
+
+	err = os.WriteFile(path, append(data, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("error writing profiles file '%s': %v", path, err)
+	}
+	return nil
+}
+
+// resolveProfile returns the named profile, or the current one, with the non empty overrides applied
+func resolveProfile(name string, overrides Profile) (Profile, error) {
+	file, path, err := loadProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if name == "" {
+		name = file.Current
+	}
+
+	var profile Profile
+	if name != "" {
+		var ok bool
+		profile, ok = file.Profiles[name]
+		if !ok {
+			return Profile{}, fmt.Errorf("profile '%s' not found in '%s'", name, path)
+		}
+	}
+
+	if overrides.URL != "" {
+		profile.URL = overrides.URL
+	}
+	if overrides.AccessKey != "" {
+		profile.AccessKey = overrides.AccessKey
+	}
+	if overrides.Project != "" {
+		profile.Project = overrides.Project
+	}
+
+	if profile.URL == "" || profile.AccessKey == "" {
+		return Profile{}, errors.New("no url or access key, create a profile with 'configleamctl profile set' or use -url and -key")
+	}
+
+	return profile, nil
+}
+
+// runProfile manages the profiles: set, use, list and delete
+func runProfile(args []string) error {
+	return subcommand("profile", args, map[string]func([]string) error{
+		"set":    setProfile,
+		"use":    useProfile,
+		"list":   listProfiles,
+		"delete": deleteProfile,
+	})
+}
+
+func setProfile(args []string) error {
+	flags := flag.NewFlagSet("profile set", flag.ContinueOnError)
+	url := flags.String("url", "", "Configleam base URL")
+	key := flags.String("key", "", "access key")
+	project := flags.String("project", "", "project (default: the default project)")
+	name, err := parseNamed(flags, args, "profile set <name> [-url url] [-key access-key] [-project name]")
+	if err != nil {
+		return err
+	}
+
+	file, path, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	profile := file.Profiles[name]
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			profile.URL = *url
+		case "key":
+			profile.AccessKey = *key
+		case "project":
+			profile.Project = *project
+		}
+	})
+	file.Profiles[name] = profile
+
+	// the first profile becomes the current one
+	if file.Current == "" {
+		file.Current = name
+	}
+
+	return file.save(path)
+}
+
+func useProfile(args []string) error {
+	name, err := parseNamed(flag.NewFlagSet("profile use", flag.ContinueOnError), args, "profile use <name>")
+	if err != nil {
+		return err
+	}
+
+	file, path, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Profiles[name]; !ok {
+		return fmt.Errorf("profile '%s' not found in '%s'", name, path)
+	}
+
+	file.Current = name
+	return file.save(path)
+}
+
+func deleteProfile(args []string) error {
+	name, err := parseNamed(flag.NewFlagSet("profile delete", flag.ContinueOnError), args, "profile delete <name>")
+	if err != nil {
+		return err
+	}
+
+	file, path, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	delete(file.Profiles, name)
+	if file.Current == name {
+		file.Current = ""
+	}
+	return file.save(path)
+}
+
+func listProfiles(args []string) error {
+	flags := flag.NewFlagSet("profile list", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	file, _, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tURL\tPROJECT\tACCESS KEY")
+	for _, name := range sortedStrings(names) {
+		profile := file.Profiles[name]
+		current := ""
+		if name == file.Current {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", current, name, profile.URL, valueOr(profile.Project, "-"), maskKey(profile.AccessKey))
+	}
+	return w.Flush()
+}
+
+// parseNamed parses the flags of a command taking a single name argument, before or after its flags
+func parseNamed(flags *flag.FlagSet, args []string, usage string) (string, error) {
+	name := ""
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+	if name == "" && flags.NArg() == 1 {
+		name = flags.Arg(0)
+	}
+	if name == "" {
+		return "", fmt.Errorf("usage: configleamctl %s", usage)
+	}
+	return name, nil
+}
+
+func sortedStrings(values []string) []string {
+	sort.Strings(values)
+	return values
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// maskKey keeps the first and last characters of an access key, as the dashboard does
+func maskKey(key string) string {
+	if len(key) < 12 {
+		return "****"
+	}
+	return key[:8] + "****" + key[len(key)-4:]
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProfilesTestSuite struct {
+	suite.Suite
+	path string
+}
+
+func (suite *ProfilesTestSuite) SetupTest() {
+	suite.path = filepath.Join(suite.T().TempDir(), "profiles.json")
+	suite.T().Setenv("CONFIGLEAM_CONFIG", suite.path)
+	for _, name := range []string{"CONFIGLEAM_PROFILE", "CONFIGLEAM_URL", "CONFIGLEAM_ACCESS_KEY", "CONFIGLEAM_PROJECT"} {
+		suite.T().Setenv(name, "")
+	}
+
+	file := &profilesFile{
+		Current: "staging",
+		Profiles: map[string]Profile{
+			"staging":    {URL: "https://staging.configleam.internal", AccessKey: "staging-key", Project: "payments"},
+			"production": {URL: "https://configleam.internal", AccessKey: "production-key"},
+		},
+	}
+	suite.Require().NoError(file.save(suite.path))
+}
+
+func TestProfilesTestSuite(t *testing.T) {
+	suite.Run(t, new(ProfilesTestSuite))
+}
+
+// resolve resolves the profile as run does, from the global flags and the variables
+func (suite *ProfilesTestSuite) resolve(args ...string) (Profile, error) {
+	name, overrides, _, err := parseGlobalFlags(append(args, "envs"))
+	suite.Require().NoError(err)
+	return resolveProfile(name, overrides)
+}
+
+func (suite *ProfilesTestSuite) TestCurrentProfile() {
+	profile, err := suite.resolve()
+
+	suite.NoError(err)
+	suite.Equal(Profile{URL: "https://staging.configleam.internal", AccessKey: "staging-key", Project: "payments"}, profile)
+}
+
+func (suite *ProfilesTestSuite) TestSelectedProfile() {
+	profile, err := suite.resolve("-profile", "production")
+	suite.NoError(err)
+	suite.Equal("production-key", profile.AccessKey)
+
+	suite.T().Setenv("CONFIGLEAM_PROFILE", "production")
+	profile, err = suite.resolve()
+	suite.NoError(err)
+	suite.Equal("production-key", profile.AccessKey)
+
+	profile, err = suite.resolve("-profile", "staging")
+	suite.NoError(err)
+	suite.Equal("staging-key", profile.AccessKey, "the flag must select the profile over the variable")
+}
+
+func (suite *ProfilesTestSuite) TestUnknownProfile() {
+	_, err := suite.resolve("-profile", "development")
+
+	suite.ErrorContains(err, "profile 'development' not found")
+}
+
+func (suite *ProfilesTestSuite) TestVariablesOverrideProfile() {
+	suite.T().Setenv("CONFIGLEAM_URL", "https://env.configleam.internal")
+	suite.T().Setenv("CONFIGLEAM_PROJECT", "billing")
+
+	profile, err := suite.resolve()
+
+	suite.NoError(err)
+	suite.Equal(Profile{URL: "https://env.configleam.internal", AccessKey: "staging-key", Project: "billing"}, profile)
+}
+
+func (suite *ProfilesTestSuite) TestFlagsOverrideVariablesAndProfile() {
+	suite.T().Setenv("CONFIGLEAM_URL", "https://env.configleam.internal")
+	suite.T().Setenv("CONFIGLEAM_ACCESS_KEY", "env-key")
+
+	profile, err := suite.resolve("-url", "https://flag.configleam.internal", "-project", "orders")
+
+	suite.NoError(err)
+	suite.Equal(Profile{URL: "https://flag.configleam.internal", AccessKey: "env-key", Project: "orders"}, profile)
+}
+
+func (suite *ProfilesTestSuite) TestWithoutProfilesFile() {
+	suite.T().Setenv("CONFIGLEAM_CONFIG", filepath.Join(suite.T().TempDir(), "missing.json"))
+
+	_, err := suite.resolve()
+	suite.Error(err, "no url or access key must be an error")
+
+	profile, err := suite.resolve("-url", "https://configleam.internal", "-key", "flag-key")
+	suite.NoError(err)
+	suite.Equal(Profile{URL: "https://configleam.internal", AccessKey: "flag-key"}, profile)
+}
+
+func (suite *ProfilesTestSuite) TestProfilesFileOnlyReadableByOwner() {
+	info, err := os.Stat(suite.path)
+
+	suite.NoError(err)
+	suite.Equal(os.FileMode(0600), info.Mode().Perm())
+}
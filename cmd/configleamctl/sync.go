@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/raw-leak/configleam/pkg/client"
+)
+
+func (c *command) sync(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	status, err := cl.Sync(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printSyncStatus(status, *output)
+}
+
+func (c *command) syncStatus(args []string) error {
+	flags := flag.NewFlagSet("sync status", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cl, err := c.client(client.Options{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	status, err := cl.SyncStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printSyncStatus(status, *output)
+}
+
+func printSyncStatus(status client.SyncStatus, output string) error {
+	if output == "json" {
+		return printJSON(status)
+	}
+
+	lastSync := "never"
+	if status.LastSync != nil {
+		lastSync = status.LastSync.Format(time.RFC3339)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "ACTIVE\t%t\n", status.Active)
+	fmt.Fprintf(w, "LAST SYNC\t%s\n", lastSync)
+	fmt.Fprintf(w, "LAST ERROR\t%s\n", valueOr(status.LastError, "-"))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(status.Envs) == 0 {
+		return nil
+	}
+
+	envs := make([]string, 0, len(status.Envs))
+	for env := range status.Envs {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	fmt.Println()
+	fmt.Fprintln(w, "ENV\tVERSION")
+	for _, env := range envs {
+		fmt.Fprintf(w, "%s\t%s\n", env, status.Envs[env])
+	}
+	return w.Flush()
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 )
 
 type Bool bool
@@ -22,25 +24,83 @@ func (b *Bool) UnmarshalText(text []byte) error {
 
 type Config struct {
 	Port         string        `envconfig:"PORT"`
+	GrpcPort     string        `envconfig:"CG_GRPC_PORT"`
 	Hostname     string        `envconfig:"HOSTNAME"`
 	Tls          Bool          `envconfig:"TLS" default:"true"`
 	PullInterval time.Duration `envconfig:"CG_PULL_INTERVAL"`
+	HistorySize  int           `envconfig:"CG_CONFIG_HISTORY_SIZE"`
+
+	// versions kept of each secret
+	SecretHistorySize int `envconfig:"CG_SECRET_HISTORY_SIZE"`
+
+	// how often the secrets and access keys are re-encrypted with the primary master key
+	ReencryptInterval time.Duration `envconfig:"CG_REENCRYPT_INTERVAL"`
+
+	// how often the secrets expiring or due for rotation are looked for, and how long ahead they are notified
+	SecretReminderInterval time.Duration `envconfig:"CG_SECRET_REMINDER_INTERVAL"`
+	SecretExpiryNotice     time.Duration `envconfig:"CG_SECRET_EXPIRY_NOTICE"`
+
+	// external secret providers of the placeholders, each one enabled by its own settings
+	SecretProviderTimeout  time.Duration `envconfig:"CG_SECRET_PROVIDER_TIMEOUT"`
+	SecretProviderCacheTTL time.Duration `envconfig:"CG_SECRET_PROVIDER_CACHE_TTL"`
+	// '[<project>/]<env>=<directory>' rules of the secret files each env may read
+	SecretFileDirs []string `envconfig:"CG_SECRET_FILE_DIRS" delim:","`
+	// '[<project>/]<env>=<pattern>' rules of the environment variables each env may read
+	SecretEnvAllowed []string `envconfig:"CG_SECRET_ENV_ALLOWED" delim:","`
+
+	EnableK8sSecrets Bool `envconfig:"K8S_ENABLE_SECRET_PROVIDER"`
+	// '[<project>/]<env>=<namespace>/<name>' rules of the kubernetes secrets each env may read
+	K8sSecretsAllowed []string `envconfig:"K8S_SECRET_PROVIDER_ALLOWED" delim:","`
+
+	VaultAddr      string `envconfig:"VAULT_ADDR"`
+	VaultToken     string `envconfig:"VAULT_TOKEN"`
+	VaultNamespace string `envconfig:"VAULT_NAMESPACE"`
+	// '[<project>/]<env>=<path>' rules of the vault paths each env may read
+	VaultAllowedPaths []string `envconfig:"VAULT_ALLOWED_PATHS" delim:","`
+
+	// age identities decrypting the encrypted secrets of the configuration repositories, inline and from a key file
+	AgeKey     string `envconfig:"CG_AGE_KEY"`
+	AgeKeyFile string `envconfig:"CG_AGE_KEY_FILE"`
+
+	// root of every storage key, allows several installations to share one Redis/etcd
+	Namespace string `envconfig:"CG_NAMESPACE" default:"configleam"`
+
+	// http server tls
+	TlsCertFile          string `envconfig:"TLS_CERT_FILE" default:"certs/cert.pem"`
+	TlsKeyFile           string `envconfig:"TLS_KEY_FILE" default:"certs/key.pem"`
+	TlsClientCAFile      string `envconfig:"TLS_CLIENT_CA_FILE"`
+	TlsClientPermissions string `envconfig:"TLS_CLIENT_PERMISSIONS_FILE"`
 
 	RedisAddrs    string `envconfig:"REDIS_ADDRS"`
 	RedisUsername string `envconfig:"REDIS_USERNAME"`
 	RedisPassword string `envconfig:"REDIS_PASSWORD"`
 	RedisTls      Bool   `envconfig:"REDIS_TLS"`
 
+	RedisTlsCertFile string `envconfig:"REDIS_TLS_CERT_FILE" default:"certs/redis-cert.pem"`
+	RedisTlsKeyFile  string `envconfig:"REDIS_TLS_KEY_FILE" default:"certs/redis-key.pem"`
+	RedisTlsCAFile   string `envconfig:"REDIS_TLS_CA_FILE"`
+	// RedisTlsServerName is the name the Redis certificate is verified against, the host of REDIS_ADDRS by default
+	RedisTlsServerName string `envconfig:"REDIS_TLS_SERVER_NAME"`
+
 	EtcdAddrs    []string `envconfig:"ETCD_ADDRS" delim:","`
 	EtcdUsername string   `envconfig:"ETCD_USERNAME"`
 	EtcdPassword string   `envconfig:"ETCD_PASSWORD"`
 	EtcdTls      Bool     `envconfig:"ETCD_TLS"`
 
+	EtcdTlsCertFile string `envconfig:"ETCD_TLS_CERT_FILE" default:"certs/etcd-cert.pem"`
+	EtcdTlsKeyFile  string `envconfig:"ETCD_TLS_KEY_FILE" default:"certs/etcd-key.pem"`
+	EtcdTlsCAFile   string `envconfig:"ETCD_TLS_CA_FILE"`
+	// EtcdTlsServerName is the name the etcd certificates are verified against, the hosts of ETCD_ADDRS by default
+	EtcdTlsServerName string `envconfig:"ETCD_TLS_SERVER_NAME"`
+
 	// cfg repo
 	RepoUrl    string   `envconfig:"GIT_REPOSITORY_URL"`
 	RepoEnvs   []string `envconfig:"GIT_REPOSITORY_ENVS" delim:","`
 	RepoBranch string   `envconfig:"GIT_REPOSITORY_BRANCH" default:"main"`
 
+	// projects with their own repositories, envs, secrets and access keys
+	ProjectsFile string `envconfig:"CG_PROJECTS_FILE"`
+
 	// k8s
 	LeaseLockName      string        `envconfig:"K8S_LEASE_LOCK_NAME" default:"configleam-lock"`
 	LeaseLockNamespace string        `envconfig:"K8S_LEASE_LOCK_NAMESPACE" default:"default"`
@@ -49,6 +109,14 @@ type Config struct {
 	RetryPeriod        time.Duration `envconfig:"K8S_RETRY_PERIOD"`
 
 	EnableLeaderElection Bool `envconfig:"K8S_ENABLE_LEADER_ELECTION"`
+
+	// mappings of envs into ConfigMaps and Secrets, synced by the leader
+	KubeSyncFile     string        `envconfig:"K8S_SYNC_FILE"`
+	KubeSyncInterval time.Duration `envconfig:"K8S_SYNC_INTERVAL"`
+
+	// rollout of the opted-in workloads of the namespaces, all of them when empty
+	EnableRollout     Bool     `envconfig:"K8S_ENABLE_ROLLOUT"`
+	RolloutNamespaces []string `envconfig:"K8S_ROLLOUT_NAMESPACES" delim:","`
 }
 
 var (
@@ -73,3 +141,28 @@ func Get() (*Config, error) {
 
 	return &config, nil
 }
+
+// RedisTlsFiles returns the TLS material configured for Redis clients
+func (c *Config) RedisTlsFiles() tlsconfig.Files {
+	return tlsconfig.Files{CertFile: c.RedisTlsCertFile, KeyFile: c.RedisTlsKeyFile, CAFile: c.RedisTlsCAFile, ServerName: c.RedisTlsServerName}
+}
+
+// EtcdTlsFiles returns the TLS material configured for etcd clients
+func (c *Config) EtcdTlsFiles() tlsconfig.Files {
+	return tlsconfig.Files{CertFile: c.EtcdTlsCertFile, KeyFile: c.EtcdTlsKeyFile, CAFile: c.EtcdTlsCAFile, ServerName: c.EtcdTlsServerName}
+}
+
+// StorageNamespace returns the namespace all the storage keys are kept under
+func (c *Config) StorageNamespace() namespace.Namespace {
+	return namespace.Namespace(c.Namespace)
+}
+
+// HttpTlsFiles returns the TLS material configured for the HTTP server
+func (c *Config) HttpTlsFiles() tlsconfig.Files {
+	return tlsconfig.Files{CertFile: c.TlsCertFile, KeyFile: c.TlsKeyFile, CAFile: c.TlsClientCAFile}
+}
+
+// GrpcTlsFiles returns the TLS material configured for the gRPC server, the same one as the HTTP server
+func (c *Config) GrpcTlsFiles() tlsconfig.Files {
+	return c.HttpTlsFiles()
+}
@@ -0,0 +1,37 @@
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/raw-leak/configleam/internal/app/access/dto"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+)
+
+// LoadCertificatePermissions reads the subject to permissions mapping from a JSON file, where every
+// entry has the same format as the body of the 'POST /access' endpoint.
+func LoadCertificatePermissions(path string) (*auth.CertificatePermissions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate permissions file '%s': %v", path, err)
+	}
+
+	subjects := map[string]dto.AccessKeyPermissionsDto{}
+	err = json.Unmarshal(data, &subjects)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling certificate permissions file '%s': %v", path, err)
+	}
+
+	perms := make(map[string]permissions.AccessKeyPermissions, len(subjects))
+	for subject, permsDto := range subjects {
+		if _, err := secretmode.Parse(permsDto.SecretMode); err != nil {
+			return nil, fmt.Errorf("invalid permissions of '%s' in certificate permissions file '%s': %v", subject, path, err)
+		}
+		perms[subject] = permsDto.ToAccessKeyPermissions()
+	}
+
+	return auth.NewCertificatePermissions(perms), nil
+}
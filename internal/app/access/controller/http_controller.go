@@ -9,6 +9,7 @@ import (
 
 	"github.com/raw-leak/configleam/internal/app/access/dto"
 	"github.com/raw-leak/configleam/internal/app/access/repository"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
 )
 
 type AccessService interface {
@@ -34,6 +35,11 @@ func (e AccessEndpoints) GenerateAccessKeyHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	if _, err := secretmode.Parse(perms.SecretMode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	perms, err = e.service.GenerateAccessKey(r.Context(), perms)
 	if err != nil {
 		log.Printf("Error generating access-key with error: %v", err)
@@ -49,6 +55,7 @@ func (e AccessEndpoints) GenerateAccessKeyHandler(w http.ResponseWriter, r *http
 	}
 }
 
+// DeleteAccessKeysHandler deletes the access-keys by the ids, the 'Key' listed by GET /access, given as 'key' query parameters
 func (e AccessEndpoints) DeleteAccessKeysHandler(w http.ResponseWriter, r *http.Request) {
 	keys := r.URL.Query()["key"]
 
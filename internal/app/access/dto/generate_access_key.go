@@ -1,9 +1,11 @@
 package dto
 
 import (
+	"strings"
 	"time"
 
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
 )
 
 // EnvironmentPermissions represents permissions for a single environment.
@@ -23,6 +25,8 @@ type AccessKeyPermissionsDto struct {
 	AccessKey   string                            `json:"accessKey,omitempty"`
 	Name        string                            `json:"name,omitempty"`
 	ExpDate     time.Time                         `json:"expDate,omitempty"`
+	// SecretMode is the secrets mode of the reads that do not request one: lenient, strict or masked
+	SecretMode string `json:"secretMode,omitempty"`
 }
 
 func (req *AccessKeyPermissionsDto) ToAccessKeyPermissions() permissions.AccessKeyPermissions {
@@ -54,6 +58,7 @@ func (req *AccessKeyPermissionsDto) ToAccessKeyPermissions() permissions.AccessK
 
 	perms.Permissions = envOps
 	perms.Admin = req.GlobalAdmin
+	perms.SecretMode = secretmode.Mode(strings.ToLower(req.SecretMode))
 
 	return perms
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/raw-leak/configleam/internal/app/access/dto"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -67,6 +68,21 @@ func (suite *AccessKeyPermissionsDtoTestSuite) TestToAccessKeyPermissions() {
 				},
 			},
 		},
+		{
+			name: "secrets mode",
+			dto: dto.AccessKeyPermissionsDto{
+				SecretMode: "Strict",
+				Envs: map[string]dto.EnvironmentPermissions{
+					"prod": {ReadConfig: true},
+				},
+			},
+			expected: permissions.AccessKeyPermissions{
+				Permissions: map[string]permissions.Operation{
+					"prod": permissions.ReadConfig,
+				},
+				SecretMode: secretmode.Strict,
+			},
+		},
 	}
 
 	for _, tt := range tests {
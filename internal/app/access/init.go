@@ -22,10 +22,15 @@ func Init(ctx context.Context, cfg *config.Config, encryptor repository.Encrypto
 		RedisUsername: cfg.RedisUsername,
 		RedisPassword: cfg.RedisPassword,
 		RedisTLS:      bool(cfg.RedisTls),
+		RedisTLSFiles: cfg.RedisTlsFiles(),
 
 		EtcdAddrs:    cfg.EtcdAddrs,
 		EtcdUsername: cfg.EtcdUsername,
 		EtcdPassword: cfg.EtcdPassword,
+		EtcdTLS:      bool(cfg.EtcdTls),
+		EtcdTLSFiles: cfg.EtcdTlsFiles(),
+
+		Namespace: cfg.StorageNamespace(),
 	}, encryptor)
 	if err != nil {
 		return nil, err
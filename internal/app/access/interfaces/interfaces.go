@@ -14,8 +14,11 @@ type Keys interface {
 type AccessRepository interface {
 	StoreAccessKey(ctx context.Context, accessKey repository.AccessKey) error
 	RemoveKeys(ctx context.Context, keys []string) error
+	AccessKeyID(ctx context.Context, key string) (string, error)
 	GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error)
 	PaginateAccessKeys(ctx context.Context, page int, size int) (*repository.PaginatedAccessKeys, error)
+	ReencryptAccessKeys(ctx context.Context) (int, error)
+	MigrateAccessKeys(ctx context.Context) (int, error)
 }
 
 type Permissions interface {
@@ -2,26 +2,38 @@ package repository
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type EtcdRepository struct {
 	*etcd.Etcd
 	encryptor Encryptor
+	root      namespace.Namespace
+	ns        namespace.Namespace
 }
 
-func NewEtcdRepository(redis *etcd.Etcd, encryptor Encryptor) *EtcdRepository {
-	return &EtcdRepository{redis, encryptor}
+func NewEtcdRepository(redis *etcd.Etcd, encryptor Encryptor, ns namespace.Namespace) *EtcdRepository {
+	return &EtcdRepository{redis, encryptor, ns, ns}
+}
+
+// scoped returns a copy of the repository building its keys under the project carried by the context
+func (r *EtcdRepository) scoped(ctx context.Context) *EtcdRepository {
+	return &EtcdRepository{r.Etcd, r.encryptor, r.root, r.root.Project(project.FromContext(ctx))}
 }
 
 func (r *EtcdRepository) StoreAccessKey(ctx context.Context, accessKey AccessKey) error {
+	r = r.scoped(ctx)
+
 	perms, err := json.Marshal(accessKey.Perms)
 	if err != nil {
 		return err
@@ -32,11 +44,10 @@ func (r *EtcdRepository) StoreAccessKey(ctx context.Context, accessKey AccessKey
 		return err
 	}
 
-	byteKey, err := r.encryptor.EncryptDet(ctx, []byte(accessKey.Key))
+	id, err := accessKeyID(ctx, r.encryptor, accessKey.Key)
 	if err != nil {
 		return err
 	}
-	encryptedKey := base64.StdEncoding.EncodeToString(byteKey)
 
 	meta, err := json.Marshal(accessKey.Metadata)
 	if err != nil {
@@ -60,13 +71,13 @@ func (r *EtcdRepository) StoreAccessKey(ctx context.Context, accessKey AccessKey
 			return err
 		}
 
-		ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(encryptedKey), string(bytePerms), clientv3.WithLease(lease.ID)))
+		ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(id), string(bytePerms), clientv3.WithLease(lease.ID)))
 	} else {
-		ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(encryptedKey), string(bytePerms)))
+		ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(id), string(bytePerms)))
 	}
 
 	// meta
-	ops = append(ops, clientv3.OpPut(r.GetAccessMetaKey(encryptedKey), string(meta)))
+	ops = append(ops, clientv3.OpPut(r.GetAccessMetaKey(id), string(meta)))
 
 	txnResp, err := r.Client.Txn(ctx).Then(ops...).Commit()
 	if err != nil {
@@ -80,13 +91,13 @@ func (r *EtcdRepository) StoreAccessKey(ctx context.Context, accessKey AccessKey
 }
 
 func (r *EtcdRepository) GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error) {
-	encryptedKeyBytes, err := r.encryptor.EncryptDet(ctx, []byte(key))
+	r = r.scoped(ctx)
+
+	key, err := accessKeyID(ctx, r.encryptor, key)
 	if err != nil {
-		return nil, false, fmt.Errorf("error encrypting access-key '%s': %v", key, err)
+		return nil, false, err
 	}
 
-	key = base64.StdEncoding.EncodeToString(encryptedKeyBytes)
-
 	res, err := r.Client.Get(ctx, r.GetAccessKeyKey(key))
 	if len(res.Kvs) < 1 {
 		return nil, false, nil
@@ -109,6 +120,8 @@ func (r *EtcdRepository) GetAccessKeyPermissions(ctx context.Context, key string
 }
 
 func (r *EtcdRepository) PaginateAccessKeys(ctx context.Context, page int, size int) (*PaginatedAccessKeys, error) {
+	r = r.scoped(ctx)
+
 	accessKeysMetadata := []AccessKeyMetadata{}
 
 	if size < 1 {
@@ -180,7 +193,14 @@ func (r *EtcdRepository) PaginateAccessKeys(ctx context.Context, page int, size
 		nil
 }
 
+// AccessKeyID returns the identifier the access-key is stored under, as listed by PaginateAccessKeys and removed by RemoveKeys
+func (r *EtcdRepository) AccessKeyID(ctx context.Context, key string) (string, error) {
+	return accessKeyID(ctx, r.encryptor, key)
+}
+
 func (r *EtcdRepository) RemoveKeys(ctx context.Context, keys []string) error {
+	r = r.scoped(ctx)
+
 	ops := make([]clientv3.Op, 0, len(keys)*2)
 
 	for _, key := range keys {
@@ -201,14 +221,199 @@ func (r *EtcdRepository) RemoveKeys(ctx context.Context, keys []string) error {
 	return nil
 }
 
+// ReencryptAccessKeys wraps the data keys of the permissions of every access-key of the project with the primary
+// master key, returning how many were rewrapped. The lease of the keys is kept.
+func (r *EtcdRepository) ReencryptAccessKeys(ctx context.Context) (int, error) {
+	r = r.scoped(ctx)
+
+	res, err := r.Client.Get(ctx, r.GetAccessKeyKey(""), clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("error getting access keys to re-encrypt: %v", err)
+	}
+
+	rewrapped := 0
+	for _, kv := range res.Kvs {
+		id := strings.TrimPrefix(string(kv.Key), r.GetAccessKeyKey(""))
+
+		perms, changed, err := r.encryptor.Rewrap(ctx, kv.Value)
+		if err != nil {
+			return rewrapped, fmt.Errorf("error rewrapping access key '%s': %v", id, err)
+		}
+		if !changed {
+			continue
+		}
+
+		txnResp, err := r.Client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(string(kv.Key), string(perms), clientv3.WithIgnoreLease())).
+			Commit()
+		if err != nil {
+			return rewrapped, fmt.Errorf("error executing etcd transaction on re-encrypting access key '%s': %v", id, err)
+		}
+		if txnResp.Succeeded {
+			rewrapped++
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// MigrateAccessKeys moves the access-keys of the project stored under their deterministic encryption to their hash,
+// keeping their lease, and returns how many were moved. The keys moved meanwhile by another instance are skipped.
+func (r *EtcdRepository) MigrateAccessKeys(ctx context.Context) (int, error) {
+	r = r.scoped(ctx)
+
+	res, err := r.Client.Get(ctx, r.GetAccessMetaKey(""), clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+	if err != nil {
+		return 0, fmt.Errorf("error getting all access keys metadata: %v", err)
+	}
+
+	migrated := 0
+	for _, meta := range res.Kvs {
+		id := strings.TrimPrefix(string(meta.Key), r.GetAccessMetaKey(""))
+
+		newID, changed, err := migratedAccessKeyID(ctx, r.encryptor, id)
+		if err != nil {
+			// left in place so the other keys are still migrated
+			log.Printf("Error migrating access key: %v", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		keyRes, err := r.Client.Get(ctx, r.GetAccessKeyKey(id))
+		if err != nil {
+			return migrated, fmt.Errorf("error getting access key '%s': %v", id, err)
+		}
+
+		var keyModRevision int64
+		ops := []clientv3.Op{clientv3.OpPut(r.GetAccessMetaKey(newID), string(meta.Value))}
+
+		// expired keys only keep their metadata
+		if len(keyRes.Kvs) > 0 {
+			kv := keyRes.Kvs[0]
+			keyModRevision = kv.ModRevision
+
+			if kv.Lease != 0 {
+				ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(newID), string(kv.Value), clientv3.WithLease(clientv3.LeaseID(kv.Lease))))
+			} else {
+				ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(newID), string(kv.Value)))
+			}
+		}
+
+		ops = append(ops, clientv3.OpDelete(r.GetAccessKeyKey(id)), clientv3.OpDelete(r.GetAccessMetaKey(id)))
+
+		txnResp, err := r.Client.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.ModRevision(r.GetAccessMetaKey(id)), "=", meta.ModRevision),
+				clientv3.Compare(clientv3.ModRevision(r.GetAccessKeyKey(id)), "=", keyModRevision),
+			).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return migrated, fmt.Errorf("error executing etcd transaction on migrating access key '%s': %v", id, err)
+		}
+		if txnResp.Succeeded {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
 func (r *EtcdRepository) GetAccessKeyKey(key string) string {
-	return fmt.Sprintf("%s:%s:%s", AccessPrefix, KeyPrefix, key)
+	return r.ns.Key(AccessSegment, KeyPrefix, key)
 }
 
 func (r *EtcdRepository) GetAccessMetaKey(key string) string {
-	return fmt.Sprintf("%s:%s:%s", AccessPrefix, MetaPrefix, key)
+	return r.ns.Key(AccessSegment, MetaPrefix, key)
 }
 
 func (r *EtcdRepository) GetAccessSetKey() string {
-	return fmt.Sprintf("%s:%s", AccessPrefix, SetPrefix)
+	return r.ns.Key(AccessSegment, SetPrefix)
+}
+
+// ExportAccessKeys retrieves every non-expired access-key with its metadata, permissions are kept encrypted.
+func (r *EtcdRepository) ExportAccessKeys(ctx context.Context) ([]AccessKeyRecord, error) {
+	r = r.scoped(ctx)
+
+	res, err := r.Client.Get(ctx, r.GetAccessMetaKey(""), clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("error getting all access keys metadata: %v", err)
+	}
+
+	records := make([]AccessKeyRecord, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		id := strings.TrimPrefix(string(kv.Key), r.GetAccessMetaKey(""))
+
+		keyRes, err := r.Client.Get(ctx, r.GetAccessKeyKey(id))
+		if err != nil {
+			return nil, fmt.Errorf("error getting access key '%s': %v", id, err)
+		}
+		if len(keyRes.Kvs) < 1 {
+			// expired
+			continue
+		}
+
+		var metadata AccessKeyMetadata
+		err = json.Unmarshal(kv.Value, &metadata)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling access key metadata '%s': %v", id, err)
+		}
+
+		records = append(records, AccessKeyRecord{ID: id, Perms: keyRes.Kvs[0].Value, Metadata: metadata})
+	}
+
+	return records, nil
+}
+
+// ImportAccessKeys stores the provided access-keys, the expiration is recalculated from the metadata
+// and the keys that already expired are skipped. The keys exported before they were stored as hashes are hashed.
+func (r *EtcdRepository) ImportAccessKeys(ctx context.Context, records []AccessKeyRecord) error {
+	r = r.scoped(ctx)
+
+	ops := make([]clientv3.Op, 0, len(records)*2)
+
+	for _, record := range records {
+		keyExpTime, expired := remainingTime(record.Metadata.ExpirationDate)
+		if expired {
+			continue
+		}
+
+		id, _, err := migratedAccessKeyID(ctx, r.encryptor, record.ID)
+		if err != nil {
+			return err
+		}
+		record.ID = id
+
+		meta, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return err
+		}
+
+		if keyExpTime > 0 {
+			lease, err := r.Client.Grant(ctx, int64(keyExpTime.Seconds()))
+			if err != nil {
+				return err
+			}
+
+			ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(record.ID), string(record.Perms), clientv3.WithLease(lease.ID)))
+		} else {
+			ops = append(ops, clientv3.OpPut(r.GetAccessKeyKey(record.ID), string(record.Perms)))
+		}
+
+		ops = append(ops, clientv3.OpPut(r.GetAccessMetaKey(record.ID), string(meta)))
+	}
+
+	if len(ops) < 1 {
+		return nil
+	}
+
+	_, err := r.Client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("error executing etcd transaction on importing access keys: %v", err)
+	}
+
+	return nil
 }
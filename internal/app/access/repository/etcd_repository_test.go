@@ -2,7 +2,7 @@ package repository_test
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"testing"
 	"time"
@@ -10,6 +10,7 @@ import (
 	"github.com/raw-leak/configleam/internal/app/access/repository"
 	"github.com/raw-leak/configleam/internal/pkg/encryptor"
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
 	clientv3 "go.etcd.io/etcd/client/v3"
 
@@ -46,7 +47,7 @@ func (suite *EtcdAccessRepositorySuite) SetupSuite() {
 	suite.encryptor, err = encryptor.NewEncryptor(suite.key)
 	suite.NoError(err)
 
-	suite.repository = repository.NewEtcdRepository(&etcd.Etcd{Client: suite.client}, suite.encryptor)
+	suite.repository = repository.NewEtcdRepository(&etcd.Etcd{Client: suite.client}, suite.encryptor, namespace.Default)
 }
 
 func (suite *EtcdAccessRepositorySuite) BeforeTest(testName string) {
@@ -114,14 +115,14 @@ func (suite *EtcdAccessRepositorySuite) TestStoreAccessKey() {
 			} else {
 				suite.NoError(err)
 
-				// determine encrypted key
-				encryptedKeyBytes, err := suite.encryptor.EncryptDet(ctx, []byte(tc.inputDate.Key))
+				// determine hashed key
+				hashedKeyBytes, err := suite.encryptor.Hash(ctx, []byte(tc.inputDate.Key))
 				suite.NoError(err)
 
-				encryptedKey := base64.StdEncoding.EncodeToString(encryptedKeyBytes)
+				hashedKey := hex.EncodeToString(hashedKeyBytes)
 
 				// ensure the key has been stored
-				res, err := suite.client.Get(ctx, suite.repository.GetAccessKeyKey(encryptedKey))
+				res, err := suite.client.Get(ctx, suite.repository.GetAccessKeyKey(hashedKey))
 				suite.NoError(err)
 
 				decryptedPermsBytes, err := suite.encryptor.Decrypt(ctx, res.Kvs[0].Value)
@@ -133,7 +134,7 @@ func (suite *EtcdAccessRepositorySuite) TestStoreAccessKey() {
 				suite.Equal(tc.inputDate.Perms, accessKeyPerms)
 
 				// ensure the meta has been stored
-				res, err = suite.client.Get(ctx, suite.repository.GetAccessMetaKey(encryptedKey))
+				res, err = suite.client.Get(ctx, suite.repository.GetAccessMetaKey(hashedKey))
 				suite.NoError(err)
 
 				var meta repository.AccessKeyMetadata
@@ -274,12 +275,12 @@ func (suite *EtcdAccessRepositorySuite) TestGetAccessKeyPermissions() {
 				encryptedPermsBytes, err := suite.encryptor.Encrypt(ctx, value)
 				suite.NoError(err)
 
-				encryptedKeyBytes, err := suite.encryptor.EncryptDet(ctx, []byte(data.key))
+				hashedKeyBytes, err := suite.encryptor.Hash(ctx, []byte(data.key))
 				suite.NoError(err)
 
-				encryptedKey := base64.StdEncoding.EncodeToString(encryptedKeyBytes)
+				hashedKey := hex.EncodeToString(hashedKeyBytes)
 
-				_, err = suite.client.Put(ctx, suite.repository.GetAccessKeyKey(encryptedKey), string(encryptedPermsBytes))
+				_, err = suite.client.Put(ctx, suite.repository.GetAccessKeyKey(hashedKey), string(encryptedPermsBytes))
 				suite.NoError(err)
 			}
 
@@ -917,3 +918,158 @@ func (suite *EtcdAccessRepositorySuite) TestPaginateAccessKeys() {
 		})
 	}
 }
+
+func (suite *EtcdAccessRepositorySuite) TestExportImportAccessKeys() {
+	ctx := context.Background()
+	suite.BeforeTest("TestExportImportAccessKeys")
+
+	perms := permissions.AccessKeyPermissions{
+		Permissions: permissions.Permissions{"develop": permissions.ReadConfig},
+	}
+
+	accessKeys := []repository.AccessKey{
+		{Key: "non-expiring-key", Perms: perms, Metadata: repository.AccessKeyMetadata{Name: "non-expiring", CreationDate: time.Now()}},
+		{Key: "expiring-key", Perms: perms, Metadata: repository.AccessKeyMetadata{Name: "expiring", CreationDate: time.Now(), ExpirationDate: time.Now().Add(time.Hour)}},
+	}
+
+	for _, accessKey := range accessKeys {
+		err := suite.repository.StoreAccessKey(ctx, accessKey)
+		suite.Require().NoError(err)
+	}
+
+	exported, err := suite.repository.ExportAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Len(exported, len(accessKeys))
+
+	// restore into an empty storage
+	suite.BeforeTest("TestExportImportAccessKeys")
+
+	err = suite.repository.ImportAccessKeys(ctx, exported)
+	suite.Require().NoError(err)
+
+	for _, accessKey := range accessKeys {
+		stored, ok, err := suite.repository.GetAccessKeyPermissions(ctx, accessKey.Key)
+		suite.Require().NoError(err)
+		suite.True(ok)
+		suite.Equal(perms, *stored)
+	}
+
+	// expired access-keys are not restored
+	exported[0].Metadata.ExpirationDate = time.Now().Add(-time.Hour)
+	suite.BeforeTest("TestExportImportAccessKeys")
+
+	err = suite.repository.ImportAccessKeys(ctx, exported[:1])
+	suite.Require().NoError(err)
+
+	restored, err := suite.repository.ExportAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Len(restored, 0)
+}
+
+func (suite *EtcdAccessRepositorySuite) TestReencryptAccessKeys() {
+	suite.BeforeTest("TestReencryptAccessKeys")
+	ctx := context.Background()
+
+	perms := permissions.AccessKeyPermissions{Permissions: permissions.Permissions{"develop": permissions.ReadConfig}}
+	err := suite.repository.StoreAccessKey(ctx, repository.AccessKey{
+		Key:      "test-access-key-1",
+		Perms:    perms,
+		Metadata: repository.AccessKeyMetadata{CreationDate: time.Now(), ExpirationDate: time.Now().Add(time.Hour)},
+	})
+	suite.Require().NoError(err)
+
+	rotated, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{
+		{ID: "rotated", Key: "98765432109876543210987654321098"},
+		{ID: encryptor.DefaultKeyID, Key: suite.key},
+	}, suite.key, "")
+	suite.Require().NoError(err)
+	repo := repository.NewEtcdRepository(&etcd.Etcd{Client: suite.client}, rotated, namespace.Default)
+
+	rewrapped, err := repo.ReencryptAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, rewrapped)
+
+	hashedKeyBytes, err := rotated.Hash(ctx, []byte("test-access-key-1"))
+	suite.Require().NoError(err)
+	hashedKey := hex.EncodeToString(hashedKeyBytes)
+
+	res, err := suite.client.Get(ctx, repo.GetAccessKeyKey(hashedKey))
+	suite.Require().NoError(err)
+	suite.Require().Len(res.Kvs, 1)
+	suite.Equal("rotated", rotated.KeyID(res.Kvs[0].Value))
+
+	// the lease is kept
+	suite.NotZero(res.Kvs[0].Lease)
+
+	stored, ok, err := repo.GetAccessKeyPermissions(ctx, "test-access-key-1")
+	suite.Require().NoError(err)
+	suite.Require().True(ok)
+	suite.Equal(perms, *stored)
+
+	// already wrapped by the primary master key
+	rewrapped, err = repo.ReencryptAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(0, rewrapped)
+}
+
+func (suite *EtcdAccessRepositorySuite) TestMigrateAccessKeys() {
+	suite.BeforeTest("TestMigrateAccessKeys")
+	ctx := context.Background()
+
+	perms := permissions.AccessKeyPermissions{Permissions: permissions.Permissions{"develop": permissions.ReadConfig}}
+	permsBytes, err := json.Marshal(perms)
+	suite.Require().NoError(err)
+	encryptedPerms, err := suite.encryptor.Encrypt(ctx, permsBytes)
+	suite.Require().NoError(err)
+
+	meta, err := json.Marshal(repository.AccessKeyMetadata{Name: "legacy", CreationDate: time.Now()})
+	suite.Require().NoError(err)
+
+	lease, err := suite.client.Grant(ctx, 3600)
+	suite.Require().NoError(err)
+
+	oldID := legacyID(suite.key, "test-access-key-1")
+	_, err = suite.client.Put(ctx, suite.repository.GetAccessKeyKey(oldID), string(encryptedPerms), clientv3.WithLease(lease.ID))
+	suite.Require().NoError(err)
+	_, err = suite.client.Put(ctx, suite.repository.GetAccessMetaKey(oldID), string(meta))
+	suite.Require().NoError(err)
+
+	// keys stored under their hash are left untouched
+	err = suite.repository.StoreAccessKey(ctx, repository.AccessKey{Key: "test-access-key-2", Perms: perms, Metadata: repository.AccessKeyMetadata{CreationDate: time.Now()}})
+	suite.Require().NoError(err)
+
+	migrated, err := suite.repository.MigrateAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, migrated)
+
+	stored, ok, err := suite.repository.GetAccessKeyPermissions(ctx, "test-access-key-1")
+	suite.Require().NoError(err)
+	suite.Require().True(ok)
+	suite.Equal(perms, *stored)
+
+	hashedKeyBytes, err := suite.encryptor.Hash(ctx, []byte("test-access-key-1"))
+	suite.Require().NoError(err)
+	hashedKey := hex.EncodeToString(hashedKeyBytes)
+
+	// the lease and the metadata are kept
+	res, err := suite.client.Get(ctx, suite.repository.GetAccessKeyKey(hashedKey))
+	suite.Require().NoError(err)
+	suite.Require().Len(res.Kvs, 1)
+	suite.Equal(int64(lease.ID), res.Kvs[0].Lease)
+
+	res, err = suite.client.Get(ctx, suite.repository.GetAccessMetaKey(hashedKey))
+	suite.Require().NoError(err)
+	suite.Require().Len(res.Kvs, 1)
+	suite.JSONEq(string(meta), string(res.Kvs[0].Value))
+
+	// nothing is left under the encrypted key
+	for _, key := range []string{suite.repository.GetAccessKeyKey(oldID), suite.repository.GetAccessMetaKey(oldID)} {
+		res, err = suite.client.Get(ctx, key)
+		suite.Require().NoError(err)
+		suite.Empty(res.Kvs)
+	}
+
+	migrated, err = suite.repository.MigrateAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Zero(migrated)
+}
@@ -28,3 +28,11 @@ type PaginatedAccessKeys struct {
 	Size  int                 `json:"size"`
 	Items []AccessKeyMetadata `json:"items"`
 }
+
+// AccessKeyRecord represents a single stored access-key as it is exported, where the ID is the
+// storage identifier of the key and the permissions are kept encrypted.
+type AccessKeyRecord struct {
+	ID       string            `json:"id"`
+	Perms    []byte            `json:"perms"`
+	Metadata AccessKeyMetadata `json:"metadata"`
+}
@@ -2,12 +2,15 @@ package repository
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
 	"github.com/redis/go-redis/v9"
 )
@@ -15,13 +18,22 @@ import (
 type RedisRepository struct {
 	*rds.Redis
 	encryptor Encryptor
+	root      namespace.Namespace
+	ns        namespace.Namespace
 }
 
-func NewRedisRepository(redis *rds.Redis, encryptor Encryptor) *RedisRepository {
-	return &RedisRepository{redis, encryptor}
+func NewRedisRepository(redis *rds.Redis, encryptor Encryptor, ns namespace.Namespace) *RedisRepository {
+	return &RedisRepository{redis, encryptor, ns, ns}
+}
+
+// scoped returns a copy of the repository building its keys under the project carried by the context
+func (r *RedisRepository) scoped(ctx context.Context) *RedisRepository {
+	return &RedisRepository{r.Redis, r.encryptor, r.root, r.root.Project(project.FromContext(ctx))}
 }
 
 func (r *RedisRepository) StoreAccessKey(ctx context.Context, accessKey AccessKey) error {
+	r = r.scoped(ctx)
+
 	perms, err := json.Marshal(accessKey.Perms)
 	if err != nil {
 		return err
@@ -32,7 +44,7 @@ func (r *RedisRepository) StoreAccessKey(ctx context.Context, accessKey AccessKe
 		return err
 	}
 
-	byteKey, err := r.encryptor.EncryptDet(ctx, []byte(accessKey.Key))
+	id, err := accessKeyID(ctx, r.encryptor, accessKey.Key)
 	if err != nil {
 		return err
 	}
@@ -50,12 +62,11 @@ func (r *RedisRepository) StoreAccessKey(ctx context.Context, accessKey AccessKe
 		keyExpTime = 0
 	}
 
-	encryptedKey := base64.StdEncoding.EncodeToString(byteKey)
 	pipeline := r.Client.TxPipeline()
 
-	pipeline.Set(ctx, r.GetAccessKeyKey(encryptedKey), bytePerms, keyExpTime)
-	pipeline.Set(ctx, r.GetAccessMetaKey(encryptedKey), meta, 0)
-	pipeline.ZAdd(ctx, r.GetAccessSetKey(), redis.Z{Score: float64(accessKey.Metadata.CreationDate.Unix()), Member: encryptedKey})
+	pipeline.Set(ctx, r.GetAccessKeyKey(id), bytePerms, keyExpTime)
+	pipeline.Set(ctx, r.GetAccessMetaKey(id), meta, 0)
+	pipeline.ZAdd(ctx, r.GetAccessSetKey(), redis.Z{Score: float64(accessKey.Metadata.CreationDate.Unix()), Member: id})
 
 	_, err = pipeline.Exec(ctx)
 	if err != nil {
@@ -66,13 +77,13 @@ func (r *RedisRepository) StoreAccessKey(ctx context.Context, accessKey AccessKe
 }
 
 func (r *RedisRepository) GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error) {
-	encryptedKeyBytes, err := r.encryptor.EncryptDet(ctx, []byte(key))
+	r = r.scoped(ctx)
+
+	key, err := accessKeyID(ctx, r.encryptor, key)
 	if err != nil {
-		return nil, false, fmt.Errorf("error encrypting access-key '%s': %v", key, err)
+		return nil, false, err
 	}
 
-	key = base64.StdEncoding.EncodeToString(encryptedKeyBytes)
-
 	raw, err := r.Client.Get(ctx, r.GetAccessKeyKey(key)).Bytes()
 	if err == redis.Nil {
 		return nil, false, nil
@@ -95,6 +106,8 @@ func (r *RedisRepository) GetAccessKeyPermissions(ctx context.Context, key strin
 }
 
 func (r *RedisRepository) PaginateAccessKeys(ctx context.Context, page int, size int) (*PaginatedAccessKeys, error) {
+	r = r.scoped(ctx)
+
 	accessKeysMetadata := []AccessKeyMetadata{}
 
 	if size == 0 {
@@ -150,7 +163,14 @@ func (r *RedisRepository) PaginateAccessKeys(ctx context.Context, page int, size
 		nil
 }
 
+// AccessKeyID returns the identifier the access-key is stored under, as listed by PaginateAccessKeys and removed by RemoveKeys
+func (r *RedisRepository) AccessKeyID(ctx context.Context, key string) (string, error) {
+	return accessKeyID(ctx, r.encryptor, key)
+}
+
 func (r *RedisRepository) RemoveKeys(ctx context.Context, keys []string) error {
+	r = r.scoped(ctx)
+
 	pipeline := r.Client.TxPipeline()
 
 	for _, key := range keys {
@@ -167,14 +187,213 @@ func (r *RedisRepository) RemoveKeys(ctx context.Context, keys []string) error {
 	return nil
 }
 
+// ReencryptAccessKeys wraps the data keys of the permissions of every access-key of the project with the primary
+// master key, returning how many were rewrapped. The expiration of the keys is kept.
+func (r *RedisRepository) ReencryptAccessKeys(ctx context.Context) (int, error) {
+	r = r.scoped(ctx)
+
+	rewrapped := 0
+	iter := r.Client.Scan(ctx, 0, r.GetAccessKeyKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		id := strings.TrimPrefix(key, r.GetAccessKeyKey(""))
+
+		changed := false
+		err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+			current, err := tx.Get(ctx, key).Bytes()
+			if err == redis.Nil {
+				// expired
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			var perms []byte
+			perms, changed, err = r.encryptor.Rewrap(ctx, current)
+			if err != nil || !changed {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(ctx, key, perms, redis.SetArgs{KeepTTL: true})
+				return nil
+			})
+			return err
+		}, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return rewrapped, fmt.Errorf("error re-encrypting access key '%s': %v", id, err)
+		}
+		if changed {
+			rewrapped++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return rewrapped, fmt.Errorf("error scanning access keys to re-encrypt: %v", err)
+	}
+
+	return rewrapped, nil
+}
+
+// MigrateAccessKeys moves the access-keys of the project stored under their deterministic encryption to their hash,
+// keeping their expiration, and returns how many were moved. The keys moved meanwhile by another instance are skipped.
+func (r *RedisRepository) MigrateAccessKeys(ctx context.Context) (int, error) {
+	r = r.scoped(ctx)
+
+	members, err := r.Client.ZRangeWithScores(ctx, r.GetAccessSetKey(), 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error getting all access keys: %v", err)
+	}
+
+	migrated := 0
+	for _, member := range members {
+		id, ok := member.Member.(string)
+		if !ok {
+			continue
+		}
+
+		newID, changed, err := migratedAccessKeyID(ctx, r.encryptor, id)
+		if err != nil {
+			// left in place so the other keys are still migrated
+			log.Printf("Error migrating access key: %v", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+			perms, err := tx.Get(ctx, r.GetAccessKeyKey(id)).Bytes()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+
+			ttl, err := tx.PTTL(ctx, r.GetAccessKeyKey(id)).Result()
+			if err != nil {
+				return err
+			}
+			if ttl < 0 {
+				ttl = 0
+			}
+
+			meta, err := tx.Get(ctx, r.GetAccessMetaKey(id)).Bytes()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				// expired keys only keep their metadata
+				if perms != nil {
+					pipe.Set(ctx, r.GetAccessKeyKey(newID), perms, ttl)
+				}
+				if meta != nil {
+					pipe.Set(ctx, r.GetAccessMetaKey(newID), meta, 0)
+				}
+				pipe.ZAdd(ctx, r.GetAccessSetKey(), redis.Z{Score: member.Score, Member: newID})
+
+				pipe.Del(ctx, r.GetAccessKeyKey(id), r.GetAccessMetaKey(id))
+				pipe.ZRem(ctx, r.GetAccessSetKey(), id)
+				return nil
+			})
+			return err
+		}, r.GetAccessKeyKey(id), r.GetAccessMetaKey(id))
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("error migrating access key '%s': %v", id, err)
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
 func (r *RedisRepository) GetAccessKeyKey(key string) string {
-	return fmt.Sprintf("%s:%s:%s", AccessPrefix, KeyPrefix, key)
+	return r.ns.Key(AccessSegment, KeyPrefix, key)
 }
 
 func (r *RedisRepository) GetAccessMetaKey(key string) string {
-	return fmt.Sprintf("%s:%s:%s", AccessPrefix, MetaPrefix, key)
+	return r.ns.Key(AccessSegment, MetaPrefix, key)
 }
 
 func (r *RedisRepository) GetAccessSetKey() string {
-	return fmt.Sprintf("%s:%s", AccessPrefix, SetPrefix)
+	return r.ns.Key(AccessSegment, SetPrefix)
+}
+
+// ExportAccessKeys retrieves every non-expired access-key with its metadata, permissions are kept encrypted.
+func (r *RedisRepository) ExportAccessKeys(ctx context.Context) ([]AccessKeyRecord, error) {
+	r = r.scoped(ctx)
+
+	ids, err := r.Client.ZRange(ctx, r.GetAccessSetKey(), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error getting all access keys: %v", err)
+	}
+
+	records := make([]AccessKeyRecord, 0, len(ids))
+	for _, id := range ids {
+		perms, err := r.Client.Get(ctx, r.GetAccessKeyKey(id)).Bytes()
+		if err == redis.Nil {
+			// expired
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting access key '%s': %v", id, err)
+		}
+
+		metadataBytes, err := r.Client.Get(ctx, r.GetAccessMetaKey(id)).Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("error getting access key metadata '%s': %v", id, err)
+		}
+
+		var metadata AccessKeyMetadata
+		err = json.Unmarshal(metadataBytes, &metadata)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling access key metadata '%s': %v", id, err)
+		}
+
+		records = append(records, AccessKeyRecord{ID: id, Perms: perms, Metadata: metadata})
+	}
+
+	return records, nil
+}
+
+// ImportAccessKeys stores the provided access-keys, the expiration is recalculated from the metadata
+// and the keys that already expired are skipped. The keys exported before they were stored as hashes are hashed.
+func (r *RedisRepository) ImportAccessKeys(ctx context.Context, records []AccessKeyRecord) error {
+	r = r.scoped(ctx)
+
+	pipeline := r.Client.TxPipeline()
+
+	for _, record := range records {
+		keyExpTime, expired := remainingTime(record.Metadata.ExpirationDate)
+		if expired {
+			continue
+		}
+
+		id, _, err := migratedAccessKeyID(ctx, r.encryptor, record.ID)
+		if err != nil {
+			return err
+		}
+		record.ID = id
+
+		meta, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return err
+		}
+
+		pipeline.Set(ctx, r.GetAccessKeyKey(record.ID), record.Perms, keyExpTime)
+		pipeline.Set(ctx, r.GetAccessMetaKey(record.ID), meta, 0)
+		pipeline.ZAdd(ctx, r.GetAccessSetKey(), redis.Z{Score: float64(record.Metadata.CreationDate.Unix()), Member: record.ID})
+	}
+
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on importing access keys: %v", err)
+	}
+
+	return nil
 }
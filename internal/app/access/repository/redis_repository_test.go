@@ -3,6 +3,7 @@ package repository_test
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"testing"
 	"time"
@@ -10,11 +11,13 @@ import (
 	"github.com/raw-leak/configleam/internal/app/access/keys"
 	"github.com/raw-leak/configleam/internal/app/access/repository"
 	"github.com/raw-leak/configleam/internal/pkg/encryptor"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
+	chacha20 "golang.org/x/crypto/chacha20poly1305"
 )
 
 type RedisAccessRepositorySuite struct {
@@ -43,7 +46,7 @@ func (suite *RedisAccessRepositorySuite) SetupSuite() {
 	suite.encryptor, err = encryptor.NewEncryptor(suite.key)
 	suite.Require().NoError(err)
 
-	suite.repository = repository.NewRedisRepository(&rds.Redis{Client: suite.client}, suite.encryptor)
+	suite.repository = repository.NewRedisRepository(&rds.Redis{Client: suite.client}, suite.encryptor, namespace.Default)
 }
 
 func (suite *RedisAccessRepositorySuite) TearDownSuite() {
@@ -111,14 +114,14 @@ func (suite *RedisAccessRepositorySuite) TestStoreAccessKey() {
 			} else {
 				suite.NoError(err)
 
-				// determine encrypted key
-				encryptedKeyBytes, err := suite.encryptor.EncryptDet(ctx, []byte(tc.inputDate.Key))
+				// determine hashed key
+				hashedKeyBytes, err := suite.encryptor.Hash(ctx, []byte(tc.inputDate.Key))
 				suite.NoError(err)
 
-				encryptedKey := base64.StdEncoding.EncodeToString(encryptedKeyBytes)
+				hashedKey := hex.EncodeToString(hashedKeyBytes)
 
 				// ensure the key has been stored
-				rawPerms, err := suite.client.Get(ctx, suite.repository.GetAccessKeyKey(encryptedKey)).Bytes()
+				rawPerms, err := suite.client.Get(ctx, suite.repository.GetAccessKeyKey(hashedKey)).Bytes()
 				suite.NoError(err)
 
 				decryptedPermsBytes, err := suite.encryptor.Decrypt(ctx, rawPerms)
@@ -130,7 +133,7 @@ func (suite *RedisAccessRepositorySuite) TestStoreAccessKey() {
 				suite.Equal(tc.inputDate.Perms, accessKeyPerms)
 
 				// ensure the meta has been stored
-				metaBytes, err := suite.client.Get(ctx, suite.repository.GetAccessMetaKey(encryptedKey)).Bytes()
+				metaBytes, err := suite.client.Get(ctx, suite.repository.GetAccessMetaKey(hashedKey)).Bytes()
 				suite.NoError(err)
 
 				var meta repository.AccessKeyMetadata
@@ -139,7 +142,7 @@ func (suite *RedisAccessRepositorySuite) TestStoreAccessKey() {
 				suite.Equal(tc.inputDate.Metadata.CreationDate.Unix(), meta.CreationDate.Unix())
 
 				// ensure that the key has been added to the sorted-set
-				score, err := suite.client.ZScore(ctx, suite.repository.GetAccessSetKey(), encryptedKey).Result()
+				score, err := suite.client.ZScore(ctx, suite.repository.GetAccessSetKey(), hashedKey).Result()
 				suite.NotEqual(err, redis.Nil)
 				suite.NoError(err)
 
@@ -279,12 +282,12 @@ func (suite *RedisAccessRepositorySuite) TestGetAccessKeyPermissions() {
 				encryptedPermsBytes, err := suite.encryptor.Encrypt(ctx, value)
 				suite.Require().NoError(err)
 
-				encryptedKeyBytes, err := suite.encryptor.EncryptDet(ctx, []byte(data.key))
+				hashedKeyBytes, err := suite.encryptor.Hash(ctx, []byte(data.key))
 				suite.Require().NoError(err)
 
-				encryptedKey := base64.StdEncoding.EncodeToString(encryptedKeyBytes)
+				hashedKey := hex.EncodeToString(hashedKeyBytes)
 
-				err = suite.client.Set(ctx, suite.repository.GetAccessKeyKey(encryptedKey), encryptedPermsBytes, 0).Err()
+				err = suite.client.Set(ctx, suite.repository.GetAccessKeyKey(hashedKey), encryptedPermsBytes, 0).Err()
 				suite.Require().NoError(err)
 			}
 
@@ -936,3 +939,169 @@ func (suite *RedisAccessRepositorySuite) TestPaginateAccessKeys() {
 		})
 	}
 }
+
+func (suite *RedisAccessRepositorySuite) TestExportImportAccessKeys() {
+	ctx := context.Background()
+	suite.BeforeTest("TestExportImportAccessKeys")
+
+	perms := permissions.AccessKeyPermissions{
+		Permissions: permissions.Permissions{"develop": permissions.ReadConfig},
+	}
+
+	accessKeys := []repository.AccessKey{
+		{Key: "non-expiring-key", Perms: perms, Metadata: repository.AccessKeyMetadata{Name: "non-expiring", CreationDate: time.Now()}},
+		{Key: "expiring-key", Perms: perms, Metadata: repository.AccessKeyMetadata{Name: "expiring", CreationDate: time.Now(), ExpirationDate: time.Now().Add(time.Hour)}},
+	}
+
+	for _, accessKey := range accessKeys {
+		err := suite.repository.StoreAccessKey(ctx, accessKey)
+		suite.Require().NoError(err)
+	}
+
+	exported, err := suite.repository.ExportAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Len(exported, len(accessKeys))
+
+	// restore into an empty storage
+	suite.BeforeTest("TestExportImportAccessKeys")
+
+	err = suite.repository.ImportAccessKeys(ctx, exported)
+	suite.Require().NoError(err)
+
+	for _, accessKey := range accessKeys {
+		stored, ok, err := suite.repository.GetAccessKeyPermissions(ctx, accessKey.Key)
+		suite.Require().NoError(err)
+		suite.True(ok)
+		suite.Equal(perms, *stored)
+	}
+
+	// expired access-keys are not restored
+	exported[0].Metadata.ExpirationDate = time.Now().Add(-time.Hour)
+	suite.BeforeTest("TestExportImportAccessKeys")
+
+	err = suite.repository.ImportAccessKeys(ctx, exported[:1])
+	suite.Require().NoError(err)
+
+	restored, err := suite.repository.ExportAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Len(restored, 0)
+}
+
+func (suite *RedisAccessRepositorySuite) TestReencryptAccessKeys() {
+	suite.BeforeTest("TestReencryptAccessKeys")
+	ctx := context.Background()
+
+	perms := permissions.AccessKeyPermissions{Permissions: permissions.Permissions{"develop": permissions.ReadConfig}}
+	err := suite.repository.StoreAccessKey(ctx, repository.AccessKey{
+		Key:      "test-access-key-1",
+		Perms:    perms,
+		Metadata: repository.AccessKeyMetadata{CreationDate: time.Now(), ExpirationDate: time.Now().Add(time.Hour)},
+	})
+	suite.Require().NoError(err)
+
+	rotated, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{
+		{ID: "rotated", Key: "98765432109876543210987654321098"},
+		{ID: encryptor.DefaultKeyID, Key: suite.key},
+	}, suite.key, "")
+	suite.Require().NoError(err)
+	repo := repository.NewRedisRepository(&rds.Redis{Client: suite.client}, rotated, namespace.Default)
+
+	rewrapped, err := repo.ReencryptAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, rewrapped)
+
+	hashedKeyBytes, err := rotated.Hash(ctx, []byte("test-access-key-1"))
+	suite.Require().NoError(err)
+	hashedKey := hex.EncodeToString(hashedKeyBytes)
+
+	raw, err := suite.client.Get(ctx, repo.GetAccessKeyKey(hashedKey)).Bytes()
+	suite.Require().NoError(err)
+	suite.Equal("rotated", rotated.KeyID(raw))
+
+	// the expiration is kept
+	ttl, err := suite.client.TTL(ctx, repo.GetAccessKeyKey(hashedKey)).Result()
+	suite.Require().NoError(err)
+	suite.Greater(ttl, time.Duration(0))
+
+	stored, ok, err := repo.GetAccessKeyPermissions(ctx, "test-access-key-1")
+	suite.Require().NoError(err)
+	suite.Require().True(ok)
+	suite.Equal(perms, *stored)
+
+	// already wrapped by the primary master key
+	rewrapped, err = repo.ReencryptAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(0, rewrapped)
+}
+
+func (suite *RedisAccessRepositorySuite) TestMigrateAccessKeys() {
+	suite.BeforeTest("TestMigrateAccessKeys")
+	ctx := context.Background()
+
+	perms := permissions.AccessKeyPermissions{Permissions: permissions.Permissions{"develop": permissions.ReadConfig}}
+	permsBytes, err := json.Marshal(perms)
+	suite.Require().NoError(err)
+	encryptedPerms, err := suite.encryptor.Encrypt(ctx, permsBytes)
+	suite.Require().NoError(err)
+
+	meta, err := json.Marshal(repository.AccessKeyMetadata{Name: "legacy", CreationDate: time.Now()})
+	suite.Require().NoError(err)
+
+	oldID := legacyID(suite.key, "test-access-key-1")
+	suite.Require().NoError(suite.client.Set(ctx, suite.repository.GetAccessKeyKey(oldID), encryptedPerms, time.Hour).Err())
+	suite.Require().NoError(suite.client.Set(ctx, suite.repository.GetAccessMetaKey(oldID), meta, 0).Err())
+	suite.Require().NoError(suite.client.ZAdd(ctx, suite.repository.GetAccessSetKey(), redis.Z{Score: 1, Member: oldID}).Err())
+
+	// keys stored under their hash are left untouched
+	err = suite.repository.StoreAccessKey(ctx, repository.AccessKey{Key: "test-access-key-2", Perms: perms, Metadata: repository.AccessKeyMetadata{CreationDate: time.Now()}})
+	suite.Require().NoError(err)
+
+	migrated, err := suite.repository.MigrateAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, migrated)
+
+	stored, ok, err := suite.repository.GetAccessKeyPermissions(ctx, "test-access-key-1")
+	suite.Require().NoError(err)
+	suite.Require().True(ok)
+	suite.Equal(perms, *stored)
+
+	hashedKeyBytes, err := suite.encryptor.Hash(ctx, []byte("test-access-key-1"))
+	suite.Require().NoError(err)
+	hashedKey := hex.EncodeToString(hashedKeyBytes)
+
+	// the expiration, the metadata and the position in the set are kept
+	ttl, err := suite.client.TTL(ctx, suite.repository.GetAccessKeyKey(hashedKey)).Result()
+	suite.Require().NoError(err)
+	suite.Greater(ttl, time.Duration(0))
+
+	storedMeta, err := suite.client.Get(ctx, suite.repository.GetAccessMetaKey(hashedKey)).Bytes()
+	suite.Require().NoError(err)
+	suite.JSONEq(string(meta), string(storedMeta))
+
+	score, err := suite.client.ZScore(ctx, suite.repository.GetAccessSetKey(), hashedKey).Result()
+	suite.Require().NoError(err)
+	suite.Equal(float64(1), score)
+
+	// nothing is left under the encrypted key
+	exists, err := suite.client.Exists(ctx, suite.repository.GetAccessKeyKey(oldID), suite.repository.GetAccessMetaKey(oldID)).Result()
+	suite.Require().NoError(err)
+	suite.Zero(exists)
+
+	ids, err := suite.client.ZRange(ctx, suite.repository.GetAccessSetKey(), 0, -1).Result()
+	suite.Require().NoError(err)
+	suite.NotContains(ids, oldID)
+
+	migrated, err = suite.repository.MigrateAccessKeys(ctx)
+	suite.Require().NoError(err)
+	suite.Zero(migrated)
+}
+
+// legacyID returns the identifier an access-key was stored under before being hashed, its deterministic encryption
+func legacyID(key, accessKey string) string {
+	aead, err := chacha20.NewX([]byte(key))
+	if err != nil {
+		panic(err)
+	}
+	nonce := []byte(key[:chacha20.NonceSizeX])
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(accessKey), nil))
+}
@@ -2,18 +2,28 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 )
 
 const (
-	AccessPrefix = "configleam:access"
-	KeyPrefix    = "key"
-	MetaPrefix   = "meta"
-	SetPrefix    = "set"
+	AccessSegment = "access"
+	KeyPrefix     = "key"
+	MetaPrefix    = "meta"
+	SetPrefix     = "set"
+
+	// prefix within the default namespace
+	AccessPrefix = namespace.Default + ":" + AccessSegment
 )
 
 type Repository interface {
@@ -21,12 +31,19 @@ type Repository interface {
 	GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error)
 	PaginateAccessKeys(ctx context.Context, page int, size int) (*PaginatedAccessKeys, error)
 	RemoveKeys(ctx context.Context, keys []string) error
+	AccessKeyID(ctx context.Context, key string) (string, error)
+	ReencryptAccessKeys(ctx context.Context) (int, error)
+	MigrateAccessKeys(ctx context.Context) (int, error)
+
+	ExportAccessKeys(ctx context.Context) ([]AccessKeyRecord, error)
+	ImportAccessKeys(ctx context.Context, records []AccessKeyRecord) error
 }
 
 type Encryptor interface {
 	Encrypt(ctx context.Context, b []byte) ([]byte, error)
-	EncryptDet(ctx context.Context, b []byte) ([]byte, error)
 	Decrypt(ctx context.Context, b []byte) ([]byte, error)
+	Rewrap(ctx context.Context, b []byte) ([]byte, bool, error)
+	Hash(ctx context.Context, b []byte) ([]byte, error)
 }
 
 type RepositoryConfig struct {
@@ -34,11 +51,15 @@ type RepositoryConfig struct {
 	RedisUsername string
 	RedisPassword string
 	RedisTLS      bool
+	RedisTLSFiles tlsconfig.Files
 
 	EtcdAddrs    []string
 	EtcdUsername string
 	EtcdPassword string
 	EtcdTLS      bool
+	EtcdTLSFiles tlsconfig.Files
+
+	Namespace namespace.Namespace
 }
 
 func New(ctx context.Context, cfg RepositoryConfig, encryptor Encryptor) (Repository, error) {
@@ -48,12 +69,13 @@ func New(ctx context.Context, cfg RepositoryConfig, encryptor Encryptor) (Reposi
 			Password: cfg.RedisPassword,
 			Username: cfg.RedisUsername,
 			TLS:      cfg.RedisTLS,
+			TLSFiles: cfg.RedisTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return NewRedisRepository(redisCli, encryptor), nil
+		return NewRedisRepository(redisCli, encryptor, cfg.Namespace), nil
 	}
 
 	if len(cfg.EtcdAddrs) > 0 {
@@ -62,13 +84,67 @@ func New(ctx context.Context, cfg RepositoryConfig, encryptor Encryptor) (Reposi
 			EtcdUsername: cfg.EtcdUsername,
 			EtcdPassword: cfg.EtcdPassword,
 			TLS:          cfg.EtcdTLS,
+			TLSFiles:     cfg.EtcdTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return NewEtcdRepository(etcdCli, encryptor), nil
+		return NewEtcdRepository(etcdCli, encryptor, cfg.Namespace), nil
 	}
 
 	return nil, fmt.Errorf("'RedisAddress' nor 'EtcdAddrs' has been provided for 'access' repository")
 }
+
+// remainingTime returns the time left until the expiration date, where zero means the key does not expire.
+func remainingTime(expirationDate time.Time) (time.Duration, bool) {
+	if expirationDate.IsZero() {
+		return 0, false
+	}
+
+	remaining := time.Until(expirationDate)
+	return remaining, remaining <= 0
+}
+
+// accessKeyID returns the identifier an access-key is stored and looked up by, the hex encoded keyed hash of the key
+func accessKeyID(ctx context.Context, encryptor Encryptor, key string) (string, error) {
+	hash, err := encryptor.Hash(ctx, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("error hashing access-key: %v", err)
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+// isAccessKeyID reports whether id is an identifier returned by accessKeyID, the hex encoding of a SHA-256 hash.
+// The base64 encoded encryptions the generated access-keys were stored under are longer, even when only made of hex digits.
+func isAccessKeyID(id string) bool {
+	if len(id) != hex.EncodedLen(sha256.Size) || strings.ToLower(id) != id {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// migratedAccessKeyID returns the identifier of the access-key stored under id, reporting whether it changed.
+// The access-keys were stored under their base64 encoded deterministic encryption, which is decrypted to hash them.
+func migratedAccessKeyID(ctx context.Context, encryptor Encryptor, id string) (string, bool, error) {
+	if isAccessKeyID(id) {
+		return id, false, nil
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return "", false, fmt.Errorf("access-key '%s' is neither hashed nor encrypted", id)
+	}
+
+	key, err := encryptor.Decrypt(ctx, encrypted)
+	if err != nil {
+		return "", false, fmt.Errorf("error decrypting access-key '%s': %v", id, err)
+	}
+
+	hashed, err := accessKeyID(ctx, encryptor, string(key))
+	if err != nil {
+		return "", false, err
+	}
+	return hashed, true, nil
+}
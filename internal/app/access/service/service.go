@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/raw-leak/configleam/internal/app/access/dto"
 	"github.com/raw-leak/configleam/internal/app/access/interfaces"
 	"github.com/raw-leak/configleam/internal/app/access/repository"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 )
 
 type AccessService struct {
@@ -37,6 +40,7 @@ func (a *AccessService) GenerateAccessKey(ctx context.Context, accessKeyPerms dt
 	}
 
 	perms := accessKeyPerms.ToAccessKeyPermissions()
+	perms.Name = accessKeyPerms.Name
 	accessKey := repository.AccessKey{
 		Key:   key,
 		Perms: perms,
@@ -65,6 +69,11 @@ func (a *AccessService) GetAccessKeyPermissions(ctx context.Context, key string)
 		return nil, false, err
 	}
 
+	// keys generated without a name, or before it was kept with the permissions, are identified by their masked form
+	if ok && perms.Name == "" {
+		perms.Name = a.GetMaskedKey(key)
+	}
+
 	return perms, ok, nil
 }
 
@@ -77,8 +86,9 @@ func (a *AccessService) PaginateAccessKeys(ctx context.Context, page, size int)
 	return paginated, nil
 }
 
-func (a *AccessService) DeleteAccessKeys(ctx context.Context, keys []string) error {
-	err := a.repository.RemoveKeys(ctx, keys)
+// DeleteAccessKeys deletes the access-keys by the identifiers they are listed with, never by the access-keys themselves
+func (a *AccessService) DeleteAccessKeys(ctx context.Context, ids []string) error {
+	err := a.repository.RemoveKeys(ctx, ids)
 	if err != nil {
 		return err
 	}
@@ -86,6 +96,47 @@ func (a *AccessService) DeleteAccessKeys(ctx context.Context, keys []string) err
 	return nil
 }
 
+// DeleteAccessKeysByPlaintext deletes the access-keys given as generated, hashing them into the identifiers they are stored under
+func (a *AccessService) DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		id, err := a.repository.AccessKeyID(ctx, key)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	return a.DeleteAccessKeys(ctx, ids)
+}
+
+// ReencryptAccessKeys wraps the permissions of the access-keys of the project with the primary master key
+func (a *AccessService) ReencryptAccessKeys(ctx context.Context) (int, error) {
+	return a.repository.ReencryptAccessKeys(ctx)
+}
+
+// MigrateAccessKeys moves the access-keys of the projects stored under their deterministic encryption to their hash,
+// it is meant to run on start, before any access-key is looked up
+func (a *AccessService) MigrateAccessKeys(ctx context.Context, projects []string) error {
+	seen := map[string]bool{}
+	for _, name := range projects {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		migrated, err := a.repository.MigrateAccessKeys(project.WithProject(ctx, name))
+		if err != nil {
+			return fmt.Errorf("error migrating access keys of project '%s': %w", name, err)
+		}
+		if migrated > 0 {
+			log.Printf("Migrated %d access keys of project '%s' to hashed lookups", migrated, name)
+		}
+	}
+
+	return nil
+}
+
 func (a *AccessService) GetMaskedKey(key string) string {
 	return key[:8] + "****" + key[len(key)-4:]
 }
@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/app/access/dto"
+	"github.com/raw-leak/configleam/internal/app/access/keys"
+	"github.com/raw-leak/configleam/internal/app/access/repository"
+	"github.com/raw-leak/configleam/internal/app/access/service"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/stretchr/testify/suite"
+)
+
+// memoryRepository stores the access-keys under their keyed hash, the way the Redis and etcd repositories do
+type memoryRepository struct {
+	keys map[string]repository.AccessKey
+}
+
+func (m *memoryRepository) AccessKeyID(_ context.Context, key string) (string, error) {
+	mac := hmac.New(sha256.New, []byte("lookup-key"))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (m *memoryRepository) StoreAccessKey(ctx context.Context, accessKey repository.AccessKey) error {
+	id, _ := m.AccessKeyID(ctx, accessKey.Key)
+	m.keys[id] = accessKey
+	return nil
+}
+
+func (m *memoryRepository) RemoveKeys(_ context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(m.keys, id)
+	}
+	return nil
+}
+
+func (m *memoryRepository) GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error) {
+	id, _ := m.AccessKeyID(ctx, key)
+	accessKey, ok := m.keys[id]
+	if !ok {
+		return nil, false, nil
+	}
+	return &accessKey.Perms, true, nil
+}
+
+func (m *memoryRepository) PaginateAccessKeys(_ context.Context, page int, size int) (*repository.PaginatedAccessKeys, error) {
+	items := []repository.AccessKeyMetadata{}
+	for id, accessKey := range m.keys {
+		metadata := accessKey.Metadata
+		metadata.Key = id
+		items = append(items, metadata)
+	}
+	return &repository.PaginatedAccessKeys{Page: page, Size: size, Items: items, Total: len(items), Pages: 1}, nil
+}
+
+func (m *memoryRepository) ReencryptAccessKeys(_ context.Context) (int, error) { return 0, nil }
+func (m *memoryRepository) MigrateAccessKeys(_ context.Context) (int, error)   { return 0, nil }
+
+type AccessServiceSuite struct {
+	suite.Suite
+	repository *memoryRepository
+	service    *service.AccessService
+}
+
+func TestAccessServiceSuite(t *testing.T) {
+	suite.Run(t, new(AccessServiceSuite))
+}
+
+func (suite *AccessServiceSuite) SetupTest() {
+	suite.repository = &memoryRepository{keys: map[string]repository.AccessKey{}}
+	suite.service = service.New(keys.New(), permissions.New(), suite.repository)
+}
+
+func (suite *AccessServiceSuite) generate() string {
+	created, err := suite.service.GenerateAccessKey(context.Background(), dto.AccessKeyPermissionsDto{Name: "ci", GlobalAdmin: true})
+	suite.Require().NoError(err)
+	return created.AccessKey
+}
+
+func (suite *AccessServiceSuite) TestDeleteAccessKeysByListedID() {
+	ctx := context.Background()
+	key := suite.generate()
+
+	// the plaintext key is not what the access-keys are stored under
+	suite.Require().NoError(suite.service.DeleteAccessKeys(ctx, []string{key}))
+	_, ok, _ := suite.service.GetAccessKeyPermissions(ctx, key)
+	suite.True(ok)
+
+	listed, err := suite.service.PaginateAccessKeys(ctx, 1, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed.Items, 1)
+
+	suite.Require().NoError(suite.service.DeleteAccessKeys(ctx, []string{listed.Items[0].Key}))
+	_, ok, _ = suite.service.GetAccessKeyPermissions(ctx, key)
+	suite.False(ok)
+}
+
+func (suite *AccessServiceSuite) TestDeleteAccessKeysByPlaintext() {
+	ctx := context.Background()
+	key, other := suite.generate(), suite.generate()
+
+	suite.Require().NoError(suite.service.DeleteAccessKeysByPlaintext(ctx, []string{key}))
+
+	_, ok, _ := suite.service.GetAccessKeyPermissions(ctx, key)
+	suite.False(ok)
+	_, ok, _ = suite.service.GetAccessKeyPermissions(ctx, other)
+	suite.True(ok)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/raw-leak/configleam/internal/app/backup/service"
+)
+
+// MaxArchiveSize is the largest archive, in bytes, accepted by the import endpoint
+const MaxArchiveSize = 256 << 20
+
+type BackupService interface {
+	Export(ctx context.Context) (*service.Archive, error)
+	Import(ctx context.Context, archive *service.Archive, opts service.ImportOptions) (*service.ImportReport, error)
+}
+
+type BackupEndpoints struct {
+	service BackupService
+}
+
+func New(s BackupService) *BackupEndpoints {
+	return &BackupEndpoints{s}
+}
+
+func (e BackupEndpoints) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	archive, err := e.service.Export(r.Context())
+	if err != nil {
+		log.Printf("Error exporting archive: %v", err)
+		http.Error(w, "Error exporting archive", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"configleam-%s.json\"", archive.CreatedAt.Format("20060102T150405Z")))
+	err = json.NewEncoder(w).Encode(archive)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (e BackupEndpoints) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	opts := service.ImportOptions{}
+	for param, value := range map[string]*bool{"dryRun": &opts.DryRun, "overrideProject": &opts.OverrideProject} {
+		if raw := query.Get(param); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid '%s' query parameter", param), http.StatusBadRequest)
+				return
+			}
+			*value = parsed
+		}
+	}
+
+	archive := &service.Archive{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxArchiveSize)).Decode(archive)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Archive is larger than %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := e.service.Import(r.Context(), archive, opts)
+	if err != nil {
+		var invalidErr service.InvalidArchiveError
+		if errors.As(err, &invalidErr) {
+			http.Error(w, invalidErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Error importing archive: %v", err)
+		http.Error(w, "Error importing archive", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
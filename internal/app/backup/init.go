@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"context"
+
+	"github.com/raw-leak/configleam/config"
+	accessRepository "github.com/raw-leak/configleam/internal/app/access/repository"
+	"github.com/raw-leak/configleam/internal/app/backup/controller"
+	"github.com/raw-leak/configleam/internal/app/backup/service"
+	configRepository "github.com/raw-leak/configleam/internal/app/configuration/repository"
+	secretsRepository "github.com/raw-leak/configleam/internal/app/secrets/repository"
+)
+
+type Encryptor interface {
+	Encrypt(ctx context.Context, b []byte) ([]byte, error)
+	Decrypt(ctx context.Context, b []byte) ([]byte, error)
+	Rewrap(ctx context.Context, b []byte) ([]byte, bool, error)
+	Hash(ctx context.Context, b []byte) ([]byte, error)
+}
+
+type BackupSet struct {
+	*service.BackupService
+	*controller.BackupEndpoints
+}
+
+func Init(ctx context.Context, cfg *config.Config, encryptor Encryptor) (*BackupSet, error) {
+	configRepo, err := configRepository.New(ctx, configRepository.RepositoryConfig{
+		RedisAddrs:    cfg.RedisAddrs,
+		RedisUsername: cfg.RedisUsername,
+		RedisPassword: cfg.RedisPassword,
+		RedisTLS:      bool(cfg.RedisTls),
+		RedisTLSFiles: cfg.RedisTlsFiles(),
+
+		EtcdAddrs:    cfg.EtcdAddrs,
+		EtcdUsername: cfg.EtcdUsername,
+		EtcdPassword: cfg.EtcdPassword,
+		EtcdTLS:      bool(cfg.EtcdTls),
+		EtcdTLSFiles: cfg.EtcdTlsFiles(),
+
+		Namespace: cfg.StorageNamespace(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secretsRepo, err := secretsRepository.New(ctx, secretsRepository.RepositoryConfig{
+		RedisAddrs:    cfg.RedisAddrs,
+		RedisUsername: cfg.RedisUsername,
+		RedisPassword: cfg.RedisPassword,
+		RedisTLS:      bool(cfg.RedisTls),
+		RedisTLSFiles: cfg.RedisTlsFiles(),
+
+		EtcdAddrs:    cfg.EtcdAddrs,
+		EtcdUsername: cfg.EtcdUsername,
+		EtcdPassword: cfg.EtcdPassword,
+		EtcdTLS:      bool(cfg.EtcdTls),
+		EtcdTLSFiles: cfg.EtcdTlsFiles(),
+
+		Namespace: cfg.StorageNamespace(),
+	}, encryptor)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRepo, err := accessRepository.New(ctx, accessRepository.RepositoryConfig{
+		RedisAddrs:    cfg.RedisAddrs,
+		RedisUsername: cfg.RedisUsername,
+		RedisPassword: cfg.RedisPassword,
+		RedisTLS:      bool(cfg.RedisTls),
+		RedisTLSFiles: cfg.RedisTlsFiles(),
+
+		EtcdAddrs:    cfg.EtcdAddrs,
+		EtcdUsername: cfg.EtcdUsername,
+		EtcdPassword: cfg.EtcdPassword,
+		EtcdTLS:      bool(cfg.EtcdTls),
+		EtcdTLSFiles: cfg.EtcdTlsFiles(),
+
+		Namespace: cfg.StorageNamespace(),
+	}, encryptor)
+	if err != nil {
+		return nil, err
+	}
+
+	service := service.New(configRepo, secretsRepo, accessRepo, encryptor)
+
+	endpoints := controller.New(service)
+
+	return &BackupSet{
+		service,
+		endpoints,
+	}, nil
+}
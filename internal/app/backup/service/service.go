@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	accessRepository "github.com/raw-leak/configleam/internal/app/access/repository"
+	configRepository "github.com/raw-leak/configleam/internal/app/configuration/repository"
+	secretsRepository "github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/pkg/encryptor"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// lookupKeyFingerprintLabel is hashed with the lookup key into the fingerprint recorded in the archives
+const lookupKeyFingerprintLabel = "configleam archive lookup-key fingerprint"
+
+const (
+	// ArchiveVersion is the format version of the archives produced by Export, version 2 adding the history and
+	// the metadata of the secrets
+	ArchiveVersion = 2
+	// MinArchiveVersion is the oldest format version that can still be imported
+	MinArchiveVersion = 1
+)
+
+type ConfigurationRepository interface {
+	GetAllEnvs(ctx context.Context) ([]configRepository.EnvParams, error)
+	AddEnv(ctx context.Context, env string, params configRepository.EnvParams) error
+	ExportConfig(ctx context.Context) ([]configRepository.EnvConfig, error)
+	ImportConfig(ctx context.Context, configs []configRepository.EnvConfig) error
+}
+
+type SecretsRepository interface {
+	ExportSecrets(ctx context.Context) ([]secretsRepository.SecretRecord, error)
+	ImportSecrets(ctx context.Context, secrets []secretsRepository.SecretRecord) error
+	ExportSecretHistory(ctx context.Context) ([]secretsRepository.SecretHistoryRecord, error)
+	ImportSecretHistory(ctx context.Context, history []secretsRepository.SecretHistoryRecord) error
+	ExportSecretInfo(ctx context.Context) ([]secretsRepository.SecretInfoEntry, error)
+	ImportSecretInfo(ctx context.Context, info []secretsRepository.SecretInfoEntry) error
+}
+
+// Hasher hashes with the lookup key the access keys are stored by
+type Hasher interface {
+	Hash(ctx context.Context, b []byte) ([]byte, error)
+}
+
+type AccessRepository interface {
+	ExportAccessKeys(ctx context.Context) ([]accessRepository.AccessKeyRecord, error)
+	ImportAccessKeys(ctx context.Context, records []accessRepository.AccessKeyRecord) error
+}
+
+// Archive is a portable snapshot of the configleam state of a project. Secrets and access-keys permissions
+// are kept encrypted, so an archive can only be restored by an installation sharing the same encryption key.
+// Access keys are kept under their hash, so it must share the same lookup key as well.
+type Archive struct {
+	Version   int       `json:"version"`
+	Project   string    `json:"project"`
+	CreatedAt time.Time `json:"createdAt"`
+	Checksum  string    `json:"checksum"`
+	// LookupKey is the fingerprint of the lookup key the access keys were hashed with, missing from older archives
+	LookupKey string      `json:"lookupKey,omitempty"`
+	Data      ArchiveData `json:"data"`
+}
+
+type ArchiveData struct {
+	Envs       []configRepository.EnvParams       `json:"envs"`
+	Config     []configRepository.EnvConfig       `json:"config"`
+	Secrets    []secretsRepository.SecretRecord   `json:"secrets"`
+	AccessKeys []accessRepository.AccessKeyRecord `json:"accessKeys"`
+	// SecretHistory and SecretInfo are left out of the archives of version 1, so their checksum is kept
+	SecretHistory []secretsRepository.SecretHistoryRecord `json:"secretHistory,omitempty"`
+	SecretInfo    []secretsRepository.SecretInfoEntry     `json:"secretInfo,omitempty"`
+}
+
+// ImportOptions tell how an archive is imported
+type ImportOptions struct {
+	// DryRun only verifies the archive, nothing is written
+	DryRun bool
+	// OverrideProject imports the archive into the project carried by the context even if it was exported from another one
+	OverrideProject bool
+}
+
+// ImportReport summarizes what has been (or would be, on dry-run) restored from an archive
+type ImportReport struct {
+	DryRun bool `json:"dryRun"`
+	// Project is the project the archive is restored into
+	Project    string `json:"project"`
+	Version    int    `json:"version"`
+	Checksum   string `json:"checksum"`
+	Envs       int    `json:"envs"`
+	Config     int    `json:"config"`
+	Secrets    int    `json:"secrets"`
+	AccessKeys int    `json:"accessKeys"`
+	// SecretHistory is how many secrets have their kept versions restored
+	SecretHistory int `json:"secretHistory"`
+	// SecretInfo is how many secrets have their metadata restored
+	SecretInfo int `json:"secretInfo"`
+}
+
+// InvalidArchiveError is returned when an archive can not be restored because it is malformed or corrupted
+type InvalidArchiveError struct {
+	Reason string
+}
+
+func (e InvalidArchiveError) Error() string {
+	return fmt.Sprintf("invalid archive: %s", e.Reason)
+}
+
+type BackupService struct {
+	configuration ConfigurationRepository
+	secrets       SecretsRepository
+	access        AccessRepository
+	hasher        Hasher
+}
+
+func New(configuration ConfigurationRepository, secrets SecretsRepository, access AccessRepository, hasher Hasher) *BackupService {
+	return &BackupService{
+		configuration: configuration,
+		secrets:       secrets,
+		access:        access,
+		hasher:        hasher,
+	}
+}
+
+// Export collects the envs metadata, configuration, secrets and access-keys of the project carried by the context into a checksummed archive
+func (s *BackupService) Export(ctx context.Context) (*Archive, error) {
+	envs, err := s.configuration.GetAllEnvs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting environments: %v", err)
+	}
+
+	config, err := s.configuration.ExportConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting configuration: %v", err)
+	}
+
+	secrets, err := s.secrets.ExportSecrets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting secrets: %v", err)
+	}
+
+	history, err := s.secrets.ExportSecretHistory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting secret history: %v", err)
+	}
+
+	info, err := s.secrets.ExportSecretInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting secret metadata: %v", err)
+	}
+
+	accessKeys, err := s.access.ExportAccessKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting access keys: %v", err)
+	}
+
+	data := ArchiveData{Envs: envs, Config: config, Secrets: secrets, AccessKeys: accessKeys, SecretHistory: history, SecretInfo: info}
+
+	checksum, err := Checksum(data)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupKey, err := LookupKeyFingerprint(ctx, s.hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		Version:   ArchiveVersion,
+		Project:   project.FromContext(ctx),
+		CreatedAt: time.Now().UTC(),
+		Checksum:  checksum,
+		LookupKey: lookupKey,
+		Data:      data,
+	}, nil
+}
+
+// Import verifies the archive and restores its content into the project carried by the context, overwriting the
+// existing keys with the same name. An archive exported from another project is refused unless the options override it.
+// On dry-run the archive is only verified and nothing is written.
+//
+// The content is written one kind after the other and a failure partway is not rolled back: the keys already written
+// are kept, and importing the same archive again completes the restore.
+func (s *BackupService) Import(ctx context.Context, archive *Archive, opts ImportOptions) (report *ImportReport, err error) {
+	err = Verify(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	target := project.FromContext(ctx)
+	if !opts.OverrideProject {
+		err = VerifyProject(archive, target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = VerifyLookupKey(ctx, archive, s.hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	report = &ImportReport{
+		DryRun:        opts.DryRun,
+		Project:       target,
+		Version:       archive.Version,
+		Checksum:      archive.Checksum,
+		Envs:          len(archive.Data.Envs),
+		Config:        len(archive.Data.Config),
+		Secrets:       len(archive.Data.Secrets),
+		AccessKeys:    len(archive.Data.AccessKeys),
+		SecretHistory: len(archive.Data.SecretHistory),
+		SecretInfo:    len(archive.Data.SecretInfo),
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	defer func() {
+		if err != nil {
+			log.Printf("Import of archive '%s' failed partway, the content already written is kept: %v", archive.Checksum, err)
+		}
+	}()
+
+	for _, env := range archive.Data.Envs {
+		err = s.configuration.AddEnv(ctx, env.Name, env)
+		if err != nil {
+			return nil, fmt.Errorf("error importing '%s' environment: %v", env.Name, err)
+		}
+	}
+
+	err = s.configuration.ImportConfig(ctx, archive.Data.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error importing configuration: %v", err)
+	}
+
+	err = s.secrets.ImportSecrets(ctx, archive.Data.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("error importing secrets: %v", err)
+	}
+
+	err = s.secrets.ImportSecretHistory(ctx, archive.Data.SecretHistory)
+	if err != nil {
+		return nil, fmt.Errorf("error importing secret history: %v", err)
+	}
+
+	err = s.secrets.ImportSecretInfo(ctx, archive.Data.SecretInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error importing secret metadata: %v", err)
+	}
+
+	err = s.access.ImportAccessKeys(ctx, archive.Data.AccessKeys)
+	if err != nil {
+		return nil, fmt.Errorf("error importing access keys: %v", err)
+	}
+
+	log.Printf("Imported archive '%s': %d envs, %d configs, %d secrets, %d secret histories, %d secret metadata and %d access keys", archive.Checksum, report.Envs, report.Config, report.Secrets, report.SecretHistory, report.SecretInfo, report.AccessKeys)
+
+	return report, nil
+}
+
+// Verify checks the archive version is supported and that its content matches the checksum
+func Verify(archive *Archive) error {
+	if archive == nil {
+		return InvalidArchiveError{Reason: "archive is empty"}
+	}
+
+	if archive.Version < MinArchiveVersion || archive.Version > ArchiveVersion {
+		return InvalidArchiveError{Reason: fmt.Sprintf("unsupported version %d, expected %d to %d", archive.Version, MinArchiveVersion, ArchiveVersion)}
+	}
+
+	checksum, err := Checksum(archive.Data)
+	if err != nil {
+		return err
+	}
+
+	if checksum != archive.Checksum {
+		return InvalidArchiveError{Reason: fmt.Sprintf("checksum mismatch, expected '%s' but got '%s'", archive.Checksum, checksum)}
+	}
+
+	return nil
+}
+
+// VerifyProject checks the archive was exported from the target project, archives of the default project recording none
+func VerifyProject(archive *Archive, target string) error {
+	if archive.Project == target {
+		return nil
+	}
+
+	return InvalidArchiveError{Reason: fmt.Sprintf("exported from the %s but imported into the %s, the project must be overridden explicitly to import it there",
+		projectName(archive.Project), projectName(target))}
+}
+
+func projectName(name string) string {
+	if name == project.Default {
+		return "default project"
+	}
+	return fmt.Sprintf("'%s' project", name)
+}
+
+// VerifyLookupKey checks the archive was exported with the same lookup key as the hasher, as its access keys could
+// not be looked up otherwise. Archives exported before the fingerprint was recorded can not be checked.
+func VerifyLookupKey(ctx context.Context, archive *Archive, hasher Hasher) error {
+	if archive.LookupKey == "" {
+		log.Printf("Archive '%s' does not record the lookup key it was exported with, its access keys can only be used with the same lookup key", archive.Checksum)
+		return nil
+	}
+
+	fingerprint, err := LookupKeyFingerprint(ctx, hasher)
+	if err != nil {
+		return err
+	}
+
+	if fingerprint != archive.LookupKey {
+		return InvalidArchiveError{Reason: fmt.Sprintf("exported with another lookup key ('%s' instead of '%s'), restoring it requires the %s it was exported with",
+			archive.LookupKey, fingerprint, encryptor.LookupKeyEnv)}
+	}
+
+	return nil
+}
+
+// LookupKeyFingerprint returns the fingerprint of the lookup key of the hasher in the "hmac-sha256:<hex>" form,
+// identifying the key without revealing it
+func LookupKeyFingerprint(ctx context.Context, hasher Hasher) (string, error) {
+	sum, err := hasher.Hash(ctx, []byte(lookupKeyFingerprintLabel))
+	if err != nil {
+		return "", fmt.Errorf("error fingerprinting the lookup key: %v", err)
+	}
+
+	return "hmac-sha256:" + hex.EncodeToString(sum), nil
+}
+
+// Checksum returns the SHA-256 of the JSON encoded archive data in the "sha256:<hex>" form
+func Checksum(data ArchiveData) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling archive data: %v", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,453 @@
+package service_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	accessRepository "github.com/raw-leak/configleam/internal/app/access/repository"
+	"github.com/raw-leak/configleam/internal/app/backup/service"
+	configRepository "github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/types"
+	secretsRepository "github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockConfigurationRepository struct {
+	mock.Mock
+}
+
+func (m *MockConfigurationRepository) GetAllEnvs(ctx context.Context) ([]configRepository.EnvParams, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]configRepository.EnvParams), args.Error(1)
+}
+
+func (m *MockConfigurationRepository) AddEnv(ctx context.Context, env string, params configRepository.EnvParams) error {
+	args := m.Called(ctx, env, params)
+	return args.Error(0)
+}
+
+func (m *MockConfigurationRepository) ExportConfig(ctx context.Context) ([]configRepository.EnvConfig, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]configRepository.EnvConfig), args.Error(1)
+}
+
+func (m *MockConfigurationRepository) ImportConfig(ctx context.Context, configs []configRepository.EnvConfig) error {
+	args := m.Called(ctx, configs)
+	return args.Error(0)
+}
+
+type MockSecretsRepository struct {
+	mock.Mock
+}
+
+func (m *MockSecretsRepository) ExportSecrets(ctx context.Context) ([]secretsRepository.SecretRecord, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]secretsRepository.SecretRecord), args.Error(1)
+}
+
+func (m *MockSecretsRepository) ImportSecrets(ctx context.Context, secrets []secretsRepository.SecretRecord) error {
+	args := m.Called(ctx, secrets)
+	return args.Error(0)
+}
+
+func (m *MockSecretsRepository) ExportSecretHistory(ctx context.Context) ([]secretsRepository.SecretHistoryRecord, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]secretsRepository.SecretHistoryRecord), args.Error(1)
+}
+
+func (m *MockSecretsRepository) ImportSecretHistory(ctx context.Context, history []secretsRepository.SecretHistoryRecord) error {
+	args := m.Called(ctx, history)
+	return args.Error(0)
+}
+
+func (m *MockSecretsRepository) ExportSecretInfo(ctx context.Context) ([]secretsRepository.SecretInfoEntry, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]secretsRepository.SecretInfoEntry), args.Error(1)
+}
+
+func (m *MockSecretsRepository) ImportSecretInfo(ctx context.Context, info []secretsRepository.SecretInfoEntry) error {
+	args := m.Called(ctx, info)
+	return args.Error(0)
+}
+
+type MockAccessRepository struct {
+	mock.Mock
+}
+
+func (m *MockAccessRepository) ExportAccessKeys(ctx context.Context) ([]accessRepository.AccessKeyRecord, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]accessRepository.AccessKeyRecord), args.Error(1)
+}
+
+func (m *MockAccessRepository) ImportAccessKeys(ctx context.Context, records []accessRepository.AccessKeyRecord) error {
+	args := m.Called(ctx, records)
+	return args.Error(0)
+}
+
+// lookupHasher hashes with a lookup key the way the encryptor does
+type lookupHasher string
+
+func (h lookupHasher) Hash(_ context.Context, b []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, []byte(h))
+	mac.Write(b)
+	return mac.Sum(nil), nil
+}
+
+type BackupServiceSuite struct {
+	suite.Suite
+
+	configuration *MockConfigurationRepository
+	secrets       *MockSecretsRepository
+	access        *MockAccessRepository
+	service       *service.BackupService
+}
+
+func TestBackupServiceSuite(t *testing.T) {
+	suite.Run(t, new(BackupServiceSuite))
+}
+
+func (suite *BackupServiceSuite) SetupTest() {
+	suite.configuration = &MockConfigurationRepository{}
+	suite.secrets = &MockSecretsRepository{}
+	suite.access = &MockAccessRepository{}
+	suite.service = service.New(suite.configuration, suite.secrets, suite.access, lookupHasher("lookup-key"))
+}
+
+func (suite *BackupServiceSuite) data() service.ArchiveData {
+	return service.ArchiveData{
+		Envs: []configRepository.EnvParams{
+			{Name: "develop", Version: "v1.0.0"},
+			{Name: "develop-clone", Version: "v1.0.0", Clone: true, Original: "develop"},
+		},
+		Config: []configRepository.EnvConfig{
+			{
+				Repo:    "configleam-config",
+				Env:     "develop",
+				Globals: map[string]interface{}{"region": "eu-west-1", "replicas": float64(2)},
+				Groups:  map[string]types.GroupConfig{"billing": {Local: map[string]interface{}{"host": "localhost"}, Global: []string{"region"}}},
+			},
+		},
+		Secrets: []secretsRepository.SecretRecord{
+			{Env: "develop", Key: "database", Value: []byte("encrypted")},
+		},
+		AccessKeys: []accessRepository.AccessKeyRecord{
+			{ID: "a2V5", Perms: []byte("encrypted"), Metadata: accessRepository.AccessKeyMetadata{Name: "ci", CreationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+		SecretHistory: []secretsRepository.SecretHistoryRecord{
+			{Env: "develop", Key: "database", History: json.RawMessage(`[{"version":2,"action":"set","changedBy":"ci","changedAt":"2024-01-02T00:00:00Z","value":"ZW5jcnlwdGVk"},{"version":1,"action":"set","changedBy":"ci","changedAt":"2024-01-01T00:00:00Z","value":"ZW5jcnlwdGVk"}]`)},
+			{Env: "develop", Key: "removed", History: json.RawMessage(`[{"version":1,"action":"delete","changedBy":"ci","changedAt":"2024-01-03T00:00:00Z","deleted":true}]`)},
+		},
+		SecretInfo: []secretsRepository.SecretInfoEntry{
+			{
+				Env: "develop", Key: "database",
+				Info:     json.RawMessage(`{"owner":"platform","expiresAt":"2024-02-01T00:00:00Z"}`),
+				Reminded: &secretsRepository.SecretReminder{Status: "expiring", DueAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+}
+
+func (suite *BackupServiceSuite) TestExport() {
+	ctx := context.Background()
+	data := suite.data()
+
+	suite.configuration.On("GetAllEnvs", ctx).Return(data.Envs, nil)
+	suite.configuration.On("ExportConfig", ctx).Return(data.Config, nil)
+	suite.secrets.On("ExportSecrets", ctx).Return(data.Secrets, nil)
+	suite.secrets.On("ExportSecretHistory", ctx).Return(data.SecretHistory, nil)
+	suite.secrets.On("ExportSecretInfo", ctx).Return(data.SecretInfo, nil)
+	suite.access.On("ExportAccessKeys", ctx).Return(data.AccessKeys, nil)
+
+	archive, err := suite.service.Export(ctx)
+	suite.Require().NoError(err)
+
+	suite.Equal(service.ArchiveVersion, archive.Version)
+	suite.Equal(data, archive.Data)
+	suite.NoError(service.Verify(archive))
+
+	fingerprint, err := service.LookupKeyFingerprint(ctx, lookupHasher("lookup-key"))
+	suite.Require().NoError(err)
+	suite.Equal(fingerprint, archive.LookupKey)
+	suite.NotContains(archive.LookupKey, "lookup-key")
+
+	// the checksum must survive the archive being written and read back
+	raw, err := json.Marshal(archive)
+	suite.Require().NoError(err)
+
+	decoded := &service.Archive{}
+	suite.Require().NoError(json.Unmarshal(raw, decoded))
+	suite.NoError(service.Verify(decoded))
+}
+
+func (suite *BackupServiceSuite) TestExportImportKeepsSecretHistoryAndInfo() {
+	ctx := context.Background()
+	data := suite.data()
+
+	suite.configuration.On("GetAllEnvs", ctx).Return(data.Envs, nil)
+	suite.configuration.On("ExportConfig", ctx).Return(data.Config, nil)
+	suite.secrets.On("ExportSecrets", ctx).Return(data.Secrets, nil)
+	suite.secrets.On("ExportSecretHistory", ctx).Return(data.SecretHistory, nil)
+	suite.secrets.On("ExportSecretInfo", ctx).Return(data.SecretInfo, nil)
+	suite.access.On("ExportAccessKeys", ctx).Return(data.AccessKeys, nil)
+
+	exported, err := suite.service.Export(ctx)
+	suite.Require().NoError(err)
+
+	raw, err := json.MarshalIndent(exported, "", "  ")
+	suite.Require().NoError(err)
+
+	archive := &service.Archive{}
+	suite.Require().NoError(json.Unmarshal(raw, archive))
+
+	suite.configuration.On("AddEnv", ctx, mock.Anything, mock.Anything).Return(nil)
+	suite.configuration.On("ImportConfig", ctx, mock.Anything).Return(nil)
+	suite.secrets.On("ImportSecrets", ctx, mock.Anything).Return(nil)
+	suite.secrets.On("ImportSecretHistory", ctx, mock.Anything).Return(nil)
+	suite.secrets.On("ImportSecretInfo", ctx, mock.Anything).Return(nil)
+	suite.access.On("ImportAccessKeys", ctx, mock.Anything).Return(nil)
+
+	report, err := suite.service.Import(ctx, archive, service.ImportOptions{})
+	suite.Require().NoError(err)
+	suite.Equal(2, report.SecretHistory)
+	suite.Equal(1, report.SecretInfo)
+
+	// the versions of the secrets, deleted ones included, are restored as they were exported
+	var imported []secretsRepository.SecretHistoryRecord
+	for _, call := range suite.secrets.Calls {
+		if call.Method == "ImportSecretHistory" {
+			imported = call.Arguments.Get(1).([]secretsRepository.SecretHistoryRecord)
+		}
+	}
+	suite.Require().Len(imported, len(data.SecretHistory))
+	for i, record := range data.SecretHistory {
+		suite.Equal(record.Env, imported[i].Env)
+		suite.Equal(record.Key, imported[i].Key)
+		suite.JSONEq(string(record.History), string(imported[i].History))
+	}
+
+	// the metadata of the secrets is restored along with the last reminder sent, which is not sent again
+	var info []secretsRepository.SecretInfoEntry
+	for _, call := range suite.secrets.Calls {
+		if call.Method == "ImportSecretInfo" {
+			info = call.Arguments.Get(1).([]secretsRepository.SecretInfoEntry)
+		}
+	}
+	suite.Require().Len(info, len(data.SecretInfo))
+	suite.JSONEq(string(data.SecretInfo[0].Info), string(info[0].Info))
+	suite.Equal(data.SecretInfo[0].Reminded, info[0].Reminded)
+}
+
+func (suite *BackupServiceSuite) TestExportError() {
+	ctx := context.Background()
+
+	suite.configuration.On("GetAllEnvs", ctx).Return([]configRepository.EnvParams{}, nil)
+	suite.configuration.On("ExportConfig", ctx).Return([]configRepository.EnvConfig{}, nil)
+	suite.secrets.On("ExportSecrets", ctx).Return([]secretsRepository.SecretRecord{}, errors.New("connection refused"))
+
+	_, err := suite.service.Export(ctx)
+	suite.Error(err)
+	suite.access.AssertNotCalled(suite.T(), "ExportAccessKeys", ctx)
+}
+
+func (suite *BackupServiceSuite) TestImport() {
+	testCases := []struct {
+		name          string
+		archive       func() *service.Archive
+		dryRun        bool
+		expectWrites  bool
+		expectedError bool
+	}{
+		{
+			name: "Imports a valid archive",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				return &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, Data: data}
+			},
+			expectWrites: true,
+		},
+		{
+			name: "Only verifies a valid archive on dry-run",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				return &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, Data: data}
+			},
+			dryRun: true,
+		},
+		{
+			name: "Rejects an archive with a tampered content",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				data.Secrets[0].Value = []byte("tampered")
+				return &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, Data: data}
+			},
+			expectedError: true,
+		},
+		{
+			name: "Imports an archive of version 1, without secret history nor metadata",
+			archive: func() *service.Archive {
+				data := suite.data()
+				data.SecretHistory, data.SecretInfo = nil, nil
+				checksum, _ := service.Checksum(data)
+				return &service.Archive{Version: 1, Checksum: checksum, Data: data}
+			},
+			expectWrites: true,
+		},
+		{
+			name: "Imports an archive exported with the same lookup key",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				fingerprint, _ := service.LookupKeyFingerprint(context.Background(), lookupHasher("lookup-key"))
+				return &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, LookupKey: fingerprint, Data: data}
+			},
+			expectWrites: true,
+		},
+		{
+			name: "Rejects an archive exported with another lookup key",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				fingerprint, _ := service.LookupKeyFingerprint(context.Background(), lookupHasher("another-lookup-key"))
+				return &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, LookupKey: fingerprint, Data: data}
+			},
+			expectedError: true,
+		},
+		{
+			name: "Rejects an archive exported with another lookup key on dry-run",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				fingerprint, _ := service.LookupKeyFingerprint(context.Background(), lookupHasher("another-lookup-key"))
+				return &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, LookupKey: fingerprint, Data: data}
+			},
+			dryRun:        true,
+			expectedError: true,
+		},
+		{
+			name: "Rejects an archive with an unsupported version",
+			archive: func() *service.Archive {
+				data := suite.data()
+				checksum, _ := service.Checksum(data)
+				return &service.Archive{Version: service.ArchiveVersion + 1, Checksum: checksum, Data: data}
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.SetupTest()
+			ctx := context.Background()
+			archive := tc.archive()
+
+			suite.configuration.On("AddEnv", ctx, mock.Anything, mock.Anything).Return(nil)
+			suite.configuration.On("ImportConfig", ctx, archive.Data.Config).Return(nil)
+			suite.secrets.On("ImportSecrets", ctx, archive.Data.Secrets).Return(nil)
+			suite.secrets.On("ImportSecretHistory", ctx, archive.Data.SecretHistory).Return(nil)
+			suite.secrets.On("ImportSecretInfo", ctx, archive.Data.SecretInfo).Return(nil)
+			suite.access.On("ImportAccessKeys", ctx, archive.Data.AccessKeys).Return(nil)
+
+			report, err := suite.service.Import(ctx, archive, service.ImportOptions{DryRun: tc.dryRun})
+
+			if tc.expectedError {
+				suite.Error(err)
+				suite.ErrorAs(err, &service.InvalidArchiveError{})
+			} else {
+				suite.Require().NoError(err)
+				suite.Equal(tc.dryRun, report.DryRun)
+				suite.Equal(len(archive.Data.Envs), report.Envs)
+				suite.Equal(len(archive.Data.Secrets), report.Secrets)
+			}
+
+			if tc.expectWrites {
+				for _, env := range archive.Data.Envs {
+					suite.configuration.AssertCalled(suite.T(), "AddEnv", ctx, env.Name, env)
+				}
+				suite.configuration.AssertCalled(suite.T(), "ImportConfig", ctx, archive.Data.Config)
+				suite.secrets.AssertCalled(suite.T(), "ImportSecrets", ctx, archive.Data.Secrets)
+				suite.secrets.AssertCalled(suite.T(), "ImportSecretHistory", ctx, archive.Data.SecretHistory)
+				suite.secrets.AssertCalled(suite.T(), "ImportSecretInfo", ctx, archive.Data.SecretInfo)
+				suite.access.AssertCalled(suite.T(), "ImportAccessKeys", ctx, archive.Data.AccessKeys)
+			} else {
+				suite.configuration.AssertNotCalled(suite.T(), "AddEnv", mock.Anything, mock.Anything, mock.Anything)
+				suite.secrets.AssertNotCalled(suite.T(), "ImportSecrets", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func (suite *BackupServiceSuite) TestImportProject() {
+	data := suite.data()
+	checksum, _ := service.Checksum(data)
+
+	testCases := []struct {
+		name          string
+		archive       string
+		target        string
+		opts          service.ImportOptions
+		expectedError bool
+	}{
+		{name: "Imports an archive into the project it was exported from", archive: "billing", target: "billing"},
+		{name: "Rejects an archive of another project", archive: "billing", target: "orders", expectedError: true},
+		{name: "Rejects an archive of another project on dry-run", archive: "billing", target: "orders", opts: service.ImportOptions{DryRun: true}, expectedError: true},
+		{name: "Rejects an archive of a project into the default project", archive: "billing", target: project.Default, expectedError: true},
+		{name: "Rejects an archive of the default project into a project", archive: project.Default, target: "billing", expectedError: true},
+		{name: "Imports an archive of another project when overridden", archive: "billing", target: "orders", opts: service.ImportOptions{OverrideProject: true}},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.SetupTest()
+			ctx := project.WithProject(context.Background(), tc.target)
+			archive := &service.Archive{Version: service.ArchiveVersion, Project: tc.archive, Checksum: checksum, Data: data}
+
+			suite.configuration.On("AddEnv", ctx, mock.Anything, mock.Anything).Return(nil)
+			suite.configuration.On("ImportConfig", ctx, mock.Anything).Return(nil)
+			suite.secrets.On("ImportSecrets", ctx, mock.Anything).Return(nil)
+			suite.secrets.On("ImportSecretHistory", ctx, mock.Anything).Return(nil)
+			suite.secrets.On("ImportSecretInfo", ctx, mock.Anything).Return(nil)
+			suite.access.On("ImportAccessKeys", ctx, mock.Anything).Return(nil)
+
+			report, err := suite.service.Import(ctx, archive, tc.opts)
+
+			if tc.expectedError {
+				suite.ErrorAs(err, &service.InvalidArchiveError{})
+				suite.configuration.AssertNotCalled(suite.T(), "AddEnv", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Equal(tc.target, report.Project)
+			suite.secrets.AssertCalled(suite.T(), "ImportSecrets", ctx, data.Secrets)
+		})
+	}
+}
+
+func (suite *BackupServiceSuite) TestImportFailurePartway() {
+	ctx := context.Background()
+	data := suite.data()
+	checksum, _ := service.Checksum(data)
+	archive := &service.Archive{Version: service.ArchiveVersion, Checksum: checksum, Data: data}
+
+	suite.configuration.On("AddEnv", ctx, mock.Anything, mock.Anything).Return(nil)
+	suite.configuration.On("ImportConfig", ctx, mock.Anything).Return(nil)
+	suite.secrets.On("ImportSecrets", ctx, mock.Anything).Return(errors.New("connection reset"))
+
+	_, err := suite.service.Import(ctx, archive, service.ImportOptions{})
+	suite.ErrorContains(err, "error importing secrets")
+
+	// what has been written before the failure is kept, and nothing is written after it
+	suite.configuration.AssertCalled(suite.T(), "ImportConfig", ctx, data.Config)
+	suite.secrets.AssertNotCalled(suite.T(), "ImportSecretHistory", mock.Anything, mock.Anything)
+	suite.access.AssertNotCalled(suite.T(), "ImportAccessKeys", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+)
+
+// configETag builds a strong entity tag from the version the configuration belongs to and a digest of the
+// requested format, the request params, such as groups and globals, and the encoded body, the body being part of it
+// as secrets are not versioned. The order of the values within each param does not change the tag.
+func configETag(version string, format formatter.Format, params [][]string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", format)
+
+	for _, values := range params {
+		sorted := append([]string{}, values...)
+		sort.Strings(sorted)
+		fmt.Fprintf(h, "%s\x00", strings.Join(sorted, ","))
+	}
+
+	h.Write(body)
+
+	return fmt.Sprintf(`"%s-%s"`, version, hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+// matchesETag reports whether the 'If-None-Match' header holds the entity tag or '*', weak tags compared as strong ones
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
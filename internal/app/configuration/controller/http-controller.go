@@ -3,27 +3,54 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/app/configuration/service"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+)
+
+const (
+	// SkippedKeysHeader lists the configuration keys that could not be represented in the requested format
+	SkippedKeysHeader = "X-Configleam-Skipped-Keys"
+	// VersionHeader is the version of the environment the returned configuration belongs to
+	VersionHeader = "X-Configleam-Version"
 )
 
 type Service interface {
 	DeleteConfig(ctx context.Context, env string) error
 	CloneConfig(ctx context.Context, env, newEnv string, updateGlobals map[string]interface{}) error
-	ReadConfig(ctx context.Context, env string, groups, globals []string) (map[string]interface{}, error)
+	ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error)
+	ReadEnvConfig(ctx context.Context, env, version string) (map[string]interface{}, string, error)
+	ListEnvs(ctx context.Context) ([]repository.EnvParams, error)
+	ListConfigKeys(ctx context.Context, env, version string) (repository.ConfigKeys, string, error)
+	Sync(ctx context.Context) (service.SyncStatus, error)
+	SyncStatus(ctx context.Context) (service.SyncStatus, error)
+}
+
+type Formatter interface {
+	Negotiate(format, accept string) (formatter.Format, error)
+	Format(format formatter.Format, cfg map[string]interface{}) (*formatter.Output, error)
 }
 
 type ConfigurationEndpoints struct {
-	service Service
+	service   Service
+	formatter Formatter
 }
 
-func New(s Service) *ConfigurationEndpoints {
-	return &ConfigurationEndpoints{s}
+func New(s Service, f Formatter) *ConfigurationEndpoints {
+	return &ConfigurationEndpoints{s, f}
 }
 
 func (e ConfigurationEndpoints) DeleteConfigHandler(w http.ResponseWriter, r *http.Request) {
-	query, ctx := r.URL.Query(), context.Background()
+	query, ctx := r.URL.Query(), r.Context()
 
 	env := query.Get("env")
 
@@ -44,7 +71,7 @@ func (e ConfigurationEndpoints) DeleteConfigHandler(w http.ResponseWriter, r *ht
 }
 
 func (e ConfigurationEndpoints) CloneConfigHandler(w http.ResponseWriter, r *http.Request) {
-	query, ctx := r.URL.Query(), context.Background()
+	query, ctx := r.URL.Query(), r.Context()
 
 	env := query.Get("env")
 	newEnv := query.Get("newEnv")
@@ -77,20 +104,205 @@ func (e ConfigurationEndpoints) ReadConfigHandler(w http.ResponseWriter, r *http
 
 	groups := query["groups"]
 	globals := query["globals"]
+	selectors := query["select"]
 	env := query.Get("env")
+	version := query.Get("version")
 
-	config, err := e.service.ReadConfig(r.Context(), env, groups, globals)
+	fields, err := selector.ParseAll(selectors)
 	if err != nil {
-		log.Println("Error building configuration response:", err.Error())
-		http.Error(w, "Error building configuration response", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e.serveConfig(w, r, [][]string{groups, globals, selectors}, func() (map[string]interface{}, string, error) {
+		return e.service.ReadConfig(r.Context(), env, version, groups, globals, fields)
+	})
+}
+
+// ReadEnvConfigHandler returns the whole environment, every group resolved under "groups" and every global key under "globals"
+func (e ConfigurationEndpoints) ReadEnvConfigHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	env := query.Get("env")
+	version := query.Get("version")
+
+	e.serveConfig(w, r, nil, func() (map[string]interface{}, string, error) {
+		return e.service.ReadEnvConfig(r.Context(), env, version)
+	})
+}
+
+// ListEnvsHandler returns the environments the access key can read, with their version and the environment they were cloned from
+func (e ConfigurationEndpoints) ListEnvsHandler(w http.ResponseWriter, r *http.Request) {
+	envs, err := e.service.ListEnvs(r.Context())
+	if err != nil {
+		log.Println("Error listing environments:", err)
+		http.Error(w, "Error listing environments", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{"envs": envs}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// ListConfigKeysHandler returns the group names and global keys of the environment
+func (e ConfigurationEndpoints) ListConfigKeysHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	env := query.Get("env")
+
+	keys, version, err := e.service.ListConfigKeys(r.Context(), env, query.Get("version"))
+	if err != nil {
+		var notFound repository.ConfigVersionNotFoundError
+		if errors.As(err, &notFound) {
+			http.Error(w, notFound.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error listing configuration keys of env %s with error: %v", env, err)
+		http.Error(w, fmt.Sprintf("Error listing configuration keys of env %s", env), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(VersionHeader, version)
+	response := map[string]interface{}{"env": env, "version": version, "groups": keys.Groups, "globals": keys.Globals}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// SyncHandler synchronizes the project with its git repository and returns the outcome
+func (e ConfigurationEndpoints) SyncHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := e.service.Sync(r.Context())
+	if err != nil {
+		var unavailable service.SyncUnavailableError
+		if errors.As(err, &unavailable) {
+			http.Error(w, unavailable.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		log.Println("Error synchronizing repository:", err)
+		http.Error(w, "Error synchronizing repository", http.StatusInternalServerError)
+		return
+	}
 
-	if err := json.NewEncoder(w).Encode(config); err != nil {
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// SyncStatusHandler returns the outcome of the last synchronization of the project with its git repository
+func (e ConfigurationEndpoints) SyncStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := e.service.SyncStatus(r.Context())
+	if err != nil {
+		log.Println("Error reading synchronization status:", err)
+		http.Error(w, "Error reading synchronization status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// serveConfig reads the configuration and writes it in the negotiated format, along with its version and entity tag
+// the request params identify the configuration in the entity tag, along with the returned version and body
+func (e ConfigurationEndpoints) serveConfig(w http.ResponseWriter, r *http.Request, params [][]string, read func() (map[string]interface{}, string, error)) {
+	query := r.URL.Query()
+
+	format, err := e.formatter.Negotiate(query.Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		var unsupported formatter.UnsupportedFormatError
+		if errors.As(err, &unsupported) {
+			http.Error(w, err.Error(), http.StatusNotAcceptable)
+			return
+		}
+		log.Println("Error negotiating configuration format:", err)
+		http.Error(w, "Error negotiating configuration format", http.StatusInternalServerError)
+		return
+	}
+
+	strict, _ := strconv.ParseBool(query.Get("strict"))
+
+	config, version, err := read()
+	if err != nil {
+		var notFound repository.ConfigVersionNotFoundError
+		if errors.As(err, &notFound) {
+			http.Error(w, notFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		var unresolved secretmode.UnresolvedError
+		if errors.As(err, &unresolved) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			response := map[string]interface{}{"message": unresolved.Error(), "unresolved": unresolved.Unresolved}
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				log.Println("Error encoding response:", err)
+			}
+			return
+		}
+		log.Println("Error building configuration response:", err.Error())
+		http.Error(w, "Error building configuration response", http.StatusInternalServerError)
+		return
+	}
+
+	output, err := e.formatter.Format(format, config)
+	if err != nil {
 		log.Println("Error encoding response:", err)
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
+
+	// strict mode rejects the body before any entity tag is compared, the tags being the same with or without it
+	if len(output.Skipped) > 0 {
+		keys := make([]string, 0, len(output.Skipped))
+		for _, skipped := range output.Skipped {
+			keys = append(keys, skipped.Key)
+		}
+		w.Header().Set(SkippedKeysHeader, strings.Join(keys, ", "))
+
+		if strict {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			response := map[string]interface{}{
+				"message": fmt.Sprintf("%d configuration keys can not be represented as %s", len(output.Skipped), format),
+				"skipped": output.Skipped,
+			}
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				log.Println("Error encoding response:", err)
+			}
+			return
+		}
+	}
+
+	// the configuration may hold revealed secrets, it must not be kept by any cache
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set(VersionHeader, version)
+
+	etag := configETag(version, format, params, output.Body)
+	w.Header().Set("ETag", etag)
+
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", output.ContentType)
+
+	if _, err := w.Write(output.Body); err != nil {
+		log.Println("Error writing response:", err)
+	}
 }
@@ -0,0 +1,313 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/controller"
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/app/configuration/service"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockConfigurationService struct {
+	mock.Mock
+}
+
+func (m *MockConfigurationService) DeleteConfig(ctx context.Context, env string) error {
+	args := m.Called(ctx, env)
+	return args.Error(0)
+}
+
+func (m *MockConfigurationService) CloneConfig(ctx context.Context, env, newEnv string, updateGlobals map[string]interface{}) error {
+	args := m.Called(ctx, env, newEnv, updateGlobals)
+	return args.Error(0)
+}
+
+func (m *MockConfigurationService) ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error) {
+	args := m.Called(ctx, env, version, groups, globals, fields)
+	cfg, _ := args.Get(0).(map[string]interface{})
+	return cfg, args.String(1), args.Error(2)
+}
+
+func (m *MockConfigurationService) ReadEnvConfig(ctx context.Context, env, version string) (map[string]interface{}, string, error) {
+	args := m.Called(ctx, env, version)
+	cfg, _ := args.Get(0).(map[string]interface{})
+	return cfg, args.String(1), args.Error(2)
+}
+
+func (m *MockConfigurationService) ListEnvs(ctx context.Context) ([]repository.EnvParams, error) {
+	args := m.Called(ctx)
+	envs, _ := args.Get(0).([]repository.EnvParams)
+	return envs, args.Error(1)
+}
+
+func (m *MockConfigurationService) ListConfigKeys(ctx context.Context, env, version string) (repository.ConfigKeys, string, error) {
+	args := m.Called(ctx, env, version)
+	return args.Get(0).(repository.ConfigKeys), args.String(1), args.Error(2)
+}
+
+func (m *MockConfigurationService) Sync(ctx context.Context) (service.SyncStatus, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(service.SyncStatus), args.Error(1)
+}
+
+func (m *MockConfigurationService) SyncStatus(ctx context.Context) (service.SyncStatus, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(service.SyncStatus), args.Error(1)
+}
+
+type EndpointSuite struct {
+	suite.Suite
+	service   *MockConfigurationService
+	endpoints *controller.ConfigurationEndpoints
+}
+
+func (suite *EndpointSuite) SetupTest() {
+	suite.service = new(MockConfigurationService)
+	suite.endpoints = controller.New(suite.service, formatter.New())
+}
+
+func TestEndpointSuite(t *testing.T) {
+	suite.Run(t, new(EndpointSuite))
+}
+
+func (suite *EndpointSuite) readConfig(target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rr := httptest.NewRecorder()
+	suite.endpoints.ReadConfigHandler(rr, req)
+	return rr
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerVersionAndETag() {
+	cfg := map[string]interface{}{"database": map[string]interface{}{"host": "localhost"}}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", []string{"app"}, []string{"database"}, []selector.Path{}).Return(cfg, "v1.0.0", nil)
+
+	rr := suite.readConfig("/config?env=dev&groups=app&globals=database", nil)
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.Equal("v1.0.0", rr.Header().Get(controller.VersionHeader))
+	suite.Regexp(`^"v1\.0\.0-[0-9a-f]{16}"$`, rr.Header().Get("ETag"))
+	suite.Equal("no-store", rr.Header().Get("Cache-Control"))
+	suite.JSONEq(`{"database":{"host":"localhost"}}`, rr.Body.String())
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerETagDependsOnRequest() {
+	cfg := map[string]interface{}{"key": "value"}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", mock.Anything, mock.Anything, mock.Anything).Return(cfg, "v1.0.0", nil)
+
+	first := suite.readConfig("/config?env=dev&groups=a&groups=b", nil).Header().Get("ETag")
+	reordered := suite.readConfig("/config?env=dev&groups=b&groups=a", nil).Header().Get("ETag")
+	otherGroups := suite.readConfig("/config?env=dev&groups=a", nil).Header().Get("ETag")
+	otherFormat := suite.readConfig("/config?env=dev&groups=a&groups=b&format=yaml", nil).Header().Get("ETag")
+
+	suite.Equal(first, reordered)
+	suite.NotEqual(first, otherGroups)
+	suite.NotEqual(first, otherFormat)
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerIfNoneMatch() {
+	cfg := map[string]interface{}{"key": "value"}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", []string(nil), []string(nil), []selector.Path{}).Return(cfg, "v1.0.0", nil)
+
+	etag := suite.readConfig("/config?env=dev", nil).Header().Get("ETag")
+
+	testCases := []struct {
+		name           string
+		ifNoneMatch    string
+		expectedStatus int
+	}{
+		{name: "Matching entity tag", ifNoneMatch: etag, expectedStatus: http.StatusNotModified},
+		{name: "Matching weak entity tag in a list", ifNoneMatch: `"other", W/` + etag, expectedStatus: http.StatusNotModified},
+		{name: "Wildcard", ifNoneMatch: "*", expectedStatus: http.StatusNotModified},
+		{name: "Stale entity tag", ifNoneMatch: `"v0.9.0-0000000000000000"`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			rr := suite.readConfig("/config?env=dev", map[string]string{"If-None-Match": tc.ifNoneMatch})
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+			suite.Equal(etag, rr.Header().Get("ETag"))
+			suite.Equal("v1.0.0", rr.Header().Get(controller.VersionHeader))
+			if tc.expectedStatus == http.StatusNotModified {
+				suite.Empty(rr.Body.String())
+			}
+		})
+	}
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerStrictIgnoresIfNoneMatch() {
+	cfg := map[string]interface{}{"key": "value", "empty": map[string]interface{}{}}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", []string(nil), []string(nil), []selector.Path{}).Return(cfg, "v1.0.0", nil)
+
+	rr := suite.readConfig("/config?env=dev&format=dotenv", nil)
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.Equal("empty", rr.Header().Get(controller.SkippedKeysHeader))
+
+	// the body strict mode rejects is never reported as not modified
+	rr = suite.readConfig("/config?env=dev&format=dotenv&strict=true", map[string]string{"If-None-Match": rr.Header().Get("ETag")})
+	suite.Equal(http.StatusUnprocessableEntity, rr.Code)
+	suite.Empty(rr.Header().Get("ETag"))
+	suite.Contains(rr.Body.String(), "can not be represented as dotenv")
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerHistoricalVersion() {
+	cfg := map[string]interface{}{"key": "old"}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "v0.9.0", []string(nil), []string(nil), []selector.Path{}).Return(cfg, "v0.9.0", nil)
+	suite.service.On("ReadConfig", mock.Anything, "dev", "v0.1.0", []string(nil), []string(nil), []selector.Path{}).
+		Return(nil, "", repository.ConfigVersionNotFoundError{Env: "dev", Version: "v0.1.0"})
+
+	rr := suite.readConfig("/config?env=dev&version=v0.9.0", nil)
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.Equal("v0.9.0", rr.Header().Get(controller.VersionHeader))
+	suite.JSONEq(`{"key":"old"}`, rr.Body.String())
+
+	rr = suite.readConfig("/config?env=dev&version=v0.1.0", nil)
+	suite.Equal(http.StatusNotFound, rr.Code)
+	suite.Contains(rr.Body.String(), "version 'v0.1.0' of env 'dev' not found")
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerUnresolvedSecrets() {
+	unresolved := []secretmode.Unresolved{
+		{Key: "db.password", Reason: secretmode.ReasonRedacted},
+		{Key: "vault:payments/api#token", Reason: secretmode.ReasonMissing},
+	}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", []string(nil), []string(nil), []selector.Path{}).
+		Return(nil, "", fmt.Errorf("failed to insert secrets: %w", secretmode.UnresolvedError{Unresolved: unresolved}))
+
+	rr := suite.readConfig("/config?env=dev", nil)
+
+	suite.Equal(http.StatusUnprocessableEntity, rr.Code)
+	suite.JSONEq(`{
+		"message": "2 secrets could not be resolved: db.password (redacted), vault:payments/api#token (missing)",
+		"unresolved": [
+			{"key": "db.password", "reason": "redacted"},
+			{"key": "vault:payments/api#token", "reason": "missing"}
+		]
+	}`, rr.Body.String())
+}
+
+func (suite *EndpointSuite) TestListEnvsHandler() {
+	envs := []repository.EnvParams{
+		{Name: "develop", Version: "v1.0.0"},
+		{Name: "develop-feature", Version: "v1.0.0", Clone: true, Original: "develop"},
+	}
+	suite.service.On("ListEnvs", mock.Anything).Return(envs, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/config/envs", nil)
+	rr := httptest.NewRecorder()
+	suite.endpoints.ListEnvsHandler(rr, req)
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.JSONEq(`{"envs":[
+		{"name":"develop","version":"v1.0.0","clone":false,"original":""},
+		{"name":"develop-feature","version":"v1.0.0","clone":true,"original":"develop"}
+	]}`, rr.Body.String())
+}
+
+func (suite *EndpointSuite) TestListConfigKeysHandler() {
+	keys := repository.ConfigKeys{Groups: []string{"billing"}, Globals: []string{"database", "region"}}
+	suite.service.On("ListConfigKeys", mock.Anything, "dev", "").Return(keys, "v1.0.0", nil)
+	suite.service.On("ListConfigKeys", mock.Anything, "dev", "v0.1.0").
+		Return(repository.ConfigKeys{}, "", repository.ConfigVersionNotFoundError{Env: "dev", Version: "v0.1.0"})
+
+	req := httptest.NewRequest(http.MethodGet, "/config/keys?env=dev", nil)
+	rr := httptest.NewRecorder()
+	suite.endpoints.ListConfigKeysHandler(rr, req)
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.Equal("v1.0.0", rr.Header().Get(controller.VersionHeader))
+	suite.JSONEq(`{"env":"dev","version":"v1.0.0","groups":["billing"],"globals":["database","region"]}`, rr.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/config/keys?env=dev&version=v0.1.0", nil)
+	rr = httptest.NewRecorder()
+	suite.endpoints.ListConfigKeysHandler(rr, req)
+
+	suite.Equal(http.StatusNotFound, rr.Code)
+}
+
+func (suite *EndpointSuite) TestSyncHandler() {
+	lastSync := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	status := service.SyncStatus{Active: true, LastSync: &lastSync, Envs: map[string]string{"develop": "v1.1.0"}}
+	suite.service.On("Sync", mock.Anything).Return(status, nil).Once()
+	suite.service.On("Sync", mock.Anything).Return(service.SyncStatus{}, service.SyncUnavailableError{}).Once()
+
+	rr := httptest.NewRecorder()
+	suite.endpoints.SyncHandler(rr, httptest.NewRequest(http.MethodPost, "/config/sync", nil))
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.JSONEq(`{"active":true,"lastSync":"2024-03-01T10:00:00Z","envs":{"develop":"v1.1.0"}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	suite.endpoints.SyncHandler(rr, httptest.NewRequest(http.MethodPost, "/config/sync", nil))
+
+	suite.Equal(http.StatusServiceUnavailable, rr.Code)
+}
+
+func (suite *EndpointSuite) TestSyncStatusHandler() {
+	status := service.SyncStatus{Active: false, LastError: "error pulling tags", Envs: map[string]string{}}
+	suite.service.On("SyncStatus", mock.Anything).Return(status, nil)
+
+	rr := httptest.NewRecorder()
+	suite.endpoints.SyncStatusHandler(rr, httptest.NewRequest(http.MethodGet, "/config/sync", nil))
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.JSONEq(`{"active":false,"lastError":"error pulling tags","envs":{}}`, rr.Body.String())
+}
+
+func (suite *EndpointSuite) TestReadEnvConfigHandler() {
+	cfg := map[string]interface{}{
+		"groups":  map[string]interface{}{"billing": map[string]interface{}{"host": "localhost"}},
+		"globals": map[string]interface{}{"region": "eu-west-1"},
+	}
+	suite.service.On("ReadEnvConfig", mock.Anything, "dev", "").Return(cfg, "v1.0.0", nil)
+
+	rr := suite.readEnvConfig("/config/env?env=dev&format=properties", nil)
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.Equal("v1.0.0", rr.Header().Get(controller.VersionHeader))
+	suite.Equal("globals.region=eu-west-1\ngroups.billing.host=localhost\n", rr.Body.String())
+
+	rr = suite.readEnvConfig("/config/env?env=dev&format=properties", map[string]string{"If-None-Match": rr.Header().Get("ETag")})
+	suite.Equal(http.StatusNotModified, rr.Code)
+}
+
+func (suite *EndpointSuite) readEnvConfig(target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rr := httptest.NewRecorder()
+	suite.endpoints.ReadEnvConfigHandler(rr, req)
+	return rr
+}
+
+func (suite *EndpointSuite) TestReadConfigHandlerSelect() {
+	fields := []selector.Path{{"analytics", "database", "host"}, {"analytics", "servers", "0"}}
+	cfg := map[string]interface{}{"analytics": map[string]interface{}{"database": map[string]interface{}{"host": "localhost"}}}
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", []string(nil), []string(nil), fields).Return(cfg, "v1.0.0", nil)
+	suite.service.On("ReadConfig", mock.Anything, "dev", "", []string(nil), []string(nil), []selector.Path{}).Return(cfg, "v1.0.0", nil)
+
+	rr := suite.readConfig("/config?env=dev&select=analytics.database.host&select=/analytics/servers/0", nil)
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.JSONEq(`{"analytics":{"database":{"host":"localhost"}}}`, rr.Body.String())
+
+	// the same body read through other selectors is another entity
+	suite.NotEqual(rr.Header().Get("ETag"), suite.readConfig("/config?env=dev", nil).Header().Get("ETag"))
+
+	rr = suite.readConfig("/config?env=dev&select=analytics..host", nil)
+	suite.Equal(http.StatusBadRequest, rr.Code)
+	suite.Contains(rr.Body.String(), "invalid selector 'analytics..host'")
+}
@@ -1,53 +1,173 @@
 package extractor
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
 )
 
+const (
+	// AgeExtension is added to the files encrypted with age, e.g. 'secrets.yaml.age'
+	AgeExtension = ".age"
+
+	// sopsConfigFile holds the creation rules of the sops tool, it is neither configuration nor secrets
+	sopsConfigFile = ".sops.yaml"
+)
+
 type fileProcessor struct {
 	Extensions []string
-	Process    func(string) (map[string]interface{}, error)
+	Process    func([]byte) (map[string]interface{}, error)
+}
+
+type encryption int
+
+const (
+	notEncrypted encryption = iota
+	ageEncrypted
+	sopsEncrypted
+)
+
+// file is a supported file of a directory, along with how it has been encrypted
+type file struct {
+	path       string
+	data       []byte
+	processor  fileProcessor
+	encryption encryption
 }
 
 type configExtractor struct {
 	processors []fileProcessor
+	// decrypt the encrypted files of the repositories
+	identities []age.Identity
 }
 
-func New() *configExtractor {
+func New(identities []age.Identity) *configExtractor {
 	return &configExtractor{
 		processors: []fileProcessor{yamlProcessor},
+		identities: identities,
 	}
 }
 
+// ExtractConfigList reads the configuration files of the directory, encrypted files holding secrets instead
 func (e *configExtractor) ExtractConfigList(dir string) (*types.ExtractedConfigList, error) {
 	var configs types.ExtractedConfigList
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	err := e.walk(dir, func(f file) error {
+		if f.encryption != notEncrypted {
+			return nil
+		}
+
+		config, err := f.processor.Process(f.data)
 		if err != nil {
 			return err
 		}
+		configs = append(configs, config)
+		return nil
+	})
 
-		if !info.IsDir() {
-			for _, processor := range e.processors {
-				if isFileSupported(info.Name(), processor.Extensions) {
-					config, err := processor.Process(path)
-					if err != nil {
-						return err
-					}
-					configs = append(configs, config)
-					break
+	return &configs, err
+}
+
+// ExtractSecrets decrypts the encrypted files of the directory, files encrypted with age (e.g. 'secrets.yaml.age')
+// and YAML files encrypted by SOPS, returning their values as secrets by key
+func (e *configExtractor) ExtractSecrets(dir string) (map[string]interface{}, error) {
+	secrets := map[string]interface{}{}
+
+	err := e.walk(dir, func(f file) error {
+		if f.encryption == notEncrypted {
+			return nil
+		}
+
+		if len(e.identities) == 0 {
+			return fmt.Errorf("'%s' is encrypted but no age identity has been configured", f.path)
+		}
+
+		var values map[string]interface{}
+		switch f.encryption {
+		case ageEncrypted:
+			plaintext, err := decryptAge(f.data, e.identities)
+			if err != nil {
+				return fmt.Errorf("error decrypting '%s': %w", f.path, err)
+			}
+			values, err = f.processor.Process(plaintext)
+			if err != nil {
+				return fmt.Errorf("error reading decrypted '%s': %w", f.path, err)
+			}
+		case sopsEncrypted:
+			var err error
+			values, err = decryptSops(f.data, e.identities)
+			if err != nil {
+				return fmt.Errorf("error decrypting '%s': %w", f.path, err)
+			}
+		}
+
+		for key, value := range values {
+			if _, ok := secrets[key]; ok {
+				return fmt.Errorf("secret '%s' of '%s' is already declared by another encrypted file", key, f.path)
+			}
+			secrets[key] = value
+		}
+		return nil
+	})
+
+	return secrets, err
+}
+
+// walk reads every supported file of the directory, encrypted or not
+func (e *configExtractor) walk(dir string, fn func(f file) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || info.Name() == sopsConfigFile {
+			return nil
+		}
+
+		name, encryption := info.Name(), notEncrypted
+		if strings.HasSuffix(name, AgeExtension) {
+			name, encryption = strings.TrimSuffix(name, AgeExtension), ageEncrypted
+		}
+
+		for _, processor := range e.processors {
+			if isFileSupported(name, processor.Extensions) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+
+				if encryption == notEncrypted && isSopsEncrypted(data) {
+					encryption = sopsEncrypted
 				}
+
+				return fn(file{path: path, data: data, processor: processor, encryption: encryption})
 			}
 		}
 
 		return nil
 	})
+}
 
-	return &configs, err
+// decryptAge decrypts a file encrypted with age, in binary or armored form
+func decryptAge(data []byte, identities []age.Identity) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		r = armor.NewReader(r)
+	}
+
+	plaintext, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(plaintext)
 }
 
 func isFileSupported(filename string, extensions []string) bool {
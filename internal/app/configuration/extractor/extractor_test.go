@@ -1,10 +1,13 @@
 package extractor_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"filippo.io/age"
 	"github.com/raw-leak/configleam/internal/app/configuration/extractor"
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
 	"github.com/stretchr/testify/assert"
@@ -467,7 +470,7 @@ func TestExtractConfigList(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Arrange
-			e := extractor.New()
+			e := extractor.New(nil)
 
 			cwd, err := os.Getwd()
 			if err != nil {
@@ -485,3 +488,97 @@ func TestExtractConfigList(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractSecrets(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current working directory: %v", err)
+	}
+
+	keyFile, err := os.ReadFile(filepath.Join(cwd, "testdata", "age-key.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read the age key of the testdata: %v", err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(keyFile))
+	if err != nil {
+		t.Fatalf("Failed to parse the age key of the testdata: %v", err)
+	}
+
+	dir := filepath.Join(cwd, "testdata", "test-7")
+
+	t.Run("Encrypted files are not part of the configuration", func(t *testing.T) {
+		configList, err := extractor.New(identities).ExtractConfigList(dir)
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.ExtractedConfigList{
+			map[string]interface{}{
+				"database": map[string]interface{}{"host": "db.internal", "password": "{{ secret.db.password }}"},
+			},
+		}, *configList)
+	})
+
+	t.Run("Decrypting age and SOPS files into secrets", func(t *testing.T) {
+		secrets, err := extractor.New(identities).ExtractSecrets(dir)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"db":                 map[string]interface{}{"password": "s3cr3t", "port": 5432},
+			"payments":           map[string]interface{}{"stripeKey": "sk_test_123", "sandbox": true},
+			"region_unencrypted": "eu-west-1",
+		}, secrets)
+	})
+
+	t.Run("No secrets without encrypted files", func(t *testing.T) {
+		secrets, err := extractor.New(nil).ExtractSecrets(filepath.Join(cwd, "testdata", "test-1"))
+
+		assert.NoError(t, err)
+		assert.Empty(t, secrets)
+	})
+
+	t.Run("Encrypted files without identities", func(t *testing.T) {
+		_, err := extractor.New(nil).ExtractSecrets(dir)
+		assert.ErrorContains(t, err, "no age identity has been configured")
+	})
+
+	t.Run("Encrypted files of another identity", func(t *testing.T) {
+		other, err := age.GenerateX25519Identity()
+		assert.NoError(t, err)
+
+		_, err = extractor.New([]age.Identity{other}).ExtractSecrets(dir)
+		assert.ErrorContains(t, err, "no configured age identity decrypts the SOPS data key")
+
+		data, err := os.ReadFile(filepath.Join(dir, "secrets.yaml.age"))
+		assert.NoError(t, err)
+
+		ageOnly := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(ageOnly, "secrets.yaml.age"), data, 0o600))
+
+		_, err = extractor.New([]age.Identity{other}).ExtractSecrets(ageOnly)
+		var noMatch *age.NoIdentityMatchError
+		assert.ErrorAs(t, err, &noMatch)
+	})
+
+	t.Run("Tampered SOPS file", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "payments.sops.yaml"))
+		assert.NoError(t, err)
+
+		tampered := t.TempDir()
+		data = bytes.Replace(data, []byte("region_unencrypted: eu-west-1"), []byte("region_unencrypted: us-east-1"), 1)
+		assert.NoError(t, os.WriteFile(filepath.Join(tampered, "payments.sops.yaml"), data, 0o600))
+
+		_, err = extractor.New(identities).ExtractSecrets(tampered)
+		assert.ErrorContains(t, err, "MAC mismatch")
+	})
+
+	t.Run("Secret declared by several files", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "secrets.yaml.age"))
+		assert.NoError(t, err)
+
+		duplicated := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(duplicated, "a.yaml.age"), data, 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(duplicated, "b.yaml.age"), data, 0o600))
+
+		_, err = extractor.New(identities).ExtractSecrets(duplicated)
+		assert.ErrorContains(t, err, "secret 'db'")
+	})
+}
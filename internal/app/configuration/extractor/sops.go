@@ -0,0 +1,325 @@
+package extractor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+// sopsMetadataKey is the key under which SOPS stores the metadata of the files it encrypts
+const sopsMetadataKey = "sops"
+
+// sopsMacOnlyEncryptedInit starts the MAC of the files whose MAC only covers their encrypted values
+var sopsMacOnlyEncryptedInit = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0xb, 0xb, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+// sopsValuePattern matches the values encrypted by SOPS, e.g. 'ENC[AES256_GCM,data:...,iv:...,tag:...,type:str]'
+var sopsValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+// sopsMetadata is the part of the SOPS metadata needed to decrypt a file whose data key is encrypted with age
+type sopsMetadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	KeyGroups    []interface{} `yaml:"key_groups"`
+	LastModified string        `yaml:"lastmodified"`
+	MAC          string        `yaml:"mac"`
+
+	UnencryptedSuffix       string `yaml:"unencrypted_suffix"`
+	EncryptedSuffix         string `yaml:"encrypted_suffix"`
+	UnencryptedRegex        string `yaml:"unencrypted_regex"`
+	EncryptedRegex          string `yaml:"encrypted_regex"`
+	UnencryptedCommentRegex string `yaml:"unencrypted_comment_regex"`
+	EncryptedCommentRegex   string `yaml:"encrypted_comment_regex"`
+	MACOnlyEncrypted        bool   `yaml:"mac_only_encrypted"`
+}
+
+// isSopsEncrypted reports whether the YAML document is a file encrypted by SOPS
+func isSopsEncrypted(data []byte) bool {
+	var file struct {
+		Sops *struct {
+			MAC string `yaml:"mac"`
+		} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return false
+	}
+	return file.Sops != nil && file.Sops.MAC != ""
+}
+
+// decryptSops decrypts a YAML file encrypted by SOPS with the age identities, returning its values without the
+// metadata. Only the data keys encrypted with age are supported: the data key is decrypted with age, then the
+// values with it, the way SOPS does, and they are verified against the MAC of the file.
+func decryptSops(data []byte, identities []age.Identity) (map[string]interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error reading SOPS file: %w", err)
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("SOPS file must be a YAML mapping")
+	}
+	root := doc.Content[0]
+
+	var metadata sopsMetadata
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == sopsMetadataKey {
+			if err := root.Content[i+1].Decode(&metadata); err != nil {
+				return nil, fmt.Errorf("error reading SOPS metadata: %w", err)
+			}
+		}
+	}
+	if len(metadata.KeyGroups) > 0 || metadata.UnencryptedCommentRegex != "" || metadata.EncryptedCommentRegex != "" {
+		return nil, errors.New("SOPS files with key groups or comment rules are not supported")
+	}
+
+	key, err := sopsDataKey(metadata, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	d := sopsDecrypter{metadata: metadata, key: key, mac: sha512.New()}
+	if metadata.MACOnlyEncrypted {
+		d.mac.Write(sopsMacOnlyEncryptedInit)
+	}
+
+	values := map[string]interface{}{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name := root.Content[i].Value
+		if name == sopsMetadataKey {
+			continue
+		}
+
+		value, err := d.decrypt(root.Content[i+1], []string{name})
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+
+	if err := d.verify(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// sopsDataKey decrypts the data key of the file with the first of its age recipients an identity matches
+func sopsDataKey(metadata sopsMetadata, identities []age.Identity) ([]byte, error) {
+	if len(metadata.Age) == 0 {
+		return nil, errors.New("the SOPS data key is not encrypted with age, the only supported key type")
+	}
+
+	var err error
+	for _, recipient := range metadata.Age {
+		var key []byte
+		key, err = decryptAge([]byte(recipient.Enc), identities)
+		if err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no configured age identity decrypts the SOPS data key: %w", err)
+}
+
+// sopsDecrypter decrypts the values of a SOPS file in order, hashing them into its MAC
+type sopsDecrypter struct {
+	metadata sopsMetadata
+	key      []byte
+	mac      hash.Hash
+}
+
+func (d *sopsDecrypter) decrypt(node *yaml.Node, path []string) (interface{}, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return d.decrypt(node.Alias, path)
+	case yaml.MappingNode:
+		values := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			value, err := d.decrypt(node.Content[i+1], append(path[:len(path):len(path)], name))
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		}
+		return values, nil
+	case yaml.SequenceNode:
+		values := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := d.decrypt(item, path)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error reading SOPS value of '%s': %w", strings.Join(path, "."), err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	encrypted := d.isEncrypted(path)
+	if encrypted {
+		ciphertext, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("SOPS value of '%s' is not encrypted", strings.Join(path, "."))
+		}
+
+		var err error
+		value, err = d.decryptValue(ciphertext, strings.Join(path, ":")+":")
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting SOPS value of '%s': %w", strings.Join(path, "."), err)
+		}
+	}
+
+	if encrypted || !d.metadata.MACOnlyEncrypted {
+		if err := d.hash(value); err != nil {
+			return nil, err
+		}
+	}
+
+	// bytes are kept as text, as the values of the other configuration files are
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	return value, nil
+}
+
+// isEncrypted reports whether the value of the path has been encrypted, according to the rules of the file
+func (d *sopsDecrypter) isEncrypted(path []string) bool {
+	encrypted := true
+	if suffix := d.metadata.UnencryptedSuffix; suffix != "" && anyKey(path, func(k string) bool { return strings.HasSuffix(k, suffix) }) {
+		encrypted = false
+	}
+	if suffix := d.metadata.EncryptedSuffix; suffix != "" {
+		encrypted = anyKey(path, func(k string) bool { return strings.HasSuffix(k, suffix) })
+	}
+	if pattern := d.metadata.UnencryptedRegex; pattern != "" && anyKey(path, func(k string) bool { return matches(pattern, k) }) {
+		encrypted = false
+	}
+	if pattern := d.metadata.EncryptedRegex; pattern != "" {
+		encrypted = anyKey(path, func(k string) bool { return matches(pattern, k) })
+	}
+	return encrypted
+}
+
+// decryptValue decrypts a value encrypted by SOPS, the path of the value being its additional data
+func (d *sopsDecrypter) decryptValue(ciphertext, additionalData string) (interface{}, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+
+	parts := sopsValuePattern.FindStringSubmatch(ciphertext)
+	if parts == nil {
+		return nil, errors.New("value does not match the SOPS format")
+	}
+
+	decoded := make([][]byte, 3)
+	for i := range decoded {
+		var err error
+		if decoded[i], err = base64.StdEncoding.DecodeString(parts[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	data, iv, tag := decoded[0], decoded[1], decoded[2]
+
+	block, err := aes.NewCipher(d.key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	switch parts[4] {
+	case "str":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	case "bytes":
+		return plaintext, nil
+	default:
+		return nil, fmt.Errorf("unknown SOPS value type '%s'", parts[4])
+	}
+}
+
+// hash adds the value to the MAC the way SOPS does
+func (d *sopsDecrypter) hash(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		d.mac.Write([]byte(v))
+	case []byte:
+		d.mac.Write(v)
+	case int:
+		d.mac.Write([]byte(strconv.Itoa(v)))
+	case float64:
+		d.mac.Write([]byte(strconv.FormatFloat(v, 'f', -1, 64)))
+	case bool:
+		if v {
+			d.mac.Write([]byte("True"))
+		} else {
+			d.mac.Write([]byte("False"))
+		}
+	default:
+		return fmt.Errorf("unsupported SOPS value type %T", value)
+	}
+	return nil
+}
+
+// verify checks the values match the MAC of the file, encrypted with its last modification date as additional data
+func (d *sopsDecrypter) verify() error {
+	lastModified, err := time.Parse(time.RFC3339, d.metadata.LastModified)
+	if err != nil {
+		return fmt.Errorf("invalid SOPS last modification date: %w", err)
+	}
+
+	expected, err := d.decryptValue(d.metadata.MAC, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error decrypting SOPS MAC: %w", err)
+	}
+
+	mac := fmt.Sprintf("%X", d.mac.Sum(nil))
+	if expected != mac {
+		return errors.New("SOPS MAC mismatch, the file has been modified after its encryption")
+	}
+	return nil
+}
+
+func anyKey(path []string, match func(key string) bool) bool {
+	for _, key := range path {
+		if match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(pattern, key string) bool {
+	matched, _ := regexp.MatchString(pattern, key)
+	return matched
+}
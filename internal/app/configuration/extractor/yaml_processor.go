@@ -1,17 +1,10 @@
 package extractor
 
 import (
-	"os"
-
 	"gopkg.in/yaml.v3"
 )
 
-func processYAMLFile(path string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
+func processYAML(data []byte) (map[string]interface{}, error) {
 	var config map[string]interface{}
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, err
@@ -22,5 +15,5 @@ func processYAMLFile(path string) (map[string]interface{}, error) {
 
 var yamlProcessor = fileProcessor{
 	Extensions: []string{".yaml", ".yml"},
-	Process:    processYAMLFile,
+	Process:    processYAML,
 }
@@ -0,0 +1,257 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// segment is a step of the path to a configuration value, either a map key or an array index
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// leaf is a scalar configuration value with the path leading to it
+type leaf struct {
+	path  []segment
+	value interface{}
+}
+
+// flatten walks the configuration in a deterministic order, map keys sorted and arrays by index,
+// returning its scalar values and the paths of the empty maps and arrays, which have no value to flatten.
+func flatten(cfg map[string]interface{}) ([]leaf, [][]segment) {
+	leaves, empty := []leaf{}, [][]segment{}
+
+	var walk func(path []segment, value interface{})
+	walk = func(path []segment, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 && len(path) > 0 {
+				empty = append(empty, path)
+				return
+			}
+			for _, key := range sortedKeys(v) {
+				walk(appendSegment(path, segment{key: key}), v[key])
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				empty = append(empty, path)
+				return
+			}
+			for i, item := range v {
+				walk(appendSegment(path, segment{index: i, isIndex: true}), item)
+			}
+		default:
+			leaves = append(leaves, leaf{path: path, value: v})
+		}
+	}
+	walk(nil, cfg)
+
+	return leaves, empty
+}
+
+func appendSegment(path []segment, s segment) []segment {
+	next := make([]segment, len(path), len(path)+1)
+	copy(next, path)
+	return append(next, s)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pathString returns the path in dot notation, e.g. "database.hosts[0]", used to report skipped values
+func pathString(path []segment) string {
+	var b strings.Builder
+	for i, s := range path {
+		if s.isIndex {
+			fmt.Fprintf(&b, "[%d]", s.index)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(s.key)
+	}
+	return b.String()
+}
+
+// scalarString returns the textual representation of a scalar value, null being an empty string
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// encodeFlat writes one line per scalar value using the provided key builder and line encoder,
+// skipping the empty maps and arrays, the keys that can not be built and the keys colliding with a previous one.
+func encodeFlat(cfg map[string]interface{}, buildKey func([]segment) string, writeLine func(b *bytes.Buffer, key, value string)) ([]byte, []SkippedKey) {
+	leaves, empty := flatten(cfg)
+
+	var b bytes.Buffer
+	skipped := []SkippedKey{}
+	seen := map[string]string{}
+
+	for _, l := range leaves {
+		key := buildKey(l.path)
+		if key == "" {
+			skipped = append(skipped, SkippedKey{Key: pathString(l.path), Reason: "key is empty once flattened"})
+			continue
+		}
+		if previous, ok := seen[key]; ok {
+			skipped = append(skipped, SkippedKey{Key: pathString(l.path), Reason: fmt.Sprintf("flattened key '%s' collides with '%s'", key, previous)})
+			continue
+		}
+		seen[key] = pathString(l.path)
+
+		writeLine(&b, key, scalarString(l.value))
+	}
+
+	for _, path := range empty {
+		skipped = append(skipped, SkippedKey{Key: pathString(path), Reason: "empty object or array has no value to flatten"})
+	}
+
+	return b.Bytes(), skipped
+}
+
+// dotenvKey upper-cases the path and joins it with '_', replacing any character that is not
+// valid in an environment variable name, e.g. "database.host" becomes "DATABASE_HOST" and "servers[0]" becomes "SERVERS_0".
+func dotenvKey(path []segment) string {
+	parts := make([]string, 0, len(path))
+	for _, s := range path {
+		if s.isIndex {
+			parts = append(parts, strconv.Itoa(s.index))
+			continue
+		}
+
+		part := strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+				return r
+			default:
+				return '_'
+			}
+		}, s.key)
+		parts = append(parts, part)
+	}
+
+	key := strings.Join(parts, "_")
+	if strings.Trim(key, "_") == "" {
+		return ""
+	}
+	if key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return key
+}
+
+// dotenvValue double-quotes the value, escaping it so it can neither break the line nor be interpolated
+func dotenvValue(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '$':
+			b.WriteString(`\$`)
+		case '`':
+			b.WriteString("\\`")
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func encodeDotenv(cfg map[string]interface{}) ([]byte, []SkippedKey) {
+	return encodeFlat(cfg, dotenvKey, func(b *bytes.Buffer, key, value string) {
+		fmt.Fprintf(b, "%s=%s\n", key, dotenvValue(value))
+	})
+}
+
+// EnvVars flattens the configuration into environment variables named as in the dotenv format, e.g. "DATABASE_HOST",
+// each name being prefixed with the prefix. The values are not quoted nor escaped as they are passed as they are.
+func EnvVars(cfg map[string]interface{}, prefix string) (map[string]string, []SkippedKey) {
+	vars := map[string]string{}
+	_, skipped := encodeFlat(cfg, dotenvKey, func(_ *bytes.Buffer, key, value string) {
+		vars[prefix+key] = value
+	})
+	return vars, skipped
+}
+
+// propertiesKey joins the path with '.', arrays indexes written as "[i]", e.g. "database.hosts[0]"
+func propertiesKey(path []segment) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return escapeProperties(pathString(path), true)
+}
+
+// escapeProperties escapes a key or value following the java.util.Properties format,
+// non printable ASCII and non ASCII characters being written as unicode escapes.
+func escapeProperties(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case isKey && (r == '=' || r == ':'), (isKey || i == 0) && (r == '#' || r == '!'):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04X`, unit)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func encodeProperties(cfg map[string]interface{}) ([]byte, []SkippedKey) {
+	return encodeFlat(cfg, propertiesKey, func(b *bytes.Buffer, key, value string) {
+		fmt.Fprintf(b, "%s=%s\n", key, escapeProperties(value, false))
+	})
+}
@@ -0,0 +1,160 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	JSON       Format = "json"
+	YAML       Format = "yaml"
+	Dotenv     Format = "dotenv"
+	Properties Format = "properties"
+	TOML       Format = "toml"
+)
+
+var contentTypes = map[Format]string{
+	JSON:       "application/json",
+	YAML:       "application/yaml",
+	Dotenv:     "text/x-dotenv",
+	Properties: "text/x-java-properties",
+	TOML:       "application/toml",
+}
+
+// media types accepted for every format, besides the one it is served with
+var mediaTypes = map[string]Format{
+	"application/json":       JSON,
+	"application/yaml":       YAML,
+	"application/x-yaml":     YAML,
+	"text/yaml":              YAML,
+	"text/x-dotenv":          Dotenv,
+	"application/x-dotenv":   Dotenv,
+	"text/x-java-properties": Properties,
+	"text/x-properties":      Properties,
+	"application/toml":       TOML,
+}
+
+// UnsupportedFormatError is returned when none of the requested formats can be produced
+type UnsupportedFormatError struct {
+	Requested string
+}
+
+func (e UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported format '%s', supported formats: json, yaml, dotenv, properties, toml", e.Requested)
+}
+
+// SkippedKey is a configuration value that could not be represented in the requested format
+type SkippedKey struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// Output is the configuration encoded in the negotiated format
+type Output struct {
+	Format      Format
+	ContentType string
+	Body        []byte
+	Skipped     []SkippedKey
+}
+
+type ConfigFormatter struct{}
+
+func New() *ConfigFormatter {
+	return &ConfigFormatter{}
+}
+
+// Negotiate picks the format from the 'format' query parameter, which takes precedence,
+// or from the media types of the 'Accept' header ordered by their quality. JSON is the default.
+func (f *ConfigFormatter) Negotiate(format, accept string) (Format, error) {
+	if format != "" {
+		requested := Format(strings.ToLower(format))
+		if _, ok := contentTypes[requested]; !ok {
+			return "", UnsupportedFormatError{Requested: format}
+		}
+		return requested, nil
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return JSON, nil
+	}
+
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType, quality})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, r := range ranges {
+		if r.mediaType == "*/*" || r.mediaType == "application/*" {
+			return JSON, nil
+		}
+		if format, ok := mediaTypes[r.mediaType]; ok {
+			return format, nil
+		}
+	}
+
+	return "", UnsupportedFormatError{Requested: accept}
+}
+
+// Format encodes the configuration, reporting the values that could not be represented in the format
+func (f *ConfigFormatter) Format(format Format, cfg map[string]interface{}) (*Output, error) {
+	var (
+		body    []byte
+		skipped []SkippedKey
+		err     error
+	)
+
+	switch format {
+	case JSON:
+		body, err = json.Marshal(cfg)
+		body = append(body, '\n')
+	case YAML:
+		body, err = yaml.Marshal(cfg)
+	case Dotenv:
+		body, skipped = encodeDotenv(cfg)
+	case Properties:
+		body, skipped = encodeProperties(cfg)
+	case TOML:
+		body, skipped = encodeTOML(cfg)
+	default:
+		return nil, UnsupportedFormatError{Requested: string(format)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding configuration as %s: %v", format, err)
+	}
+
+	return &Output{
+		Format:      format,
+		ContentType: contentTypes[format] + "; charset=utf-8",
+		Body:        body,
+		Skipped:     skipped,
+	}, nil
+}
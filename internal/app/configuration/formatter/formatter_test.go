@@ -0,0 +1,196 @@
+package formatter_test
+
+import (
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name           string
+		format         string
+		accept         string
+		expectedFormat formatter.Format
+		expectedErr    bool
+	}{
+		{name: "Defaults to JSON without format nor Accept", expectedFormat: formatter.JSON},
+		{name: "Format parameter takes precedence over Accept", format: "dotenv", accept: "application/yaml", expectedFormat: formatter.Dotenv},
+		{name: "Format parameter is case insensitive", format: "TOML", expectedFormat: formatter.TOML},
+		{name: "Unknown format parameter is rejected", format: "xml", expectedErr: true},
+		{name: "Accept with a single media type", accept: "application/x-yaml", expectedFormat: formatter.YAML},
+		{name: "Accept media types are ordered by quality", accept: "application/json;q=0.5, text/x-java-properties", expectedFormat: formatter.Properties},
+		{name: "Accept skips unsupported media types", accept: "application/xml, application/toml;q=0.8", expectedFormat: formatter.TOML},
+		{name: "Accept wildcard resolves to JSON", accept: "text/html, */*;q=0.1", expectedFormat: formatter.JSON},
+		{name: "Accept without supported media types is rejected", accept: "application/xml", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := formatter.New().Negotiate(tc.format, tc.accept)
+			if tc.expectedErr {
+				assert.ErrorAs(t, err, &formatter.UnsupportedFormatError{})
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFormat, format)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cfg := map[string]interface{}{
+		"database": map[string]interface{}{
+			"host":     "localhost",
+			"port":     float64(5432),
+			"password": "p@ss\"word\n$HOME",
+		},
+		"servers": []interface{}{"a", "b"},
+		"ratio":   0.75,
+		"debug":   true,
+	}
+
+	testCases := []struct {
+		name            string
+		format          formatter.Format
+		cfg             map[string]interface{}
+		expectedBody    string
+		expectedSkipped []formatter.SkippedKey
+	}{
+		{
+			name:   "Dotenv flattens and quotes every value",
+			format: formatter.Dotenv,
+			cfg:    cfg,
+			expectedBody: "DATABASE_HOST=\"localhost\"\n" +
+				"DATABASE_PASSWORD=\"p@ss\\\"word\\n\\$HOME\"\n" +
+				"DATABASE_PORT=\"5432\"\n" +
+				"DEBUG=\"true\"\n" +
+				"RATIO=\"0.75\"\n" +
+				"SERVERS_0=\"a\"\n" +
+				"SERVERS_1=\"b\"\n",
+			expectedSkipped: []formatter.SkippedKey{},
+		},
+		{
+			name:   "Properties flattens with dots and indexes",
+			format: formatter.Properties,
+			cfg:    cfg,
+			expectedBody: "database.host=localhost\n" +
+				"database.password=p@ss\"word\\n$HOME\n" +
+				"database.port=5432\n" +
+				"debug=true\n" +
+				"ratio=0.75\n" +
+				"servers[0]=a\n" +
+				"servers[1]=b\n",
+			expectedSkipped: []formatter.SkippedKey{},
+		},
+		{
+			name:   "Properties escapes keys and non ASCII values",
+			format: formatter.Properties,
+			cfg: map[string]interface{}{
+				"key with=sign": " café",
+			},
+			expectedBody:    "key\\ with\\=sign=\\ caf\\u00E9\n",
+			expectedSkipped: []formatter.SkippedKey{},
+		},
+		{
+			name:   "Dotenv reports colliding keys and empty containers",
+			format: formatter.Dotenv,
+			cfg: map[string]interface{}{
+				"db-host": "a",
+				"db_host": "b",
+				"empty":   map[string]interface{}{},
+				"list":    []interface{}{},
+				"1st":     "c",
+			},
+			expectedBody: "_1ST=\"c\"\n" +
+				"DB_HOST=\"a\"\n",
+			expectedSkipped: []formatter.SkippedKey{
+				{Key: "db_host", Reason: "flattened key 'DB_HOST' collides with 'db-host'"},
+				{Key: "empty", Reason: "empty object or array has no value to flatten"},
+				{Key: "list", Reason: "empty object or array has no value to flatten"},
+			},
+		},
+		{
+			name:   "TOML writes values before tables and arrays of tables",
+			format: formatter.TOML,
+			cfg: map[string]interface{}{
+				"name":     "app",
+				"database": map[string]interface{}{"host": "localhost", "port": float64(5432)},
+				"tags":     []interface{}{"a", float64(1)},
+				"workers": []interface{}{
+					map[string]interface{}{"name": "w1"},
+					map[string]interface{}{"name": "w2"},
+				},
+				"ratio": 1.5,
+			},
+			expectedBody: "name = \"app\"\n" +
+				"ratio = 1.5\n" +
+				"tags = [\"a\", 1]\n" +
+				"\n[database]\n" +
+				"host = \"localhost\"\n" +
+				"port = 5432\n" +
+				"\n[[workers]]\n" +
+				"name = \"w1\"\n" +
+				"\n[[workers]]\n" +
+				"name = \"w2\"\n",
+			expectedSkipped: []formatter.SkippedKey{},
+		},
+		{
+			name:   "TOML reports null values and quotes keys",
+			format: formatter.TOML,
+			cfg: map[string]interface{}{
+				"missing":  nil,
+				"some.key": "v",
+			},
+			expectedBody: "\"some.key\" = \"v\"\n",
+			expectedSkipped: []formatter.SkippedKey{
+				{Key: "missing", Reason: "null has no TOML representation"},
+			},
+		},
+		{
+			name:            "YAML keeps the configuration structure",
+			format:          formatter.YAML,
+			cfg:             map[string]interface{}{"database": map[string]interface{}{"host": "localhost"}},
+			expectedBody:    "database:\n    host: localhost\n",
+			expectedSkipped: nil,
+		},
+		{
+			name:            "JSON keeps the configuration structure",
+			format:          formatter.JSON,
+			cfg:             map[string]interface{}{"database": map[string]interface{}{"host": "localhost"}},
+			expectedBody:    "{\"database\":{\"host\":\"localhost\"}}\n",
+			expectedSkipped: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := formatter.New().Format(tc.format, tc.cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBody, string(output.Body))
+			assert.Equal(t, tc.expectedSkipped, output.Skipped)
+			assert.Contains(t, output.ContentType, "charset=utf-8")
+		})
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	cfg := map[string]interface{}{
+		"database": map[string]interface{}{"host": "localhost", "password": "p@ss\"word\n$HOME"},
+		"servers":  []interface{}{"a", "b"},
+		"db-host":  "collides",
+		"db_host":  "collides too",
+	}
+
+	vars, skipped := formatter.EnvVars(cfg, "APP_")
+
+	assert.Equal(t, map[string]string{
+		"APP_DATABASE_HOST":     "localhost",
+		"APP_DATABASE_PASSWORD": "p@ss\"word\n$HOME",
+		"APP_DB_HOST":           "collides",
+		"APP_SERVERS_0":         "a",
+		"APP_SERVERS_1":         "b",
+	}, vars)
+	assert.Equal(t, []formatter.SkippedKey{{Key: "db_host", Reason: "flattened key 'DB_HOST' collides with 'db-host'"}}, skipped)
+}
@@ -0,0 +1,203 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type tomlEncoder struct {
+	b       bytes.Buffer
+	skipped []SkippedKey
+}
+
+// encodeTOML writes the configuration as a TOML document. Maps become tables, arrays of maps become
+// arrays of tables and any other array an inline array. Null values have no TOML representation and are skipped.
+func encodeTOML(cfg map[string]interface{}) ([]byte, []SkippedKey) {
+	e := &tomlEncoder{skipped: []SkippedKey{}}
+	e.writeTable(nil, cfg, false)
+	return e.b.Bytes(), e.skipped
+}
+
+// writeTable writes the key/values of the table followed by its sub-tables, the header
+// is only written for tables holding values or empty ones, and always for array of tables elements
+func (e *tomlEncoder) writeTable(path []segment, table map[string]interface{}, arrayElement bool) {
+	keys := sortedKeys(table)
+
+	values, tables, arrays := []string{}, []string{}, []string{}
+	for _, key := range keys {
+		switch v := table[key].(type) {
+		case map[string]interface{}:
+			tables = append(tables, key)
+		case []interface{}:
+			if isArrayOfTables(v) {
+				arrays = append(arrays, key)
+			} else {
+				values = append(values, key)
+			}
+		default:
+			values = append(values, key)
+		}
+	}
+
+	if arrayElement {
+		fmt.Fprintf(&e.b, "[[%s]]\n", tomlHeader(path))
+	} else if len(path) > 0 && (len(values) > 0 || len(keys) == 0) {
+		fmt.Fprintf(&e.b, "[%s]\n", tomlHeader(path))
+	}
+
+	for _, key := range values {
+		keyPath := appendSegment(path, segment{key: key})
+
+		value, ok := e.value(keyPath, table[key])
+		if ok {
+			fmt.Fprintf(&e.b, "%s = %s\n", tomlKey(key), value)
+		}
+	}
+
+	for _, key := range tables {
+		e.separate()
+		e.writeTable(appendSegment(path, segment{key: key}), table[key].(map[string]interface{}), false)
+	}
+
+	for _, key := range arrays {
+		for _, item := range table[key].([]interface{}) {
+			e.separate()
+			e.writeTable(appendSegment(path, segment{key: key}), item.(map[string]interface{}), true)
+		}
+	}
+}
+
+// separate leaves a blank line between a table and the previous content
+func (e *tomlEncoder) separate() {
+	if e.b.Len() > 0 && !bytes.HasSuffix(e.b.Bytes(), []byte("\n\n")) {
+		e.b.WriteByte('\n')
+	}
+}
+
+// value returns the inline TOML representation of a value, reporting it as skipped when it holds a null
+func (e *tomlEncoder) value(path []segment, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		e.skipped = append(e.skipped, SkippedKey{Key: pathString(path), Reason: "null has no TOML representation"})
+		return "", false
+	case string:
+		return tomlString(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return tomlFloat(v), true
+	case float32:
+		return tomlFloat(float64(v)), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for i, item := range v {
+			itemValue, ok := e.value(appendSegment(path, segment{index: i, isIndex: true}), item)
+			if !ok {
+				return "", false
+			}
+			items = append(items, itemValue)
+		}
+		return "[" + strings.Join(items, ", ") + "]", true
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			itemValue, ok := e.value(appendSegment(path, segment{key: key}), v[key])
+			if !ok {
+				continue
+			}
+			items = append(items, tomlKey(key)+" = "+itemValue)
+		}
+		if len(items) == 0 {
+			return "{}", true
+		}
+		return "{ " + strings.Join(items, ", ") + " }", true
+	default:
+		return tomlString(fmt.Sprint(v)), true
+	}
+}
+
+func isArrayOfTables(items []interface{}) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func tomlHeader(path []segment) string {
+	keys := make([]string, 0, len(path))
+	for _, s := range path {
+		keys = append(keys, tomlKey(s.key))
+	}
+	return strings.Join(keys, ".")
+}
+
+func tomlKey(key string) string {
+	if bareKeyRegexp.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlFloat writes the numbers decoded as float64 from JSON as integers when they have no fractional part
+func tomlFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return strconv.FormatInt(int64(v), 10)
+	}
+
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
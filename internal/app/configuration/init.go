@@ -2,14 +2,22 @@ package configuration
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
 
 	"github.com/raw-leak/configleam/config"
 	"github.com/raw-leak/configleam/internal/app/configuration/analyzer"
 	"github.com/raw-leak/configleam/internal/app/configuration/controller"
 	"github.com/raw-leak/configleam/internal/app/configuration/extractor"
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
 	"github.com/raw-leak/configleam/internal/app/configuration/parser"
 	"github.com/raw-leak/configleam/internal/app/configuration/repository"
 	"github.com/raw-leak/configleam/internal/app/configuration/service"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 )
 
 type ConfigurationSet struct {
@@ -23,30 +31,82 @@ func Init(ctx context.Context, cfg *config.Config, secrets service.Secrets, noti
 		RedisUsername: cfg.RedisUsername,
 		RedisPassword: cfg.RedisPassword,
 		RedisTLS:      bool(cfg.RedisTls),
+		RedisTLSFiles: cfg.RedisTlsFiles(),
 
 		EtcdAddrs:    cfg.EtcdAddrs,
 		EtcdUsername: cfg.EtcdUsername,
 		EtcdPassword: cfg.EtcdPassword,
 		EtcdTLS:      bool(cfg.EtcdTls),
+		EtcdTLSFiles: cfg.EtcdTlsFiles(),
+
+		Namespace: cfg.StorageNamespace(),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	projects := map[string]service.ProjectConfig{}
+	if cfg.ProjectsFile != "" {
+		projects, err = service.LoadProjects(cfg.ProjectsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the default project keeps reading its configuration from the GIT_REPOSITORY_* variables
+	if cfg.RepoUrl != "" {
+		projects[project.Default] = service.ProjectConfig{
+			RepoUrl: cfg.RepoUrl,
+			Branch:  cfg.RepoBranch,
+			Envs:    cfg.RepoEnvs,
+		}
+	}
+
+	if len(projects) == 0 {
+		return nil, errors.New("no git repository has been configured")
+	}
+
+	identities, err := ageIdentities(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	parser := parser.New()
-	extractor := extractor.New()
+	extractor := extractor.New(identities)
 	analyzer := analyzer.New()
 
 	service := service.New(service.ConfigurationConfig{
-		Branch:       cfg.RepoBranch,
-		RepoUrl:      cfg.RepoUrl,
-		Envs:         cfg.RepoEnvs,
+		Projects:     projects,
 		PullInterval: cfg.PullInterval,
+		HistorySize:  cfg.HistorySize,
 	}, parser, extractor, repo, analyzer, secrets, notify)
 
-	endpoints := controller.New(service)
+	endpoints := controller.New(service, formatter.New())
 
 	return &ConfigurationSet{
 		service, endpoints,
 	}, nil
 }
+
+// ageIdentities reads the age identities decrypting the encrypted secrets of the repositories, inline and from the key file
+func ageIdentities(cfg *config.Config) ([]age.Identity, error) {
+	keys := cfg.AgeKey
+	if cfg.AgeKeyFile != "" {
+		data, err := os.ReadFile(cfg.AgeKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading age key file: %w", err)
+		}
+		keys += "\n" + string(data)
+	}
+
+	// repositories without encrypted files need no identity
+	if strings.TrimSpace(keys) == "" {
+		return nil, nil
+	}
+
+	identities, err := age.ParseIdentities(strings.NewReader(keys))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identities: %w", err)
+	}
+	return identities, nil
+}
@@ -11,6 +11,16 @@ func (e EnvNotFoundError) Error() string {
 	return fmt.Sprintf("env '%s' not found", e.Key)
 }
 
+// ConfigVersionNotFoundError is used when a version of an environment configuration is not kept in the history.
+type ConfigVersionNotFoundError struct {
+	Env     string
+	Version string
+}
+
+func (e ConfigVersionNotFoundError) Error() string {
+	return fmt.Sprintf("version '%s' of env '%s' not found", e.Version, e.Env)
+}
+
 type EnvLockHeldError struct {
 	Env string
 }
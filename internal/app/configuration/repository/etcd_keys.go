@@ -1,27 +1,47 @@
 package repository
 
-import "fmt"
+import (
+	"fmt"
 
-type EtcdKeys struct{}
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+)
+
+type EtcdKeys struct {
+	ns namespace.Namespace
+}
+
+// NewEtcdKeys creates the keys builder for the provided namespace, an empty namespace resolves to the default one
+func NewEtcdKeys(ns namespace.Namespace) EtcdKeys {
+	return EtcdKeys{ns: ns}
+}
+
+func (k EtcdKeys) configPrefix() string {
+	return k.ns.Key(ConfigurationSegment)
+}
 
 func (k EtcdKeys) GetReadGroupRepoEnvKey(repo, env, key string) string {
-	return fmt.Sprintf("%s:%s:%s:%s:%s", ConfigurationPrefix, repo, env, GroupPrefix, key)
+	return fmt.Sprintf("%s:%s:%s:%s:%s", k.configPrefix(), repo, env, GroupPrefix, key)
 }
 
 func (k EtcdKeys) GetReadGlobalRepoEnvKey(repo, env, key string) string {
-	return fmt.Sprintf("%s:%s:%s:%s:%s", ConfigurationPrefix, repo, env, GlobalPrefix, key)
+	return fmt.Sprintf("%s:%s:%s:%s:%s", k.configPrefix(), repo, env, GlobalPrefix, key)
 }
 
 func (k EtcdKeys) GetBaseKey(repo string, env string) string {
-	return fmt.Sprintf("%s:%s:%s", ConfigurationPrefix, repo, env)
+	return fmt.Sprintf("%s:%s:%s", k.configPrefix(), repo, env)
 }
 
 func (k EtcdKeys) GetGlobalKey(prefix, key string) string {
 	return fmt.Sprintf("%s:%s:%s", prefix, GlobalPrefix, key)
 }
 
+// GetEnvConfigPrefixKey returns the prefix of every group and global key of the repository environment
+func (k EtcdKeys) GetEnvConfigPrefixKey(repo, env string) string {
+	return fmt.Sprintf("%s:", k.GetBaseKey(repo, env))
+}
+
 func (k EtcdKeys) GetCloneEnvPatternKey(cloneEnv string) string {
-	return fmt.Sprintf("%s:*:%s:*", ConfigurationPrefix, cloneEnv)
+	return fmt.Sprintf("%s:*:%s:*", k.configPrefix(), cloneEnv)
 }
 
 func (k EtcdKeys) GetGroupKey(prefix, key string) string {
@@ -29,9 +49,27 @@ func (k EtcdKeys) GetGroupKey(prefix, key string) string {
 }
 
 func (k EtcdKeys) GetEnvLockKey(env string) string {
-	return fmt.Sprintf("%s:%s", ConfigurationLockPrefix, env)
+	return k.ns.Key(ConfigurationLockSegment, env)
 }
 
 func (k EtcdKeys) GetEnvKey(env string) string {
-	return fmt.Sprintf("%s:%s", ConfigurationEnvPrefix, env)
+	return k.ns.Key(ConfigurationEnvSegment, env)
+}
+
+// GetHistoryPrefixKey returns the prefix of the versions kept for the repository environment
+func (k EtcdKeys) GetHistoryPrefixKey(repo, env string) string {
+	return k.ns.Key(ConfigurationHistorySegment, repo, env, "")
+}
+
+func (k EtcdKeys) GetHistoryVersionKey(repo, env, version string) string {
+	return k.ns.Key(ConfigurationHistorySegment, repo, env, version)
+}
+
+func (k EtcdKeys) GetAllConfigPrefixKey() string {
+	return fmt.Sprintf("%s:", k.configPrefix())
+}
+
+// ParseConfigKey splits a '<prefix>:<repo>:<env>:global|group:<name>' key into its segments
+func (k EtcdKeys) ParseConfigKey(key string) (repo, env, kind, name string, ok bool) {
+	return parseConfigKey(k.configPrefix(), key)
 }
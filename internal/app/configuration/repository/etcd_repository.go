@@ -11,19 +11,29 @@ import (
 
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type EtcdRepository struct {
 	*etcd.Etcd
+	root namespace.Namespace
 	keys EtcdKeys
 }
 
-func NewEtcdRepository(etcd *etcd.Etcd) *EtcdRepository {
-	return &EtcdRepository{etcd, EtcdKeys{}}
+func NewEtcdRepository(etcd *etcd.Etcd, ns namespace.Namespace) *EtcdRepository {
+	return &EtcdRepository{etcd, ns, NewEtcdKeys(ns)}
+}
+
+// scoped returns a copy of the repository building its keys under the project carried by the context
+func (r *EtcdRepository) scoped(ctx context.Context) *EtcdRepository {
+	return &EtcdRepository{r.Etcd, r.root, NewEtcdKeys(r.root.Project(project.FromContext(ctx)))}
 }
 
 func (r *EtcdRepository) UpsertConfig(ctx context.Context, repo, env string, config *types.ParsedRepoConfig) error {
+	r = r.scoped(ctx)
+
 	lockID, err := r.lockEnv(ctx, env)
 	if err != nil {
 		return fmt.Errorf("error locking '%s' environment for '%s' repository: %v", env, repo, err)
@@ -78,6 +88,8 @@ func (r *EtcdRepository) storeConfig(ctx context.Context, env, repo string, conf
 
 // DeleteConfig deletes all configuration keys for a specific repository and environment.
 func (r *EtcdRepository) DeleteConfig(ctx context.Context, repo, env string) error {
+	r = r.scoped(ctx)
+
 	prefix := r.keys.GetBaseKey(repo, env)
 	_, err := r.Client.Delete(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
@@ -86,6 +98,68 @@ func (r *EtcdRepository) DeleteConfig(ctx context.Context, repo, env string) err
 	return nil
 }
 
+// SaveConfigVersion keeps the configuration applied for a version of the environment,
+// pruning the oldest versions so only the last 'keep' ones are kept.
+func (r *EtcdRepository) SaveConfigVersion(ctx context.Context, repo, env, version string, config *types.ParsedRepoConfig, keep int) error {
+	r = r.scoped(ctx)
+
+	jsonData, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("error marshaling config version '%s': %v", version, err)
+	}
+
+	_, err = r.Client.Put(ctx, r.keys.GetHistoryVersionKey(repo, env, version), string(jsonData))
+	if err != nil {
+		return fmt.Errorf("error saving config version '%s' of environment '%s': %v", version, env, err)
+	}
+
+	if keep < 1 {
+		return nil
+	}
+
+	// keys only, ordered from the oldest to the newest applied version
+	res, err := r.Client.Get(ctx, r.keys.GetHistoryPrefixKey(repo, env), clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithSort(clientv3.SortByModRevision, clientv3.SortAscend))
+	if err != nil {
+		return fmt.Errorf("error reading config versions of environment '%s': %v", env, err)
+	}
+	if len(res.Kvs) <= keep {
+		return nil
+	}
+
+	ops := []clientv3.Op{}
+	for _, kv := range res.Kvs[:len(res.Kvs)-keep] {
+		ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+	}
+
+	_, err = r.Client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("error executing etcd transaction on pruning config versions: %v", err)
+	}
+
+	return nil
+}
+
+// GetConfigVersion returns the configuration applied for a version of the environment, if still kept
+func (r *EtcdRepository) GetConfigVersion(ctx context.Context, repo, env, version string) (*types.ParsedRepoConfig, error) {
+	r = r.scoped(ctx)
+
+	res, err := r.Client.Get(ctx, r.keys.GetHistoryVersionKey(repo, env, version))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching config version '%s' of environment '%s': %v", version, env, err)
+	}
+	if len(res.Kvs) < 1 {
+		return nil, ConfigVersionNotFoundError{Env: env, Version: version}
+	}
+
+	config := &types.ParsedRepoConfig{}
+	err = json.Unmarshal(res.Kvs[0].Value, config)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling config version '%s' of environment '%s': %v", version, env, err)
+	}
+
+	return config, nil
+}
+
 func (r *EtcdRepository) lockEnv(ctx context.Context, env string) (clientv3.LeaseID, error) {
 	lease, err := r.Client.Grant(ctx, 5)
 	if err != nil {
@@ -128,7 +202,35 @@ func (r *EtcdRepository) isEnvLockHeld(ctx context.Context, env string) error {
 	return nil
 }
 
+// GetConfigKeys lists the group names and global keys stored for the repository environment.
+func (r *EtcdRepository) GetConfigKeys(ctx context.Context, repo, env string) (ConfigKeys, error) {
+	r = r.scoped(ctx)
+
+	err := r.isEnvLockHeld(ctx, env)
+	if err != nil {
+		return ConfigKeys{}, fmt.Errorf("error verifying the lock while listing config keys for environment '%s': %v", env, err)
+	}
+
+	res, err := r.Client.Get(ctx, r.keys.GetEnvConfigPrefixKey(repo, env), clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return ConfigKeys{}, fmt.Errorf("error fetching config keys of environment '%s': %v", env, err)
+	}
+
+	builder := newConfigKeysBuilder()
+	for _, kv := range res.Kvs {
+		_, _, kind, name, ok := r.keys.ParseConfigKey(string(kv.Key))
+		if !ok {
+			continue
+		}
+		builder.add(kind, name)
+	}
+
+	return builder.build(), nil
+}
+
 func (r *EtcdRepository) ReadConfig(ctx context.Context, repo, env string, groups, globalKeys []string) (map[string]interface{}, error) {
+	r = r.scoped(ctx)
+
 	err := r.isEnvLockHeld(ctx, env)
 	if err != nil {
 		return nil, fmt.Errorf("error verifying the lock while reading config for environment '%s': %v", env, err)
@@ -213,6 +315,8 @@ func (r *EtcdRepository) ReadConfig(ctx context.Context, repo, env string, group
 }
 
 func (r *EtcdRepository) CloneConfig(ctx context.Context, repo, env, newEnv string, updateGlobal map[string]interface{}) error {
+	r = r.scoped(ctx)
+
 	prefix := r.keys.GetBaseKey(repo, env)
 
 	oldSegment := fmt.Sprintf(":%s:", env)
@@ -301,6 +405,8 @@ MainLoop:
 
 // AddEnv adds metadata for a new environment to the repository.
 func (r *EtcdRepository) AddEnv(ctx context.Context, env string, params EnvParams) error {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return errors.New("environment name cannot be empty")
 	}
@@ -319,6 +425,8 @@ func (r *EtcdRepository) AddEnv(ctx context.Context, env string, params EnvParam
 
 // DeleteEnv removes metadata for the specified environment from the repository.
 func (r *EtcdRepository) DeleteEnv(ctx context.Context, env string) error {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return errors.New("environment name cannot be empty")
 	}
@@ -337,6 +445,8 @@ func (r *EtcdRepository) DeleteEnv(ctx context.Context, env string) error {
 
 // GetEnvOriginal retrieves the original value of the specified environment from the repository.
 func (r *EtcdRepository) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return "", false, errors.New("environment name cannot be empty")
 	}
@@ -360,6 +470,8 @@ func (r *EtcdRepository) GetEnvOriginal(ctx context.Context, env string) (string
 
 // SetEnvVersion sets the version metadata for the specified environment in the repository.
 func (r *EtcdRepository) SetEnvVersion(ctx context.Context, env string, version string) error {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return errors.New("environment name cannot be empty")
 	}
@@ -381,6 +493,8 @@ func (r *EtcdRepository) SetEnvVersion(ctx context.Context, env string, version
 
 // GetEnvParams retrieves the environment metadata for the specified key.
 func (r *EtcdRepository) GetEnvParams(ctx context.Context, env string) (EnvParams, error) {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return EnvParams{}, errors.New("environment name cannot be empty")
 	}
@@ -404,6 +518,8 @@ func (r *EtcdRepository) GetEnvParams(ctx context.Context, env string) (EnvParam
 
 // GetAllEnvs retrieves all available environments from the repository.
 func (r *EtcdRepository) GetAllEnvs(ctx context.Context) ([]EnvParams, error) {
+	r = r.scoped(ctx)
+
 	res, err := r.Client.Get(ctx, r.keys.GetEnvKey(""), clientv3.WithPrefix())
 	if err != nil {
 		return nil, fmt.Errorf("error on fetching all environments metadata: %w", err)
@@ -426,3 +542,42 @@ func (r *EtcdRepository) GetAllEnvs(ctx context.Context) ([]EnvParams, error) {
 func (r *EtcdRepository) extractEnvName(key string) string {
 	return strings.TrimPrefix(key, r.keys.GetEnvKey(""))
 }
+
+// ExportConfig retrieves the stored configuration of every repository and environment.
+func (r *EtcdRepository) ExportConfig(ctx context.Context) ([]EnvConfig, error) {
+	r = r.scoped(ctx)
+
+	res, err := r.Client.Get(ctx, r.keys.GetAllConfigPrefixKey(), clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error on fetching all configuration keys: %w", err)
+	}
+
+	builder := newEnvConfigBuilder()
+	for _, kv := range res.Kvs {
+		repo, env, kind, name, ok := r.keys.ParseConfigKey(string(kv.Key))
+		if !ok {
+			continue
+		}
+
+		err = builder.add(repo, env, kind, name, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.build(), nil
+}
+
+// ImportConfig stores the provided configurations, overwriting the existing keys with the same name.
+func (r *EtcdRepository) ImportConfig(ctx context.Context, configs []EnvConfig) error {
+	r = r.scoped(ctx)
+
+	for _, cfg := range configs {
+		err := r.storeConfig(ctx, cfg.Env, cfg.Repo, &types.ParsedRepoConfig{Globals: cfg.Globals, Groups: cfg.Groups})
+		if err != nil {
+			return fmt.Errorf("error importing configuration of '%s' environment for '%s' repository: %v", cfg.Env, cfg.Repo, err)
+		}
+	}
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/raw-leak/configleam/internal/app/configuration/repository"
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	clientv3 "go.etcd.io/etcd/client/v3"
 
 	"github.com/stretchr/testify/assert"
@@ -37,7 +38,7 @@ func (suite *EtcdRepositorySuite) SetupSuite() {
 
 	suite.keys = repository.EtcdKeys{}
 	suite.client = client
-	suite.repository = repository.NewEtcdRepository(&etcd.Etcd{Client: suite.client})
+	suite.repository = repository.NewEtcdRepository(&etcd.Etcd{Client: suite.client}, namespace.Default)
 }
 
 func (suite *EtcdRepositorySuite) TearDownSuite() {
@@ -1282,3 +1283,80 @@ func (suite *EtcdRepositorySuite) TestGetAllEnvs() {
 		})
 	}
 }
+
+func (suite *EtcdRepositorySuite) TestExportImportConfig() {
+	ctx := context.Background()
+	suite.BeforeTest("TestExportImportConfig")
+
+	config := &types.ParsedRepoConfig{
+		Globals: map[string]interface{}{"region": "eu-west-1", "replicas": float64(2)},
+		Groups: map[string]types.GroupConfig{
+			"billing": {Local: map[string]interface{}{"host": "localhost"}, Global: []string{"region"}},
+		},
+	}
+
+	err := suite.repository.UpsertConfig(ctx, "repo", "develop", config)
+	suite.Require().NoError(err)
+
+	exported, err := suite.repository.ExportConfig(ctx)
+	suite.Require().NoError(err)
+	suite.Equal([]repository.EnvConfig{{Repo: "repo", Env: "develop", Globals: config.Globals, Groups: config.Groups}}, exported)
+
+	// restore into an empty storage
+	suite.BeforeTest("TestExportImportConfig")
+
+	err = suite.repository.ImportConfig(ctx, exported)
+	suite.Require().NoError(err)
+
+	imported, err := suite.repository.ExportConfig(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(exported, imported)
+}
+
+func (suite *EtcdRepositorySuite) TestConfigVersionHistory() {
+	ctx := context.Background()
+	suite.BeforeTest("TestConfigVersionHistory")
+
+	for i := 1; i <= 3; i++ {
+		config := &types.ParsedRepoConfig{Globals: map[string]interface{}{"version": float64(i)}, Groups: map[string]types.GroupConfig{}}
+		err := suite.repository.SaveConfigVersion(ctx, "repo", "develop", fmt.Sprintf("v%d.0.0", i), config, 2)
+		suite.Require().NoError(err)
+	}
+
+	// only the last two versions are kept
+	_, err := suite.repository.GetConfigVersion(ctx, "repo", "develop", "v1.0.0")
+	suite.ErrorAs(err, &repository.ConfigVersionNotFoundError{})
+
+	for i := 2; i <= 3; i++ {
+		config, err := suite.repository.GetConfigVersion(ctx, "repo", "develop", fmt.Sprintf("v%d.0.0", i))
+		suite.Require().NoError(err)
+		suite.Equal(float64(i), config.Globals["version"])
+	}
+
+	_, err = suite.repository.GetConfigVersion(ctx, "repo", "staging", "v3.0.0")
+	suite.ErrorAs(err, &repository.ConfigVersionNotFoundError{})
+}
+
+func (suite *EtcdRepositorySuite) TestGetConfigKeys() {
+	ctx := context.Background()
+	suite.BeforeTest("TestGetConfigKeys")
+
+	config := &types.ParsedRepoConfig{
+		Globals: map[string]interface{}{"region": "eu-west-1", "database": map[string]interface{}{"host": "localhost"}},
+		Groups: map[string]types.GroupConfig{
+			"billing":   {Local: map[string]interface{}{"port": float64(8080)}, Global: []string{"region"}},
+			"analytics": {Local: map[string]interface{}{}, Global: []string{"database"}},
+		},
+	}
+
+	err := suite.repository.UpsertConfig(ctx, "repo", "develop", config)
+	suite.Require().NoError(err)
+
+	keys, err := suite.repository.GetConfigKeys(ctx, "repo", "develop")
+	suite.Require().NoError(err)
+	suite.Equal(repository.ConfigKeys{Groups: []string{"analytics", "billing"}, Globals: []string{"database", "region"}}, keys)
+
+	keys, err = suite.repository.GetConfigKeys(ctx, "repo", "staging")
+	suite.Require().NoError(err)
+	suite.Equal(repository.ConfigKeys{Groups: []string{}, Globals: []string{}}, keys)
+}
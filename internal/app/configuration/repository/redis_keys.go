@@ -1,11 +1,27 @@
 package repository
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
 
-type RedisKeys struct{}
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+)
+
+type RedisKeys struct {
+	ns namespace.Namespace
+}
+
+// NewRedisKeys creates the keys builder for the provided namespace, an empty namespace resolves to the default one
+func NewRedisKeys(ns namespace.Namespace) RedisKeys {
+	return RedisKeys{ns: ns}
+}
+
+func (k RedisKeys) configPrefix() string {
+	return k.ns.Key(ConfigurationSegment)
+}
 
 func (k RedisKeys) GetBaseKey(gitRepoName string, envName string) string {
-	return fmt.Sprintf("%s:%s:%s", ConfigurationPrefix, gitRepoName, envName)
+	return fmt.Sprintf("%s:%s:%s", k.configPrefix(), gitRepoName, envName)
 }
 
 func (k RedisKeys) GetGlobalKeyKey(baseKeyPrefix string, configKey string) string {
@@ -17,29 +33,66 @@ func (k RedisKeys) GetGroupKey(baseKeyPrefix string, groupName string) string {
 }
 
 func (k RedisKeys) GetGroupPatternKey(env string, groupName string) string {
-	return fmt.Sprintf("%s:*:%s:%s:%s", ConfigurationPrefix, env, GroupPrefix, groupName)
+	return fmt.Sprintf("%s:*:%s:%s:%s", k.configPrefix(), env, GroupPrefix, groupName)
 }
 
 func (k RedisKeys) GetGlobalPatternKey(env string, key string) string {
-	return fmt.Sprintf("%s:*:%s:%s:%s", ConfigurationPrefix, env, GlobalPrefix, key)
+	return fmt.Sprintf("%s:*:%s:%s:%s", k.configPrefix(), env, GlobalPrefix, key)
+}
+
+// GetEnvConfigPatternKey matches every group and global key of the repository environment
+func (k RedisKeys) GetEnvConfigPatternKey(gitRepoName, envName string) string {
+	return fmt.Sprintf("%s:*", k.GetBaseKey(gitRepoName, envName))
 }
 
 func (k RedisKeys) GetCloneEnvPatternKey(cloneEnv string) string {
-	return fmt.Sprintf("%s:*:%s:*", ConfigurationPrefix, cloneEnv)
+	return fmt.Sprintf("%s:*:%s:*", k.configPrefix(), cloneEnv)
 }
 
 func (k RedisKeys) GetCloneEnvDeletePatternKey(gitRepoName, clonedEnv string) string {
-	return fmt.Sprintf("%s:*:%s:%s:*", ConfigurationPrefix, gitRepoName, clonedEnv)
+	return fmt.Sprintf("%s:*:%s:%s:*", k.configPrefix(), gitRepoName, clonedEnv)
 }
 
 func (k RedisKeys) GetEnvKey(envName string) string {
-	return fmt.Sprintf("%s:%s", ConfigurationEnvPrefix, envName)
+	return k.ns.Key(ConfigurationEnvSegment, envName)
 }
 
 func (k RedisKeys) GetAllEnvsPatternKey() string {
-	return fmt.Sprintf("%s:*", ConfigurationEnvPrefix)
+	return k.ns.Key(ConfigurationEnvSegment, "*")
 }
 
 func (k RedisKeys) GetEnvLockKey(envName string) string {
-	return fmt.Sprintf("%s:%s", ConfigurationLockPrefix, envName)
+	return k.ns.Key(ConfigurationLockSegment, envName)
+}
+
+// GetHistoryKey returns the key of the sorted set indexing the versions kept for the repository environment
+func (k RedisKeys) GetHistoryKey(repo, env string) string {
+	return k.ns.Key(ConfigurationHistorySegment, repo, env)
+}
+
+func (k RedisKeys) GetHistoryVersionKey(repo, env, version string) string {
+	return k.ns.Key(ConfigurationHistorySegment, repo, env, version)
+}
+
+func (k RedisKeys) GetAllConfigPatternKey() string {
+	return fmt.Sprintf("%s:*", k.configPrefix())
+}
+
+// ParseConfigKey splits a '<prefix>:<repo>:<env>:global|group:<name>' key into its segments
+func (k RedisKeys) ParseConfigKey(key string) (repo, env, kind, name string, ok bool) {
+	return parseConfigKey(k.configPrefix(), key)
+}
+
+func parseConfigKey(prefix, key string) (repo, env, kind, name string, ok bool) {
+	rest, found := strings.CutPrefix(key, prefix+":")
+	if !found {
+		return "", "", "", "", false
+	}
+
+	parts := strings.SplitN(rest, ":", 4)
+	if len(parts) != 4 || (parts[2] != GlobalPrefix && parts[2] != GroupPrefix) {
+		return "", "", "", "", false
+	}
+
+	return parts[0], parts[1], parts[2], parts[3], true
 }
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
 	"github.com/redis/go-redis/v9"
 )
@@ -21,11 +23,17 @@ const (
 
 type RedisRepository struct {
 	*rds.Redis
+	root namespace.Namespace
 	keys RedisKeys
 }
 
-func NewRedisRepository(redis *rds.Redis) *RedisRepository {
-	return &RedisRepository{redis, RedisKeys{}}
+func NewRedisRepository(redis *rds.Redis, ns namespace.Namespace) *RedisRepository {
+	return &RedisRepository{redis, ns, NewRedisKeys(ns)}
+}
+
+// scoped returns a copy of the repository building its keys under the project carried by the context
+func (r *RedisRepository) scoped(ctx context.Context) *RedisRepository {
+	return &RedisRepository{r.Redis, r.root, NewRedisKeys(r.root.Project(project.FromContext(ctx)))}
 }
 
 func (r *RedisRepository) storeConfig(ctx context.Context, repo, env string, config *types.ParsedRepoConfig) error {
@@ -68,6 +76,8 @@ func (r *RedisRepository) storeConfig(ctx context.Context, repo, env string, con
 }
 
 func (r *RedisRepository) UpsertConfig(ctx context.Context, repo, env string, config *types.ParsedRepoConfig) error {
+	r = r.scoped(ctx)
+
 	lockKey := r.keys.GetEnvLockKey(env)
 
 	_, err := r.Client.Set(ctx, lockKey, "lock", time.Second).Result()
@@ -85,6 +95,8 @@ func (r *RedisRepository) UpsertConfig(ctx context.Context, repo, env string, co
 }
 
 func (r *RedisRepository) DeleteConfig(ctx context.Context, repo, env string) error {
+	r = r.scoped(ctx)
+
 	luaScript := `
         local keys = redis.call('keys', ARGV[1])
         for i=1,#keys do
@@ -103,7 +115,103 @@ func (r *RedisRepository) DeleteConfig(ctx context.Context, repo, env string) er
 	return nil
 }
 
+// SaveConfigVersion keeps the configuration applied for a version of the environment,
+// pruning the oldest versions so only the last 'keep' ones are kept.
+func (r *RedisRepository) SaveConfigVersion(ctx context.Context, repo, env, version string, config *types.ParsedRepoConfig, keep int) error {
+	r = r.scoped(ctx)
+
+	jsonData, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("error marshaling config version '%s': %v", version, err)
+	}
+
+	historyKey := r.keys.GetHistoryKey(repo, env)
+
+	pipeline := r.Client.TxPipeline()
+	pipeline.Set(ctx, r.keys.GetHistoryVersionKey(repo, env, version), jsonData, 0)
+	pipeline.ZAdd(ctx, historyKey, redis.Z{Score: float64(time.Now().UnixNano()), Member: version})
+
+	_, err = pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on saving config version: %v", err)
+	}
+
+	if keep < 1 {
+		return nil
+	}
+
+	pruned, err := r.Client.ZRange(ctx, historyKey, 0, int64(-keep-1)).Result()
+	if err != nil {
+		return fmt.Errorf("error reading config versions of environment '%s': %v", env, err)
+	}
+	if len(pruned) < 1 {
+		return nil
+	}
+
+	pipeline = r.Client.TxPipeline()
+	for _, prunedVersion := range pruned {
+		pipeline.Del(ctx, r.keys.GetHistoryVersionKey(repo, env, prunedVersion))
+		pipeline.ZRem(ctx, historyKey, prunedVersion)
+	}
+
+	_, err = pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on pruning config versions: %v", err)
+	}
+
+	return nil
+}
+
+// GetConfigVersion returns the configuration applied for a version of the environment, if still kept
+func (r *RedisRepository) GetConfigVersion(ctx context.Context, repo, env, version string) (*types.ParsedRepoConfig, error) {
+	r = r.scoped(ctx)
+
+	val, err := r.Client.Get(ctx, r.keys.GetHistoryVersionKey(repo, env, version)).Bytes()
+	if err == redis.Nil {
+		return nil, ConfigVersionNotFoundError{Env: env, Version: version}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching config version '%s' of environment '%s': %v", version, env, err)
+	}
+
+	config := &types.ParsedRepoConfig{}
+	err = json.Unmarshal(val, config)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling config version '%s' of environment '%s': %v", version, env, err)
+	}
+
+	return config, nil
+}
+
+// GetConfigKeys lists the group names and global keys stored for the repository environment.
+func (r *RedisRepository) GetConfigKeys(ctx context.Context, repo, env string) (ConfigKeys, error) {
+	r = r.scoped(ctx)
+
+	err := r.checkLockAndRetry(ctx, env)
+	if err != nil {
+		return ConfigKeys{}, fmt.Errorf("error verifying the lock while listing config keys for environment '%s': %v", env, err)
+	}
+
+	keys, err := r.Client.Keys(ctx, r.keys.GetEnvConfigPatternKey(repo, env)).Result()
+	if err != nil {
+		return ConfigKeys{}, fmt.Errorf("error fetching config keys of environment '%s': %v", env, err)
+	}
+
+	builder := newConfigKeysBuilder()
+	for _, key := range keys {
+		_, _, kind, name, ok := r.keys.ParseConfigKey(key)
+		if !ok {
+			continue
+		}
+		builder.add(kind, name)
+	}
+
+	return builder.build(), nil
+}
+
 func (r *RedisRepository) ReadConfig(ctx context.Context, repo, env string, groups, globalKeys []string) (map[string]interface{}, error) {
+	r = r.scoped(ctx)
+
 	err := r.checkLockAndRetry(ctx, env)
 	if err != nil {
 		return nil, fmt.Errorf("error verifying the lock while reading config for environment '%s': %v", env, err)
@@ -251,6 +359,8 @@ func (r *RedisRepository) checkLockAndRetry(ctx context.Context, env string) err
 }
 
 func (r *RedisRepository) CloneConfig(ctx context.Context, repo, cloneEnv, newEnv string, updateGlobal map[string]interface{}) error {
+	r = r.scoped(ctx)
+
 	matchPattern := r.keys.GetCloneEnvPatternKey(cloneEnv)
 	oldSegment := fmt.Sprintf(":%s:", cloneEnv)
 	newSegment := fmt.Sprintf(":%s:", newEnv)
@@ -344,6 +454,8 @@ func (r *RedisRepository) HealthCheck(ctx context.Context) error {
 
 // AddEnv adds metadata for a new environment to the repository.
 func (r *RedisRepository) AddEnv(ctx context.Context, env string, params EnvParams) error {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return errors.New("environment name cannot be empty")
 	}
@@ -363,6 +475,8 @@ func (r *RedisRepository) AddEnv(ctx context.Context, env string, params EnvPara
 
 // DeleteEnv removes metadata for the specified environment from the repository.
 func (r *RedisRepository) DeleteEnv(ctx context.Context, env string) error {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return errors.New("environment name cannot be empty")
 	}
@@ -379,6 +493,8 @@ func (r *RedisRepository) DeleteEnv(ctx context.Context, env string) error {
 
 // GetEnvOriginal retrieves the original value of the specified environment from the repository.
 func (r *RedisRepository) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return "", false, errors.New("environment name cannot be empty")
 	}
@@ -395,6 +511,8 @@ func (r *RedisRepository) GetEnvOriginal(ctx context.Context, env string) (strin
 
 // SetEnvVersion sets the version metadata for the specified environment in the repository.
 func (r *RedisRepository) SetEnvVersion(ctx context.Context, env string, version string) error {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return errors.New("environment name cannot be empty")
 	}
@@ -408,6 +526,8 @@ func (r *RedisRepository) SetEnvVersion(ctx context.Context, env string, version
 
 // GetAllEnvs retrieves all available environments from the repository.
 func (r *RedisRepository) GetAllEnvs(ctx context.Context) ([]EnvParams, error) {
+	r = r.scoped(ctx)
+
 	keys, err := r.Client.Keys(ctx, r.keys.GetAllEnvsPatternKey()).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all environments keys: %w", err)
@@ -428,6 +548,8 @@ func (r *RedisRepository) GetAllEnvs(ctx context.Context) ([]EnvParams, error) {
 
 // GetEnvParams retrieves the environment metadata for the specified key.
 func (r *RedisRepository) GetEnvParams(ctx context.Context, env string) (EnvParams, error) {
+	r = r.scoped(ctx)
+
 	if len(env) < 1 {
 		return EnvParams{}, errors.New("environment name cannot be empty")
 	}
@@ -452,3 +574,50 @@ func (r *RedisRepository) GetEnvParams(ctx context.Context, env string) (EnvPara
 func (r *RedisRepository) extractEnvName(key string) string {
 	return strings.TrimPrefix(key, r.keys.GetEnvKey(""))
 }
+
+// ExportConfig retrieves the stored configuration of every repository and environment.
+func (r *RedisRepository) ExportConfig(ctx context.Context) ([]EnvConfig, error) {
+	r = r.scoped(ctx)
+
+	keys, err := r.Client.Keys(ctx, r.keys.GetAllConfigPatternKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all configuration keys: %w", err)
+	}
+
+	builder := newEnvConfigBuilder()
+	for _, key := range keys {
+		repo, env, kind, name, ok := r.keys.ParseConfigKey(key)
+		if !ok {
+			continue
+		}
+
+		value, err := r.Client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting configuration key '%s': %v", key, err)
+		}
+
+		err = builder.add(repo, env, kind, name, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.build(), nil
+}
+
+// ImportConfig stores the provided configurations, overwriting the existing keys with the same name.
+func (r *RedisRepository) ImportConfig(ctx context.Context, configs []EnvConfig) error {
+	r = r.scoped(ctx)
+
+	for _, cfg := range configs {
+		err := r.storeConfig(ctx, cfg.Repo, cfg.Env, &types.ParsedRepoConfig{Globals: cfg.Globals, Groups: cfg.Groups})
+		if err != nil {
+			return fmt.Errorf("error importing configuration of '%s' environment for '%s' repository: %v", cfg.Env, cfg.Repo, err)
+		}
+	}
+
+	return nil
+}
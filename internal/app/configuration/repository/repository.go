@@ -6,13 +6,22 @@ import (
 
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 )
 
 const (
-	ConfigurationPrefix     = "configleam:config"
-	ConfigurationEnvPrefix  = "configleam:env"
-	ConfigurationLockPrefix = "configleam:lock"
+	ConfigurationSegment        = "config"
+	ConfigurationEnvSegment     = "env"
+	ConfigurationLockSegment    = "lock"
+	ConfigurationHistorySegment = "history"
+
+	// prefixes within the default namespace
+	ConfigurationPrefix        = namespace.Default + ":" + ConfigurationSegment
+	ConfigurationEnvPrefix     = namespace.Default + ":" + ConfigurationEnvSegment
+	ConfigurationLockPrefix    = namespace.Default + ":" + ConfigurationLockSegment
+	ConfigurationHistoryPrefix = namespace.Default + ":" + ConfigurationHistorySegment
 
 	GlobalPrefix = "global"
 	GroupPrefix  = "group"
@@ -21,15 +30,22 @@ const (
 type Repository interface {
 	CloneConfig(ctx context.Context, repo, env, newEnv string, updateGlobals map[string]interface{}) error
 	ReadConfig(ctx context.Context, repo, env string, groups, globalKeys []string) (map[string]interface{}, error)
+	GetConfigKeys(ctx context.Context, repo, env string) (ConfigKeys, error)
 	UpsertConfig(ctx context.Context, repo, env string, config *types.ParsedRepoConfig) error
 	DeleteConfig(ctx context.Context, repo, env string) error
 
+	SaveConfigVersion(ctx context.Context, repo, env, version string, config *types.ParsedRepoConfig, keep int) error
+	GetConfigVersion(ctx context.Context, repo, env, version string) (*types.ParsedRepoConfig, error)
+
 	AddEnv(ctx context.Context, env string, params EnvParams) error
 	DeleteEnv(ctx context.Context, env string) error
 	GetEnvOriginal(ctx context.Context, env string) (string, bool, error)
 	SetEnvVersion(ctx context.Context, env string, v string) error
 	GetAllEnvs(ctx context.Context) ([]EnvParams, error)
 	GetEnvParams(ctx context.Context, env string) (EnvParams, error)
+
+	ExportConfig(ctx context.Context) ([]EnvConfig, error)
+	ImportConfig(ctx context.Context, configs []EnvConfig) error
 }
 
 type RepositoryConfig struct {
@@ -37,11 +53,15 @@ type RepositoryConfig struct {
 	RedisUsername string
 	RedisPassword string
 	RedisTLS      bool
+	RedisTLSFiles tlsconfig.Files
 
 	EtcdAddrs    []string
 	EtcdUsername string
 	EtcdPassword string
 	EtcdTLS      bool
+	EtcdTLSFiles tlsconfig.Files
+
+	Namespace namespace.Namespace
 }
 
 func New(ctx context.Context, cfg RepositoryConfig) (Repository, error) {
@@ -51,12 +71,13 @@ func New(ctx context.Context, cfg RepositoryConfig) (Repository, error) {
 			Password: cfg.RedisPassword,
 			Username: cfg.RedisUsername,
 			TLS:      cfg.RedisTLS,
+			TLSFiles: cfg.RedisTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return NewRedisRepository(redisCli), nil
+		return NewRedisRepository(redisCli, cfg.Namespace), nil
 	}
 
 	if len(cfg.EtcdAddrs) > 0 {
@@ -65,12 +86,13 @@ func New(ctx context.Context, cfg RepositoryConfig) (Repository, error) {
 			EtcdUsername: cfg.EtcdUsername,
 			EtcdPassword: cfg.EtcdPassword,
 			TLS:          cfg.EtcdTLS,
+			TLSFiles:     cfg.EtcdTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return NewEtcdRepository(etcdCli), nil
+		return NewEtcdRepository(etcdCli, cfg.Namespace), nil
 	}
 
 	return nil, fmt.Errorf("'RedisAddress' nor 'EtcdAddrs' has been provided for 'configuration' repository")
@@ -1,5 +1,13 @@
 package repository
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/types"
+)
+
 // EnvParams represents parameters for managing environment metadata.
 type EnvParams struct {
 	Name     string `json:"name"`
@@ -7,3 +15,111 @@ type EnvParams struct {
 	Clone    bool   `json:"clone"`
 	Original string `json:"original"`
 }
+
+// ConfigKeys lists the group names and global keys stored for an environment.
+type ConfigKeys struct {
+	Groups  []string `json:"groups"`
+	Globals []string `json:"globals"`
+}
+
+// NewConfigKeys returns the sorted group names and global keys of a parsed configuration.
+func NewConfigKeys(config *types.ParsedRepoConfig) ConfigKeys {
+	keys := ConfigKeys{Groups: make([]string, 0, len(config.Groups)), Globals: make([]string, 0, len(config.Globals))}
+	for name := range config.Groups {
+		keys.Groups = append(keys.Groups, name)
+	}
+	for key := range config.Globals {
+		keys.Globals = append(keys.Globals, key)
+	}
+
+	sort.Strings(keys.Groups)
+	sort.Strings(keys.Globals)
+
+	return keys
+}
+
+// configKeysBuilder collects the group names and global keys from the raw configuration keys of an environment.
+type configKeysBuilder struct {
+	keys ConfigKeys
+}
+
+func newConfigKeysBuilder() *configKeysBuilder {
+	return &configKeysBuilder{keys: ConfigKeys{Groups: []string{}, Globals: []string{}}}
+}
+
+func (b *configKeysBuilder) add(kind, name string) {
+	switch kind {
+	case GlobalPrefix:
+		b.keys.Globals = append(b.keys.Globals, name)
+	case GroupPrefix:
+		b.keys.Groups = append(b.keys.Groups, name)
+	}
+}
+
+func (b *configKeysBuilder) build() ConfigKeys {
+	sort.Strings(b.keys.Groups)
+	sort.Strings(b.keys.Globals)
+	return b.keys
+}
+
+// EnvConfig represents the stored configuration of an environment for a single repository,
+// it is used to export and import the configuration between storages.
+type EnvConfig struct {
+	Repo    string                       `json:"repo"`
+	Env     string                       `json:"env"`
+	Globals map[string]interface{}       `json:"globals"`
+	Groups  map[string]types.GroupConfig `json:"groups"`
+}
+
+// envConfigBuilder groups raw configuration keys by repository and environment while exporting.
+type envConfigBuilder struct {
+	configs map[string]*EnvConfig
+}
+
+func newEnvConfigBuilder() *envConfigBuilder {
+	return &envConfigBuilder{configs: map[string]*EnvConfig{}}
+}
+
+func (b *envConfigBuilder) add(repo, env, kind, name string, value []byte) error {
+	id := repo + ":" + env
+
+	cfg, ok := b.configs[id]
+	if !ok {
+		cfg = &EnvConfig{Repo: repo, Env: env, Globals: map[string]interface{}{}, Groups: map[string]types.GroupConfig{}}
+		b.configs[id] = cfg
+	}
+
+	switch kind {
+	case GlobalPrefix:
+		var global interface{}
+		if err := json.Unmarshal(value, &global); err != nil {
+			return fmt.Errorf("error unmarshalling global config '%s' of '%s' environment: %v", name, env, err)
+		}
+		cfg.Globals[name] = global
+	case GroupPrefix:
+		var group types.GroupConfig
+		if err := json.Unmarshal(value, &group); err != nil {
+			return fmt.Errorf("error unmarshalling group config '%s' of '%s' environment: %v", name, env, err)
+		}
+		cfg.Groups[name] = group
+	}
+
+	return nil
+}
+
+// build returns the collected configurations sorted by repository and environment.
+func (b *envConfigBuilder) build() []EnvConfig {
+	configs := make([]EnvConfig, 0, len(b.configs))
+	for _, cfg := range b.configs {
+		configs = append(configs, *cfg)
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Repo != configs[j].Repo {
+			return configs[i].Repo < configs[j].Repo
+		}
+		return configs[i].Env < configs[j].Env
+	})
+
+	return configs
+}
@@ -0,0 +1,186 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is the sequence of map keys and array indexes leading to a configuration value
+type Path []string
+
+// Root returns the group or global key the path starts from
+func (p Path) Root() string {
+	return p[0]
+}
+
+func (p Path) String() string {
+	return strings.Join(p, ".")
+}
+
+// InvalidSelectorError is returned when a field selector can not be parsed
+type InvalidSelectorError struct {
+	Selector string
+	Reason   string
+}
+
+func (e InvalidSelectorError) Error() string {
+	return fmt.Sprintf("invalid selector '%s': %s", e.Selector, e.Reason)
+}
+
+// Parse reads a field selector, either a JSON Pointer such as "/analytics/database/host"
+// or a dot path such as "analytics.database.host", array indexes written as "servers.0" or "servers[0]".
+func Parse(selector string) (Path, error) {
+	if selector == "" {
+		return nil, InvalidSelectorError{Selector: selector, Reason: "selector is empty"}
+	}
+
+	if strings.HasPrefix(selector, "/") {
+		return parsePointer(selector)
+	}
+
+	return parseDotPath(selector)
+}
+
+// ParseAll reads every field selector, failing on the first invalid one
+func ParseAll(selectors []string) ([]Path, error) {
+	paths := make([]Path, 0, len(selectors))
+	for _, s := range selectors {
+		path, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// parsePointer reads a JSON Pointer (RFC 6901), '~1' and '~0' being unescaped into '/' and '~'
+func parsePointer(pointer string) (Path, error) {
+	tokens := strings.Split(pointer[1:], "/")
+
+	path := make(Path, 0, len(tokens))
+	for _, token := range tokens {
+		if strings.Contains(strings.NewReplacer("~0", "", "~1", "").Replace(token), "~") {
+			return nil, InvalidSelectorError{Selector: pointer, Reason: "'~' must be escaped as '~0'"}
+		}
+		path = append(path, strings.NewReplacer("~1", "/", "~0", "~").Replace(token))
+	}
+
+	return path, nil
+}
+
+func parseDotPath(selector string) (Path, error) {
+	path := Path{}
+
+	for _, part := range strings.Split(selector, ".") {
+		key, indexes, _ := strings.Cut(part, "[")
+		if key == "" && (len(path) == 0 || indexes == "") {
+			return nil, InvalidSelectorError{Selector: selector, Reason: "empty key"}
+		}
+		if key != "" {
+			path = append(path, key)
+		}
+
+		if indexes == "" {
+			continue
+		}
+
+		// every "[i]" following the key, e.g. "matrix[0][1]"
+		for _, index := range strings.Split("["+indexes, "[")[1:] {
+			index, ok := strings.CutSuffix(index, "]")
+			if _, err := strconv.Atoi(index); !ok || err != nil {
+				return nil, InvalidSelectorError{Selector: selector, Reason: "array indexes must be written as '[i]'"}
+			}
+			path = append(path, index)
+		}
+	}
+
+	return path, nil
+}
+
+// node is a step of the tree built from the selected paths, a terminal node selects its whole subtree
+type node struct {
+	children map[string]*node
+	terminal bool
+}
+
+func newTree(paths []Path) *node {
+	root := &node{children: map[string]*node{}}
+	for _, path := range paths {
+		current := root
+		for _, segment := range path {
+			if current.terminal {
+				break
+			}
+			next, ok := current.children[segment]
+			if !ok {
+				next = &node{children: map[string]*node{}}
+				current.children[segment] = next
+			}
+			current = next
+		}
+		current.terminal = true
+		current.children = map[string]*node{}
+	}
+	return root
+}
+
+// Project keeps only the selected values of the configuration, along with the maps and arrays leading to them.
+// Arrays keep the selected elements in their original order and paths not found in the configuration are left out.
+func Project(cfg map[string]interface{}, paths []Path) map[string]interface{} {
+	projected, ok := project(cfg, newTree(paths))
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return projected.(map[string]interface{})
+}
+
+func project(value interface{}, n *node) (interface{}, bool) {
+	if n.terminal {
+		return value, true
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, child := range n.children {
+			item, ok := v[key]
+			if !ok {
+				continue
+			}
+			if projected, ok := project(item, child); ok {
+				result[key] = projected
+			}
+		}
+		return result, len(result) > 0 || len(n.children) == 0
+
+	case []interface{}:
+		children := map[int]*node{}
+		indexes := []int{}
+		for key, child := range n.children {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				continue
+			}
+			if _, ok := children[i]; !ok {
+				indexes = append(indexes, i)
+			}
+			children[i] = child
+		}
+		sort.Ints(indexes)
+
+		result := []interface{}{}
+		for _, i := range indexes {
+			if projected, ok := project(v[i], children[i]); ok {
+				result = append(result, projected)
+			}
+		}
+		return result, len(result) > 0
+
+	default:
+		// scalars have no value to select from
+		return nil, false
+	}
+}
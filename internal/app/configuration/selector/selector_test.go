@@ -0,0 +1,97 @@
+package selector_test
+
+import (
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name         string
+		selector     string
+		expectedPath selector.Path
+		expectedErr  bool
+	}{
+		{name: "Dot path", selector: "analytics.database.host", expectedPath: selector.Path{"analytics", "database", "host"}},
+		{name: "Dot path with array indexes", selector: "analytics.servers[0].host", expectedPath: selector.Path{"analytics", "servers", "0", "host"}},
+		{name: "Dot path with nested array indexes", selector: "matrix[1][2]", expectedPath: selector.Path{"matrix", "1", "2"}},
+		{name: "JSON Pointer", selector: "/analytics/servers/0/host", expectedPath: selector.Path{"analytics", "servers", "0", "host"}},
+		{name: "JSON Pointer with escaped characters", selector: "/analytics/a~1b/c~0d", expectedPath: selector.Path{"analytics", "a/b", "c~d"}},
+		{name: "Empty selector is rejected", selector: "", expectedErr: true},
+		{name: "Empty key is rejected", selector: "analytics..host", expectedErr: true},
+		{name: "Unclosed index is rejected", selector: "servers[0", expectedErr: true},
+		{name: "Non numeric index is rejected", selector: "servers[a]", expectedErr: true},
+		{name: "Unescaped JSON Pointer tilde is rejected", selector: "/analytics/a~b", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := selector.Parse(tc.selector)
+			if tc.expectedErr {
+				assert.ErrorAs(t, err, &selector.InvalidSelectorError{})
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedPath, path)
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	cfg := map[string]interface{}{
+		"analytics": map[string]interface{}{
+			"database": map[string]interface{}{"host": "localhost", "port": float64(5432), "password": "{{ secret.db }}"},
+			"servers": []interface{}{
+				map[string]interface{}{"host": "a", "port": float64(1)},
+				map[string]interface{}{"host": "b", "port": float64(2)},
+			},
+		},
+		"region": "eu-west-1",
+	}
+
+	testCases := []struct {
+		name     string
+		paths    []selector.Path
+		expected map[string]interface{}
+	}{
+		{
+			name:     "Selects a nested value keeping its parents",
+			paths:    []selector.Path{{"analytics", "database", "host"}},
+			expected: map[string]interface{}{"analytics": map[string]interface{}{"database": map[string]interface{}{"host": "localhost"}}},
+		},
+		{
+			name:  "Merges sibling selections",
+			paths: []selector.Path{{"analytics", "database", "host"}, {"analytics", "database", "port"}, {"region"}},
+			expected: map[string]interface{}{
+				"analytics": map[string]interface{}{"database": map[string]interface{}{"host": "localhost", "port": float64(5432)}},
+				"region":    "eu-west-1",
+			},
+		},
+		{
+			name:     "Selecting a subtree includes its descendants",
+			paths:    []selector.Path{{"analytics", "database"}, {"analytics", "database", "host"}},
+			expected: map[string]interface{}{"analytics": map[string]interface{}{"database": cfg["analytics"].(map[string]interface{})["database"]}},
+		},
+		{
+			name:  "Selects within arrays keeping the elements order",
+			paths: []selector.Path{{"analytics", "servers", "1", "host"}, {"analytics", "servers", "0", "host"}},
+			expected: map[string]interface{}{"analytics": map[string]interface{}{"servers": []interface{}{
+				map[string]interface{}{"host": "a"},
+				map[string]interface{}{"host": "b"},
+			}}},
+		},
+		{
+			name:     "Leaves out missing paths",
+			paths:    []selector.Path{{"analytics", "cache"}, {"region", "name"}, {"analytics", "servers", "5"}},
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, selector.Project(cfg, tc.paths))
+		})
+	}
+}
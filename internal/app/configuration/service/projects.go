@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// ProjectConfig is the git repository a project reads its configuration from
+type ProjectConfig struct {
+	RepoUrl string   `json:"repositoryUrl"`
+	Branch  string   `json:"branch"`
+	Envs    []string `json:"envs"`
+}
+
+// LoadProjects reads the project name to repository mapping from a JSON file,
+// the branch of a project defaults to 'main' when missing.
+func LoadProjects(path string) (map[string]ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading projects file '%s': %v", path, err)
+	}
+
+	projects := map[string]ProjectConfig{}
+	err = json.Unmarshal(data, &projects)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling projects file '%s': %v", path, err)
+	}
+
+	for name, cfg := range projects {
+		if name == project.Default {
+			return nil, fmt.Errorf("projects file '%s' must not configure the default project", path)
+		}
+		if err := project.Validate(name); err != nil {
+			return nil, err
+		}
+		if cfg.RepoUrl == "" || len(cfg.Envs) == 0 {
+			return nil, fmt.Errorf("project '%s' must have a repository url and at least one env", name)
+		}
+		if cfg.Branch == "" {
+			cfg.Branch = "main"
+			projects[name] = cfg
+		}
+	}
+
+	return projects, nil
+}
@@ -5,32 +5,56 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/raw-leak/configleam/internal/app/configuration/analyzer"
 	"github.com/raw-leak/configleam/internal/app/configuration/gitmanager"
 	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
 	"github.com/raw-leak/configleam/internal/app/configuration/types"
 	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/envorigin"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
 )
 
 const (
 	PullIntervalDefault = 5 * time.Second
+	HistorySizeDefault  = 10
+
+	// GitActorPrefix names the tag the encrypted secrets of the repositories were applied from, in their history
+	GitActorPrefix = "git:"
 )
 
 type Notify interface {
 	NotifyConfigUpdate(ctx context.Context, repo, env, version string)
 }
 
+// Notifiers notifies every configuration update to each of the notifiers, in order
+type Notifiers []Notify
+
+func (n Notifiers) NotifyConfigUpdate(ctx context.Context, repo, env, version string) {
+	for _, notify := range n {
+		notify.NotifyConfigUpdate(ctx, repo, env, version)
+	}
+}
+
 type Secrets interface {
-	InsertSecrets(ctx context.Context, env string, cfg *map[string]interface{}, populate bool) error
+	InsertSecrets(ctx context.Context, env string, cfg *map[string]interface{}, reveal func(env string) bool) error
 	CloneSecrets(ctx context.Context, env, newEnv string) error
+	ApplySecrets(ctx context.Context, env string, secrets map[string]interface{}) (int, error)
+	PruneSecrets(ctx context.Context, env string, applied map[string]interface{}, managed func(changedBy string) bool) (int, error)
 }
 
 type Extractor interface {
 	ExtractConfigList(dir string) (*types.ExtractedConfigList, error)
+	ExtractSecrets(dir string) (map[string]interface{}, error)
 }
 
 type Parser interface {
@@ -41,13 +65,21 @@ type Analyzer interface {
 	AnalyzeTagsForUpdates(envs map[string]gitmanager.Env, tags []string) ([]analyzer.EnvUpdate, bool, error)
 }
 
-type ConfigurationService struct {
+// projectRepo holds the git repository and the reserved environments of a single project
+type projectRepo struct {
 	gitrepo *gitmanager.GitRepository
 	envs    map[string]bool
+	sync    projectSync
+}
+
+type ConfigurationService struct {
+	projects map[string]*projectRepo
 
 	mux          sync.RWMutex
 	pollInterval time.Duration
 	ticker       *time.Ticker
+	historySize  int
+	running      atomic.Bool
 
 	repository repository.Repository
 	extractor  Extractor
@@ -59,31 +91,44 @@ type ConfigurationService struct {
 }
 
 type ConfigurationConfig struct {
-	RepoUrl      string
-	Envs         []string
-	Branch       string
+	Projects     map[string]ProjectConfig
 	PullInterval time.Duration
+	// amount of applied versions kept per environment to be read with '?version='
+	HistorySize int
 }
 
 func New(cfg ConfigurationConfig, parser Parser, extractor Extractor, repository repository.Repository, analyzer Analyzer, secrets Secrets, notify Notify) *ConfigurationService {
-	gitrepo, err := gitmanager.NewGitRepository(cfg.RepoUrl, cfg.Branch, cfg.Envs)
-	if err != nil {
-		log.Fatalf("Fatal generating '%s' local git-repository", cfg.RepoUrl)
+	projects := make(map[string]*projectRepo, len(cfg.Projects))
+
+	for name, projectCfg := range cfg.Projects {
+		gitrepo, err := gitmanager.NewGitRepository(projectCfg.RepoUrl, projectCfg.Branch, projectCfg.Envs)
+		if err != nil {
+			log.Fatalf("Fatal generating '%s' local git-repository", projectCfg.RepoUrl)
+		}
+
+		// repositories of different projects may share the same name
+		gitrepo.Dir = filepath.Join("repositories", name, gitrepo.Name)
+
+		envs := map[string]bool{}
+		for _, env := range projectCfg.Envs {
+			envs[env] = true
+		}
+
+		projects[name] = &projectRepo{gitrepo: gitrepo, envs: envs}
 	}
 
 	if cfg.PullInterval == 0 {
 		cfg.PullInterval = PullIntervalDefault
 	}
 
-	envs := map[string]bool{}
-	for _, env := range cfg.Envs {
-		envs[env] = true
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = HistorySizeDefault
 	}
 
 	return &ConfigurationService{
-		gitrepo:      gitrepo,
-		envs:         envs,
+		projects:     projects,
 		pollInterval: cfg.PullInterval,
+		historySize:  cfg.HistorySize,
 		mux:          sync.RWMutex{},
 		repository:   repository,
 		extractor:    extractor,
@@ -95,44 +140,138 @@ func New(cfg ConfigurationConfig, parser Parser, extractor Extractor, repository
 }
 
 func (s *ConfigurationService) Run(ctx context.Context) {
-	err := s.cloneAllRemoteRepos(ctx)
-	if err != nil {
-		log.Fatalf(err.Error())
-	}
+	for name, p := range s.projects {
+		projectCtx := project.WithProject(ctx, name)
 
-	err = s.buildConfigFromLocalFirstTime(ctx)
-	if err != nil {
-		log.Fatalf(err.Error())
+		err := s.cloneAllRemoteRepos(projectCtx, p)
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+
+		err = s.buildConfigFromLocalFirstTime(projectCtx, p)
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
 	}
 
+	s.running.Store(true)
 	go s.watchRemoteReposForUpdates()
 }
 
-func (s *ConfigurationService) ReadConfig(ctx context.Context, env string, groups, globals []string) (map[string]interface{}, error) {
+// project returns the project the context belongs to
+func (s *ConfigurationService) project(ctx context.Context) (*projectRepo, error) {
+	name := project.FromContext(ctx)
+
+	p, ok := s.projects[name]
+	if !ok {
+		return nil, fmt.Errorf("project '%s' not found", name)
+	}
+
+	return p, nil
+}
+
+// ReadConfig reads the requested groups and globals of the env, returning them along with the version they belong to.
+// The version applied to the env is read unless a previous one, still kept in the history, is requested.
+// Secrets are not versioned, so their current value is inserted in any version.
+// When fields are selected only their values are returned and only their secrets resolved, the groups and
+// globals to read being taken from the first segment of each field when none are requested.
+func (s *ConfigurationService) ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error) {
 	if env == "" {
-		return nil, errors.New("env cannot be empty")
+		return nil, "", errors.New("env cannot be empty")
+	}
+
+	if len(fields) > 0 && len(groups) == 0 && len(globals) == 0 {
+		var err error
+		groups, globals, err = s.fieldsRoots(ctx, env, version, fields)
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
 	accessKeyPerms, ok := ctx.Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
 	if !ok {
-		return nil, errors.New("permissions were not found")
+		return nil, "", errors.New("permissions were not found")
 	}
 
-	cfg, err := s.repository.ReadConfig(ctx, s.gitrepo.Name, env, groups, globals)
+	cfg, version, err := s.selectConfig(ctx, env, version, groups, globals, fields)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read configuration: %w", err)
+		return nil, "", err
 	}
 
-	err = s.secrets.InsertSecrets(ctx, env, &cfg, accessKeyPerms.CanRevealSecrets(env))
+	err = s.insertSecrets(ctx, env, accessKeyPerms, &cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert secrets: %w", err)
+		return nil, "", err
 	}
 
-	return cfg, nil
+	return cfg, version, nil
 }
 
-func (s *ConfigurationService) cloneAllRemoteRepos(_ context.Context) error {
-	err := s.gitrepo.CloneRemoteRepo()
+// selectConfig reads the groups and global keys of the env at the version, projected on the fields when any,
+// their secret placeholders left as they are
+func (s *ConfigurationService) selectConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error) {
+	p, err := s.project(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	appliedVersion, err := s.appliedVersion(ctx, env)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var cfg map[string]interface{}
+
+	if version == "" || version == appliedVersion {
+		version = appliedVersion
+
+		cfg, err = s.repository.ReadConfig(ctx, p.gitrepo.Name, env, groups, globals)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read configuration: %w", err)
+		}
+	} else {
+		versionConfig, err := s.repository.GetConfigVersion(ctx, p.gitrepo.Name, env, version)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read configuration version: %w", err)
+		}
+
+		cfg = versionConfig.Select(groups, globals)
+	}
+
+	if len(fields) > 0 {
+		cfg = selector.Project(cfg, fields)
+	}
+
+	return cfg, version, nil
+}
+
+// insertSecrets resolves the secret placeholders of the configuration as allowed by the permissions of the access key
+func (s *ConfigurationService) insertSecrets(ctx context.Context, env string, accessKeyPerms permissions.AccessKeyPermissions, cfg *map[string]interface{}) error {
+	// the secrets mode requested by the read prevails over the one of the access key
+	if secretmode.FromContext(ctx) == "" {
+		ctx = secretmode.WithMode(ctx, accessKeyPerms.SecretMode)
+	}
+
+	// a clone references the external secrets its original env is allowed to
+	if !s.IsEnvOriginal(ctx, env) {
+		original, ok, err := s.repository.GetEnvOriginal(ctx, env)
+		if err != nil {
+			return fmt.Errorf("failed to read original env: %w", err)
+		}
+		if ok {
+			ctx = envorigin.WithOriginal(ctx, env, original)
+		}
+	}
+
+	err := s.secrets.InsertSecrets(ctx, env, cfg, accessKeyPerms.CanRevealSecrets)
+	if err != nil {
+		return fmt.Errorf("failed to insert secrets: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ConfigurationService) cloneAllRemoteRepos(_ context.Context, p *projectRepo) error {
+	err := p.gitrepo.CloneRemoteRepo()
 	if err != nil {
 		return err
 	}
@@ -140,15 +279,15 @@ func (s *ConfigurationService) cloneAllRemoteRepos(_ context.Context) error {
 	return nil
 }
 
-func (s *ConfigurationService) buildConfigFromLocalFirstTime(ctx context.Context) error {
-	err := s.buildConfigFromLocalRepo(ctx)
+func (s *ConfigurationService) buildConfigFromLocalFirstTime(ctx context.Context, p *projectRepo) error {
+	err := s.syncProject(ctx, p)
 	if err != nil {
 		log.Printf("Error while building the config from a local repo: %e\n", err)
 		return err
 	}
 
 	addedEnvs := []string{}
-	for _, env := range s.gitrepo.Envs {
+	for _, env := range p.gitrepo.Envs {
 		envParams := repository.EnvParams{
 			Name:    env.Name,
 			Version: env.LastTag,
@@ -156,7 +295,7 @@ func (s *ConfigurationService) buildConfigFromLocalFirstTime(ctx context.Context
 		}
 		err = s.repository.AddEnv(ctx, env.Name, envParams)
 		if err != nil {
-			log.Printf("Error while adding environment '%s' to the repository: %e\n", s.gitrepo.Name, err)
+			log.Printf("Error while adding environment '%s' to the repository: %e\n", p.gitrepo.Name, err)
 			for _, addedEnv := range addedEnvs {
 				delErr := s.repository.DeleteEnv(ctx, addedEnv)
 				if delErr != nil {
@@ -175,40 +314,46 @@ func (s *ConfigurationService) watchRemoteReposForUpdates() {
 	s.ticker = time.NewTicker(s.pollInterval)
 
 	for range s.ticker.C {
-		err := s.buildConfigFromLocalRepo(context.Background())
-		if err != nil {
-			log.Printf("Error on watching while building the config from a local repo: %e\n", err)
+		for name, p := range s.projects {
+			err := s.syncProject(project.WithProject(context.Background(), name), p)
+			if err != nil {
+				log.Printf("Error on watching while building the config from a local repo: %e\n", err)
+			}
 		}
 	}
 }
 
-func (s *ConfigurationService) buildConfigFromLocalRepo(ctx context.Context) error {
-	tags, err := s.gitrepo.PullTagsFromRemoteRepo()
+func (s *ConfigurationService) buildConfigFromLocalRepo(ctx context.Context, p *projectRepo) error {
+	tags, err := p.gitrepo.PullTagsFromRemoteRepo()
 	if err != nil {
 		log.Println("Error pulling tags:", err)
 		return err
 	}
 
-	updatedEnvs, ok, err := s.analyzer.AnalyzeTagsForUpdates(s.gitrepo.Envs, tags)
+	updatedEnvs, ok, err := s.analyzer.AnalyzeTagsForUpdates(p.gitrepo.Envs, tags)
 	if err != nil {
 		log.Println("Error analyzing tags:", err)
 		return err
 
 	}
 	if !ok {
-		log.Printf("No changes detected for '%s' repository", s.gitrepo.URL)
+		log.Printf("No changes detected for '%s' repository", p.gitrepo.URL)
 		return nil
 	}
 
 	for _, env := range updatedEnvs {
 		log.Printf("Applying detected new '%s' version for '%s' environment", env.Tag, env.Name)
 
-		s.gitrepo.FetchAndCheckout(env.Tag)
+		p.gitrepo.FetchAndCheckout(env.Tag)
 
-		// need to lock the repo from change while extracting the config-list
-		s.gitrepo.Mux.Lock()
-		configList, err := s.extractor.ExtractConfigList(s.gitrepo.Dir + "/" + env.Name)
-		s.gitrepo.Mux.Unlock()
+		// need to lock the repo from change while extracting the config-list and the encrypted secrets
+		p.gitrepo.Mux.Lock()
+		configList, err := s.extractor.ExtractConfigList(p.gitrepo.Dir + "/" + env.Name)
+		var secrets map[string]interface{}
+		if err == nil {
+			secrets, err = s.extractor.ExtractSecrets(p.gitrepo.Dir + "/" + env.Name)
+		}
+		p.gitrepo.Mux.Unlock()
 
 		if err != nil {
 			log.Printf("Error extracting configuration from '%s' repository for '%s': %v", env.Name, env.Tag, err)
@@ -221,16 +366,50 @@ func (s *ConfigurationService) buildConfigFromLocalRepo(ctx context.Context) err
 			return err
 		}
 
+		// the secrets are applied first so the new configuration never references secrets that are not stored yet
+		gitCtx := auth.WithActor(ctx, GitActorPrefix+env.Tag)
+		if len(secrets) > 0 {
+			applied, err := s.secrets.ApplySecrets(gitCtx, env.Name, secrets)
+			if err != nil {
+				log.Printf("Error applying encrypted secrets of '%s' environment for '%s': %v", env.Name, env.Tag, err)
+				return err
+			}
+			log.Printf("Applied %d changed encrypted secrets of '%s' environment for '%s'", applied, env.Name, env.Tag)
+		}
+
 		log.Printf("Upserting new configuration for '%s' environment for '%s': %v", env.Name, env.Tag, err)
-		err = s.repository.UpsertConfig(ctx, s.gitrepo.Name, env.Name, repoConfig)
+		err = s.repository.UpsertConfig(ctx, p.gitrepo.Name, env.Name, repoConfig)
 		if err != nil {
 			log.Printf("Error upserting configuration from '%s' environment for '%s': %v", env.Name, env.Tag, err)
 			return err
 		}
 
-		s.gitrepo.SetEnvLatestVersion(ctx, env.Name, env.Tag, env.SemVer)
+		// the secrets removed from the encrypted files are deleted once the new configuration is served, unless
+		// they were changed through the API since they were last applied from the repository
+		pruned, err := s.secrets.PruneSecrets(gitCtx, env.Name, secrets, isGitActor)
+		if err != nil {
+			// the removed secrets are only kept until the next applied version
+			log.Printf("Error deleting the removed encrypted secrets of '%s' environment for '%s': %v", env.Name, env.Tag, err)
+		} else if pruned > 0 {
+			log.Printf("Deleted %d removed encrypted secrets of '%s' environment for '%s'", pruned, env.Name, env.Tag)
+		}
+
+		err = s.repository.SaveConfigVersion(ctx, p.gitrepo.Name, env.Name, env.Tag, repoConfig, s.historySize)
+		if err != nil {
+			// the applied configuration is still served, only reading this version with '?version=' is not possible
+			log.Printf("Error saving configuration version '%s' of '%s' environment: %v", env.Tag, env.Name, err)
+		}
+
+		// keeps the applied version of the environment up to date
+		err = s.repository.AddEnv(ctx, env.Name, repository.EnvParams{Name: env.Name, Version: env.Tag})
+		if err != nil {
+			log.Printf("Error updating version '%s' of '%s' environment: %v", env.Tag, env.Name, err)
+			return err
+		}
+
+		p.gitrepo.SetEnvLatestVersion(ctx, env.Name, env.Tag, env.SemVer)
 
-		s.notify.NotifyConfigUpdate(ctx, s.gitrepo.Name, env.Name, env.Tag)
+		s.notify.NotifyConfigUpdate(ctx, p.gitrepo.Name, env.Name, env.Tag)
 	}
 
 	return nil
@@ -239,15 +418,18 @@ func (s *ConfigurationService) buildConfigFromLocalRepo(ctx context.Context) err
 func (s *ConfigurationService) cleanLocalRepos() {
 	log.Println("Cleaning local repositories...")
 
-	err := s.gitrepo.RemoveLocalRepo()
-	if err != nil {
-		log.Printf("Error on removing local repo %s from dir %s", s.gitrepo.URL, s.gitrepo.Dir)
+	for _, p := range s.projects {
+		err := p.gitrepo.RemoveLocalRepo()
+		if err != nil {
+			log.Printf("Error on removing local repo %s from dir %s", p.gitrepo.URL, p.gitrepo.Dir)
+		}
 	}
 
 	// TODO: do we need to clear the repo environments once down?
 }
 
 func (s *ConfigurationService) Shutdown() {
+	s.running.Store(false)
 	if s.ticker != nil {
 		s.ticker.Stop()
 		s.ticker = nil
@@ -256,13 +438,18 @@ func (s *ConfigurationService) Shutdown() {
 }
 
 func (s *ConfigurationService) DeleteConfig(ctx context.Context, deleteEnv string) error {
-	for reservedEnv := range s.envs {
+	p, err := s.project(ctx)
+	if err != nil {
+		return err
+	}
+
+	for reservedEnv := range p.envs {
 		if reservedEnv == deleteEnv {
 			return fmt.Errorf("env '%s' reserved and can not be deleted", deleteEnv)
 		}
 	}
 
-	err := s.repository.DeleteConfig(ctx, s.gitrepo.Name, deleteEnv)
+	err = s.repository.DeleteConfig(ctx, p.gitrepo.Name, deleteEnv)
 	if err != nil {
 		log.Printf("Error deleting config environment '%s' with error %v:", deleteEnv, err)
 		return err
@@ -278,9 +465,14 @@ func (s *ConfigurationService) DeleteConfig(ctx context.Context, deleteEnv strin
 }
 
 func (s *ConfigurationService) CloneConfig(ctx context.Context, env, newEnv string, updateGlobals map[string]interface{}) error {
+	p, err := s.project(ctx)
+	if err != nil {
+		return err
+	}
+
 	found := false
 
-	for e := range s.envs {
+	for e := range p.envs {
 		if e == env {
 			found = true
 			break
@@ -291,31 +483,31 @@ func (s *ConfigurationService) CloneConfig(ctx context.Context, env, newEnv stri
 		return fmt.Errorf("env %s for cloning has not been found", env)
 	}
 
-	s.gitrepo.Mux.Lock()
-	defer s.gitrepo.Mux.Unlock()
+	p.gitrepo.Mux.Lock()
+	defer p.gitrepo.Mux.Unlock()
 
-	if err := s.repository.CloneConfig(ctx, s.gitrepo.Name, env, newEnv, updateGlobals); err != nil {
+	if err := s.repository.CloneConfig(ctx, p.gitrepo.Name, env, newEnv, updateGlobals); err != nil {
 		return err
 	}
 
 	if err := s.secrets.CloneSecrets(ctx, env, newEnv); err != nil {
 		log.Printf("Error cloning secrets for %s to %s: %v", env, newEnv, err)
-		if delErr := s.repository.DeleteConfig(ctx, s.gitrepo.Name, newEnv); delErr != nil {
+		if delErr := s.repository.DeleteConfig(ctx, p.gitrepo.Name, newEnv); delErr != nil {
 			log.Printf("Error cleaning up config for '%s' after failed secrets clone: %v", newEnv, delErr)
 		}
 		return err
 	}
 
 	newEnvParams := repository.EnvParams{
-		Name:     s.gitrepo.Name,
-		Version:  s.gitrepo.LastTag,
+		Name:     newEnv,
+		Version:  p.gitrepo.LastTag,
 		Clone:    true,
 		Original: env,
 	}
-	err := s.repository.AddEnv(ctx, newEnv, newEnvParams)
+	err = s.repository.AddEnv(ctx, newEnv, newEnvParams)
 	if err != nil {
 		log.Printf("Error adding clone '%s' of environment '%s': %v", env, newEnv, err)
-		if delErr := s.repository.DeleteConfig(ctx, s.gitrepo.Name, newEnv); delErr != nil {
+		if delErr := s.repository.DeleteConfig(ctx, p.gitrepo.Name, newEnv); delErr != nil {
 			log.Printf("Error cleaning up config for %s after failed adding clone: %v", newEnv, delErr)
 		}
 		return err
@@ -324,10 +516,164 @@ func (s *ConfigurationService) CloneConfig(ctx context.Context, env, newEnv stri
 	return nil
 }
 
+// ListEnvs returns the stored environments, with their version and the environment they were cloned from,
+// that the access key is allowed to read, a clone being readable with the permissions of its original.
+func (s *ConfigurationService) ListEnvs(ctx context.Context) ([]repository.EnvParams, error) {
+	accessKeyPerms, ok := ctx.Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+	if !ok {
+		return nil, errors.New("permissions were not found")
+	}
+
+	envs, err := s.repository.GetAllEnvs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environments: %w", err)
+	}
+
+	readable := make([]repository.EnvParams, 0, len(envs))
+	for _, env := range envs {
+		permsEnv := env.Name
+		if env.Clone {
+			permsEnv = env.Original
+		}
+
+		if accessKeyPerms.Can(permsEnv, permissions.ReadConfig) {
+			readable = append(readable, env)
+		}
+	}
+
+	sort.Slice(readable, func(i, j int) bool { return readable[i].Name < readable[j].Name })
+
+	return readable, nil
+}
+
+// ListConfigKeys returns the group names and global keys of the env, along with the version they belong to.
+// The version applied to the env is listed unless a previous one, still kept in the history, is requested.
+func (s *ConfigurationService) ListConfigKeys(ctx context.Context, env, version string) (repository.ConfigKeys, string, error) {
+	if env == "" {
+		return repository.ConfigKeys{}, "", errors.New("env cannot be empty")
+	}
+
+	p, err := s.project(ctx)
+	if err != nil {
+		return repository.ConfigKeys{}, "", err
+	}
+
+	appliedVersion, err := s.appliedVersion(ctx, env)
+	if err != nil {
+		return repository.ConfigKeys{}, "", err
+	}
+
+	if version == "" || version == appliedVersion {
+		keys, err := s.repository.GetConfigKeys(ctx, p.gitrepo.Name, env)
+		if err != nil {
+			return repository.ConfigKeys{}, "", fmt.Errorf("failed to list configuration keys: %w", err)
+		}
+		return keys, appliedVersion, nil
+	}
+
+	versionConfig, err := s.repository.GetConfigVersion(ctx, p.gitrepo.Name, env, version)
+	if err != nil {
+		return repository.ConfigKeys{}, "", fmt.Errorf("failed to read configuration version: %w", err)
+	}
+
+	return repository.NewConfigKeys(versionConfig), version, nil
+}
+
+// ReadEnvConfig reads the whole env, every group resolved with the global keys it references under "groups"
+// and every global key under "globals", returning it along with the version it belongs to.
+func (s *ConfigurationService) ReadEnvConfig(ctx context.Context, env, version string) (map[string]interface{}, string, error) {
+	keys, version, err := s.ListConfigKeys(ctx, env, version)
+	if err != nil {
+		return nil, "", err
+	}
+
+	accessKeyPerms, ok := ctx.Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+	if !ok {
+		return nil, "", errors.New("permissions were not found")
+	}
+
+	// groups and globals are read apart as a group may share its name with a global key
+	groups, _, err := s.selectConfig(ctx, env, version, keys.Groups, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	globals, _, err := s.selectConfig(ctx, env, version, nil, keys.Globals, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the secrets of both are inserted at once, so that every unresolved one is reported in Strict mode
+	cfg := map[string]interface{}{"groups": groups, "globals": globals}
+	err = s.insertSecrets(ctx, env, accessKeyPerms, &cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cfg, version, nil
+}
+
+// fieldsRoots splits the first segment of the fields into the groups and global keys of the env they refer to,
+// a group taking precedence over a global key with the same name
+func (s *ConfigurationService) fieldsRoots(ctx context.Context, env, version string, fields []selector.Path) ([]string, []string, error) {
+	keys, _, err := s.ListConfigKeys(ctx, env, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isGroup, isGlobal := map[string]bool{}, map[string]bool{}
+	for _, group := range keys.Groups {
+		isGroup[group] = true
+	}
+	for _, global := range keys.Globals {
+		isGlobal[global] = true
+	}
+
+	groups, globals, seen := []string{}, []string{}, map[string]bool{}
+	for _, field := range fields {
+		root := field.Root()
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+
+		if isGroup[root] {
+			groups = append(groups, root)
+		} else if isGlobal[root] {
+			globals = append(globals, root)
+		}
+	}
+
+	return groups, globals, nil
+}
+
+// isGitActor reports whether a change was applied from a tag of the repositories
+func isGitActor(changedBy string) bool {
+	return strings.HasPrefix(changedBy, GitActorPrefix)
+}
+
+// appliedVersion returns the version applied to the env, empty when the env has no metadata stored
+func (s *ConfigurationService) appliedVersion(ctx context.Context, env string) (string, error) {
+	params, err := s.repository.GetEnvParams(ctx, env)
+	if err != nil {
+		if errors.As(err, &repository.EnvNotFoundError{}) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read environment: %w", err)
+	}
+
+	return params.Version, nil
+}
+
 func (s *ConfigurationService) GetEnvs(ctx context.Context) []string {
-	envs := make([]string, 0, len(s.envs))
+	p, err := s.project(ctx)
+	if err != nil {
+		return []string{}
+	}
+
+	envs := make([]string, 0, len(p.envs))
 
-	for env := range s.envs {
+	for env := range p.envs {
 		envs = append(envs, env)
 	}
 
@@ -335,10 +681,33 @@ func (s *ConfigurationService) GetEnvs(ctx context.Context) []string {
 }
 
 func (s *ConfigurationService) IsEnvOriginal(ctx context.Context, env string) bool {
-	_, ok := s.envs[env]
+	p, err := s.project(ctx)
+	if err != nil {
+		return false
+	}
+
+	_, ok := p.envs[env]
 	return ok
 }
 
 func (s *ConfigurationService) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
 	return s.repository.GetEnvOriginal(ctx, env)
 }
+
+// ProjectExists reports whether the project has been configured
+func (s *ConfigurationService) ProjectExists(ctx context.Context, name string) bool {
+	_, ok := s.projects[name]
+	return ok
+}
+
+// GetProjects returns the names of all the configured projects
+func (s *ConfigurationService) GetProjects(ctx context.Context) []string {
+	projects := make([]string, 0, len(s.projects))
+
+	for name := range s.projects {
+		projects = append(projects, name)
+	}
+	sort.Strings(projects)
+
+	return projects
+}
@@ -0,0 +1,302 @@
+package service_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/app/configuration/service"
+	"github.com/raw-leak/configleam/internal/app/configuration/types"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CloneConfig(ctx context.Context, repo, env, newEnv string, updateGlobals map[string]interface{}) error {
+	args := m.Called(ctx, repo, env, newEnv, updateGlobals)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ReadConfig(ctx context.Context, repo, env string, groups, globalKeys []string) (map[string]interface{}, error) {
+	args := m.Called(ctx, repo, env, groups, globalKeys)
+	cfg, _ := args.Get(0).(map[string]interface{})
+	return cfg, args.Error(1)
+}
+
+func (m *MockRepository) GetConfigKeys(ctx context.Context, repo, env string) (repository.ConfigKeys, error) {
+	args := m.Called(ctx, repo, env)
+	return args.Get(0).(repository.ConfigKeys), args.Error(1)
+}
+
+func (m *MockRepository) UpsertConfig(ctx context.Context, repo, env string, config *types.ParsedRepoConfig) error {
+	args := m.Called(ctx, repo, env, config)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteConfig(ctx context.Context, repo, env string) error {
+	args := m.Called(ctx, repo, env)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SaveConfigVersion(ctx context.Context, repo, env, version string, config *types.ParsedRepoConfig, keep int) error {
+	args := m.Called(ctx, repo, env, version, config, keep)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetConfigVersion(ctx context.Context, repo, env, version string) (*types.ParsedRepoConfig, error) {
+	args := m.Called(ctx, repo, env, version)
+	config, _ := args.Get(0).(*types.ParsedRepoConfig)
+	return config, args.Error(1)
+}
+
+func (m *MockRepository) AddEnv(ctx context.Context, env string, params repository.EnvParams) error {
+	args := m.Called(ctx, env, params)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteEnv(ctx context.Context, env string) error {
+	args := m.Called(ctx, env)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
+	args := m.Called(ctx, env)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockRepository) SetEnvVersion(ctx context.Context, env string, v string) error {
+	args := m.Called(ctx, env, v)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetAllEnvs(ctx context.Context) ([]repository.EnvParams, error) {
+	args := m.Called(ctx)
+	envs, _ := args.Get(0).([]repository.EnvParams)
+	return envs, args.Error(1)
+}
+
+func (m *MockRepository) GetEnvParams(ctx context.Context, env string) (repository.EnvParams, error) {
+	args := m.Called(ctx, env)
+	return args.Get(0).(repository.EnvParams), args.Error(1)
+}
+
+func (m *MockRepository) ExportConfig(ctx context.Context) ([]repository.EnvConfig, error) {
+	args := m.Called(ctx)
+	configs, _ := args.Get(0).([]repository.EnvConfig)
+	return configs, args.Error(1)
+}
+
+func (m *MockRepository) ImportConfig(ctx context.Context, configs []repository.EnvConfig) error {
+	args := m.Called(ctx, configs)
+	return args.Error(0)
+}
+
+// MockSecrets records the configuration secrets are inserted into, failing as in Strict mode on the missing placeholders
+type MockSecrets struct {
+	inserted []map[string]interface{}
+	// missing maps the placeholders that can not be resolved to the name of their secret
+	missing map[string]string
+}
+
+func (m *MockSecrets) InsertSecrets(ctx context.Context, env string, cfg *map[string]interface{}, reveal func(env string) bool) error {
+	m.inserted = append(m.inserted, *cfg)
+
+	unresolved := m.unresolved(*cfg)
+	if len(unresolved) > 0 {
+		sort.Slice(unresolved, func(i, j int) bool { return unresolved[i].Key < unresolved[j].Key })
+		return secretmode.UnresolvedError{Unresolved: unresolved}
+	}
+	return nil
+}
+
+func (m *MockSecrets) unresolved(value interface{}) []secretmode.Unresolved {
+	unresolved := []secretmode.Unresolved{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, nested := range v {
+			unresolved = append(unresolved, m.unresolved(nested)...)
+		}
+	case string:
+		if key, ok := m.missing[v]; ok {
+			unresolved = append(unresolved, secretmode.Unresolved{Key: key, Reason: secretmode.ReasonMissing})
+		}
+	}
+	return unresolved
+}
+
+func (m *MockSecrets) CloneSecrets(ctx context.Context, env, newEnv string) error {
+	return nil
+}
+
+func (m *MockSecrets) ApplySecrets(ctx context.Context, env string, secrets map[string]interface{}) (int, error) {
+	return len(secrets), nil
+}
+
+func (m *MockSecrets) PruneSecrets(ctx context.Context, env string, applied map[string]interface{}, managed func(changedBy string) bool) (int, error) {
+	return 0, nil
+}
+
+type ConfigurationServiceSuite struct {
+	suite.Suite
+	repository *MockRepository
+	secrets    *MockSecrets
+	service    *service.ConfigurationService
+}
+
+func TestConfigurationServiceSuite(t *testing.T) {
+	suite.Run(t, new(ConfigurationServiceSuite))
+}
+
+func (suite *ConfigurationServiceSuite) SetupTest() {
+	suite.repository = new(MockRepository)
+	suite.secrets = &MockSecrets{}
+
+	cfg := service.ConfigurationConfig{
+		Projects: map[string]service.ProjectConfig{
+			"": {RepoUrl: "https://github.com/acme/config.git", Branch: "main", Envs: []string{"develop", "production"}},
+		},
+	}
+	suite.service = service.New(cfg, nil, nil, suite.repository, nil, suite.secrets, nil)
+}
+
+func (suite *ConfigurationServiceSuite) ctx(perms *permissions.AccessKeyPermissions) context.Context {
+	return context.WithValue(context.Background(), auth.AccessKeyContextKey{}, *perms)
+}
+
+func (suite *ConfigurationServiceSuite) adminCtx() context.Context {
+	perms := permissions.New().NewAccessKeyPermissions()
+	perms.SetAdmin()
+	return suite.ctx(perms)
+}
+
+func (suite *ConfigurationServiceSuite) TestReadConfigVersion() {
+	suite.repository.On("GetEnvParams", mock.Anything, "develop").Return(repository.EnvParams{Name: "develop", Version: "v1.1.0"}, nil)
+	suite.repository.On("ReadConfig", mock.Anything, "config", "develop", []string{"billing"}, []string(nil)).
+		Return(map[string]interface{}{"billing": map[string]interface{}{"host": "current"}}, nil)
+	suite.repository.On("GetConfigVersion", mock.Anything, "config", "develop", "v1.0.0").
+		Return(&types.ParsedRepoConfig{Groups: map[string]types.GroupConfig{"billing": {Local: map[string]interface{}{"host": "previous"}}}}, nil)
+	suite.repository.On("GetConfigVersion", mock.Anything, "config", "develop", "v0.1.0").
+		Return(nil, repository.ConfigVersionNotFoundError{Env: "develop", Version: "v0.1.0"})
+
+	testCases := []struct {
+		name            string
+		version         string
+		expectedConfig  map[string]interface{}
+		expectedVersion string
+		expectedErr     bool
+	}{
+		{name: "Reads the applied version by default", version: "", expectedConfig: map[string]interface{}{"billing": map[string]interface{}{"host": "current"}}, expectedVersion: "v1.1.0"},
+		{name: "Reads the applied version when requested", version: "v1.1.0", expectedConfig: map[string]interface{}{"billing": map[string]interface{}{"host": "current"}}, expectedVersion: "v1.1.0"},
+		{name: "Reads a previous version from the history", version: "v1.0.0", expectedConfig: map[string]interface{}{"billing": map[string]interface{}{"host": "previous"}}, expectedVersion: "v1.0.0"},
+		{name: "Fails for a version not kept in the history", version: "v0.1.0", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			cfg, version, err := suite.service.ReadConfig(suite.adminCtx(), "develop", tc.version, []string{"billing"}, nil, nil)
+			if tc.expectedErr {
+				suite.ErrorAs(err, &repository.ConfigVersionNotFoundError{})
+				return
+			}
+			suite.NoError(err)
+			suite.Equal(tc.expectedConfig, cfg)
+			suite.Equal(tc.expectedVersion, version)
+		})
+	}
+}
+
+func (suite *ConfigurationServiceSuite) TestReadConfigFields() {
+	suite.repository.On("GetEnvParams", mock.Anything, "develop").Return(repository.EnvParams{Name: "develop", Version: "v1.1.0"}, nil)
+	suite.repository.On("GetConfigKeys", mock.Anything, "config", "develop").
+		Return(repository.ConfigKeys{Groups: []string{"analytics", "billing"}, Globals: []string{"analytics", "region"}}, nil)
+	suite.repository.On("ReadConfig", mock.Anything, "config", "develop", []string{"analytics"}, []string{"region"}).
+		Return(map[string]interface{}{
+			"analytics": map[string]interface{}{
+				"database": map[string]interface{}{"host": "localhost", "password": "{{ secret.db }}"},
+			},
+			"region": "eu-west-1",
+		}, nil)
+
+	fields := []selector.Path{{"analytics", "database", "host"}, {"region"}, {"unknown", "key"}}
+
+	cfg, _, err := suite.service.ReadConfig(suite.adminCtx(), "develop", "", nil, nil, fields)
+	suite.NoError(err)
+
+	expected := map[string]interface{}{
+		"analytics": map[string]interface{}{"database": map[string]interface{}{"host": "localhost"}},
+		"region":    "eu-west-1",
+	}
+	suite.Equal(expected, cfg)
+
+	// the secret placeholder out of the selected fields is never handed over to be resolved
+	suite.Equal([]map[string]interface{}{expected}, suite.secrets.inserted)
+}
+
+func (suite *ConfigurationServiceSuite) TestReadEnvConfigReportsEveryUnresolvedSecret() {
+	suite.secrets.missing = map[string]string{"{{ secret.db }}": "db", "{{ secret.api-token }}": "api-token"}
+
+	suite.repository.On("GetEnvParams", mock.Anything, "develop").Return(repository.EnvParams{Name: "develop", Version: "v1.1.0"}, nil)
+	suite.repository.On("GetConfigKeys", mock.Anything, "config", "develop").
+		Return(repository.ConfigKeys{Groups: []string{"analytics"}, Globals: []string{"token"}}, nil)
+	suite.repository.On("ReadConfig", mock.Anything, "config", "develop", []string{"analytics"}, []string(nil)).
+		Return(map[string]interface{}{"analytics": map[string]interface{}{"password": "{{ secret.db }}"}}, nil)
+	suite.repository.On("ReadConfig", mock.Anything, "config", "develop", []string(nil), []string{"token"}).
+		Return(map[string]interface{}{"token": "{{ secret.api-token }}"}, nil)
+
+	ctx := secretmode.WithMode(suite.adminCtx(), secretmode.Strict)
+	_, _, err := suite.service.ReadEnvConfig(ctx, "develop", "")
+
+	var unresolved secretmode.UnresolvedError
+	suite.Require().ErrorAs(err, &unresolved)
+	suite.Equal([]secretmode.Unresolved{
+		{Key: "api-token", Reason: secretmode.ReasonMissing},
+		{Key: "db", Reason: secretmode.ReasonMissing},
+	}, unresolved.Unresolved)
+
+	// the groups and the globals are resolved in a single pass
+	suite.Len(suite.secrets.inserted, 1)
+}
+
+func (suite *ConfigurationServiceSuite) TestListEnvs() {
+	suite.repository.On("GetAllEnvs", mock.Anything).Return([]repository.EnvParams{
+		{Name: "production", Version: "v1.0.0"},
+		{Name: "develop", Version: "v1.1.0"},
+		{Name: "develop-feature", Version: "v1.1.0", Clone: true, Original: "develop"},
+		{Name: "production-copy", Version: "v1.0.0", Clone: true, Original: "production"},
+	}, nil)
+
+	perms := permissions.New().NewAccessKeyPermissions()
+	perms.Grant("develop", permissions.ReadConfig)
+	perms.Grant("production", permissions.CreateSecrets)
+
+	envs, err := suite.service.ListEnvs(suite.ctx(perms))
+	suite.NoError(err)
+	suite.Equal([]repository.EnvParams{
+		{Name: "develop", Version: "v1.1.0"},
+		{Name: "develop-feature", Version: "v1.1.0", Clone: true, Original: "develop"},
+	}, envs)
+}
+
+func (suite *ConfigurationServiceSuite) TestSyncNotRunning() {
+	_, err := suite.service.Sync(suite.adminCtx())
+	suite.ErrorAs(err, &service.SyncUnavailableError{})
+
+	status, err := suite.service.SyncStatus(suite.adminCtx())
+	suite.NoError(err)
+	suite.False(status.Active)
+	suite.Nil(status.LastSync)
+	suite.Empty(status.Envs)
+
+	_, err = suite.service.SyncStatus(project.WithProject(suite.adminCtx(), "unknown"))
+	suite.Error(err)
+}
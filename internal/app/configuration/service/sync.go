@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// SyncStatus is the outcome of the last synchronization of a project with its git repository
+type SyncStatus struct {
+	// Active reports whether this instance synchronizes the repositories, only the leader does when running with leader election
+	Active    bool              `json:"active"`
+	LastSync  *time.Time        `json:"lastSync,omitempty"`
+	LastError string            `json:"lastError,omitempty"`
+	Envs      map[string]string `json:"envs"`
+}
+
+// SyncUnavailableError is returned when a synchronization is requested to an instance that does not synchronize the repositories
+type SyncUnavailableError struct{}
+
+func (e SyncUnavailableError) Error() string {
+	return "repositories are not synchronized by this instance, retry against the leader"
+}
+
+// projectSync serializes the synchronizations of a project and keeps the outcome of the last one
+type projectSync struct {
+	mux sync.Mutex
+
+	statusMux sync.RWMutex
+	lastSync  *time.Time
+	lastErr   error
+	envs      map[string]string
+}
+
+// Sync synchronizes the project with its git repository right away, instead of waiting for the next poll
+func (s *ConfigurationService) Sync(ctx context.Context) (SyncStatus, error) {
+	p, err := s.project(ctx)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+
+	if !s.running.Load() {
+		return SyncStatus{}, SyncUnavailableError{}
+	}
+
+	err = s.syncProject(ctx, p)
+	return s.syncStatus(p), err
+}
+
+// SyncStatus returns the outcome of the last synchronization of the project
+func (s *ConfigurationService) SyncStatus(ctx context.Context) (SyncStatus, error) {
+	p, err := s.project(ctx)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+
+	return s.syncStatus(p), nil
+}
+
+// syncProject applies the new versions of the project repository, a sync already running is awaited
+func (s *ConfigurationService) syncProject(ctx context.Context, p *projectRepo) error {
+	p.sync.mux.Lock()
+	defer p.sync.mux.Unlock()
+
+	err := s.buildConfigFromLocalRepo(ctx, p)
+	if err != nil {
+		log.Printf("Error synchronizing '%s' repository: %v", p.gitrepo.URL, err)
+	}
+
+	now := time.Now().UTC()
+	envs := make(map[string]string, len(p.gitrepo.Envs))
+	for name, env := range p.gitrepo.Envs {
+		envs[name] = env.LastTag
+	}
+
+	p.sync.statusMux.Lock()
+	p.sync.lastSync, p.sync.lastErr, p.sync.envs = &now, err, envs
+	p.sync.statusMux.Unlock()
+
+	return err
+}
+
+func (s *ConfigurationService) syncStatus(p *projectRepo) SyncStatus {
+	p.sync.statusMux.RLock()
+	defer p.sync.statusMux.RUnlock()
+
+	status := SyncStatus{Active: s.running.Load(), LastSync: p.sync.lastSync, Envs: map[string]string{}}
+	if p.sync.lastErr != nil {
+		status.LastError = p.sync.lastErr.Error()
+	}
+	for name, version := range p.sync.envs {
+		status.Envs[name] = version
+	}
+
+	return status
+}
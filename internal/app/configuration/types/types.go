@@ -17,3 +17,41 @@ type ParsedRepoConfig struct {
 	// need to store all the keys used in this file
 	AllKeys []string
 }
+
+// Select assembles the requested groups, with their local values and the global keys they reference,
+// and the requested global keys, the same way the configuration is read from the repository.
+func (c *ParsedRepoConfig) Select(groups, globals []string) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	for _, groupName := range groups {
+		combined := map[string]interface{}{}
+
+		if group, ok := c.Groups[groupName]; ok {
+			for localKey, localVal := range group.Local {
+				combined[localKey] = localVal
+			}
+
+			for _, key := range group.Global {
+				if _, ok := combined[key]; ok {
+					continue
+				}
+				if globalVal, ok := c.Globals[key]; ok {
+					combined[key] = globalVal
+				}
+			}
+		}
+
+		result[groupName] = combined
+	}
+
+	for _, key := range globals {
+		if _, ok := result[key]; ok {
+			continue
+		}
+		if globalVal, ok := c.Globals[key]; ok {
+			result[key] = globalVal
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,62 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsedRepoConfigSelect(t *testing.T) {
+	cfg := types.ParsedRepoConfig{
+		Globals: map[string]interface{}{
+			"database":     map[string]interface{}{"host": "global-db-host"},
+			"featureFlags": map[string]interface{}{"darkMode": true},
+		},
+		Groups: map[string]types.GroupConfig{
+			"analytics": {
+				Local:  map[string]interface{}{"database": map[string]interface{}{"host": "analytics-db-host"}},
+				Global: []string{"database", "featureFlags", "missing"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		groups   []string
+		globals  []string
+		expected map[string]interface{}
+	}{
+		{
+			name:   "Group local values override the referenced globals",
+			groups: []string{"analytics"},
+			expected: map[string]interface{}{
+				"analytics": map[string]interface{}{
+					"database":     map[string]interface{}{"host": "analytics-db-host"},
+					"featureFlags": map[string]interface{}{"darkMode": true},
+				},
+			},
+		},
+		{
+			name:    "Globals are returned at the root",
+			globals: []string{"database", "missing"},
+			expected: map[string]interface{}{
+				"database": map[string]interface{}{"host": "global-db-host"},
+			},
+		},
+		{
+			name:    "Unknown group is empty and hides a global with the same name",
+			groups:  []string{"database"},
+			globals: []string{"database"},
+			expected: map[string]interface{}{
+				"database": map[string]interface{}{},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, cfg.Select(tc.groups, tc.globals))
+		})
+	}
+}
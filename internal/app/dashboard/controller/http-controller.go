@@ -12,6 +12,7 @@ import (
 	accessDto "github.com/raw-leak/configleam/internal/app/access/dto"
 	"github.com/raw-leak/configleam/internal/app/dashboard/dto"
 	"github.com/raw-leak/configleam/internal/app/dashboard/templates"
+	secretsService "github.com/raw-leak/configleam/internal/app/secrets/service"
 )
 
 var tmpl *template.Template
@@ -21,6 +22,7 @@ type DashboardService interface {
 	CreateAccessKeyParams(context.Context) dto.CreateAccessKeyParams
 	CreateAccessKey(context.Context, accessDto.AccessKeyPermissionsDto) (dto.CreatedAccessKey, error)
 	DeleteAccessKey(context.Context, string) error
+	DashboardDueSecrets(context.Context, time.Duration) (dto.SecretsParams, error)
 }
 
 type DashboardEndpoints struct {
@@ -85,6 +87,30 @@ func (e DashboardEndpoints) AccessHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+func (e DashboardEndpoints) SecretsHandler(w http.ResponseWriter, r *http.Request) {
+	within := secretsService.DueWithinDefault
+	if value := r.URL.Query().Get("within"); value != "" {
+		var err error
+		within, err = time.ParseDuration(value)
+		if err != nil || within < 0 {
+			http.Error(w, "Within must be a positive duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	payload, err := e.service.DashboardDueSecrets(r.Context(), within)
+	if err != nil {
+		log.Printf("Error loading dashboard secrets data: %v", err)
+		e.templates.ErrorSection(w, err.Error())
+		return
+	}
+
+	err = e.templates.Secrets(w, payload)
+	if err != nil {
+		e.templates.ErrorSection(w, err.Error())
+	}
+}
+
 func (e DashboardEndpoints) CreateAccessKeyParamsHandler(w http.ResponseWriter, r *http.Request) {
 	params := e.service.CreateAccessKeyParams(r.Context())
 	err := e.templates.CreateAccessKeyParams(w, params)
@@ -0,0 +1,6 @@
+package dto
+
+type SecretsParams struct {
+	Within string
+	Items  []map[string]string
+}
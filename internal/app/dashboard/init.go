@@ -13,8 +13,8 @@ type DashboardSet struct {
 	*controller.DashboardEndpoints
 }
 
-func Init(ctx context.Context, cfg *config.Config, accessService service.AccessService, configService service.ConfigurationService) (*DashboardSet, error) {
-	service := service.New(accessService, configService)
+func Init(ctx context.Context, cfg *config.Config, accessService service.AccessService, configService service.ConfigurationService, secretsService service.SecretsService) (*DashboardSet, error) {
+	service := service.New(accessService, configService, secretsService)
 	templates := templates.New()
 	endpoints := controller.New(service, templates)
 
@@ -7,6 +7,7 @@ import (
 	accessDto "github.com/raw-leak/configleam/internal/app/access/dto"
 	"github.com/raw-leak/configleam/internal/app/access/repository"
 	"github.com/raw-leak/configleam/internal/app/dashboard/dto"
+	secretsService "github.com/raw-leak/configleam/internal/app/secrets/service"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
 )
 
@@ -14,6 +15,10 @@ type ConfigurationService interface {
 	GetEnvs(ctx context.Context) []string
 }
 
+type SecretsService interface {
+	ListDueSecrets(ctx context.Context, env string, within time.Duration) ([]secretsService.DueSecret, error)
+}
+
 type AccessService interface {
 	GenerateAccessKey(ctx context.Context, accessKeyPerms accessDto.AccessKeyPermissionsDto) (accessDto.AccessKeyPermissionsDto, error)
 	DeleteAccessKeys(ctx context.Context, keys []string) error
@@ -24,13 +29,15 @@ type AccessService interface {
 type DashboardService struct {
 	accessService        AccessService
 	configurationService ConfigurationService
+	secretsService       SecretsService
 }
 
 // New creates a new instance of DashboardService service.
-func New(accessService AccessService, configurationService ConfigurationService) *DashboardService {
+func New(accessService AccessService, configurationService ConfigurationService, secretsService SecretsService) *DashboardService {
 	return &DashboardService{
 		accessService:        accessService,
 		configurationService: configurationService,
+		secretsService:       secretsService,
 	}
 }
 
@@ -81,6 +88,35 @@ func (a *DashboardService) DashboardAccessKeys(ctx context.Context, page, size i
 	return ap, nil
 }
 
+// DashboardDueSecrets lists the secrets of every environment that expired or are due for rotation, or will be within the notice
+func (a *DashboardService) DashboardDueSecrets(ctx context.Context, within time.Duration) (dto.SecretsParams, error) {
+	due, err := a.secretsService.ListDueSecrets(ctx, "", within)
+	if err != nil {
+		return dto.SecretsParams{}, err
+	}
+
+	items := []map[string]string{}
+	for _, secret := range due {
+		items = append(items, map[string]string{
+			"Env":         secret.Env,
+			"Key":         secret.Key,
+			"Status":      secret.Status,
+			"DueAt":       secret.DueAt.Format("2006-01-02T15:04:05Z07:00"),
+			"Owner":       valueOr(secret.Owner, "-"),
+			"Description": valueOr(secret.Description, "-"),
+		})
+	}
+
+	return dto.SecretsParams{Within: within.String(), Items: items}, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
 func (a *DashboardService) GetConfigEnvs(ctx context.Context) []string {
 	return a.configurationService.GetEnvs(ctx)
 }
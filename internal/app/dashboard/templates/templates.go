@@ -18,6 +18,7 @@ const (
 	CreateAccessKeyTemplate  = "create-access-key.html"
 	CreatedAccessKeyTemplate = "created-access-key.html"
 	DeletedAccessKeyTemplate = "deleted-access-key.html"
+	SecretsTemplate          = "secrets.html"
 )
 
 var funcMap = template.FuncMap{
@@ -104,6 +105,16 @@ func (t DashboardTemplates) CreateAccessKeyParams(w http.ResponseWriter, payload
 	return err
 }
 
+// secrets
+func (t DashboardTemplates) Secrets(w http.ResponseWriter, payload dto.SecretsParams) error {
+	err := t.tmpl.ExecuteTemplate(w, SecretsTemplate, payload)
+	if err != nil {
+		log.Printf("Error generating '%s' in dashboard: %v", SecretsTemplate, err)
+	}
+
+	return err
+}
+
 // config
 func (t DashboardTemplates) Config(w http.ResponseWriter, payload map[string]any) error {
 	err := t.tmpl.ExecuteTemplate(w, ConfigTemplate, payload)
@@ -0,0 +1,48 @@
+package kubesync
+
+import (
+	"context"
+
+	"github.com/raw-leak/configleam/config"
+	"github.com/raw-leak/configleam/internal/app/kubesync/service"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+type KubeSyncSet struct {
+	*service.KubeSyncService
+}
+
+// Init creates the syncer of the mappings of the kubernetes sync file, it does nothing when no file is configured
+func Init(ctx context.Context, cfg *config.Config) (*KubeSyncSet, error) {
+	if cfg.KubeSyncFile == "" {
+		service, err := service.New(nil, nil, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		return &KubeSyncSet{service}, nil
+	}
+
+	mappings, err := service.LoadMappings(cfg.KubeSyncFile)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// the objects are owned by the installation, the one of its storage namespace
+	service, err := service.New(clientset, mappings, cfg.Namespace, cfg.KubeSyncInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubeSyncSet{service}, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+	secrets "github.com/raw-leak/configleam/internal/app/secrets/service"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// formats the file of a mapping is encoded with, chosen by its extension
+var fileFormats = map[string]formatter.Format{
+	".json":       formatter.JSON,
+	".yaml":       formatter.YAML,
+	".yml":        formatter.YAML,
+	".env":        formatter.Dotenv,
+	".properties": formatter.Properties,
+	".toml":       formatter.TOML,
+}
+
+// Mapping mirrors the groups and globals of an environment into the ConfigMap, and the Secret
+// for the secret-backed values, with the same name in a Kubernetes namespace
+type Mapping struct {
+	Project   string   `json:"project"`
+	Env       string   `json:"env"`
+	Groups    []string `json:"groups"`
+	Globals   []string `json:"globals"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	// File writes the configuration as a single file, encoded in the format of its extension, instead of a key per value
+	File string `json:"file"`
+	// Scopes are the shared secret scopes whose secrets, referenced by the configuration, are synced along with the
+	// ones of the env
+	Scopes []string `json:"scopes"`
+}
+
+// key identifies the objects of the mapping in the cluster
+func (m Mapping) key() string {
+	return m.Namespace + "/" + m.Name
+}
+
+// LoadMappings reads the list of mappings from a JSON file
+func LoadMappings(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubernetes sync file '%s': %v", path, err)
+	}
+
+	mappings := []Mapping{}
+	err = json.Unmarshal(data, &mappings)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling kubernetes sync file '%s': %v", path, err)
+	}
+
+	err = ValidateMappings(mappings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubernetes sync file '%s': %w", path, err)
+	}
+
+	return mappings, nil
+}
+
+// ValidateMappings checks every mapping targets valid and distinct objects with at least a group or a global
+func ValidateMappings(mappings []Mapping) error {
+	seen := map[string]bool{}
+
+	for _, m := range mappings {
+		if err := project.Validate(m.Project); err != nil {
+			return err
+		}
+		if m.Env == "" {
+			return fmt.Errorf("mapping '%s' must have an env", m.key())
+		}
+		if len(m.Groups) == 0 && len(m.Globals) == 0 {
+			return fmt.Errorf("mapping '%s' must have at least one group or global", m.key())
+		}
+		if errs := validation.IsDNS1123Label(m.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace '%s': %s", m.Namespace, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsDNS1123Subdomain(m.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid name '%s': %s", m.Name, strings.Join(errs, ", "))
+		}
+		if m.File != "" {
+			if errs := validation.IsConfigMapKey(m.File); len(errs) > 0 {
+				return fmt.Errorf("invalid file '%s' of mapping '%s': %s", m.File, m.key(), strings.Join(errs, ", "))
+			}
+			if _, ok := fileFormats[filepath.Ext(m.File)]; !ok {
+				return fmt.Errorf("unsupported extension of file '%s' of mapping '%s', supported extensions: .json, .yaml, .yml, .env, .properties, .toml", m.File, m.key())
+			}
+		}
+		for _, scope := range m.Scopes {
+			if err := secrets.ValidateScope(scope); err != nil {
+				return fmt.Errorf("invalid scope of mapping '%s': %w", m.key(), err)
+			}
+		}
+		if seen[m.key()] {
+			return fmt.Errorf("mapping '%s' is declared more than once", m.key())
+		}
+		seen[m.key()] = true
+	}
+
+	return nil
+}
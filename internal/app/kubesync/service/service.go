@@ -0,0 +1,492 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/formatter"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	secrets "github.com/raw-leak/configleam/internal/app/secrets/service"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/runner"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ResyncIntervalDefault = 5 * time.Minute
+
+	// ManagedByLabel and OwnerLabel mark the objects written by an installation, the ones it garbage collects
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "configleam"
+	OwnerLabel     = "configleam.io/owner"
+
+	ProjectAnnotation = "configleam.io/project"
+	EnvAnnotation     = "configleam.io/env"
+	VersionAnnotation = "configleam.io/version"
+)
+
+type ConfigReader interface {
+	ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error)
+}
+
+// NotManagedError is returned when the object of a mapping already exists without being managed by the installation
+type NotManagedError struct {
+	Kind string
+	Key  string
+}
+
+func (e NotManagedError) Error() string {
+	return fmt.Sprintf("%s '%s' already exists and is not managed by configleam", e.Kind, e.Key)
+}
+
+// envKey identifies an environment of a project
+type envKey struct {
+	project string
+	env     string
+}
+
+// KubeSyncService mirrors the configuration of the mapped environments into Kubernetes ConfigMaps and Secrets.
+// The environments are synced when their configuration is updated and all of them periodically, as the
+// secrets are updated without any notification, deleting the objects of the mappings that were removed.
+type KubeSyncService struct {
+	client   kubernetes.Interface
+	mappings []Mapping
+	owner    string
+	interval time.Duration
+
+	mux     sync.Mutex
+	pending map[envKey]bool
+	wake    chan struct{}
+	runner  runner.Runner
+}
+
+// New creates the syncer of the mappings, the owner being the value of the owner label of its objects,
+// it does nothing when there is no mapping
+func New(client kubernetes.Interface, mappings []Mapping, owner string, interval time.Duration) (*KubeSyncService, error) {
+	if len(mappings) > 0 {
+		if errs := validation.IsValidLabelValue(owner); len(errs) > 0 || owner == "" {
+			return nil, fmt.Errorf("invalid kubernetes sync owner '%s': %s", owner, strings.Join(errs, ", "))
+		}
+	}
+
+	if interval == 0 {
+		interval = ResyncIntervalDefault
+	}
+
+	return &KubeSyncService{
+		client:   client,
+		mappings: mappings,
+		owner:    owner,
+		interval: interval,
+		pending:  map[envKey]bool{},
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// Run syncs every mapping and keeps them in sync until Shutdown is called, it is meant to run on the leader only
+func (s *KubeSyncService) Run(ctx context.Context, reader ConfigReader) {
+	if len(s.mappings) == 0 {
+		return
+	}
+
+	s.mux.Lock()
+	// the full sync below covers the updates notified before running
+	s.pending = map[envKey]bool{}
+	s.mux.Unlock()
+
+	s.runner.Start(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		if err := s.Sync(ctx, reader); err != nil {
+			log.Printf("Error syncing configuration into kubernetes: %v", err)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Sync(ctx, reader); err != nil {
+					log.Printf("Error syncing configuration into kubernetes: %v", err)
+				}
+			case <-s.wake:
+				s.syncPending(ctx, reader)
+			}
+		}
+	})
+}
+
+// Shutdown stops syncing, waiting for the ongoing sync to finish
+func (s *KubeSyncService) Shutdown() {
+	s.runner.Stop()
+}
+
+// NotifyConfigUpdate queues the mappings of the updated environment of the project carried by the context, without blocking
+func (s *KubeSyncService) NotifyConfigUpdate(ctx context.Context, repo, env, version string) {
+	key := envKey{project: project.FromContext(ctx), env: env}
+
+	mapped := false
+	for _, m := range s.mappings {
+		if m.Project == key.project && m.Env == key.env {
+			mapped = true
+			break
+		}
+	}
+	if !mapped {
+		return
+	}
+
+	s.mux.Lock()
+	s.pending[key] = true
+	s.mux.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *KubeSyncService) syncPending(ctx context.Context, reader ConfigReader) {
+	s.mux.Lock()
+	pending := s.pending
+	s.pending = map[envKey]bool{}
+	s.mux.Unlock()
+
+	for _, m := range s.mappings {
+		if !pending[envKey{project: m.Project, env: m.Env}] {
+			continue
+		}
+
+		if _, err := s.syncMapping(ctx, reader, m); err != nil {
+			log.Printf("Error syncing mapping '%s' into kubernetes: %v", m.key(), err)
+		}
+	}
+}
+
+// Sync syncs every mapping and deletes the objects owned by the installation that are no longer mapped
+func (s *KubeSyncService) Sync(ctx context.Context, reader ConfigReader) error {
+	configMaps, secrets := map[string]bool{}, map[string]bool{}
+	var errs []error
+
+	for _, m := range s.mappings {
+		configMaps[m.key()] = true
+
+		hasSecret, err := s.syncMapping(ctx, reader, m)
+		if err != nil {
+			// the secret of a mapping failing to sync is kept until it syncs again
+			secrets[m.key()] = true
+			errs = append(errs, fmt.Errorf("error syncing mapping '%s': %w", m.key(), err))
+			continue
+		}
+		if hasSecret {
+			secrets[m.key()] = true
+		}
+	}
+
+	err := s.collectGarbage(ctx, configMaps, secrets)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// syncMapping writes the configuration of the mapping into its ConfigMap and the secret-backed values into its Secret,
+// deleting the Secret when there are none, and reports whether the mapping has a Secret
+func (s *KubeSyncService) syncMapping(ctx context.Context, reader ConfigReader, m Mapping) (bool, error) {
+	ctx = project.WithProject(ctx, m.Project)
+
+	revealed, version, err := reader.ReadConfig(readContext(ctx, m, true), m.Env, "", m.Groups, m.Globals, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// the values differing once the secrets are masked are the secret-backed ones
+	masked, _, err := reader.ReadConfig(readContext(ctx, m, false), m.Env, version, m.Groups, m.Globals, nil)
+	if err != nil {
+		return false, err
+	}
+
+	configData, secretData, err := splitData(m, revealed, masked)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.applyConfigMap(ctx, m, version, configData)
+	if err != nil {
+		return false, err
+	}
+
+	if len(secretData) == 0 {
+		return false, s.deleteSecret(ctx, m)
+	}
+
+	return true, s.applySecret(ctx, m, version, secretData)
+}
+
+// readContext carries the permissions to read the env of the mapping, revealing its secrets, along with those of
+// the shared scopes of the mapping, or not
+func readContext(ctx context.Context, m Mapping, reveal bool) context.Context {
+	perms := permissions.New().NewAccessKeyPermissions()
+	perms.Grant(m.Env, permissions.ReadConfig)
+	if reveal {
+		perms.Grant(m.Env, permissions.RevealSecrets)
+		for _, scope := range m.Scopes {
+			perms.Grant(secrets.ScopeEnv(scope), permissions.RevealSecrets)
+		}
+	}
+
+	return context.WithValue(ctx, auth.AccessKeyContextKey{}, *perms)
+}
+
+// splitData flattens the configuration into a key per value named as environment variables, e.g. "DATABASE_HOST",
+// the secret-backed values going into the secret data. A mapping with a file gets it into the secret data
+// as soon as it holds a secret-backed value.
+func splitData(m Mapping, revealed, masked map[string]interface{}) (map[string]string, map[string][]byte, error) {
+	configData, secretData := map[string]string{}, map[string][]byte{}
+
+	if m.File != "" {
+		output, err := formatter.New().Format(fileFormats[filepath.Ext(m.File)], revealed)
+		if err != nil {
+			return nil, nil, err
+		}
+		logSkipped(m, output.Skipped)
+
+		if reflect.DeepEqual(revealed, masked) {
+			configData[m.File] = string(output.Body)
+		} else {
+			secretData[m.File] = output.Body
+		}
+		return configData, secretData, nil
+	}
+
+	revealedVars, skipped := formatter.EnvVars(revealed, "")
+	logSkipped(m, skipped)
+	maskedVars, _ := formatter.EnvVars(masked, "")
+
+	for key, value := range revealedVars {
+		if maskedValue, ok := maskedVars[key]; ok && maskedValue == value {
+			configData[key] = value
+		} else {
+			secretData[key] = []byte(value)
+		}
+	}
+
+	return configData, secretData, nil
+}
+
+func logSkipped(m Mapping, skipped []formatter.SkippedKey) {
+	for _, sk := range skipped {
+		log.Printf("Skipped key '%s' of mapping '%s': %s", sk.Key, m.key(), sk.Reason)
+	}
+}
+
+// objectMeta returns the metadata of the objects of the mapping
+func (s *KubeSyncService) objectMeta(m Mapping, version string) metav1.ObjectMeta {
+	annotations := map[string]string{EnvAnnotation: m.Env, VersionAnnotation: version}
+	if m.Project != project.Default {
+		annotations[ProjectAnnotation] = m.Project
+	}
+
+	return metav1.ObjectMeta{
+		Name:        m.Name,
+		Namespace:   m.Namespace,
+		Labels:      s.ownerLabels(),
+		Annotations: annotations,
+	}
+}
+
+func (s *KubeSyncService) ownerLabels() map[string]string {
+	return map[string]string{ManagedByLabel: ManagedByValue, OwnerLabel: s.owner}
+}
+
+func (s *KubeSyncService) owns(meta metav1.ObjectMeta) bool {
+	return meta.Labels[ManagedByLabel] == ManagedByValue && meta.Labels[OwnerLabel] == s.owner
+}
+
+// metaChanged reports whether the labels or annotations of the object lack or differ from the desired ones
+func metaChanged(current, desired metav1.ObjectMeta) bool {
+	for key, value := range desired.Labels {
+		if current.Labels[key] != value {
+			return true
+		}
+	}
+	for key, value := range desired.Annotations {
+		if current.Annotations[key] != value {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeMeta sets the desired labels and annotations keeping the ones added by others
+func mergeMeta(current *metav1.ObjectMeta, desired metav1.ObjectMeta) {
+	if current.Labels == nil {
+		current.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		current.Labels[key] = value
+	}
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	for key, value := range desired.Annotations {
+		current.Annotations[key] = value
+	}
+}
+
+func (s *KubeSyncService) applyConfigMap(ctx context.Context, m Mapping, version string, data map[string]string) error {
+	configMaps := s.client.CoreV1().ConfigMaps(m.Namespace)
+	desired := &corev1.ConfigMap{ObjectMeta: s.objectMeta(m, version), Data: data}
+
+	current, err := configMaps.Get(ctx, m.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating configmap '%s': %w", m.key(), err)
+		}
+		log.Printf("Created configmap '%s' with version '%s' of '%s' environment", m.key(), version, m.Env)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting configmap '%s': %w", m.key(), err)
+	}
+
+	if !s.owns(current.ObjectMeta) {
+		return NotManagedError{Kind: "configmap", Key: m.key()}
+	}
+	if !metaChanged(current.ObjectMeta, desired.ObjectMeta) && reflect.DeepEqual(current.Data, data) && len(current.BinaryData) == 0 {
+		return nil
+	}
+
+	updated := current.DeepCopy()
+	mergeMeta(&updated.ObjectMeta, desired.ObjectMeta)
+	updated.Data, updated.BinaryData = data, nil
+
+	_, err = configMaps.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating configmap '%s': %w", m.key(), err)
+	}
+	log.Printf("Updated configmap '%s' with version '%s' of '%s' environment", m.key(), version, m.Env)
+	return nil
+}
+
+func (s *KubeSyncService) applySecret(ctx context.Context, m Mapping, version string, data map[string][]byte) error {
+	secrets := s.client.CoreV1().Secrets(m.Namespace)
+	desired := &corev1.Secret{ObjectMeta: s.objectMeta(m, version), Type: corev1.SecretTypeOpaque, Data: data}
+
+	current, err := secrets.Get(ctx, m.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating secret '%s': %w", m.key(), err)
+		}
+		log.Printf("Created secret '%s' with version '%s' of '%s' environment", m.key(), version, m.Env)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting secret '%s': %w", m.key(), err)
+	}
+
+	if !s.owns(current.ObjectMeta) {
+		return NotManagedError{Kind: "secret", Key: m.key()}
+	}
+	if !metaChanged(current.ObjectMeta, desired.ObjectMeta) && reflect.DeepEqual(current.Data, data) {
+		return nil
+	}
+
+	updated := current.DeepCopy()
+	mergeMeta(&updated.ObjectMeta, desired.ObjectMeta)
+	updated.Data, updated.StringData = data, nil
+
+	_, err = secrets.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating secret '%s': %w", m.key(), err)
+	}
+	log.Printf("Updated secret '%s' with version '%s' of '%s' environment", m.key(), version, m.Env)
+	return nil
+}
+
+// deleteSecret deletes the Secret of the mapping when it exists and is owned by the installation
+func (s *KubeSyncService) deleteSecret(ctx context.Context, m Mapping) error {
+	secrets := s.client.CoreV1().Secrets(m.Namespace)
+
+	current, err := secrets.Get(ctx, m.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting secret '%s': %w", m.key(), err)
+	}
+	if !s.owns(current.ObjectMeta) {
+		return nil
+	}
+
+	err = secrets.Delete(ctx, m.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting secret '%s': %w", m.key(), err)
+	}
+	log.Printf("Deleted secret '%s' as it no longer has secret-backed values", m.key())
+	return nil
+}
+
+// collectGarbage deletes the ConfigMaps and Secrets owned by the installation, in any namespace, that are not mapped
+func (s *KubeSyncService) collectGarbage(ctx context.Context, configMaps, secrets map[string]bool) error {
+	selector := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(s.ownerLabels()).String()}
+	var errs []error
+
+	configMapList, err := s.client.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, selector)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error listing owned configmaps: %w", err))
+	} else {
+		for _, cm := range configMapList.Items {
+			key := cm.Namespace + "/" + cm.Name
+			if configMaps[key] {
+				continue
+			}
+
+			err = s.client.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("error deleting configmap '%s': %w", key, err))
+				continue
+			}
+			log.Printf("Deleted configmap '%s' as it is no longer mapped", key)
+		}
+	}
+
+	secretList, err := s.client.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, selector)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error listing owned secrets: %w", err))
+	} else {
+		for _, secret := range secretList.Items {
+			key := secret.Namespace + "/" + secret.Name
+			if secrets[key] {
+				continue
+			}
+
+			err = s.client.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("error deleting secret '%s': %w", key, err))
+				continue
+			}
+			log.Printf("Deleted secret '%s' as it is no longer mapped", key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
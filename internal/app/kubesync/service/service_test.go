@@ -0,0 +1,349 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/app/kubesync/service"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// fakeReader serves the configuration of an env, the values of the secrets being emptied
+// unless the permissions carried by the context can reveal them, on their scope when they have one
+type fakeReader struct {
+	mux     sync.Mutex
+	version string
+	cfg     map[string]interface{}
+	secrets map[string]string
+	// scopes are the scope envs of the secrets of shared scopes, by key
+	scopes map[string]string
+	err    error
+}
+
+func (r *fakeReader) set(version string, cfg map[string]interface{}, secrets map[string]string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.version, r.cfg, r.secrets = version, cfg, secrets
+}
+
+func (r *fakeReader) ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.err != nil {
+		return nil, "", r.err
+	}
+
+	perms := ctx.Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+	if perms.IsGlobalAdmin() {
+		return nil, "", errors.New("configuration must not be read as global admin")
+	}
+
+	cfg := map[string]interface{}{}
+	for key, value := range r.cfg {
+		if secret, ok := r.secrets[key]; ok {
+			secretEnv := env
+			if scope, ok := r.scopes[key]; ok {
+				secretEnv = scope
+			}
+			if perms.CanRevealSecrets(secretEnv) {
+				value = secret
+			} else {
+				value = ""
+			}
+		}
+		cfg[key] = value
+	}
+
+	return map[string]interface{}{"app": cfg}, r.version, nil
+}
+
+type KubeSyncServiceSuite struct {
+	suite.Suite
+	client  *fake.Clientset
+	reader  *fakeReader
+	mapping service.Mapping
+}
+
+func TestKubeSyncServiceSuite(t *testing.T) {
+	suite.Run(t, new(KubeSyncServiceSuite))
+}
+
+func (suite *KubeSyncServiceSuite) SetupTest() {
+	suite.client = fake.NewSimpleClientset()
+	suite.reader = &fakeReader{}
+	suite.reader.set("v1.0.0", map[string]interface{}{"host": "db", "port": 5432, "password": "{{ secret.db.password }}"}, map[string]string{"password": "s3cr3t"})
+	suite.mapping = service.Mapping{Env: "develop", Groups: []string{"app"}, Namespace: "apps", Name: "app-config"}
+}
+
+func (suite *KubeSyncServiceSuite) newService(mappings ...service.Mapping) *service.KubeSyncService {
+	s, err := service.New(suite.client, mappings, "configleam", time.Hour)
+	suite.Require().NoError(err)
+	return s
+}
+
+func (suite *KubeSyncServiceSuite) getConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	return suite.client.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+func (suite *KubeSyncServiceSuite) getSecret(namespace, name string) (*corev1.Secret, error) {
+	return suite.client.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncSplitsSecretBackedValues() {
+	s := suite.newService(suite.mapping)
+
+	err := s.Sync(context.Background(), suite.reader)
+	suite.Require().NoError(err)
+
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]string{"APP_HOST": "db", "APP_PORT": "5432"}, cm.Data)
+	suite.Equal(service.ManagedByValue, cm.Labels[service.ManagedByLabel])
+	suite.Equal("configleam", cm.Labels[service.OwnerLabel])
+	suite.Equal("develop", cm.Annotations[service.EnvAnnotation])
+	suite.Equal("v1.0.0", cm.Annotations[service.VersionAnnotation])
+
+	secret, err := suite.getSecret("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string][]byte{"APP_PASSWORD": []byte("s3cr3t")}, secret.Data)
+	suite.Equal(corev1.SecretTypeOpaque, secret.Type)
+	suite.Equal("configleam", secret.Labels[service.OwnerLabel])
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncRevealsMappingScopes() {
+	suite.reader.set("v1.0.0", map[string]interface{}{
+		"host":     "db",
+		"password": "{{ secret.db.password }}",
+		"smtp":     "{{ secret@shared.smtp.password }}",
+		"payments": "{{ secret@payments.api.token }}",
+	}, map[string]string{"password": "s3cr3t", "smtp": "m41l", "payments": "t0k3n"})
+	suite.reader.scopes = map[string]string{"smtp": "@shared", "payments": "@payments"}
+
+	suite.mapping.Scopes = []string{"shared"}
+	s := suite.newService(suite.mapping)
+
+	err := s.Sync(context.Background(), suite.reader)
+	suite.Require().NoError(err)
+
+	// secrets of scopes the mapping does not list are never revealed
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]string{"APP_HOST": "db", "APP_PAYMENTS": ""}, cm.Data)
+
+	secret, err := suite.getSecret("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string][]byte{"APP_PASSWORD": []byte("s3cr3t"), "APP_SMTP": []byte("m41l")}, secret.Data)
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncFile() {
+	suite.mapping.File = "config.json"
+	s := suite.newService(suite.mapping)
+
+	suite.reader.set("v1.0.0", map[string]interface{}{"host": "db"}, nil)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]string{"config.json": "{\"app\":{\"host\":\"db\"}}\n"}, cm.Data)
+
+	_, err = suite.getSecret("apps", "app-config")
+	suite.True(apierrors.IsNotFound(err))
+
+	// holding a secret-backed value, the whole file moves into the secret
+	suite.reader.set("v1.0.1", map[string]interface{}{"host": "db", "password": "{{ secret.db.password }}"}, map[string]string{"password": "s3cr3t"})
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	cm, err = suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Empty(cm.Data)
+
+	secret, err := suite.getSecret("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal("{\"app\":{\"host\":\"db\",\"password\":\"s3cr3t\"}}\n", string(secret.Data["config.json"]))
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncUpdatesAndDeletesSecret() {
+	s := suite.newService(suite.mapping)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	suite.reader.set("v1.1.0", map[string]interface{}{"host": "db-2"}, nil)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]string{"APP_HOST": "db-2"}, cm.Data)
+	suite.Equal("v1.1.0", cm.Annotations[service.VersionAnnotation])
+
+	_, err = suite.getSecret("apps", "app-config")
+	suite.True(apierrors.IsNotFound(err))
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncKeepsForeignMetadata() {
+	s := suite.newService(suite.mapping)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	cm.Labels["team"] = "payments"
+	_, err = suite.client.CoreV1().ConfigMaps("apps").Update(context.Background(), cm, metav1.UpdateOptions{})
+	suite.Require().NoError(err)
+
+	suite.reader.set("v1.1.0", map[string]interface{}{"host": "db-2"}, nil)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	cm, err = suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal("payments", cm.Labels["team"])
+	suite.Equal(map[string]string{"APP_HOST": "db-2"}, cm.Data)
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncRefusesUnmanagedObjects() {
+	_, err := suite.client.CoreV1().ConfigMaps("apps").Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "apps"},
+		Data:       map[string]string{"KEEP": "me"},
+	}, metav1.CreateOptions{})
+	suite.Require().NoError(err)
+
+	s := suite.newService(suite.mapping)
+	err = s.Sync(context.Background(), suite.reader)
+
+	var notManaged service.NotManagedError
+	suite.ErrorAs(err, &notManaged)
+
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]string{"KEEP": "me"}, cm.Data)
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncCollectsGarbage() {
+	other := suite.mapping
+	other.Namespace, other.Name = "billing", "billing-config"
+
+	s := suite.newService(suite.mapping, other)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	// objects of another installation and unmanaged ones are left untouched
+	for _, cm := range []*corev1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "billing", Labels: map[string]string{service.ManagedByLabel: service.ManagedByValue, service.OwnerLabel: "other"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "billing"}},
+	} {
+		_, err := suite.client.CoreV1().ConfigMaps(cm.Namespace).Create(context.Background(), cm, metav1.CreateOptions{})
+		suite.Require().NoError(err)
+	}
+
+	s = suite.newService(suite.mapping)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	_, err := suite.getConfigMap("billing", "billing-config")
+	suite.True(apierrors.IsNotFound(err))
+	_, err = suite.getSecret("billing", "billing-config")
+	suite.True(apierrors.IsNotFound(err))
+
+	_, err = suite.getConfigMap("apps", "app-config")
+	suite.NoError(err)
+	_, err = suite.getSecret("apps", "app-config")
+	suite.NoError(err)
+	_, err = suite.getConfigMap("billing", "foreign")
+	suite.NoError(err)
+	_, err = suite.getConfigMap("billing", "unmanaged")
+	suite.NoError(err)
+}
+
+func (suite *KubeSyncServiceSuite) TestSyncErrorKeepsSecret() {
+	s := suite.newService(suite.mapping)
+	suite.Require().NoError(s.Sync(context.Background(), suite.reader))
+
+	suite.reader.err = errors.New("redis is down")
+	suite.Error(s.Sync(context.Background(), suite.reader))
+
+	_, err := suite.getSecret("apps", "app-config")
+	suite.NoError(err)
+}
+
+func (suite *KubeSyncServiceSuite) TestNotifyConfigUpdate() {
+	billing := suite.mapping
+	billing.Project, billing.Namespace = "billing", "billing"
+
+	s := suite.newService(suite.mapping, billing)
+	s.Run(context.Background(), suite.reader)
+	defer s.Shutdown()
+
+	suite.Eventually(func() bool {
+		_, err := suite.getConfigMap("billing", "app-config")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	suite.reader.set("v2.0.0", map[string]interface{}{"host": "db-2"}, nil)
+	s.NotifyConfigUpdate(project.WithProject(context.Background(), "billing"), "repo", "develop", "v2.0.0")
+
+	suite.Eventually(func() bool {
+		cm, err := suite.getConfigMap("billing", "app-config")
+		return err == nil && cm.Data["APP_HOST"] == "db-2"
+	}, time.Second, 10*time.Millisecond)
+
+	// only the mappings of the updated project are synced
+	cm, err := suite.getConfigMap("apps", "app-config")
+	suite.Require().NoError(err)
+	suite.Equal("db", cm.Data["APP_HOST"])
+}
+
+func (suite *KubeSyncServiceSuite) TestNewInvalidOwner() {
+	_, err := service.New(suite.client, []service.Mapping{suite.mapping}, "invalid/owner", 0)
+	suite.Error(err)
+
+	// without mappings there is nothing to own
+	_, err = service.New(nil, nil, "", 0)
+	suite.NoError(err)
+}
+
+func (suite *KubeSyncServiceSuite) TestLoadMappings() {
+	dir := suite.T().TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `[{"env":"develop","groups":["app"],"namespace":"apps","name":"app-config","file":"app.yaml"}]`, false},
+		{"missing env", `[{"groups":["app"],"namespace":"apps","name":"app-config"}]`, true},
+		{"no groups nor globals", `[{"env":"develop","namespace":"apps","name":"app-config"}]`, true},
+		{"invalid namespace", `[{"env":"develop","groups":["app"],"namespace":"Apps","name":"app-config"}]`, true},
+		{"invalid name", `[{"env":"develop","groups":["app"],"namespace":"apps","name":"app_config"}]`, true},
+		{"unsupported file", `[{"env":"develop","groups":["app"],"namespace":"apps","name":"app-config","file":"app.xml"}]`, true},
+		{"invalid project", `[{"project":"Billing","env":"develop","groups":["app"],"namespace":"apps","name":"app-config"}]`, true},
+		{"scopes", `[{"env":"develop","groups":["app"],"namespace":"apps","name":"app-config","scopes":["shared"]}]`, false},
+		{"invalid scope", `[{"env":"develop","groups":["app"],"namespace":"apps","name":"app-config","scopes":["@shared"]}]`, true},
+		{"duplicated", `[{"env":"develop","groups":["app"],"namespace":"apps","name":"app-config"},{"env":"prod","globals":["region"],"namespace":"apps","name":"app-config"}]`, true},
+		{"malformed", `{`, true},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			path := filepath.Join(dir, "mappings.json")
+			suite.Require().NoError(os.WriteFile(path, []byte(tt.content), 0600))
+
+			mappings, err := service.LoadMappings(path)
+			if tt.wantErr {
+				suite.Error(err)
+				return
+			}
+			suite.NoError(err)
+			suite.Len(mappings, 1)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -64,7 +65,7 @@ func (e NotifyEndpoints) NotifyHandler(w http.ResponseWriter, r *http.Request) {
 			}
 
 			log.Printf("INFO: Sending update to client %s, environment '%s'", clientIP, env)
-			_, err := fmt.Fprint(w, msg.Env)
+			_, err := writeUpdate(w, msg)
 			if err != nil {
 				log.Printf("ERROR: Failed to send update to %s: %v", clientIP, err)
 				e.service.Unsubscribe(ctx, client)
@@ -79,3 +80,18 @@ func (e NotifyEndpoints) NotifyHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// writeUpdate writes the update as a server-sent event terminated by a blank line. Configuration updates are
+// unnamed events whose data is the env, i.e. "data: <env>\n\n", while the other updates are named after their
+// event and hold the update as JSON, i.e. "event: <event>\ndata: <json>\n\n".
+func writeUpdate(w http.ResponseWriter, msg *service.ConfigUpdate) (int, error) {
+	if msg.Event == "" {
+		return fmt.Fprintf(w, "data: %s\n\n", msg.Env)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	return fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Event, data)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 )
 
 type EtcdRepository struct {
@@ -12,8 +13,8 @@ type EtcdRepository struct {
 	keys Keys
 }
 
-func NewEtcdRepository(etcd *etcd.Etcd) *EtcdRepository {
-	return &EtcdRepository{etcd, Keys{}}
+func NewEtcdRepository(etcd *etcd.Etcd, ns namespace.Namespace) *EtcdRepository {
+	return &EtcdRepository{etcd, NewKeys(ns)}
 }
 
 // Publish updates the value of a key, triggering notifications to watchers.
@@ -1,11 +1,18 @@
 package repository
 
-import "fmt"
+import "github.com/raw-leak/configleam/internal/pkg/namespace"
 
-type Keys struct{}
+type Keys struct {
+	ns namespace.Namespace
+}
+
+// NewKeys creates the keys builder for the provided namespace, an empty namespace resolves to the default one
+func NewKeys(ns namespace.Namespace) Keys {
+	return Keys{ns: ns}
+}
 
 var PubSubChannel = "channel"
 
 func (k Keys) GetNotifyChannel() string {
-	return fmt.Sprintf("%s:%s:", NotifyPrefix, PubSubChannel)
+	return k.ns.Key(NotifySegment, PubSubChannel, "")
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
 )
 
@@ -12,8 +13,8 @@ type RedisRepository struct {
 	keys Keys
 }
 
-func NewRedisRepository(redis *rds.Redis) *RedisRepository {
-	return &RedisRepository{redis, Keys{}}
+func NewRedisRepository(redis *rds.Redis, ns namespace.Namespace) *RedisRepository {
+	return &RedisRepository{redis, NewKeys(ns)}
 }
 
 func (r *RedisRepository) Publish(ctx context.Context, payload string) error {
@@ -5,11 +5,16 @@ import (
 	"fmt"
 
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 )
 
 const (
-	NotifyPrefix = "configleam:notify"
+	NotifySegment = "notify"
+
+	// prefix within the default namespace
+	NotifyPrefix = namespace.Default + ":" + NotifySegment
 )
 
 type Repository interface {
@@ -23,11 +28,15 @@ type RepositoryConfig struct {
 	RedisUsername string
 	RedisPassword string
 	RedisTLS      bool
+	RedisTLSFiles tlsconfig.Files
 
 	EtcdAddrs    []string
 	EtcdUsername string
 	EtcdPassword string
 	EtcdTLS      bool
+	EtcdTLSFiles tlsconfig.Files
+
+	Namespace namespace.Namespace
 }
 
 func New(ctx context.Context, cfg RepositoryConfig) (Repository, error) {
@@ -37,12 +46,13 @@ func New(ctx context.Context, cfg RepositoryConfig) (Repository, error) {
 			Password: cfg.RedisPassword,
 			Username: cfg.RedisUsername,
 			TLS:      cfg.RedisTLS,
+			TLSFiles: cfg.RedisTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return NewRedisRepository(redisCli), nil
+		return NewRedisRepository(redisCli, cfg.Namespace), nil
 	}
 
 	if len(cfg.EtcdAddrs) > 0 {
@@ -51,11 +61,12 @@ func New(ctx context.Context, cfg RepositoryConfig) (Repository, error) {
 			EtcdUsername: cfg.EtcdUsername,
 			EtcdPassword: cfg.EtcdPassword,
 			TLS:          cfg.EtcdTLS,
+			TLSFiles:     cfg.EtcdTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
-		return NewEtcdRepository(etcdCli), nil
+		return NewEtcdRepository(etcdCli, cfg.Namespace), nil
 	}
 
 	return nil, fmt.Errorf("'RedisAddress' nor 'EtcdAddrs' has been provided for 'notify' repository")
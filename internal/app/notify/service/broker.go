@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"time"
 )
 
+// SecretExpiryEvent is the event of the updates reminding that a secret expires or is due for rotation
+const SecretExpiryEvent = "secretExpiry"
+
 // Broker manages clients and messages.
 type Broker struct {
 	clients     map[*Client]bool
@@ -16,14 +20,30 @@ type Broker struct {
 
 // Client represents a subscriber with a channel to send messages.
 type Client struct {
-	Send chan *ConfigUpdate
-	env  string
+	Send    chan *ConfigUpdate
+	project string
+	env     string
+	// secrets tells whether the subscriber may reveal the secrets of the env, and so be reminded of them
+	secrets bool
 }
 
 type ConfigUpdate struct {
+	// Event is the kind of the update, a configuration update when empty
+	Event   string `json:"event,omitempty"`
+	Project string `json:"project"`
 	Env     string `json:"env"`
 	Repo    string `json:"repo"`
 	Version string `json:"version"`
+	// Secret is the secret a SecretExpiryEvent reminds of
+	Secret *SecretNotice `json:"secret,omitempty"`
+}
+
+// SecretNotice describes a secret that expires or is due for rotation
+type SecretNotice struct {
+	Key    string    `json:"key"`
+	Status string    `json:"status"`
+	DueAt  time.Time `json:"dueAt"`
+	Owner  string    `json:"owner,omitempty"`
 }
 
 func NewBroker() *Broker {
@@ -72,7 +92,7 @@ func (b *Broker) Run(ctx context.Context) {
 
 		case msg := <-b.broadcast:
 			for client := range b.clients {
-				if client.env == msg.Env {
+				if client.project == msg.Project && client.env == msg.Env && (msg.Event != SecretExpiryEvent || client.secrets) {
 					select {
 					case client.Send <- msg:
 					default:
@@ -7,6 +7,9 @@ import (
 	"log"
 
 	"github.com/raw-leak/configleam/internal/app/notify/repository"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 )
 
 type NotifyService struct {
@@ -48,9 +51,9 @@ func (n *NotifyService) ShutdownGlobal() {
 	n.repository.Unsubscribe()
 }
 
-// NotifyConfigUpdate notifies all nodes and local subscribers about a configuration update.
+// NotifyConfigUpdate notifies all nodes and local subscribers about a configuration update of the project carried by the context.
 func (n *NotifyService) NotifyConfigUpdate(ctx context.Context, repo, env, version string) {
-	cu := &ConfigUpdate{Env: env, Repo: repo, Version: version}
+	cu := &ConfigUpdate{Project: project.FromContext(ctx), Env: env, Repo: repo, Version: version}
 
 	if n.global {
 		if err := n.NotifyGlobally(ctx, cu); err != nil {
@@ -62,6 +65,20 @@ func (n *NotifyService) NotifyConfigUpdate(ctx context.Context, repo, env, versi
 	n.NotifyLocally(cu)
 }
 
+// NotifySecretExpiry notifies all nodes and local subscribers of the environment that a secret of the project
+// carried by the context expires or is due for rotation.
+func (n *NotifyService) NotifySecretExpiry(ctx context.Context, env string, notice SecretNotice) {
+	cu := &ConfigUpdate{Event: SecretExpiryEvent, Project: project.FromContext(ctx), Env: env, Secret: &notice}
+
+	if n.global {
+		if err := n.NotifyGlobally(ctx, cu); err != nil {
+			log.Printf("Global notification error: %v", err)
+		}
+	}
+
+	n.NotifyLocally(cu)
+}
+
 func (n *NotifyService) NotifyGlobally(ctx context.Context, cu *ConfigUpdate) error {
 	// Implementation for global notifications (e.g., via Redis pub/sub or etcd watchers).
 	// Placeholder for actual implementation.
@@ -83,10 +100,16 @@ func (n *NotifyService) NotifyLocally(cu *ConfigUpdate) {
 	n.broker.Broadcast(cu)
 }
 
+// Subscribe subscribes to the updates of the env of the project carried by the context. The secrets being reminded of
+// are only sent to the subscribers whose permissions, carried by the context, allow revealing the secrets of the env.
 func (n *NotifyService) Subscribe(ctx context.Context, env string) *Client {
+	perms, ok := ctx.Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+
 	client := &Client{
-		Send: make(chan *ConfigUpdate),
-		env:  env,
+		Send:    make(chan *ConfigUpdate),
+		project: project.FromContext(ctx),
+		env:     env,
+		secrets: ok && perms.CanRevealSecrets(env),
 	}
 	n.broker.Subscribe(ctx, client)
 	return client
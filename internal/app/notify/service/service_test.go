@@ -0,0 +1,82 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/raw-leak/configleam/internal/app/notify/service"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+)
+
+// subscriber collects the updates sent to a client
+type subscriber struct {
+	mux     sync.Mutex
+	updates []service.ConfigUpdate
+}
+
+func (s *subscriber) listen(client *service.Client) {
+	for update := range client.Send {
+		s.mux.Lock()
+		s.updates = append(s.updates, *update)
+		s.mux.Unlock()
+	}
+}
+
+func (s *subscriber) count(event string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	n := 0
+	for _, update := range s.updates {
+		if update.Event == event {
+			n++
+		}
+	}
+	return n
+}
+
+type NotifyServiceSuite struct {
+	suite.Suite
+}
+
+func TestNotifyServiceSuite(t *testing.T) {
+	suite.Run(t, new(NotifyServiceSuite))
+}
+
+func (suite *NotifyServiceSuite) TestSecretExpiryIsOnlySentToRevealingSubscribers() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := service.New(false)
+	notifier.RunLocal(ctx)
+	defer notifier.ShutdownLocal(ctx)
+
+	perms := permissions.New().NewAccessKeyPermissions()
+	perms.Grant("develop", permissions.ReadConfig|permissions.RevealSecrets)
+	revealing := &subscriber{}
+	go revealing.listen(notifier.Subscribe(context.WithValue(ctx, auth.AccessKeyContextKey{}, *perms), "develop"))
+
+	perms = permissions.New().NewAccessKeyPermissions()
+	perms.Grant("develop", permissions.ReadConfig)
+	reading := &subscriber{}
+	go reading.listen(notifier.Subscribe(context.WithValue(ctx, auth.AccessKeyContextKey{}, *perms), "develop"))
+
+	anonymous := &subscriber{}
+	go anonymous.listen(notifier.Subscribe(ctx, "develop"))
+
+	// updates are dropped for the subscribers not listening yet, so they are sent until received
+	suite.Eventually(func() bool {
+		notifier.NotifySecretExpiry(ctx, "develop", service.SecretNotice{Key: "token", Status: "expiring", DueAt: time.Now()})
+		notifier.NotifyConfigUpdate(ctx, "repo", "develop", "v1.0.0")
+
+		return revealing.count(service.SecretExpiryEvent) > 0 && reading.count("") > 0 && anonymous.count("") > 0
+	}, time.Second, 5*time.Millisecond)
+
+	suite.Zero(reading.count(service.SecretExpiryEvent), "secrets must not be reminded of to keys that can not reveal them")
+	suite.Zero(anonymous.count(service.SecretExpiryEvent), "secrets must not be reminded of to anonymous subscribers")
+}
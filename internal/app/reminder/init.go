@@ -0,0 +1,17 @@
+package reminder
+
+import (
+	"context"
+
+	"github.com/raw-leak/configleam/config"
+	"github.com/raw-leak/configleam/internal/app/reminder/service"
+)
+
+type ReminderSet struct {
+	*service.ReminderService
+}
+
+// Init creates the notification of the secrets expiring or due for rotation
+func Init(ctx context.Context, cfg *config.Config, secrets service.DueSecretsTracker, notifier service.SecretNotifier) (*ReminderSet, error) {
+	return &ReminderSet{service.New(secrets, notifier, cfg.SecretReminderInterval, cfg.SecretExpiryNotice)}, nil
+}
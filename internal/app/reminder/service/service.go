@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	notify "github.com/raw-leak/configleam/internal/app/notify/service"
+	secrets "github.com/raw-leak/configleam/internal/app/secrets/service"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/runner"
+)
+
+const IntervalDefault = time.Hour
+
+type DueSecretsTracker interface {
+	ListDueSecrets(ctx context.Context, env string, within time.Duration) ([]secrets.DueSecret, error)
+	MarkSecretReminded(ctx context.Context, secret secrets.DueSecret) error
+}
+
+type SecretNotifier interface {
+	NotifySecretExpiry(ctx context.Context, env string, notice notify.SecretNotice)
+}
+
+type ProjectLister interface {
+	GetProjects(ctx context.Context) []string
+}
+
+// ReminderService notifies ahead of time the secrets of every project that expire or are due for rotation,
+// each secret being notified once per status and due time. The notices sent are stored along with the metadata
+// of the secrets, so they are not sent again after a restart or by another leader.
+type ReminderService struct {
+	secrets  DueSecretsTracker
+	notifier SecretNotifier
+	interval time.Duration
+	notice   time.Duration
+
+	// runs one reminder at a time
+	remindMux sync.Mutex
+
+	runner runner.Runner
+}
+
+// New creates the reminder job, running every interval and notifying the secrets due within the notice
+func New(tracker DueSecretsTracker, notifier SecretNotifier, interval, notice time.Duration) *ReminderService {
+	if interval == 0 {
+		interval = IntervalDefault
+	}
+	if notice == 0 {
+		notice = secrets.DueWithinDefault
+	}
+
+	return &ReminderService{
+		secrets:  tracker,
+		notifier: notifier,
+		interval: interval,
+		notice:   notice,
+	}
+}
+
+// Run notifies the due secrets of every project and keeps doing so periodically until Shutdown is called,
+// it is meant to run on the leader only
+func (s *ReminderService) Run(ctx context.Context, projects ProjectLister) {
+	s.runner.Start(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		if err := s.Remind(ctx, projects); err != nil {
+			log.Printf("Error reminding of the due secrets: %v", err)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Remind(ctx, projects); err != nil {
+					log.Printf("Error reminding of the due secrets: %v", err)
+				}
+			}
+		}
+	})
+}
+
+// Shutdown stops reminding, waiting for the ongoing run to finish
+func (s *ReminderService) Shutdown() {
+	s.runner.Stop()
+}
+
+// Remind notifies the secrets of the default project and of every configured one that are due within the notice
+// and were not notified with the same status and due time yet, going on with the other projects when one fails
+func (s *ReminderService) Remind(ctx context.Context, projects ProjectLister) error {
+	var errs []error
+
+	s.remindMux.Lock()
+	defer s.remindMux.Unlock()
+
+	seen := map[string]bool{}
+	for _, name := range append([]string{project.Default}, projects.GetProjects(ctx)...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		projectCtx := project.WithProject(ctx, name)
+
+		due, err := s.secrets.ListDueSecrets(projectCtx, "", s.notice)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error listing due secrets of project '%s': %w", name, err))
+			continue
+		}
+
+		// a rotated secret gets a new due time, so it is notified again once due
+		for _, secret := range due {
+			if secret.AlreadyReminded() {
+				continue
+			}
+
+			notice := notify.SecretNotice{Key: secret.Key, Status: secret.Status, DueAt: secret.DueAt, Owner: secret.Owner}
+			s.notifier.NotifySecretExpiry(projectCtx, secret.Env, notice)
+			log.Printf("Notified secret '%s' of env '%s' of project '%s' as %s at %s", secret.Key, secret.Env, name, secret.Status, secret.DueAt.Format(time.RFC3339))
+
+			if err := s.secrets.MarkSecretReminded(projectCtx, secret); err != nil {
+				errs = append(errs, fmt.Errorf("error recording reminder of secret '%s' of project '%s': %w", secret.Key, name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,211 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	notify "github.com/raw-leak/configleam/internal/app/notify/service"
+	"github.com/raw-leak/configleam/internal/app/reminder/service"
+	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+	secrets "github.com/raw-leak/configleam/internal/app/secrets/service"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// fakeSecrets returns the due secrets of each project along with the reminders recorded for them, failing for
+// the projects in fail
+type fakeSecrets struct {
+	mux      sync.Mutex
+	due      map[string][]secrets.DueSecret
+	reminded map[string]repository.SecretReminder
+	fail     map[string]bool
+	runs     int
+}
+
+func reminderKey(name, env, key string) string {
+	return name + "/" + env + "/" + key
+}
+
+func (f *fakeSecrets) ListDueSecrets(ctx context.Context, env string, within time.Duration) ([]secrets.DueSecret, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	name := project.FromContext(ctx)
+	if name == project.Default {
+		f.runs++
+	}
+	if f.fail[name] {
+		return nil, errors.New("storage unavailable")
+	}
+
+	due := []secrets.DueSecret{}
+	for _, secret := range f.due[name] {
+		if reminder, ok := f.reminded[reminderKey(name, secret.Env, secret.Key)]; ok {
+			secret.Reminded = &reminder
+		}
+		due = append(due, secret)
+	}
+	return due, nil
+}
+
+func (f *fakeSecrets) MarkSecretReminded(ctx context.Context, secret secrets.DueSecret) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.reminded[reminderKey(project.FromContext(ctx), secret.Env, secret.Key)] = repository.SecretReminder{Status: secret.Status, DueAt: secret.DueAt}
+	return nil
+}
+
+func (f *fakeSecrets) setDue(name string, due ...secrets.DueSecret) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.due[name] = due
+}
+
+func (f *fakeSecrets) calls() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.runs
+}
+
+type notification struct {
+	project string
+	env     string
+	notice  notify.SecretNotice
+}
+
+// fakeNotifier records the notices it was asked to send
+type fakeNotifier struct {
+	mux     sync.Mutex
+	notices []notification
+}
+
+func (f *fakeNotifier) NotifySecretExpiry(ctx context.Context, env string, notice notify.SecretNotice) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.notices = append(f.notices, notification{project: project.FromContext(ctx), env: env, notice: notice})
+}
+
+func (f *fakeNotifier) sent() []notification {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return append([]notification{}, f.notices...)
+}
+
+type fakeProjects []string
+
+func (p fakeProjects) GetProjects(ctx context.Context) []string {
+	return p
+}
+
+func dueSecret(env, key, status string, dueAt time.Time) secrets.DueSecret {
+	return secrets.DueSecret{
+		SecretInfoRecord: repository.SecretInfoRecord{Env: env, Key: key, SecretInfo: repository.SecretInfo{Owner: "payments-team"}},
+		Status:           status,
+		DueAt:            dueAt,
+	}
+}
+
+type ReminderServiceSuite struct {
+	suite.Suite
+	secrets  *fakeSecrets
+	notifier *fakeNotifier
+}
+
+func TestReminderServiceSuite(t *testing.T) {
+	suite.Run(t, new(ReminderServiceSuite))
+}
+
+func (suite *ReminderServiceSuite) SetupTest() {
+	suite.secrets = &fakeSecrets{due: map[string][]secrets.DueSecret{}, reminded: map[string]repository.SecretReminder{}, fail: map[string]bool{}}
+	suite.notifier = &fakeNotifier{}
+}
+
+func (suite *ReminderServiceSuite) TestRemind() {
+	dueAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	suite.Run("notifies the due secrets of every project once per status", func() {
+		suite.SetupTest()
+		suite.secrets.setDue("", dueSecret("develop", "token", secrets.DueExpiring, dueAt))
+		suite.secrets.setDue("billing", dueSecret("production", "stripe", secrets.DueRotation, dueAt))
+		reminder := service.New(suite.secrets, suite.notifier, 0, 0)
+
+		suite.Require().NoError(reminder.Remind(context.Background(), fakeProjects{"billing"}))
+		suite.Equal([]notification{
+			{project: "", env: "develop", notice: notify.SecretNotice{Key: "token", Status: secrets.DueExpiring, DueAt: dueAt, Owner: "payments-team"}},
+			{project: "billing", env: "production", notice: notify.SecretNotice{Key: "stripe", Status: secrets.DueRotation, DueAt: dueAt, Owner: "payments-team"}},
+		}, suite.notifier.sent())
+
+		// nothing changed
+		suite.Require().NoError(reminder.Remind(context.Background(), fakeProjects{"billing"}))
+		suite.Len(suite.notifier.sent(), 2)
+
+		// the secret expired meanwhile
+		suite.secrets.setDue("", dueSecret("develop", "token", secrets.DueExpired, dueAt))
+		suite.Require().NoError(reminder.Remind(context.Background(), fakeProjects{"billing"}))
+		suite.Require().Len(suite.notifier.sent(), 3)
+		suite.Equal(secrets.DueExpired, suite.notifier.sent()[2].notice.Status)
+	})
+
+	suite.Run("notifies again a secret due once more after being rotated", func() {
+		suite.SetupTest()
+		suite.secrets.setDue("", dueSecret("develop", "token", secrets.DueRotation, dueAt))
+		reminder := service.New(suite.secrets, suite.notifier, 0, 0)
+
+		suite.Require().NoError(reminder.Remind(context.Background(), fakeProjects{}))
+		suite.secrets.setDue("")
+		suite.Require().NoError(reminder.Remind(context.Background(), fakeProjects{}))
+
+		// rotating the secret moves its next rotation
+		suite.secrets.setDue("", dueSecret("develop", "token", secrets.DueRotation, dueAt.Add(720*time.Hour)))
+		suite.Require().NoError(reminder.Remind(context.Background(), fakeProjects{}))
+
+		suite.Len(suite.notifier.sent(), 2)
+	})
+
+	suite.Run("does not notify again after a restart", func() {
+		suite.SetupTest()
+		suite.secrets.setDue("billing", dueSecret("production", "stripe", secrets.DueExpiring, dueAt))
+
+		suite.Require().NoError(service.New(suite.secrets, suite.notifier, 0, 0).Remind(context.Background(), fakeProjects{"billing"}))
+		suite.Require().Len(suite.notifier.sent(), 1)
+
+		restarted := service.New(suite.secrets, suite.notifier, 0, 0)
+		suite.Require().NoError(restarted.Remind(context.Background(), fakeProjects{"billing"}))
+		suite.Len(suite.notifier.sent(), 1)
+	})
+
+	suite.Run("goes on with the other projects when one fails", func() {
+		suite.SetupTest()
+		suite.secrets.fail["billing"] = true
+		suite.secrets.setDue("payments", dueSecret("develop", "token", secrets.DueExpiring, dueAt))
+		reminder := service.New(suite.secrets, suite.notifier, 0, 0)
+
+		err := reminder.Remind(context.Background(), fakeProjects{"billing", "payments"})
+		suite.ErrorContains(err, "error listing due secrets of project 'billing'")
+		suite.Len(suite.notifier.sent(), 1)
+	})
+}
+
+func (suite *ReminderServiceSuite) TestRun() {
+	suite.Run("reminds on start and periodically until shut down", func() {
+		suite.SetupTest()
+		reminder := service.New(suite.secrets, suite.notifier, 10*time.Millisecond, 0)
+
+		reminder.Run(context.Background(), fakeProjects{})
+
+		suite.Eventually(func() bool {
+			return suite.secrets.calls() >= 2
+		}, time.Second, 5*time.Millisecond)
+
+		reminder.Shutdown()
+		calls := suite.secrets.calls()
+
+		time.Sleep(30 * time.Millisecond)
+		suite.Equal(calls, suite.secrets.calls())
+	})
+}
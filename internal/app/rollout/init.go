@@ -0,0 +1,33 @@
+package rollout
+
+import (
+	"context"
+
+	"github.com/raw-leak/configleam/config"
+	"github.com/raw-leak/configleam/internal/app/rollout/service"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+type RolloutSet struct {
+	*service.RolloutService
+}
+
+// Init creates the rollout of the opted-in workloads, it does nothing unless enabled
+func Init(ctx context.Context, cfg *config.Config) (*RolloutSet, error) {
+	if !bool(cfg.EnableRollout) {
+		return &RolloutSet{service.New(nil, nil)}, nil
+	}
+
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RolloutSet{service.New(clientset, cfg.RolloutNamespaces)}, nil
+}
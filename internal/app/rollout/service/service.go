@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/runner"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EnvAnnotation and GroupsAnnotation opt a Deployment or StatefulSet in, naming the env and the comma separated
+	// groups it reads, ProjectAnnotation naming the project when it is not the default one
+	EnvAnnotation     = "configleam.io/rollout-env"
+	GroupsAnnotation  = "configleam.io/rollout-groups"
+	ProjectAnnotation = "configleam.io/rollout-project"
+
+	// HashAnnotation holds the hash of the resolved groups, on the workload to remember the last one seen
+	// and on its pod template to roll the pods out when it changes
+	HashAnnotation = "configleam.io/config-hash"
+)
+
+type ConfigReader interface {
+	ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error)
+}
+
+// envKey identifies an environment of a project
+type envKey struct {
+	project string
+	env     string
+}
+
+// workload is an opted-in Deployment or StatefulSet
+type workload struct {
+	kind      string
+	namespace string
+	name      string
+	project   string
+	env       string
+	groups    []string
+	hash      string
+	patch     func(ctx context.Context, data []byte) error
+}
+
+func (w workload) key() string {
+	return fmt.Sprintf("%s '%s/%s'", w.kind, w.namespace, w.name)
+}
+
+// configKey identifies the resolved groups of the workload, shared with the workloads reading the same ones
+func (w workload) configKey() string {
+	return w.project + "/" + w.env + "/" + strings.Join(w.groups, ",")
+}
+
+// RolloutService rolls the opted-in Deployments and StatefulSets out when the groups they read are updated,
+// patching the hash of the resolved groups into their pod template.
+// The first hash seen of a workload is only recorded, so opting in does not roll it out.
+type RolloutService struct {
+	client     kubernetes.Interface
+	namespaces []string
+
+	mux     sync.Mutex
+	pending map[envKey]bool
+	wake    chan struct{}
+	runner  runner.Runner
+}
+
+// New creates the rollout of the workloads of the namespaces, all of them when none is provided,
+// it does nothing without a client
+func New(client kubernetes.Interface, namespaces []string) *RolloutService {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	return &RolloutService{
+		client:     client,
+		namespaces: namespaces,
+		pending:    map[envKey]bool{},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Run reconciles every opted-in workload, catching up with the updates applied while not running, and keeps
+// rolling them out on updates until Shutdown is called. It is meant to run on the leader only.
+func (s *RolloutService) Run(ctx context.Context, reader ConfigReader) {
+	if s.client == nil {
+		return
+	}
+
+	s.mux.Lock()
+	s.pending = map[envKey]bool{}
+	s.mux.Unlock()
+
+	s.runner.Start(ctx, func(ctx context.Context) {
+		if err := s.Reconcile(ctx, reader); err != nil {
+			log.Printf("Error rolling out workloads: %v", err)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+				s.mux.Lock()
+				pending := s.pending
+				s.pending = map[envKey]bool{}
+				s.mux.Unlock()
+
+				err := s.reconcile(ctx, reader, func(w workload) bool {
+					return pending[envKey{project: w.project, env: w.env}]
+				})
+				if err != nil {
+					log.Printf("Error rolling out workloads: %v", err)
+				}
+			}
+		}
+	})
+}
+
+// Shutdown stops rolling out, waiting for the ongoing rollouts to be patched
+func (s *RolloutService) Shutdown() {
+	s.runner.Stop()
+}
+
+// NotifyConfigUpdate queues the updated environment of the project carried by the context, without blocking
+func (s *RolloutService) NotifyConfigUpdate(ctx context.Context, repo, env, version string) {
+	if s.client == nil {
+		return
+	}
+
+	s.mux.Lock()
+	s.pending[envKey{project: project.FromContext(ctx), env: env}] = true
+	s.mux.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Reconcile rolls out every opted-in workload whose resolved groups changed since their hash was recorded
+func (s *RolloutService) Reconcile(ctx context.Context, reader ConfigReader) error {
+	return s.reconcile(ctx, reader, func(workload) bool { return true })
+}
+
+func (s *RolloutService) reconcile(ctx context.Context, reader ConfigReader, match func(workload) bool) error {
+	workloads, err := s.listWorkloads(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	hashes := map[string]string{}
+
+	for _, w := range workloads {
+		if !match(w) {
+			continue
+		}
+
+		hash, ok := hashes[w.configKey()]
+		if !ok {
+			hash, err = configHash(ctx, reader, w)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error hashing configuration of %s: %w", w.key(), err))
+				continue
+			}
+			hashes[w.configKey()] = hash
+		}
+
+		err = s.rollout(ctx, w, hash)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rollout records the hash on the workload, and patches it into its pod template when a previous one was recorded
+func (s *RolloutService) rollout(ctx context.Context, w workload, hash string) error {
+	if w.hash == hash {
+		return nil
+	}
+
+	annotations := map[string]interface{}{"annotations": map[string]string{HashAnnotation: hash}}
+	patch := map[string]interface{}{"metadata": annotations}
+	if w.hash != "" {
+		patch["spec"] = map[string]interface{}{"template": map[string]interface{}{"metadata": annotations}}
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("error encoding patch of %s: %w", w.key(), err)
+	}
+
+	err = w.patch(ctx, data)
+	if err != nil {
+		return fmt.Errorf("error patching %s: %w", w.key(), err)
+	}
+
+	if w.hash == "" {
+		log.Printf("Recorded configuration hash of %s", w.key())
+	} else {
+		log.Printf("Rolling out %s as the groups '%s' of '%s' environment changed", w.key(), strings.Join(w.groups, ","), w.env)
+	}
+	return nil
+}
+
+// configHash hashes the resolved groups of the workload, the secrets masked as their values are not versioned
+func configHash(ctx context.Context, reader ConfigReader, w workload) (string, error) {
+	perms := permissions.New().NewAccessKeyPermissions()
+	perms.Grant(w.env, permissions.ReadConfig)
+
+	ctx = context.WithValue(project.WithProject(ctx, w.project), auth.AccessKeyContextKey{}, *perms)
+
+	cfg, _, err := reader.ReadConfig(ctx, w.env, "", w.groups, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// maps are encoded with sorted keys, so equal configurations get equal hashes
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// listWorkloads lists the opted-in Deployments and StatefulSets of the namespaces
+func (s *RolloutService) listWorkloads(ctx context.Context) ([]workload, error) {
+	workloads := []workload{}
+
+	for _, namespace := range s.namespaces {
+		deployments, err := s.client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing deployments: %w", err)
+		}
+		for _, d := range deployments.Items {
+			deployments := s.client.AppsV1().Deployments(d.Namespace)
+			name := d.Name
+			w, ok := newWorkload("deployment", d.ObjectMeta, func(ctx context.Context, data []byte) error {
+				_, err := deployments.Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{})
+				return err
+			})
+			if ok {
+				workloads = append(workloads, w)
+			}
+		}
+
+		statefulSets, err := s.client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing statefulsets: %w", err)
+		}
+		for _, ss := range statefulSets.Items {
+			statefulSets := s.client.AppsV1().StatefulSets(ss.Namespace)
+			name := ss.Name
+			w, ok := newWorkload("statefulset", ss.ObjectMeta, func(ctx context.Context, data []byte) error {
+				_, err := statefulSets.Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{})
+				return err
+			})
+			if ok {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+
+	return workloads, nil
+}
+
+// newWorkload reads the opt-in annotations of the workload, reporting whether it opted in
+func newWorkload(kind string, meta metav1.ObjectMeta, patch func(ctx context.Context, data []byte) error) (workload, bool) {
+	env := meta.Annotations[EnvAnnotation]
+	if env == "" {
+		return workload{}, false
+	}
+
+	groups := []string{}
+	for _, group := range strings.Split(meta.Annotations[GroupsAnnotation], ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		log.Printf("Skipping %s '%s/%s' as its '%s' annotation names no group", kind, meta.Namespace, meta.Name, GroupsAnnotation)
+		return workload{}, false
+	}
+	sort.Strings(groups)
+
+	return workload{
+		kind:      kind,
+		namespace: meta.Namespace,
+		name:      meta.Name,
+		project:   meta.Annotations[ProjectAnnotation],
+		env:       env,
+		groups:    groups,
+		hash:      meta.Annotations[HashAnnotation],
+		patch:     patch,
+	}, true
+}
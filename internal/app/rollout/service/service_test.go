@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/app/rollout/service"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// fakeReader serves the groups of each project and env
+type fakeReader struct {
+	mux    sync.Mutex
+	groups map[string]map[string]interface{}
+}
+
+func (r *fakeReader) set(project, env, group string, cfg interface{}) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	key := project + "/" + env
+	if r.groups[key] == nil {
+		r.groups[key] = map[string]interface{}{}
+	}
+	r.groups[key][group] = cfg
+}
+
+func (r *fakeReader) ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	cfg := map[string]interface{}{}
+	for _, group := range groups {
+		if value, ok := r.groups[project.FromContext(ctx)+"/"+env][group]; ok {
+			cfg[group] = value
+		}
+	}
+	return cfg, "v1.0.0", nil
+}
+
+type RolloutServiceSuite struct {
+	suite.Suite
+	client *fake.Clientset
+	reader *fakeReader
+}
+
+func TestRolloutServiceSuite(t *testing.T) {
+	suite.Run(t, new(RolloutServiceSuite))
+}
+
+func (suite *RolloutServiceSuite) SetupTest() {
+	suite.reader = &fakeReader{groups: map[string]map[string]interface{}{}}
+	suite.reader.set("", "production", "billing", map[string]interface{}{"host": "db"})
+	suite.reader.set("", "production", "payments", map[string]interface{}{"timeout": 30})
+
+	suite.client = fake.NewSimpleClientset(
+		deployment("billing", map[string]string{service.EnvAnnotation: "production", service.GroupsAnnotation: "billing"}),
+		deployment("payments", map[string]string{service.EnvAnnotation: "production", service.GroupsAnnotation: "payments, billing"}),
+		deployment("unmanaged", nil),
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "ledger", Namespace: "apps", Annotations: map[string]string{
+			service.EnvAnnotation: "production", service.GroupsAnnotation: "payments",
+		}}},
+	)
+}
+
+func deployment(name string, annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", Annotations: annotations}}
+}
+
+func (suite *RolloutServiceSuite) getDeployment(name string) *appsv1.Deployment {
+	d, err := suite.client.AppsV1().Deployments("apps").Get(context.Background(), name, metav1.GetOptions{})
+	suite.Require().NoError(err)
+	return d
+}
+
+func (suite *RolloutServiceSuite) getStatefulSet(name string) *appsv1.StatefulSet {
+	ss, err := suite.client.AppsV1().StatefulSets("apps").Get(context.Background(), name, metav1.GetOptions{})
+	suite.Require().NoError(err)
+	return ss
+}
+
+func (suite *RolloutServiceSuite) TestReconcileRecordsWithoutRollingOut() {
+	s := service.New(suite.client, nil)
+	suite.Require().NoError(s.Reconcile(context.Background(), suite.reader))
+
+	billing := suite.getDeployment("billing")
+	suite.NotEmpty(billing.Annotations[service.HashAnnotation])
+	suite.Empty(billing.Spec.Template.Annotations[service.HashAnnotation])
+
+	ledger := suite.getStatefulSet("ledger")
+	suite.NotEmpty(ledger.Annotations[service.HashAnnotation])
+	suite.Empty(ledger.Spec.Template.Annotations[service.HashAnnotation])
+
+	suite.Empty(suite.getDeployment("unmanaged").Annotations[service.HashAnnotation])
+}
+
+func (suite *RolloutServiceSuite) TestReconcileRollsOutChangedGroupsOnly() {
+	s := service.New(suite.client, nil)
+	suite.Require().NoError(s.Reconcile(context.Background(), suite.reader))
+
+	// nothing changed, nothing rolls out
+	suite.Require().NoError(s.Reconcile(context.Background(), suite.reader))
+	suite.Empty(suite.getDeployment("billing").Spec.Template.Annotations[service.HashAnnotation])
+
+	previous := suite.getDeployment("billing").Annotations[service.HashAnnotation]
+	suite.reader.set("", "production", "billing", map[string]interface{}{"host": "db-2"})
+	suite.Require().NoError(s.Reconcile(context.Background(), suite.reader))
+
+	billing := suite.getDeployment("billing")
+	suite.NotEqual(previous, billing.Annotations[service.HashAnnotation])
+	suite.Equal(billing.Annotations[service.HashAnnotation], billing.Spec.Template.Annotations[service.HashAnnotation])
+
+	// reading the billing group as well, the payments deployment rolls out
+	payments := suite.getDeployment("payments")
+	suite.Equal(payments.Annotations[service.HashAnnotation], payments.Spec.Template.Annotations[service.HashAnnotation])
+
+	// the ledger statefulset only reads the payments group
+	suite.Empty(suite.getStatefulSet("ledger").Spec.Template.Annotations[service.HashAnnotation])
+}
+
+func (suite *RolloutServiceSuite) TestNotifyConfigUpdate() {
+	_, err := suite.client.AppsV1().Deployments("apps").Create(context.Background(), deployment("invoices", map[string]string{
+		service.EnvAnnotation: "production", service.GroupsAnnotation: "billing", service.ProjectAnnotation: "invoicing",
+	}), metav1.CreateOptions{})
+	suite.Require().NoError(err)
+
+	s := service.New(suite.client, []string{"apps"})
+	s.Run(context.Background(), suite.reader)
+	defer s.Shutdown()
+
+	suite.Eventually(func() bool {
+		return suite.getDeployment("invoices").Annotations[service.HashAnnotation] != ""
+	}, time.Second, 10*time.Millisecond)
+
+	suite.reader.set("invoicing", "production", "billing", map[string]interface{}{"host": "db-2"})
+	suite.reader.set("", "production", "billing", map[string]interface{}{"host": "db-2"})
+	s.NotifyConfigUpdate(project.WithProject(context.Background(), "invoicing"), "repo", "production", "v1.1.0")
+
+	suite.Eventually(func() bool {
+		return suite.getDeployment("invoices").Spec.Template.Annotations[service.HashAnnotation] != ""
+	}, time.Second, 10*time.Millisecond)
+
+	// the update of the invoicing project does not roll the workloads of the default one out
+	suite.Empty(suite.getDeployment("billing").Spec.Template.Annotations[service.HashAnnotation])
+}
+
+func (suite *RolloutServiceSuite) TestDisabled() {
+	s := service.New(nil, nil)
+	s.Run(context.Background(), suite.reader)
+	s.NotifyConfigUpdate(context.Background(), "repo", "production", "v1.1.0")
+	s.Shutdown()
+}
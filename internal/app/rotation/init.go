@@ -0,0 +1,17 @@
+package rotation
+
+import (
+	"context"
+
+	"github.com/raw-leak/configleam/config"
+	"github.com/raw-leak/configleam/internal/app/rotation/service"
+)
+
+type RotationSet struct {
+	*service.RotationService
+}
+
+// Init creates the re-encryption of the stored secrets and access-keys with the primary master key
+func Init(ctx context.Context, cfg *config.Config, secrets service.SecretsReencryptor, access service.AccessKeysReencryptor) (*RotationSet, error) {
+	return &RotationSet{service.New(secrets, access, cfg.ReencryptInterval)}, nil
+}
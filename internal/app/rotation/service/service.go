@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/runner"
+)
+
+const IntervalDefault = time.Hour
+
+type SecretsReencryptor interface {
+	ReencryptSecrets(ctx context.Context) (int, error)
+}
+
+type AccessKeysReencryptor interface {
+	ReencryptAccessKeys(ctx context.Context) (int, error)
+}
+
+type ProjectLister interface {
+	GetProjects(ctx context.Context) []string
+}
+
+// RotationService re-encrypts the stored secrets and access-keys of every project with the primary master key,
+// so that a retired master key can be removed once no ciphertext is wrapped by it anymore.
+type RotationService struct {
+	secrets  SecretsReencryptor
+	access   AccessKeysReencryptor
+	interval time.Duration
+
+	runner runner.Runner
+}
+
+// New creates the re-encryption job, running every interval
+func New(secrets SecretsReencryptor, access AccessKeysReencryptor, interval time.Duration) *RotationService {
+	if interval == 0 {
+		interval = IntervalDefault
+	}
+
+	return &RotationService{
+		secrets:  secrets,
+		access:   access,
+		interval: interval,
+	}
+}
+
+// Run re-encrypts every project and keeps doing so periodically until Shutdown is called,
+// it is meant to run on the leader only
+func (s *RotationService) Run(ctx context.Context, projects ProjectLister) {
+	s.runner.Start(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		if err := s.Rotate(ctx, projects); err != nil {
+			log.Printf("Error re-encrypting with the primary master key: %v", err)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Rotate(ctx, projects); err != nil {
+					log.Printf("Error re-encrypting with the primary master key: %v", err)
+				}
+			}
+		}
+	})
+}
+
+// Shutdown stops re-encrypting, waiting for the ongoing run to finish
+func (s *RotationService) Shutdown() {
+	s.runner.Stop()
+}
+
+// Rotate re-encrypts the secrets and access-keys of the default project and of every configured one,
+// going on with the other projects when one fails
+func (s *RotationService) Rotate(ctx context.Context, projects ProjectLister) error {
+	var errs []error
+
+	seen := map[string]bool{}
+	for _, name := range append([]string{project.Default}, projects.GetProjects(ctx)...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		projectCtx := project.WithProject(ctx, name)
+
+		secrets, err := s.secrets.ReencryptSecrets(projectCtx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error re-encrypting secrets of project '%s': %w", name, err))
+		}
+
+		keys, err := s.access.ReencryptAccessKeys(projectCtx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error re-encrypting access keys of project '%s': %w", name, err))
+		}
+
+		if secrets > 0 || keys > 0 {
+			log.Printf("Re-encrypted %d secrets and %d access keys of project '%s' with the primary master key", secrets, keys, name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/raw-leak/configleam/internal/app/rotation/service"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// fakeReencryptor records the projects it re-encrypted, failing for the ones in fail
+type fakeReencryptor struct {
+	mux      sync.Mutex
+	projects []string
+	fail     map[string]bool
+}
+
+func (f *fakeReencryptor) reencrypt(ctx context.Context) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	name := project.FromContext(ctx)
+	f.projects = append(f.projects, name)
+	if f.fail[name] {
+		return 0, errors.New("storage unavailable")
+	}
+	return 1, nil
+}
+
+func (f *fakeReencryptor) ReencryptSecrets(ctx context.Context) (int, error) {
+	return f.reencrypt(ctx)
+}
+
+func (f *fakeReencryptor) ReencryptAccessKeys(ctx context.Context) (int, error) {
+	return f.reencrypt(ctx)
+}
+
+func (f *fakeReencryptor) calls() []string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return append([]string{}, f.projects...)
+}
+
+type fakeProjects []string
+
+func (p fakeProjects) GetProjects(ctx context.Context) []string {
+	return p
+}
+
+type RotationServiceSuite struct {
+	suite.Suite
+	secrets *fakeReencryptor
+	access  *fakeReencryptor
+}
+
+func TestRotationServiceSuite(t *testing.T) {
+	suite.Run(t, new(RotationServiceSuite))
+}
+
+func (suite *RotationServiceSuite) SetupTest() {
+	suite.secrets = &fakeReencryptor{fail: map[string]bool{}}
+	suite.access = &fakeReencryptor{fail: map[string]bool{}}
+}
+
+func (suite *RotationServiceSuite) TestRotate() {
+	suite.Run("re-encrypts the default project and every configured one once", func() {
+		suite.SetupTest()
+		rotation := service.New(suite.secrets, suite.access, 0)
+
+		err := rotation.Rotate(context.Background(), fakeProjects{"", "billing", "payments"})
+		suite.NoError(err)
+
+		suite.Equal([]string{"", "billing", "payments"}, suite.secrets.calls())
+		suite.Equal([]string{"", "billing", "payments"}, suite.access.calls())
+	})
+
+	suite.Run("goes on with the other projects when one fails", func() {
+		suite.SetupTest()
+		suite.secrets.fail["billing"] = true
+		rotation := service.New(suite.secrets, suite.access, 0)
+
+		err := rotation.Rotate(context.Background(), fakeProjects{"billing", "payments"})
+		suite.ErrorContains(err, "error re-encrypting secrets of project 'billing'")
+
+		suite.Equal([]string{"", "billing", "payments"}, suite.secrets.calls())
+		suite.Equal([]string{"", "billing", "payments"}, suite.access.calls())
+	})
+}
+
+func (suite *RotationServiceSuite) TestRun() {
+	suite.Run("re-encrypts on start and periodically until shut down", func() {
+		suite.SetupTest()
+		rotation := service.New(suite.secrets, suite.access, 10*time.Millisecond)
+
+		rotation.Run(context.Background(), fakeProjects{})
+
+		suite.Eventually(func() bool {
+			return len(suite.secrets.calls()) >= 2
+		}, time.Second, 5*time.Millisecond)
+
+		rotation.Shutdown()
+		calls := len(suite.secrets.calls())
+
+		time.Sleep(30 * time.Millisecond)
+		suite.Equal(calls, len(suite.secrets.calls()))
+	})
+}
@@ -3,13 +3,29 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/app/secrets/service"
 )
 
+// MetadataKey is the key of the upserted secrets body holding the metadata of the secrets, by key
+const MetadataKey = "$metadata"
+
 type SecretsService interface {
-	UpsertSecrets(ctx context.Context, env string, cfg map[string]interface{}) error
+	UpsertSecrets(ctx context.Context, env string, cfg map[string]interface{}, info map[string]repository.SecretInfo) error
+	ListDueSecrets(ctx context.Context, env string, within time.Duration) ([]service.DueSecret, error)
+	ListSecrets(ctx context.Context, env string) ([]repository.SecretMetadata, error)
+	DeleteSecret(ctx context.Context, env, key string) error
+	PatchSecrets(ctx context.Context, env string, patch map[string]interface{}) error
+	CheckPlaceholders(ctx context.Context, env, value string) ([]service.PlaceholderCheck, error)
+	ListSecretVersions(ctx context.Context, env, key string) ([]repository.SecretVersion, error)
+	RollbackSecret(ctx context.Context, env, key string, version int64) error
 }
 
 type SecretsEndpoints struct {
@@ -21,7 +37,7 @@ func New(s SecretsService) *SecretsEndpoints {
 }
 
 func (e SecretsEndpoints) UpsertSecretsHandler(w http.ResponseWriter, r *http.Request) {
-	query, ctx := r.URL.Query(), context.Background()
+	query, ctx := r.URL.Query(), r.Context()
 
 	env := query.Get("env")
 
@@ -33,10 +49,15 @@ func (e SecretsEndpoints) UpsertSecretsHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	err = e.service.UpsertSecrets(ctx, env, secrets)
+	info, err := popSecretInfo(secrets)
 	if err != nil {
-		log.Printf("Error upserting secrets for env %s with error: %v", env, err)
-		http.Error(w, fmt.Sprintf("Error upserting secrets for env %s", env), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = e.service.UpsertSecrets(ctx, env, secrets, info)
+	if err != nil {
+		writeSecretError(w, err, fmt.Sprintf("Error upserting secrets for env %s", env))
 		return
 	}
 
@@ -48,3 +69,241 @@ func (e SecretsEndpoints) UpsertSecretsHandler(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
+
+// popSecretInfo removes the metadata of the secrets from the upserted secrets, returning it
+func popSecretInfo(secrets map[string]interface{}) (map[string]repository.SecretInfo, error) {
+	raw, ok := secrets[MetadataKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(secrets, MetadataKey)
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]repository.SecretInfo{}
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s': %v", MetadataKey, err)
+	}
+	return info, nil
+}
+
+// ListDueSecretsHandler lists the secrets of the env that expired or are due for rotation, or will be within the notice
+func (e SecretsEndpoints) ListDueSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	env := query.Get("env")
+
+	within := service.DueWithinDefault
+	if value := query.Get("within"); value != "" {
+		var err error
+		within, err = time.ParseDuration(value)
+		if err != nil || within < 0 {
+			http.Error(w, "within must be a positive duration, e.g. 720h", http.StatusBadRequest)
+			return
+		}
+	}
+
+	secrets, err := e.service.ListDueSecrets(r.Context(), env, within)
+	if err != nil {
+		log.Printf("Error listing due secrets for env %s with error: %v", env, err)
+		http.Error(w, fmt.Sprintf("Error listing due secrets for env %s", env), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"env": env, "secrets": secrets})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// ListSecretsHandler lists the keys of the secrets of the env along with their metadata, never their values
+func (e SecretsEndpoints) ListSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	env := r.URL.Query().Get("env")
+
+	secrets, err := e.service.ListSecrets(r.Context(), env)
+	if err != nil {
+		log.Printf("Error listing secrets for env %s with error: %v", env, err)
+		http.Error(w, fmt.Sprintf("Error listing secrets for env %s", env), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"env": env, "secrets": secrets})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// DeleteSecretHandler deletes a secret, or a nested value of a secret when the key is a dot path
+func (e SecretsEndpoints) DeleteSecretHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	env, key := query.Get("env"), query.Get("key")
+
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	err := e.service.DeleteSecret(r.Context(), env, key)
+	if err != nil {
+		writeSecretError(w, err, fmt.Sprintf("Error deleting secret %s for env %s", key, env))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]string{"message": "Secret deleted successfully"}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// PatchSecretsHandler sets the secrets, or their nested values, of the dot paths of the body, null values deleting them
+func (e SecretsEndpoints) PatchSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	env := r.URL.Query().Get("env")
+
+	patch := make(map[string]interface{})
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Error decoding request body", http.StatusBadRequest)
+		return
+	}
+	if len(patch) == 0 {
+		http.Error(w, "Request body must hold at least one secret", http.StatusBadRequest)
+		return
+	}
+
+	err = e.service.PatchSecrets(r.Context(), env, patch)
+	if err != nil {
+		writeSecretError(w, err, fmt.Sprintf("Error patching secrets for env %s", env))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]string{"message": "Secrets patched successfully"}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// CheckSecretsHandler reports whether each secret placeholder of the value resolves, without revealing any secret
+func (e SecretsEndpoints) CheckSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	env, value := query.Get("env"), query.Get("value")
+
+	if value == "" {
+		http.Error(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	checks, err := e.service.CheckPlaceholders(r.Context(), env, value)
+	if err != nil {
+		writeSecretError(w, err, fmt.Sprintf("Error checking secret placeholders for env %s", env))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"env": env, "placeholders": checks})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// ListSecretVersionsHandler lists the kept versions of a secret, with who changed it and when, never their values
+func (e SecretsEndpoints) ListSecretVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	env, key := query.Get("env"), query.Get("key")
+
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := e.service.ListSecretVersions(r.Context(), env, key)
+	if err != nil {
+		writeSecretError(w, err, fmt.Sprintf("Error listing versions of secret %s for env %s", key, env))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"env": env, "key": key, "versions": versions})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// RollbackSecretHandler brings a secret back to one of its kept versions
+func (e SecretsEndpoints) RollbackSecretHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	env, key := query.Get("env"), query.Get("key")
+
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	version, err := strconv.ParseInt(query.Get("version"), 10, 64)
+	if err != nil || version < 1 {
+		http.Error(w, "version must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	err = e.service.RollbackSecret(r.Context(), env, key, version)
+	if err != nil {
+		writeSecretError(w, err, fmt.Sprintf("Error rolling back secret %s for env %s", key, env))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]string{"message": fmt.Sprintf("Secret rolled back to version %d successfully", version)}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// writeSecretError answers with the status matching the error, the message being used for unexpected errors
+func writeSecretError(w http.ResponseWriter, err error, message string) {
+	var (
+		notFound    repository.SecretNotFoundError
+		noVersion   repository.SecretVersionNotFoundError
+		conflict    repository.SecretConflictError
+		invalidPath service.SecretPathError
+		invalidPh   service.InvalidPlaceholderError
+		invalidScp  service.InvalidScopeError
+		notAllowed  service.RevealNotAllowedError
+	)
+
+	switch {
+	case errors.As(err, &notFound):
+		http.Error(w, notFound.Error(), http.StatusNotFound)
+	case errors.As(err, &noVersion):
+		http.Error(w, noVersion.Error(), http.StatusNotFound)
+	case errors.As(err, &conflict):
+		http.Error(w, conflict.Error(), http.StatusConflict)
+	case errors.As(err, &invalidPath):
+		http.Error(w, invalidPath.Error(), http.StatusBadRequest)
+	case errors.As(err, &invalidPh):
+		http.Error(w, invalidPh.Error(), http.StatusBadRequest)
+	case errors.As(err, &invalidScp):
+		http.Error(w, invalidScp.Error(), http.StatusBadRequest)
+	case errors.As(err, &notAllowed):
+		http.Error(w, notAllowed.Error(), http.StatusForbidden)
+	default:
+		log.Printf("%s with error: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
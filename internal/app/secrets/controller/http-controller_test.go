@@ -0,0 +1,256 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/controller"
+	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/app/secrets/service"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockSecretsService struct {
+	mock.Mock
+}
+
+func (m *MockSecretsService) UpsertSecrets(ctx context.Context, env string, cfg map[string]interface{}, info map[string]repository.SecretInfo) error {
+	args := m.Called(ctx, env, cfg, info)
+	return args.Error(0)
+}
+
+func (m *MockSecretsService) ListDueSecrets(ctx context.Context, env string, within time.Duration) ([]service.DueSecret, error) {
+	args := m.Called(ctx, env, within)
+	secrets, _ := args.Get(0).([]service.DueSecret)
+	return secrets, args.Error(1)
+}
+
+func (m *MockSecretsService) ListSecrets(ctx context.Context, env string) ([]repository.SecretMetadata, error) {
+	args := m.Called(ctx, env)
+	secrets, _ := args.Get(0).([]repository.SecretMetadata)
+	return secrets, args.Error(1)
+}
+
+func (m *MockSecretsService) DeleteSecret(ctx context.Context, env, key string) error {
+	args := m.Called(ctx, env, key)
+	return args.Error(0)
+}
+
+func (m *MockSecretsService) PatchSecrets(ctx context.Context, env string, patch map[string]interface{}) error {
+	args := m.Called(ctx, env, patch)
+	return args.Error(0)
+}
+
+func (m *MockSecretsService) CheckPlaceholders(ctx context.Context, env, value string) ([]service.PlaceholderCheck, error) {
+	args := m.Called(ctx, env, value)
+	checks, _ := args.Get(0).([]service.PlaceholderCheck)
+	return checks, args.Error(1)
+}
+
+func (m *MockSecretsService) ListSecretVersions(ctx context.Context, env, key string) ([]repository.SecretVersion, error) {
+	args := m.Called(ctx, env, key)
+	versions, _ := args.Get(0).([]repository.SecretVersion)
+	return versions, args.Error(1)
+}
+
+func (m *MockSecretsService) RollbackSecret(ctx context.Context, env, key string, version int64) error {
+	args := m.Called(ctx, env, key, version)
+	return args.Error(0)
+}
+
+type SecretsEndpointSuite struct {
+	suite.Suite
+	service   *MockSecretsService
+	endpoints *controller.SecretsEndpoints
+}
+
+func (suite *SecretsEndpointSuite) SetupTest() {
+	suite.service = new(MockSecretsService)
+	suite.endpoints = controller.New(suite.service)
+}
+
+func TestSecretsEndpointSuite(t *testing.T) {
+	suite.Run(t, new(SecretsEndpointSuite))
+}
+
+func (suite *SecretsEndpointSuite) serve(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func (suite *SecretsEndpointSuite) TestUpsertSecretsHandlerMetadata() {
+	rotation := repository.Duration(720 * time.Hour)
+	secrets := map[string]interface{}{"apiKey": "secret"}
+	info := map[string]repository.SecretInfo{"apiKey": {Owner: "payments", RotationInterval: rotation}}
+	suite.service.On("UpsertSecrets", mock.Anything, "dev", secrets, info).Return(nil)
+
+	body := `{"apiKey":"secret","$metadata":{"apiKey":{"owner":"payments","rotationInterval":"720h"}}}`
+	rr := suite.serve(suite.endpoints.UpsertSecretsHandler, http.MethodPut, "/secrets?env=dev", body)
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.JSONEq(`{"message":"Secrets upserted successfully"}`, rr.Body.String())
+	suite.service.AssertExpectations(suite.T())
+}
+
+func (suite *SecretsEndpointSuite) TestUpsertSecretsHandlerWithoutMetadata() {
+	secrets := map[string]interface{}{"apiKey": "secret"}
+	suite.service.On("UpsertSecrets", mock.Anything, "dev", secrets, map[string]repository.SecretInfo(nil)).Return(nil)
+
+	rr := suite.serve(suite.endpoints.UpsertSecretsHandler, http.MethodPut, "/secrets?env=dev", `{"apiKey":"secret"}`)
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.service.AssertExpectations(suite.T())
+}
+
+func (suite *SecretsEndpointSuite) TestUpsertSecretsHandlerBadInput() {
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{"apiKey":`},
+		{"metadata not an object", `{"apiKey":"secret","$metadata":"owner"}`},
+		{"invalid rotation interval", `{"apiKey":"secret","$metadata":{"apiKey":{"rotationInterval":"monthly"}}}`},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			rr := suite.serve(suite.endpoints.UpsertSecretsHandler, http.MethodPut, "/secrets?env=dev", tc.body)
+
+			suite.Equal(http.StatusBadRequest, rr.Code)
+		})
+	}
+	suite.service.AssertNotCalled(suite.T(), "UpsertSecrets", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *SecretsEndpointSuite) TestWriteSecretError() {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{"secret not found", repository.SecretNotFoundError{Key: "apiKey"}, http.StatusNotFound, "secret 'apiKey' not found"},
+		{"version not found", repository.SecretVersionNotFoundError{Key: "apiKey", Version: 3}, http.StatusNotFound, repository.SecretVersionNotFoundError{Key: "apiKey", Version: 3}.Error()},
+		{"conflict", repository.SecretConflictError{Key: "apiKey"}, http.StatusConflict, repository.SecretConflictError{Key: "apiKey"}.Error()},
+		{"invalid path", service.SecretPathError{Key: "apiKey.value", Reason: "not an object"}, http.StatusBadRequest, "not an object"},
+		{"invalid placeholder", service.InvalidPlaceholderError{Placeholder: "{{secret}}", Reason: "no key"}, http.StatusBadRequest, "no key"},
+		{"invalid scope", service.InvalidScopeError{Scope: "shared", Reason: "unknown"}, http.StatusBadRequest, "unknown"},
+		{"reveal not allowed", service.RevealNotAllowedError{Env: "prod"}, http.StatusForbidden, service.RevealNotAllowedError{Env: "prod"}.Error()},
+		{"wrapped error", fmt.Errorf("error patching: %w", repository.SecretNotFoundError{Key: "apiKey"}), http.StatusNotFound, "secret 'apiKey' not found"},
+		{"unexpected error", errors.New("connection refused"), http.StatusInternalServerError, "Error deleting secret apiKey for env dev"},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.SetupTest()
+			suite.service.On("DeleteSecret", mock.Anything, "dev", "apiKey").Return(tc.err)
+
+			rr := suite.serve(suite.endpoints.DeleteSecretHandler, http.MethodDelete, "/secrets?env=dev&key=apiKey", "")
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+			suite.Contains(rr.Body.String(), tc.expectedMessage)
+			suite.NotContains(rr.Body.String(), "connection refused")
+		})
+	}
+}
+
+func (suite *SecretsEndpointSuite) TestRollbackSecretHandler() {
+	suite.service.On("RollbackSecret", mock.Anything, "dev", "apiKey", int64(2)).Return(nil)
+
+	rr := suite.serve(suite.endpoints.RollbackSecretHandler, http.MethodPost, "/secrets/rollback?env=dev&key=apiKey&version=2", "")
+
+	suite.Equal(http.StatusOK, rr.Code)
+	suite.JSONEq(`{"message":"Secret rolled back to version 2 successfully"}`, rr.Body.String())
+}
+
+func (suite *SecretsEndpointSuite) TestRollbackSecretHandlerBadInput() {
+	testCases := []struct {
+		name   string
+		target string
+	}{
+		{"missing key", "/secrets/rollback?env=dev&version=2"},
+		{"missing version", "/secrets/rollback?env=dev&key=apiKey"},
+		{"version not a number", "/secrets/rollback?env=dev&key=apiKey&version=latest"},
+		{"version zero", "/secrets/rollback?env=dev&key=apiKey&version=0"},
+		{"negative version", "/secrets/rollback?env=dev&key=apiKey&version=-1"},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			rr := suite.serve(suite.endpoints.RollbackSecretHandler, http.MethodPost, tc.target, "")
+
+			suite.Equal(http.StatusBadRequest, rr.Code)
+		})
+	}
+	suite.service.AssertNotCalled(suite.T(), "RollbackSecret", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *SecretsEndpointSuite) TestListDueSecretsHandlerWithin() {
+	testCases := []struct {
+		name           string
+		target         string
+		expectedWithin time.Duration
+	}{
+		{"default notice", "/secrets/due?env=dev", service.DueWithinDefault},
+		{"given notice", "/secrets/due?env=dev&within=720h", 720 * time.Hour},
+		{"no notice", "/secrets/due?env=dev&within=0s", 0},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.SetupTest()
+			suite.service.On("ListDueSecrets", mock.Anything, "dev", tc.expectedWithin).Return([]service.DueSecret{}, nil)
+
+			rr := suite.serve(suite.endpoints.ListDueSecretsHandler, http.MethodGet, tc.target, "")
+
+			suite.Equal(http.StatusOK, rr.Code)
+			suite.JSONEq(`{"env":"dev","secrets":[]}`, rr.Body.String())
+			suite.service.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *SecretsEndpointSuite) TestListDueSecretsHandlerBadInput() {
+	for _, within := range []string{"monthly", "-1h", "30"} {
+		suite.Run(within, func() {
+			rr := suite.serve(suite.endpoints.ListDueSecretsHandler, http.MethodGet, "/secrets/due?env=dev&within="+within, "")
+
+			suite.Equal(http.StatusBadRequest, rr.Code)
+		})
+	}
+	suite.service.AssertNotCalled(suite.T(), "ListDueSecrets", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *SecretsEndpointSuite) TestRequiredParameters() {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{"delete without key", suite.endpoints.DeleteSecretHandler, http.MethodDelete, "/secrets?env=dev", ""},
+		{"versions without key", suite.endpoints.ListSecretVersionsHandler, http.MethodGet, "/secrets/versions?env=dev", ""},
+		{"check without value", suite.endpoints.CheckSecretsHandler, http.MethodGet, "/secrets/check?env=dev", ""},
+		{"patch with empty body", suite.endpoints.PatchSecretsHandler, http.MethodPatch, "/secrets?env=dev", `{}`},
+		{"patch with invalid JSON", suite.endpoints.PatchSecretsHandler, http.MethodPatch, "/secrets?env=dev", `{"apiKey"`},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			rr := suite.serve(tc.handler, tc.method, tc.target, tc.body)
+
+			suite.Equal(http.StatusBadRequest, rr.Code)
+		})
+	}
+	suite.Empty(suite.service.Calls)
+}
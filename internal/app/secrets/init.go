@@ -5,8 +5,11 @@ import (
 
 	"github.com/raw-leak/configleam/config"
 	"github.com/raw-leak/configleam/internal/app/secrets/controller"
+	"github.com/raw-leak/configleam/internal/app/secrets/provider"
 	"github.com/raw-leak/configleam/internal/app/secrets/repository"
 	"github.com/raw-leak/configleam/internal/app/secrets/service"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 type SecretsSet struct {
@@ -20,16 +23,28 @@ func Init(ctx context.Context, cfg *config.Config, encryptor repository.Encrypto
 		RedisUsername: cfg.RedisUsername,
 		RedisPassword: cfg.RedisPassword,
 		RedisTLS:      bool(cfg.RedisTls),
+		RedisTLSFiles: cfg.RedisTlsFiles(),
 
 		EtcdAddrs:    cfg.EtcdAddrs,
 		EtcdUsername: cfg.EtcdUsername,
 		EtcdPassword: cfg.EtcdPassword,
+		EtcdTLS:      bool(cfg.EtcdTls),
+		EtcdTLSFiles: cfg.EtcdTlsFiles(),
+
+		Namespace: cfg.StorageNamespace(),
+
+		HistorySize: cfg.SecretHistorySize,
 	}, encryptor)
 	if err != nil {
 		return nil, err
 	}
 
-	service := service.New(repo)
+	providers, err := newProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	service := service.New(repo, providers)
 
 	endpoints := controller.New(service)
 
@@ -37,3 +52,80 @@ func Init(ctx context.Context, cfg *config.Config, encryptor repository.Encrypto
 		service, endpoints,
 	}, nil
 }
+
+// newProviders registers the external secret providers enabled by the configuration
+func newProviders(cfg *config.Config) (*provider.Registry, error) {
+	registry := provider.NewRegistry()
+
+	options := provider.Options{Timeout: cfg.SecretProviderTimeout, CacheTTL: cfg.SecretProviderCacheTTL}
+	if options.Timeout == 0 {
+		options.Timeout = provider.TimeoutDefault
+	}
+	// a negative TTL disables the cache
+	if options.CacheTTL == 0 {
+		options.CacheTTL = provider.CacheTTLDefault
+	}
+
+	if len(cfg.SecretFileDirs) > 0 {
+		allowed, err := provider.ParseAccessRules(cfg.SecretFileDirs)
+		if err != nil {
+			return nil, err
+		}
+
+		files, err := provider.NewFileProvider(allowed)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(provider.FileScheme, files, options)
+	}
+
+	if len(cfg.SecretEnvAllowed) > 0 {
+		allowed, err := provider.ParseAccessRules(cfg.SecretEnvAllowed)
+		if err != nil {
+			return nil, err
+		}
+
+		env, err := provider.NewEnvProvider(allowed)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(provider.EnvScheme, env, options)
+	}
+
+	if bool(cfg.EnableK8sSecrets) {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := provider.ParseAccessRules(cfg.K8sSecretsAllowed)
+		if err != nil {
+			return nil, err
+		}
+
+		k8s, err := provider.NewKubernetesProvider(clientset, allowed)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(provider.KubernetesScheme, k8s, options)
+	}
+
+	if cfg.VaultAddr != "" {
+		allowed, err := provider.ParseAccessRules(cfg.VaultAllowedPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		vault, err := provider.NewVaultProvider(cfg.VaultAddr, cfg.VaultToken, cfg.VaultNamespace, allowed, nil)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(provider.VaultScheme, vault, options)
+	}
+
+	return registry, nil
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/raw-leak/configleam/internal/pkg/envorigin"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+const (
+	// AnyName matches every project or env of an access rule
+	AnyName = "*"
+
+	ruleSeparator    = "="
+	projectSeparator = "/"
+)
+
+// AccessRule allows the env of a project to reference the secrets of a provider matching the pattern,
+// so the credentials of Configleam can not be used to read the secrets meant for other projects or envs
+type AccessRule struct {
+	Project string
+	Env     string
+	Pattern string
+}
+
+// AccessRules are the rules of a provider, a reference being allowed when a rule of its project and env matches it
+type AccessRules []AccessRule
+
+// ParseAccessRules parses '[<project>/]<env>=<pattern>' rules, e.g. "billing/production=secret/data/billing".
+// Rules without a project are for the default project, and '*' matches every project or env.
+func ParseAccessRules(rules []string) (AccessRules, error) {
+	parsed := AccessRules{}
+
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		target, pattern, ok := strings.Cut(rule, ruleSeparator)
+		target, pattern = strings.TrimSpace(target), strings.TrimSpace(pattern)
+		if !ok || target == "" || pattern == "" {
+			return nil, fmt.Errorf("invalid access rule '%s', rules must be formatted as '[<project>/]<env>=<pattern>'", rule)
+		}
+
+		r := AccessRule{Project: project.Default, Env: target, Pattern: pattern}
+		if name, env, ok := strings.Cut(target, projectSeparator); ok {
+			if name != AnyName {
+				if err := project.Validate(name); err != nil {
+					return nil, fmt.Errorf("invalid access rule '%s': %v", rule, err)
+				}
+			}
+			r.Project, r.Env = name, env
+		}
+		if r.Env == "" {
+			return nil, fmt.Errorf("invalid access rule '%s', the env must not be empty", rule)
+		}
+
+		parsed = append(parsed, r)
+	}
+
+	return parsed, nil
+}
+
+// patterns returns the patterns the env of the project carried by the context is allowed to reference, a clone
+// being allowed the patterns of its original env
+func (rules AccessRules) patterns(ctx context.Context, env string) []string {
+	name := project.FromContext(ctx)
+	original := envorigin.Original(ctx, env)
+
+	patterns := []string{}
+	for _, r := range rules {
+		if (r.Project == AnyName || r.Project == name) && (r.Env == AnyName || r.Env == env || r.Env == original) {
+			patterns = append(patterns, r.Pattern)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+)
+
+// EnvScheme addresses the environment variables of Configleam, e.g. "{{ env.DB_PASS }}"
+const EnvScheme = "env"
+
+// EnvProvider resolves the environment variables allowed for the env, so the ones configuring Configleam itself
+// can not be read, nor the ones meant for other projects or envs
+type EnvProvider struct {
+	allowed AccessRules
+}
+
+// NewEnvProvider creates a provider reading the variables matching one of the patterns of the rules only, e.g.
+// "develop=DB_*", each env reading the variables of its own rules
+func NewEnvProvider(allowed AccessRules) (*EnvProvider, error) {
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("at least one allowed environment variable pattern must be provided")
+	}
+	for _, rule := range allowed {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowed environment variable pattern '%s': %v", rule.Pattern, err)
+		}
+	}
+
+	return &EnvProvider{allowed: allowed}, nil
+}
+
+func (p *EnvProvider) Resolve(ctx context.Context, env, ref string) (interface{}, error) {
+	if !p.isAllowed(ctx, env, ref) {
+		return nil, InvalidRefError{Scheme: EnvScheme, Ref: ref, Reason: "variable is not allowed for the env"}
+	}
+
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return nil, NotFoundError{Scheme: EnvScheme, Ref: ref}
+	}
+	return value, nil
+}
+
+// isAllowed reports whether the variable matches one of the patterns allowed for the env
+func (p *EnvProvider) isAllowed(ctx context.Context, env, name string) bool {
+	for _, pattern := range p.allowed.patterns(ctx, env) {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// FileScheme addresses files such as Docker and Kubernetes mounted secrets, e.g. "{{ file./run/secrets/db }}"
+	FileScheme = "file"
+
+	maxFileSize = 1 << 20
+)
+
+// FileProvider resolves the content of the files within the directories allowed for the env, without its trailing newlines
+type FileProvider struct {
+	allowed AccessRules
+}
+
+// NewFileProvider creates a provider reading the files within the directories of the rules only, each env
+// reading the files of its own rules
+func NewFileProvider(allowed AccessRules) (*FileProvider, error) {
+	p := &FileProvider{}
+
+	for _, rule := range allowed {
+		abs, err := filepath.Abs(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret files directory '%s': %v", rule.Pattern, err)
+		}
+
+		// the directory may be mounted later on, its symlinks are then resolved on every read
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		rule.Pattern = abs
+		p.allowed = append(p.allowed, rule)
+	}
+
+	if len(p.allowed) == 0 {
+		return nil, fmt.Errorf("at least one secret files directory must be provided")
+	}
+
+	return p, nil
+}
+
+func (p *FileProvider) Resolve(ctx context.Context, env, ref string) (interface{}, error) {
+	if !filepath.IsAbs(ref) {
+		return nil, InvalidRefError{Scheme: FileScheme, Ref: ref, Reason: "path must be absolute"}
+	}
+
+	path := filepath.Clean(ref)
+	if !p.isAllowed(ctx, env, path) {
+		return nil, InvalidRefError{Scheme: FileScheme, Ref: ref, Reason: "path is not within the directories allowed for the env"}
+	}
+
+	// a symlink must not lead out of the allowed directories
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return nil, NotFoundError{Scheme: FileScheme, Ref: ref}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error resolving secret file '%s': %v", ref, err)
+	}
+	if !p.isAllowed(ctx, env, resolved) {
+		return nil, InvalidRefError{Scheme: FileScheme, Ref: ref, Reason: "path is not within the directories allowed for the env"}
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secret file '%s': %v", ref, err)
+	}
+	if info.IsDir() {
+		return nil, InvalidRefError{Scheme: FileScheme, Ref: ref, Reason: "path is a directory"}
+	}
+	if info.Size() > maxFileSize {
+		return nil, InvalidRefError{Scheme: FileScheme, Ref: ref, Reason: fmt.Sprintf("file is larger than %d bytes", maxFileSize)}
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secret file '%s': %v", ref, err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// isAllowed reports whether the path is within one of the directories allowed for the env, the directories
+// themselves excluded
+func (p *FileProvider) isAllowed(ctx context.Context, env, path string) bool {
+	for _, dir := range p.allowed.patterns(ctx, env) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
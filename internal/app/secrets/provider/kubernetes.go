@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KubernetesScheme addresses the keys of Kubernetes Secrets, e.g. "{{ k8s.apps/db#password }}"
+const KubernetesScheme = "k8s"
+
+// KubernetesProvider resolves a '<namespace>/<name>#<key>' reference to the value of the key of the Secret,
+// or to all of its keys when no key is provided. Each env only reads the Secrets its access rules allow,
+// whatever the service account can read.
+type KubernetesProvider struct {
+	client  kubernetes.Interface
+	allowed AccessRules
+}
+
+// NewKubernetesProvider creates a provider reading the Secrets with the client. The patterns of the rules are
+// '<namespace>/<name>' Secrets, each part possibly being a pattern, e.g. "billing/*".
+func NewKubernetesProvider(client kubernetes.Interface, allowed AccessRules) (*KubernetesProvider, error) {
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("at least one allowed kubernetes secret must be provided")
+	}
+	for _, rule := range allowed {
+		namespace, name, ok := strings.Cut(rule.Pattern, "/")
+		if _, err := path.Match(rule.Pattern, ""); err != nil || !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("invalid allowed kubernetes secret '%s', it must be formatted as '<namespace>/<name>'", rule.Pattern)
+		}
+	}
+
+	return &KubernetesProvider{client: client, allowed: allowed}, nil
+}
+
+func (p *KubernetesProvider) Resolve(ctx context.Context, env, ref string) (interface{}, error) {
+	object, key, hasKey := strings.Cut(ref, "#")
+	namespace, name, ok := strings.Cut(object, "/")
+	if !ok || namespace == "" || name == "" || (hasKey && key == "") {
+		return nil, InvalidRefError{Scheme: KubernetesScheme, Ref: ref, Reason: "reference must be formatted as '<namespace>/<name>#<key>'"}
+	}
+
+	if !p.isAllowed(ctx, env, object) {
+		return nil, InvalidRefError{Scheme: KubernetesScheme, Ref: ref, Reason: "secret is not allowed for the env"}
+	}
+
+	secret, err := p.client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, NotFoundError{Scheme: KubernetesScheme, Ref: ref}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubernetes secret '%s': %w", object, err)
+	}
+
+	if !hasKey {
+		values := make(map[string]interface{}, len(secret.Data))
+		for k, v := range secret.Data {
+			values[k] = string(v)
+		}
+		return values, nil
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, NotFoundError{Scheme: KubernetesScheme, Ref: ref}
+	}
+	return string(value), nil
+}
+
+// isAllowed reports whether the '<namespace>/<name>' Secret matches one of the Secrets allowed for the env
+func (p *KubernetesProvider) isAllowed(ctx context.Context, env, object string) bool {
+	for _, pattern := range p.allowed.patterns(ctx, env) {
+		if ok, _ := path.Match(pattern, object); ok {
+			return true
+		}
+	}
+	return false
+}
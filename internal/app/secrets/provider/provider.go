@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+const (
+	// SecretScheme addresses the secrets stored by Configleam, e.g. "{{ secret.db.password }}"
+	SecretScheme = "secret"
+
+	TimeoutDefault  = 5 * time.Second
+	CacheTTLDefault = 30 * time.Second
+
+	// maxCacheEntries bounds the cache, as the references may come from requests
+	maxCacheEntries = 1024
+)
+
+// Provider resolves the references of the placeholders of a scheme, e.g. "/run/secrets/db" of "{{ file./run/secrets/db }}"
+type Provider interface {
+	Resolve(ctx context.Context, env, ref string) (interface{}, error)
+}
+
+// NotFoundError is returned when the referenced secret does not exist, the placeholder being resolved as empty
+type NotFoundError struct {
+	Scheme string
+	Ref    string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("secret '%s' not found by provider '%s'", e.Ref, e.Scheme)
+}
+
+// InvalidRefError is returned when the reference can not be resolved by the provider whatever the secret value is
+type InvalidRefError struct {
+	Scheme string
+	Ref    string
+	Reason string
+}
+
+func (e InvalidRefError) Error() string {
+	return fmt.Sprintf("invalid reference '%s' of provider '%s': %s", e.Ref, e.Scheme, e.Reason)
+}
+
+// Options sets how the values of a provider are resolved, zero values disabling the timeout and the cache
+type Options struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+type registered struct {
+	provider Provider
+	options  Options
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Registry resolves the placeholders through the provider of their scheme, bounding each resolution by the timeout
+// of the provider and caching its values for its cache TTL
+type Registry struct {
+	providers map[string]registered
+
+	mux   sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewRegistry creates a registry without any provider
+func NewRegistry() *Registry {
+	return &Registry{providers: map[string]registered{}, cache: map[string]cacheEntry{}}
+}
+
+// Register makes the provider resolve the placeholders of the scheme, replacing the one registered before
+func (r *Registry) Register(scheme string, provider Provider, options Options) {
+	r.providers[scheme] = registered{provider: provider, options: options}
+}
+
+// Has reports whether a provider resolves the placeholders of the scheme
+func (r *Registry) Has(scheme string) bool {
+	_, ok := r.providers[scheme]
+	return ok
+}
+
+// Schemes returns the schemes having a provider, sorted
+func (r *Registry) Schemes() []string {
+	schemes := make([]string, 0, len(r.providers))
+	for scheme := range r.providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Resolve returns the value of the reference from the provider of the scheme, of the env and project carried by the context
+func (r *Registry) Resolve(ctx context.Context, env, scheme, ref string) (interface{}, error) {
+	reg, ok := r.providers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no secret provider registered for '%s'", scheme)
+	}
+
+	cacheKey := scheme + "\x00" + project.FromContext(ctx) + "\x00" + env + "\x00" + ref
+	if reg.options.CacheTTL > 0 {
+		if value, ok := r.cached(cacheKey); ok {
+			return value, nil
+		}
+	}
+
+	resolveCtx := ctx
+	if reg.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		resolveCtx, cancel = context.WithTimeout(ctx, reg.options.Timeout)
+		defer cancel()
+	}
+
+	value, err := reg.provider.Resolve(resolveCtx, env, ref)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("secret provider '%s' timed out after %s resolving '%s'", scheme, reg.options.Timeout, ref)
+		}
+		return nil, err
+	}
+
+	if reg.options.CacheTTL > 0 {
+		r.store(cacheKey, value, reg.options.CacheTTL)
+	}
+	return copyValue(value), nil
+}
+
+func (r *Registry) cached(key string) (interface{}, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	entry, ok := r.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return copyValue(entry.value), true
+}
+
+func (r *Registry) store(key string, value interface{}, ttl time.Duration) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	now := time.Now()
+	if len(r.cache) >= maxCacheEntries {
+		for k, entry := range r.cache {
+			if now.After(entry.expires) {
+				delete(r.cache, k)
+			}
+		}
+		if len(r.cache) >= maxCacheEntries {
+			return
+		}
+	}
+
+	r.cache[key] = cacheEntry{value: copyValue(value), expires: now.Add(ttl)}
+}
+
+// copyValue returns a deep copy of the maps and arrays of the value, so cached values are not modified by their readers
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			c[key] = copyValue(nested)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, nested := range v {
+			c[i] = copyValue(nested)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,299 @@
+package provider_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/provider"
+	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/pkg/envorigin"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+type ProvidersSuite struct {
+	suite.Suite
+}
+
+func TestProvidersSuite(t *testing.T) {
+	suite.Run(t, new(ProvidersSuite))
+}
+
+func (suite *ProvidersSuite) TestFileProvider() {
+	ctx := context.Background()
+
+	dir := suite.T().TempDir()
+	suite.Require().NoError(os.WriteFile(filepath.Join(dir, "db"), []byte("s3cr3t\n"), 0o600))
+
+	outside := suite.T().TempDir()
+	suite.Require().NoError(os.WriteFile(filepath.Join(outside, "key"), []byte("private"), 0o600))
+	suite.Require().NoError(os.Symlink(filepath.Join(outside, "key"), filepath.Join(dir, "link")))
+
+	billing := suite.T().TempDir()
+	suite.Require().NoError(os.WriteFile(filepath.Join(billing, "db"), []byte("billing"), 0o600))
+
+	allowed, err := provider.ParseAccessRules([]string{"develop=" + dir, "billing/*=" + billing})
+	suite.Require().NoError(err)
+	files, err := provider.NewFileProvider(allowed)
+	suite.Require().NoError(err)
+
+	value, err := files.Resolve(ctx, "develop", filepath.Join(dir, "db"))
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+
+	_, err = files.Resolve(ctx, "develop", filepath.Join(dir, "missing"))
+	suite.ErrorAs(err, &provider.NotFoundError{})
+
+	for _, ref := range []string{
+		filepath.Join(outside, "key"),
+		filepath.Join(dir, "..", filepath.Base(outside), "key"),
+		filepath.Join(dir, "link"),
+		"db",
+		dir,
+	} {
+		_, err = files.Resolve(ctx, "develop", ref)
+		suite.ErrorAs(err, &provider.InvalidRefError{}, ref)
+	}
+
+	// files outside of the rules of the project and env are denied, even when they exist
+	_, err = files.Resolve(ctx, "production", filepath.Join(dir, "db"))
+	suite.ErrorContains(err, "path is not within the directories allowed for the env")
+	_, err = files.Resolve(ctx, "develop", filepath.Join(billing, "db"))
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+	_, err = files.Resolve(project.WithProject(ctx, "billing"), "develop", filepath.Join(dir, "db"))
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	value, err = files.Resolve(project.WithProject(ctx, "billing"), "production", filepath.Join(billing, "db"))
+	suite.Require().NoError(err)
+	suite.Equal("billing", value)
+
+	// a clone reads the files of its original env only
+	clone := envorigin.WithOriginal(ctx, "develop-feature", "develop")
+	value, err = files.Resolve(clone, "develop-feature", filepath.Join(dir, "db"))
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+	_, err = files.Resolve(envorigin.WithOriginal(ctx, "production-copy", "production"), "production-copy", filepath.Join(dir, "db"))
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	_, err = provider.NewFileProvider(nil)
+	suite.Error(err)
+}
+
+func (suite *ProvidersSuite) TestEnvProvider() {
+	ctx := context.Background()
+	suite.T().Setenv("DB_PASS", "s3cr3t")
+	suite.T().Setenv("CG_ENCRYPTION_KEY", "01234567890123456789012345678901")
+
+	suite.T().Setenv("BILLING_TOKEN", "t0k3n")
+
+	allowed, err := provider.ParseAccessRules([]string{"develop=DB_*", "billing/*=BILLING_*"})
+	suite.Require().NoError(err)
+	env, err := provider.NewEnvProvider(allowed)
+	suite.Require().NoError(err)
+
+	value, err := env.Resolve(ctx, "develop", "DB_PASS")
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+
+	_, err = env.Resolve(ctx, "develop", "DB_MISSING")
+	suite.ErrorAs(err, &provider.NotFoundError{})
+
+	_, err = env.Resolve(ctx, "develop", "CG_ENCRYPTION_KEY")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	// variables outside of the rules of the project and env are denied, even when they exist
+	_, err = env.Resolve(ctx, "production", "DB_PASS")
+	suite.ErrorContains(err, "variable is not allowed for the env")
+	_, err = env.Resolve(ctx, "develop", "BILLING_TOKEN")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+	_, err = env.Resolve(project.WithProject(ctx, "billing"), "develop", "DB_PASS")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	value, err = env.Resolve(project.WithProject(ctx, "billing"), "production", "BILLING_TOKEN")
+	suite.Require().NoError(err)
+	suite.Equal("t0k3n", value)
+
+	// a clone reads the variables of its original env only
+	value, err = env.Resolve(envorigin.WithOriginal(ctx, "develop-feature", "develop"), "develop-feature", "DB_PASS")
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+	_, err = env.Resolve(envorigin.WithOriginal(ctx, "develop-feature", "develop"), "develop-feature", "BILLING_TOKEN")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	_, err = provider.NewEnvProvider(nil)
+	suite.Error(err)
+	_, err = provider.NewEnvProvider(provider.AccessRules{{Env: "develop", Pattern: "DB_["}})
+	suite.Error(err)
+}
+
+func (suite *ProvidersSuite) TestKubernetesProvider() {
+	ctx := context.Background()
+
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t"), "user": []byte("admin")},
+	})
+	allowed, err := provider.ParseAccessRules([]string{"develop=apps/*", "develop=other/db", "billing/*=billing/*"})
+	suite.Require().NoError(err)
+	k8s, err := provider.NewKubernetesProvider(client, allowed)
+	suite.Require().NoError(err)
+
+	value, err := k8s.Resolve(ctx, "develop", "apps/db#password")
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+
+	value, err = k8s.Resolve(ctx, "develop", "apps/db")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]interface{}{"password": "s3cr3t", "user": "admin"}, value)
+
+	for _, ref := range []string{"apps/db#missing", "apps/missing#password", "other/db#password"} {
+		_, err = k8s.Resolve(ctx, "develop", ref)
+		suite.ErrorAs(err, &provider.NotFoundError{}, ref)
+	}
+
+	for _, ref := range []string{"db#password", "apps/#password", "apps/db#"} {
+		_, err = k8s.Resolve(ctx, "develop", ref)
+		suite.ErrorAs(err, &provider.InvalidRefError{}, ref)
+	}
+
+	// secrets outside of the rules of the project and env are denied, even when they exist
+	_, err = k8s.Resolve(ctx, "develop", "kube-system/db#password")
+	suite.ErrorContains(err, "secret is not allowed for the env")
+	_, err = k8s.Resolve(ctx, "production", "apps/db#password")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+	_, err = k8s.Resolve(project.WithProject(ctx, "billing"), "develop", "apps/db#password")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	// a clone is allowed the secrets of its original env
+	value, err = k8s.Resolve(envorigin.WithOriginal(ctx, "develop-feature", "develop"), "develop-feature", "apps/db#password")
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+
+	_, err = provider.NewKubernetesProvider(client, nil)
+	suite.Error(err)
+	_, err = provider.NewKubernetesProvider(client, provider.AccessRules{{Env: "develop", Pattern: "apps"}})
+	suite.Error(err)
+}
+
+func (suite *ProvidersSuite) TestVaultProvider() {
+	ctx := context.Background()
+
+	// stands in for a Vault server with a KV version 2 and a KV version 1 mount
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		suite.Equal("team-a", r.Header.Get("X-Vault-Namespace"))
+
+		switch r.URL.Path {
+		case "/v1/secret/data/db":
+			w.Write([]byte(`{"data":{"data":{"password":"s3cr3t","port":5432},"metadata":{"version":3}}}`))
+		case "/v1/kv/api":
+			w.Write([]byte(`{"data":{"token":"t0k3n"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	allowed, err := provider.ParseAccessRules([]string{"develop=secret/data", "*/*=kv/", "billing/production=secret/data/billing"})
+	suite.Require().NoError(err)
+	vault, err := provider.NewVaultProvider(server.URL, "root-token", "team-a", allowed, server.Client())
+	suite.Require().NoError(err)
+
+	value, err := vault.Resolve(ctx, "develop", "secret/data/db#password")
+	suite.Require().NoError(err)
+	suite.Equal("s3cr3t", value)
+
+	value, err = vault.Resolve(ctx, "develop", "secret/data/db")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]interface{}{"password": "s3cr3t", "port": float64(5432)}, value)
+
+	value, err = vault.Resolve(ctx, "develop", "kv/api#token")
+	suite.Require().NoError(err)
+	suite.Equal("t0k3n", value)
+
+	for _, ref := range []string{"secret/data/db#missing", "secret/data/missing#password"} {
+		_, err = vault.Resolve(ctx, "develop", ref)
+		suite.ErrorAs(err, &provider.NotFoundError{}, ref)
+	}
+
+	for _, ref := range []string{"#password", "secret/../sys/seal#x", "secret/data/db#"} {
+		_, err = vault.Resolve(ctx, "develop", ref)
+		suite.ErrorAs(err, &provider.InvalidRefError{}, ref)
+	}
+
+	// paths outside of the rules of the project and env are denied, whatever the token can read
+	for _, ref := range []string{"auth/token/lookup-self", "secret/database#password", "secret#password", "sys/seal-status"} {
+		_, err = vault.Resolve(ctx, "develop", ref)
+		suite.ErrorContains(err, "path is not allowed for the env", ref)
+	}
+	_, err = vault.Resolve(ctx, "production", "secret/data/db#password")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+	_, err = vault.Resolve(project.WithProject(ctx, "billing"), "production", "secret/data/db#password")
+	suite.ErrorAs(err, &provider.InvalidRefError{})
+
+	value, err = vault.Resolve(project.WithProject(ctx, "billing"), "production", "kv/api#token")
+	suite.Require().NoError(err)
+	suite.Equal("t0k3n", value)
+
+	denied, err := provider.NewVaultProvider(server.URL, "wrong-token", "team-a", allowed, server.Client())
+	suite.Require().NoError(err)
+	_, err = denied.Resolve(ctx, "develop", "secret/data/db#password")
+	suite.ErrorContains(err, "permission denied")
+
+	_, err = provider.NewVaultProvider("vault:8200", "", "", allowed, nil)
+	suite.Error(err)
+
+	_, err = provider.NewVaultProvider(server.URL, "root-token", "", nil, nil)
+	suite.Error(err)
+}
+
+func (suite *ProvidersSuite) TestParseAccessRules() {
+	rules, err := provider.ParseAccessRules([]string{"production=secret/data/prod", " billing/* = billing/* ", "", "*/@shared=secret/data/shared"})
+	suite.Require().NoError(err)
+	suite.Equal(provider.AccessRules{
+		{Project: "", Env: "production", Pattern: "secret/data/prod"},
+		{Project: "billing", Env: "*", Pattern: "billing/*"},
+		{Project: "*", Env: "@shared", Pattern: "secret/data/shared"},
+	}, rules)
+
+	for _, rule := range []string{"secret/data/prod", "production=", "=secret", "billing/=secret", "Billing!/production=secret"} {
+		_, err = provider.ParseAccessRules([]string{rule})
+		suite.Error(err, rule)
+	}
+}
+
+// secretGetter serves the secrets of the develop env
+type secretGetter map[string]interface{}
+
+func (g secretGetter) GetSecret(ctx context.Context, env, key string) (interface{}, error) {
+	value, ok := g[key]
+	if !ok || env != "develop" {
+		return nil, repository.SecretNotFoundError{Key: key}
+	}
+	return value, nil
+}
+
+func (suite *ProvidersSuite) TestRepositoryProvider() {
+	ctx := context.Background()
+	secrets := provider.NewRepositoryProvider(secretGetter{"token": "t0k3n"})
+
+	value, err := secrets.Resolve(ctx, "develop", "token")
+	suite.Require().NoError(err)
+	suite.Equal("t0k3n", value)
+
+	_, err = secrets.Resolve(ctx, "production", "token")
+	suite.ErrorAs(err, &provider.NotFoundError{})
+}
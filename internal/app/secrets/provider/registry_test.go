@@ -0,0 +1,130 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/provider"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+)
+
+// countingProvider returns a copy of its value, counting the resolutions and waiting for delay before answering
+type countingProvider struct {
+	mux   sync.Mutex
+	calls int
+	value interface{}
+	delay time.Duration
+}
+
+func (p *countingProvider) Resolve(ctx context.Context, env, ref string) (interface{}, error) {
+	p.mux.Lock()
+	p.calls++
+	p.mux.Unlock()
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if ref == "missing" {
+		return nil, provider.NotFoundError{Scheme: "test", Ref: ref}
+	}
+	return map[string]interface{}{"value": p.value, "ref": ref, "env": env}, nil
+}
+
+func (p *countingProvider) count() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.calls
+}
+
+type RegistrySuite struct {
+	suite.Suite
+}
+
+func TestRegistrySuite(t *testing.T) {
+	suite.Run(t, new(RegistrySuite))
+}
+
+func (suite *RegistrySuite) TestResolve() {
+	ctx := context.Background()
+
+	suite.Run("resolves through the provider of the scheme", func() {
+		registry := provider.NewRegistry()
+		registry.Register("test", &countingProvider{value: "a"}, provider.Options{})
+
+		suite.True(registry.Has("test"))
+		suite.False(registry.Has("other"))
+		suite.Equal([]string{"test"}, registry.Schemes())
+
+		value, err := registry.Resolve(ctx, "develop", "test", "db")
+		suite.Require().NoError(err)
+		suite.Equal(map[string]interface{}{"value": "a", "ref": "db", "env": "develop"}, value)
+
+		_, err = registry.Resolve(ctx, "develop", "other", "db")
+		suite.Error(err)
+
+		_, err = registry.Resolve(ctx, "develop", "test", "missing")
+		suite.ErrorAs(err, &provider.NotFoundError{})
+	})
+
+	suite.Run("caches the values for the TTL, per project, env and reference", func() {
+		p := &countingProvider{value: "a"}
+		registry := provider.NewRegistry()
+		registry.Register("test", p, provider.Options{CacheTTL: 50 * time.Millisecond})
+
+		for i := 0; i < 3; i++ {
+			_, err := registry.Resolve(ctx, "develop", "test", "db")
+			suite.Require().NoError(err)
+		}
+		suite.Equal(1, p.count())
+
+		_, err := registry.Resolve(ctx, "production", "test", "db")
+		suite.Require().NoError(err)
+		_, err = registry.Resolve(project.WithProject(ctx, "billing"), "develop", "test", "db")
+		suite.Require().NoError(err)
+		suite.Equal(3, p.count())
+
+		// missing secrets are not cached
+		for i := 0; i < 2; i++ {
+			_, err = registry.Resolve(ctx, "develop", "test", "missing")
+			suite.ErrorAs(err, &provider.NotFoundError{})
+		}
+		suite.Equal(5, p.count())
+
+		time.Sleep(60 * time.Millisecond)
+		_, err = registry.Resolve(ctx, "develop", "test", "db")
+		suite.Require().NoError(err)
+		suite.Equal(6, p.count())
+	})
+
+	suite.Run("cached values are not modified by their readers", func() {
+		registry := provider.NewRegistry()
+		registry.Register("test", &countingProvider{value: "a"}, provider.Options{CacheTTL: time.Minute})
+
+		value, err := registry.Resolve(ctx, "develop", "test", "db")
+		suite.Require().NoError(err)
+		value.(map[string]interface{})["value"] = "modified"
+
+		value, err = registry.Resolve(ctx, "develop", "test", "db")
+		suite.Require().NoError(err)
+		suite.Equal("a", value.(map[string]interface{})["value"])
+	})
+
+	suite.Run("bounds the resolution by the timeout", func() {
+		registry := provider.NewRegistry()
+		registry.Register("test", &countingProvider{value: "a", delay: time.Second}, provider.Options{Timeout: 20 * time.Millisecond})
+
+		start := time.Now()
+		_, err := registry.Resolve(ctx, "develop", "test", "db")
+		suite.ErrorContains(err, "timed out")
+		suite.False(errors.As(err, &provider.NotFoundError{}))
+		suite.Less(time.Since(start), 500*time.Millisecond)
+	})
+}
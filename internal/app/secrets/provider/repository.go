@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+)
+
+type SecretGetter interface {
+	GetSecret(ctx context.Context, env, key string) (interface{}, error)
+}
+
+// RepositoryProvider resolves the secrets stored by Configleam for the env, the reference being the key of a secret
+// or the dot path of a nested value, e.g. "database.password"
+type RepositoryProvider struct {
+	repository SecretGetter
+}
+
+func NewRepositoryProvider(repository SecretGetter) *RepositoryProvider {
+	return &RepositoryProvider{repository: repository}
+}
+
+func (p *RepositoryProvider) Resolve(ctx context.Context, env, ref string) (interface{}, error) {
+	value, err := p.repository.GetSecret(ctx, env, ref)
+	if err != nil {
+		if errors.As(err, &repository.SecretNotFoundError{}) {
+			return nil, NotFoundError{Scheme: SecretScheme, Ref: ref}
+		}
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// VaultScheme addresses the secrets of a Vault compatible HTTP API, e.g. "{{ vault.secret/data/db#password }}"
+const VaultScheme = "vault"
+
+// VaultProvider resolves a '<path>#<field>' reference to the field of the secret read from the API path,
+// or to all of its fields when no field is provided. Both KV version 1 and 2 responses are supported.
+// Each env only reads the paths its access rules allow, whatever the token can read.
+type VaultProvider struct {
+	addr      string
+	token     string
+	namespace string
+	allowed   AccessRules
+	client    *http.Client
+}
+
+// NewVaultProvider creates a provider reading the secrets from the server address with the token,
+// the namespace being sent when provided. The patterns of the rules are path prefixes, whose segments
+// may be patterns themselves, e.g. "secret/data/billing" or "secret/data/*/shared".
+func NewVaultProvider(addr, token, namespace string, allowed AccessRules, client *http.Client) (*VaultProvider, error) {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid vault address '%s'", addr)
+	}
+
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("at least one allowed vault path must be provided")
+	}
+	for _, rule := range allowed {
+		if _, err := path.Match(rule.Pattern, ""); err != nil || strings.Trim(rule.Pattern, "/") == "" {
+			return nil, fmt.Errorf("invalid allowed vault path '%s'", rule.Pattern)
+		}
+	}
+
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &VaultProvider{addr: strings.TrimRight(addr, "/"), token: token, namespace: namespace, allowed: allowed, client: client}, nil
+}
+
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+func (p *VaultProvider) Resolve(ctx context.Context, env, ref string) (interface{}, error) {
+	secretPath, field, hasField := strings.Cut(ref, "#")
+	secretPath = strings.Trim(secretPath, "/")
+	if secretPath == "" || (hasField && field == "") {
+		return nil, InvalidRefError{Scheme: VaultScheme, Ref: ref, Reason: "reference must be formatted as '<path>#<field>'"}
+	}
+
+	segments := strings.Split(secretPath, "/")
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, InvalidRefError{Scheme: VaultScheme, Ref: ref, Reason: "path segments must not be empty, '.' or '..'"}
+		}
+		escaped[i] = url.PathEscape(segment)
+	}
+
+	if !p.isAllowed(ctx, env, segments) {
+		return nil, InvalidRefError{Scheme: VaultScheme, Ref: ref, Reason: "path is not allowed for the env"}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+strings.Join(escaped, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Vault-Token", p.token)
+	}
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vault secret '%s': %w", secretPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, NotFoundError{Scheme: VaultScheme, Ref: ref}
+	}
+
+	var body vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxFileSize)).Decode(&body); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("error decoding vault secret '%s': %v", secretPath, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("vault answered %d reading secret '%s': %s", resp.StatusCode, secretPath, strings.Join(body.Errors, ", "))
+	}
+
+	data := body.Data
+	// KV version 2 nests the fields of the secret along with its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	if data == nil {
+		return nil, NotFoundError{Scheme: VaultScheme, Ref: ref}
+	}
+
+	if !hasField {
+		return data, nil
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return nil, NotFoundError{Scheme: VaultScheme, Ref: ref}
+	}
+	return value, nil
+}
+
+// isAllowed reports whether the path starts with the segments of one of the paths allowed for the env
+func (p *VaultProvider) isAllowed(ctx context.Context, env string, segments []string) bool {
+	for _, pattern := range p.allowed.patterns(ctx, env) {
+		prefix := strings.Split(strings.Trim(pattern, "/"), "/")
+		if len(prefix) > len(segments) {
+			continue
+		}
+
+		matches := true
+		for i, segment := range prefix {
+			if ok, _ := path.Match(segment, segments[i]); !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
@@ -9,3 +9,22 @@ type SecretNotFoundError struct {
 func (e SecretNotFoundError) Error() string {
 	return fmt.Sprintf("secret '%s' not found", e.Key)
 }
+
+// SecretConflictError is returned when a secret keeps being modified concurrently while being updated
+type SecretConflictError struct {
+	Key string
+}
+
+func (e SecretConflictError) Error() string {
+	return fmt.Sprintf("secret '%s' was modified concurrently", e.Key)
+}
+
+// SecretVersionNotFoundError is returned when a version of a secret is not kept in its history
+type SecretVersionNotFoundError struct {
+	Key     string
+	Version int64
+}
+
+func (e SecretVersionNotFoundError) Error() string {
+	return fmt.Sprintf("version %d of secret '%s' not found", e.Version, e.Key)
+}
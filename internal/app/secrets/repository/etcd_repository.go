@@ -10,19 +10,31 @@ import (
 	"strings"
 
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type EtcdRepository struct {
 	*etcd.Etcd
-	encryptor Encryptor
+	encryptor   Encryptor
+	root        namespace.Namespace
+	ns          namespace.Namespace
+	historySize int
 }
 
-func NewEtcdRepository(etcd *etcd.Etcd, encryptor Encryptor) *EtcdRepository {
-	return &EtcdRepository{etcd, encryptor}
+func NewEtcdRepository(etcd *etcd.Etcd, encryptor Encryptor, ns namespace.Namespace, historySize int) *EtcdRepository {
+	return &EtcdRepository{etcd, encryptor, ns, ns, historySize}
+}
+
+// scoped returns a copy of the repository building its keys under the project carried by the context
+func (r *EtcdRepository) scoped(ctx context.Context) *EtcdRepository {
+	return &EtcdRepository{r.Etcd, r.encryptor, r.root, r.root.Project(project.FromContext(ctx)), r.historySize}
 }
 
 func (r *EtcdRepository) GetSecret(ctx context.Context, env, fullKey string) (interface{}, error) {
+	r = r.scoped(ctx)
+
 	keyPath := strings.Split(fullKey, ".")
 	if len(keyPath) < 1 {
 		return nil, fmt.Errorf("the key '%s' is malformed", fullKey)
@@ -66,6 +78,8 @@ func (r *EtcdRepository) GetSecret(ctx context.Context, env, fullKey string) (in
 }
 
 func (r *EtcdRepository) UpsertSecrets(ctx context.Context, env string, secrets map[string]interface{}) error {
+	r = r.scoped(ctx)
+
 	if len(secrets) < 1 {
 		return errors.New("provided configuration is empty")
 	}
@@ -80,33 +94,31 @@ func (r *EtcdRepository) UpsertSecrets(ctx context.Context, env string, secrets
 		}
 	}
 
-	ops := make([]clientv3.Op, 0, len(secrets))
+	changes := make(map[string]change, len(secrets))
+	keys := make([]string, 0, len(secrets))
 	for key, value := range secrets {
-		data, err := json.Marshal(value)
-		if err != nil {
-			return err
-		}
-
-		encrypted, err := r.encryptor.Encrypt(ctx, data)
+		encrypted, err := r.encrypt(ctx, value)
 		if err != nil {
 			return err
 		}
 
-		ops = append(ops, clientv3.OpPut(r.GetSecretKey(env, key), string(encrypted)))
+		changes[key] = change{value: encrypted, action: ActionSet}
+		keys = append(keys, key)
 	}
 
-	txnResp, err := r.Client.Txn(ctx).Then(ops...).Commit()
+	err := r.transact(ctx, env, keys, func(map[string]secretState) (map[string]change, error) {
+		return changes, nil
+	})
 	if err != nil {
-		return fmt.Errorf("error executing etcd transaction on storing secrets config: %v", err)
-	}
-	if !txnResp.Succeeded {
-		return fmt.Errorf("etcd transaction failed")
+		return fmt.Errorf("error executing etcd transaction on storing secrets config: %w", err)
 	}
 
 	return nil
 }
 
 func (r *EtcdRepository) CloneSecrets(ctx context.Context, env, newEnv string) error {
+	r = r.scoped(ctx)
+
 	prefix := r.GetBaseKey(env)
 
 	oldSegment := fmt.Sprintf(":%s:", env)
@@ -163,20 +175,325 @@ MainLoop:
 
 // DeleteSecrets deletes all secrets keys for a specific environment.
 func (r *EtcdRepository) DeleteSecrets(ctx context.Context, env string) error {
+	r = r.scoped(ctx)
+
 	prefix := r.GetBaseKey(env)
 	_, err := r.Client.Delete(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return fmt.Errorf("error deleting secrets for '%s' environment: %v", env, err)
 	}
+
+	_, err = r.Client.Delete(ctx, r.GetSecretHistoryKey(env, ""), clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("error deleting secrets history for '%s' environment: %v", env, err)
+	}
+
+	_, err = r.Client.Delete(ctx, r.GetSecretInfoKey(env, ""), clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("error deleting secrets metadata for '%s' environment: %v", env, err)
+	}
+
+	_, err = r.Client.Delete(ctx, r.GetSecretReminderKey(env, ""), clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("error deleting secrets reminders for '%s' environment: %v", env, err)
+	}
+	return nil
+}
+
+// SetSecretInfo replaces the metadata of the secrets of the environment.
+func (r *EtcdRepository) SetSecretInfo(ctx context.Context, env string, info map[string]SecretInfo) error {
+	r = r.scoped(ctx)
+
+	ops := make([]clientv3.Op, 0, len(info))
+	for key, i := range info {
+		data, err := json.Marshal(i)
+		if err != nil {
+			return fmt.Errorf("error marshalling metadata of secret '%s': %v", key, err)
+		}
+		ops = append(ops, clientv3.OpPut(r.GetSecretInfoKey(env, key), string(data)))
+	}
+
+	_, err := r.Client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("error executing etcd transaction on storing secrets metadata: %v", err)
+	}
+
+	return nil
+}
+
+// ListSecretInfo returns the metadata of the existing secrets of the environment, of every environment when empty,
+// sorted by environment and key.
+func (r *EtcdRepository) ListSecretInfo(ctx context.Context, env string) ([]SecretInfoRecord, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.ns.Key(SecretInfoSegment)
+	from := prefix + ":"
+	if env != "" {
+		from = r.GetSecretInfoKey(env, "")
+	}
+
+	res, err := r.Client.Get(ctx, from, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("error listing secrets metadata: %v", err)
+	}
+
+	records := make([]SecretInfoRecord, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		env, key, ok := parseSecretKey(prefix, string(kv.Key))
+		if !ok {
+			continue
+		}
+
+		state, _, err := r.readState(ctx, env, key)
+		if err != nil {
+			return nil, err
+		}
+
+		reminder, err := r.readReminder(ctx, env, key)
+		if err != nil {
+			return nil, err
+		}
+
+		record, ok, err := infoRecord(env, key, kv.Value, reminder, state)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// SetSecretReminder records the last reminder sent for a secret.
+func (r *EtcdRepository) SetSecretReminder(ctx context.Context, env, key string, reminder SecretReminder) error {
+	r = r.scoped(ctx)
+
+	data, err := json.Marshal(reminder)
+	if err != nil {
+		return fmt.Errorf("error marshalling reminder of secret '%s': %v", key, err)
+	}
+
+	_, err = r.Client.Put(ctx, r.GetSecretReminderKey(env, key), string(data))
+	if err != nil {
+		return fmt.Errorf("error storing reminder of secret '%s': %v", key, err)
+	}
+
 	return nil
 }
 
+// readReminder reads the last reminder sent for a secret, nil when none was sent
+func (r *EtcdRepository) readReminder(ctx context.Context, env, key string) ([]byte, error) {
+	res, err := r.Client.Get(ctx, r.GetSecretReminderKey(env, key))
+	if err != nil {
+		return nil, fmt.Errorf("error reading reminder of secret '%s': %v", key, err)
+	}
+	if len(res.Kvs) < 1 {
+		return nil, nil
+	}
+	return res.Kvs[0].Value, nil
+}
+
+// ListSecrets describes every secret of the environment, sorted by key.
+func (r *EtcdRepository) ListSecrets(ctx context.Context, env string) ([]SecretMetadata, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.GetBaseKey(env) + ":"
+	res, err := r.Client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("error listing secrets of '%s' environment: %v", env, err)
+	}
+
+	secrets := make([]SecretMetadata, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		key := strings.TrimPrefix(string(kv.Key), prefix)
+
+		value, err := r.decrypt(ctx, key, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, secretMetadata(key, value))
+	}
+
+	return secrets, nil
+}
+
+// DeleteSecret deletes a secret of the environment, its history being kept so it can be rolled back.
+func (r *EtcdRepository) DeleteSecret(ctx context.Context, env, key string) error {
+	r = r.scoped(ctx)
+
+	return r.transact(ctx, env, []string{key}, func(states map[string]secretState) (map[string]change, error) {
+		if states[key].value == nil {
+			return nil, SecretNotFoundError{Key: key}
+		}
+		return map[string]change{key: {action: ActionDelete}}, nil
+	})
+}
+
+// UpdateSecret replaces a secret of the environment with the value returned by update, reading it
+// again and retrying when it is modified concurrently.
+func (r *EtcdRepository) UpdateSecret(ctx context.Context, env, key string, update UpdateFunc) error {
+	r = r.scoped(ctx)
+
+	return r.transact(ctx, env, []string{key}, func(states map[string]secretState) (map[string]change, error) {
+		return updateChange(ctx, r, key, states[key], update)
+	})
+}
+
+// ListSecretVersions describes the kept versions of a secret of the environment, newest first.
+func (r *EtcdRepository) ListSecretVersions(ctx context.Context, env, key string) ([]SecretVersion, error) {
+	r = r.scoped(ctx)
+
+	state, _, err := r.readState(ctx, env, key)
+	if err != nil {
+		return nil, err
+	}
+	if state.value == nil && len(state.history) < 1 {
+		return nil, SecretNotFoundError{Key: key}
+	}
+
+	return state.versions(), nil
+}
+
+// RollbackSecret brings a secret of the environment back to one of its kept versions, recorded as a new version.
+func (r *EtcdRepository) RollbackSecret(ctx context.Context, env, key string, version int64) error {
+	r = r.scoped(ctx)
+
+	return r.transact(ctx, env, []string{key}, func(states map[string]secretState) (map[string]change, error) {
+		c, err := states[key].rollback(key, version)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]change{key: c}, nil
+	})
+}
+
+// transact applies the changes returned by fn to the secrets and records them in their history. The transaction only
+// succeeds if the secrets and their history were not modified since read, otherwise they are read again and fn called again.
+func (r *EtcdRepository) transact(ctx context.Context, env string, keys []string, fn transactFunc) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		states := make(map[string]secretState, len(keys))
+		cmps := make([]clientv3.Cmp, 0, len(keys)*2)
+
+		for _, key := range keys {
+			state, revisions, err := r.readState(ctx, env, key)
+			if err != nil {
+				return err
+			}
+			states[key] = state
+
+			// a missing key has a modification revision of 0
+			cmps = append(cmps,
+				clientv3.Compare(clientv3.ModRevision(r.GetSecretKey(env, key)), "=", revisions[0]),
+				clientv3.Compare(clientv3.ModRevision(r.GetSecretHistoryKey(env, key)), "=", revisions[1]),
+			)
+		}
+
+		changes, err := fn(states)
+		if err != nil || len(changes) < 1 {
+			return err
+		}
+
+		ops := make([]clientv3.Op, 0, len(changes)*2)
+		for key, c := range changes {
+			history, err := json.Marshal(states[key].record(ctx, c, r.historySize))
+			if err != nil {
+				return fmt.Errorf("error marshalling history of secret '%s': %v", key, err)
+			}
+
+			if c.value == nil {
+				ops = append(ops, clientv3.OpDelete(r.GetSecretKey(env, key)))
+			} else {
+				ops = append(ops, clientv3.OpPut(r.GetSecretKey(env, key), string(c.value)))
+			}
+			ops = append(ops, clientv3.OpPut(r.GetSecretHistoryKey(env, key), string(history)))
+		}
+
+		txnResp, err := r.Client.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		if err != nil {
+			return fmt.Errorf("error executing etcd transaction on writing secrets: %v", err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+
+	return SecretConflictError{Key: strings.Join(keys, ", ")}
+}
+
+// readState reads the encrypted value of a secret along with its history, and the modification revisions of both
+func (r *EtcdRepository) readState(ctx context.Context, env, key string) (secretState, [2]int64, error) {
+	var state secretState
+	var revisions [2]int64
+
+	res, err := r.Client.Get(ctx, r.GetSecretKey(env, key))
+	if err != nil {
+		return state, revisions, fmt.Errorf("error reading secret '%s': %v", key, err)
+	}
+	if len(res.Kvs) > 0 {
+		state.value, revisions[0] = res.Kvs[0].Value, res.Kvs[0].ModRevision
+	}
+
+	res, err = r.Client.Get(ctx, r.GetSecretHistoryKey(env, key))
+	if err != nil {
+		return state, revisions, fmt.Errorf("error reading history of secret '%s': %v", key, err)
+	}
+	if len(res.Kvs) > 0 {
+		revisions[1] = res.Kvs[0].ModRevision
+		state.history, err = decodeHistory(key, res.Kvs[0].Value)
+		if err != nil {
+			return state, revisions, err
+		}
+	}
+
+	return state, revisions, nil
+}
+
+func (r *EtcdRepository) encrypt(ctx context.Context, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return r.encryptor.Encrypt(ctx, data)
+}
+
+func (r *EtcdRepository) decrypt(ctx context.Context, key string, encrypted []byte) (interface{}, error) {
+	decrypted, err := r.encryptor.Decrypt(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal(decrypted, &value)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling secret '%s': %v", key, err)
+	}
+	return value, nil
+}
+
 func (r *EtcdRepository) GetBaseKey(env string) string {
-	return fmt.Sprintf("%s:%s", SecretPrefix, env)
+	return fmt.Sprintf("%s:%s", r.prefix(), env)
 }
 
 func (r *EtcdRepository) GetSecretKey(env, key string) string {
-	return fmt.Sprintf("%s:%s:%s", SecretPrefix, env, key)
+	return fmt.Sprintf("%s:%s:%s", r.prefix(), env, key)
+}
+
+// GetSecretHistoryKey returns the key of the kept versions of a secret
+func (r *EtcdRepository) GetSecretHistoryKey(env, key string) string {
+	return r.ns.Key(SecretHistorySegment, env, key)
+}
+
+// GetSecretInfoKey returns the key of the metadata of a secret
+func (r *EtcdRepository) GetSecretInfoKey(env, key string) string {
+	return r.ns.Key(SecretInfoSegment, env, key)
+}
+
+// GetSecretReminderKey returns the key of the last reminder sent for a secret
+func (r *EtcdRepository) GetSecretReminderKey(env, key string) string {
+	return r.ns.Key(SecretReminderSegment, env, key)
 }
 
 func (r *EtcdRepository) getValueByNestedKeys(m map[string]interface{}, keys []string) (interface{}, bool) {
@@ -196,3 +513,208 @@ func (r *EtcdRepository) getValueByNestedKeys(m map[string]interface{}, keys []s
 
 	return val, true
 }
+
+// ReencryptSecrets wraps the data keys of every secret of the project, and of their kept versions, with the primary
+// master key, returning how many were rewrapped. A value modified meanwhile is left for the next run.
+func (r *EtcdRepository) ReencryptSecrets(ctx context.Context) (int, error) {
+	r = r.scoped(ctx)
+
+	rewrapped := 0
+	for _, prefix := range []string{r.prefix() + ":", r.ns.Key(SecretHistorySegment) + ":"} {
+		res, err := r.Client.Get(ctx, prefix, clientv3.WithPrefix())
+		if err != nil {
+			return rewrapped, fmt.Errorf("error fetching secrets to re-encrypt: %v", err)
+		}
+
+		for _, kv := range res.Kvs {
+			key := strings.TrimPrefix(string(kv.Key), prefix)
+
+			var value []byte
+			var changed bool
+			if prefix == r.prefix()+":" {
+				value, changed, err = r.encryptor.Rewrap(ctx, kv.Value)
+			} else {
+				value, changed, err = rewrapHistory(ctx, r.encryptor, key, kv.Value)
+			}
+			if err != nil {
+				return rewrapped, fmt.Errorf("error rewrapping secret '%s': %w", key, err)
+			}
+			if !changed {
+				continue
+			}
+
+			txnResp, err := r.Client.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+				Then(clientv3.OpPut(string(kv.Key), string(value))).
+				Commit()
+			if err != nil {
+				return rewrapped, fmt.Errorf("error executing etcd transaction on re-encrypting secret '%s': %v", key, err)
+			}
+			if txnResp.Succeeded {
+				rewrapped++
+			}
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// ExportSecrets retrieves every stored secret of all environments without decrypting them.
+func (r *EtcdRepository) ExportSecrets(ctx context.Context) ([]SecretRecord, error) {
+	r = r.scoped(ctx)
+
+	res, err := r.Client.Get(ctx, r.prefix()+":", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error on fetching all secrets: %w", err)
+	}
+
+	records := make([]SecretRecord, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		env, secretKey, ok := parseSecretKey(r.prefix(), string(kv.Key))
+		if !ok {
+			continue
+		}
+
+		records = append(records, SecretRecord{Env: env, Key: secretKey, Value: kv.Value})
+	}
+
+	return records, nil
+}
+
+// ImportSecrets stores the provided already encrypted secrets, overwriting the existing ones with the same key.
+func (r *EtcdRepository) ImportSecrets(ctx context.Context, secrets []SecretRecord) error {
+	r = r.scoped(ctx)
+
+	ops := make([]clientv3.Op, 0, len(secrets))
+	for _, secret := range secrets {
+		ops = append(ops, clientv3.OpPut(r.GetSecretKey(secret.Env, secret.Key), string(secret.Value)))
+	}
+
+	if len(ops) < 1 {
+		return nil
+	}
+
+	_, err := r.Client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("error executing etcd transaction on importing secrets: %v", err)
+	}
+
+	return nil
+}
+
+// ExportSecretHistory retrieves the kept versions of every secret of all environments, deleted secrets included,
+// without decrypting them.
+func (r *EtcdRepository) ExportSecretHistory(ctx context.Context) ([]SecretHistoryRecord, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.ns.Key(SecretHistorySegment)
+	res, err := r.Client.Get(ctx, prefix+":", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error on fetching all secret history: %w", err)
+	}
+
+	records := make([]SecretHistoryRecord, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		env, secretKey, ok := parseSecretKey(prefix, string(kv.Key))
+		if !ok {
+			continue
+		}
+
+		records = append(records, SecretHistoryRecord{Env: env, Key: secretKey, History: kv.Value})
+	}
+
+	return records, nil
+}
+
+// ImportSecretHistory stores the provided kept versions, overwriting the history of the secrets with the same key.
+func (r *EtcdRepository) ImportSecretHistory(ctx context.Context, history []SecretHistoryRecord) error {
+	r = r.scoped(ctx)
+
+	ops := make([]clientv3.Op, 0, len(history))
+	for _, record := range history {
+		if _, err := decodeHistory(record.Key, record.History); err != nil {
+			return err
+		}
+		ops = append(ops, clientv3.OpPut(r.GetSecretHistoryKey(record.Env, record.Key), string(record.History)))
+	}
+
+	if len(ops) < 1 {
+		return nil
+	}
+
+	_, err := r.Client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("error executing etcd transaction on importing secret history: %v", err)
+	}
+
+	return nil
+}
+
+// ExportSecretInfo retrieves the metadata of every secret of all environments, deleted secrets included,
+// along with their last reminder.
+func (r *EtcdRepository) ExportSecretInfo(ctx context.Context) ([]SecretInfoEntry, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.ns.Key(SecretInfoSegment)
+	res, err := r.Client.Get(ctx, prefix+":", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error on fetching all secret metadata: %w", err)
+	}
+
+	entries := make([]SecretInfoEntry, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		env, secretKey, ok := parseSecretKey(prefix, string(kv.Key))
+		if !ok {
+			continue
+		}
+
+		data, err := r.readReminder(ctx, env, secretKey)
+		if err != nil {
+			return nil, err
+		}
+		reminded, err := decodeReminder(secretKey, data)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, SecretInfoEntry{Env: env, Key: secretKey, Info: kv.Value, Reminded: reminded})
+	}
+
+	return entries, nil
+}
+
+// ImportSecretInfo stores the provided metadata and reminders, overwriting the ones of the secrets with the same key.
+func (r *EtcdRepository) ImportSecretInfo(ctx context.Context, info []SecretInfoEntry) error {
+	r = r.scoped(ctx)
+
+	ops := make([]clientv3.Op, 0, len(info))
+	for _, entry := range info {
+		if err := validateInfoEntry(entry); err != nil {
+			return err
+		}
+		ops = append(ops, clientv3.OpPut(r.GetSecretInfoKey(entry.Env, entry.Key), string(entry.Info)))
+
+		if entry.Reminded != nil {
+			data, err := json.Marshal(entry.Reminded)
+			if err != nil {
+				return fmt.Errorf("error marshalling reminder of secret '%s': %v", entry.Key, err)
+			}
+			ops = append(ops, clientv3.OpPut(r.GetSecretReminderKey(entry.Env, entry.Key), string(data)))
+		}
+	}
+
+	if len(ops) < 1 {
+		return nil
+	}
+
+	_, err := r.Client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("error executing etcd transaction on importing secret metadata: %v", err)
+	}
+
+	return nil
+}
+
+func (r *EtcdRepository) prefix() string {
+	return r.ns.Key(SecretSegment)
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+)
+
+// HistorySizeDefault is how many versions of each secret are kept when no retention is configured
+const HistorySizeDefault = 10
+
+// Actions recorded in the history of a secret
+const (
+	ActionSet      = "set"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+)
+
+// SecretVersion describes a kept version of a secret, without its value
+type SecretVersion struct {
+	Version   int64     `json:"version"`
+	Action    string    `json:"action"`
+	ChangedBy string    `json:"changedBy"`
+	ChangedAt time.Time `json:"changedAt"`
+	// Deleted is set when the secret did not exist in this version
+	Deleted bool `json:"deleted,omitempty"`
+	// Restores is the version brought back by a rollback
+	Restores int64 `json:"restores,omitempty"`
+}
+
+// SecretHistoryRecord represents the kept versions of a single secret as they are stored, their values encrypted,
+// so the history of the secrets is exported along with them
+type SecretHistoryRecord struct {
+	Env     string          `json:"env"`
+	Key     string          `json:"key"`
+	History json.RawMessage `json:"history"`
+}
+
+// versionRecord is a stored version of a secret, its value kept encrypted as the secret itself
+type versionRecord struct {
+	SecretVersion
+	Value []byte `json:"value,omitempty"`
+}
+
+// secretState is the stored value of a secret, encrypted and nil when it does not exist, along with its kept versions, newest first
+type secretState struct {
+	value   []byte
+	history []versionRecord
+}
+
+// change is the new encrypted value of a secret, nil deleting it, and how it is recorded in its history
+type change struct {
+	value    []byte
+	action   string
+	restores int64
+}
+
+// transactFunc returns the changes to apply from the current state of the secrets, it may be called again
+// when the secrets are modified concurrently
+type transactFunc func(states map[string]secretState) (map[string]change, error)
+
+// record returns the history of the secret with the change as its newest version, keeping the last 'keep' versions
+func (s secretState) record(ctx context.Context, c change, keep int) []versionRecord {
+	var version int64 = 1
+	if len(s.history) > 0 {
+		version = s.history[0].Version + 1
+	}
+
+	record := versionRecord{
+		SecretVersion: SecretVersion{
+			Version:   version,
+			Action:    c.action,
+			ChangedBy: auth.Actor(ctx),
+			ChangedAt: time.Now().UTC(),
+			Deleted:   c.value == nil,
+			Restores:  c.restores,
+		},
+		Value: c.value,
+	}
+
+	history := append([]versionRecord{record}, s.history...)
+	if keep > 0 && len(history) > keep {
+		history = history[:keep]
+	}
+	return history
+}
+
+// versions describes the kept versions of the secret, newest first
+func (s secretState) versions() []SecretVersion {
+	versions := make([]SecretVersion, 0, len(s.history))
+	for _, record := range s.history {
+		versions = append(versions, record.SecretVersion)
+	}
+	return versions
+}
+
+// rollback returns the change bringing the secret back to a kept version
+func (s secretState) rollback(key string, version int64) (change, error) {
+	for _, record := range s.history {
+		if record.Version == version {
+			return change{value: record.Value, action: ActionRollback, restores: version}, nil
+		}
+	}
+	return change{}, SecretVersionNotFoundError{Key: key, Version: version}
+}
+
+// valueCodec encrypts and decrypts the values of the secrets
+type valueCodec interface {
+	encrypt(ctx context.Context, value interface{}) ([]byte, error)
+	decrypt(ctx context.Context, key string, encrypted []byte) (interface{}, error)
+}
+
+// updateChange returns the change applying update to the current value of a secret
+func updateChange(ctx context.Context, codec valueCodec, key string, state secretState, update UpdateFunc) (map[string]change, error) {
+	var current interface{}
+	exists := state.value != nil
+	if exists {
+		var err error
+		current, err = codec.decrypt(ctx, key, state.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := update(current, exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		if !exists {
+			return nil, nil
+		}
+		return map[string]change{key: {action: ActionDelete}}, nil
+	}
+
+	encrypted, err := codec.encrypt(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]change{key: {value: encrypted, action: ActionSet}}, nil
+}
+
+// rewrapHistory wraps the data keys of the kept versions with the primary master key, reporting whether any changed
+func rewrapHistory(ctx context.Context, encryptor Encryptor, key string, data []byte) ([]byte, bool, error) {
+	history, err := decodeHistory(key, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrapped := false
+	for i, record := range history {
+		if record.Value == nil {
+			continue
+		}
+
+		value, changed, err := encryptor.Rewrap(ctx, record.Value)
+		if err != nil {
+			return nil, false, fmt.Errorf("error rewrapping version %d of secret '%s': %v", record.Version, key, err)
+		}
+		history[i].Value = value
+		rewrapped = rewrapped || changed
+	}
+
+	if !rewrapped {
+		return data, false, nil
+	}
+
+	data, err = json.Marshal(history)
+	if err != nil {
+		return nil, false, fmt.Errorf("error marshalling history of secret '%s': %v", key, err)
+	}
+	return data, true, nil
+}
+
+func decodeHistory(key string, data []byte) ([]versionRecord, error) {
+	if len(data) < 1 {
+		return nil, nil
+	}
+
+	var history []versionRecord
+	err := json.Unmarshal(data, &history)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling history of secret '%s': %v", key, err)
+	}
+	return history, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SecretInfo is the metadata of a secret, given when it is upserted and kept in plain text along with it
+type SecretInfo struct {
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	// ExpiresAt is when the secret stops being valid, e.g. the expiry date of an API token
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// RotationInterval is how often the value of the secret has to change
+	RotationInterval Duration `json:"rotationInterval,omitempty"`
+}
+
+// SecretInfoRecord is the metadata of a stored secret along with when its value last changed
+type SecretInfoRecord struct {
+	Env string `json:"env"`
+	Key string `json:"key"`
+	SecretInfo
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Reminded is the last reminder sent for the secret, if any
+	Reminded *SecretReminder `json:"reminded,omitempty"`
+}
+
+// SecretReminder is a reminder sent for a secret, which is not sent again while the secret keeps the same status
+// and due time
+type SecretReminder struct {
+	Status string    `json:"status"`
+	DueAt  time.Time `json:"dueAt"`
+}
+
+// SecretInfoEntry is the stored metadata of a secret, deleted ones included, along with its last reminder
+type SecretInfoEntry struct {
+	Env      string          `json:"env"`
+	Key      string          `json:"key"`
+	Info     json.RawMessage `json:"info"`
+	Reminded *SecretReminder `json:"reminded,omitempty"`
+}
+
+// Duration is a time.Duration written in JSON as a Go duration string, e.g. "720h"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"720h\": %v", err)
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// infoRecord returns the record of the metadata of a secret, when it still exists, along with its last reminder
+func infoRecord(env, key string, data, reminder []byte, state secretState) (SecretInfoRecord, bool, error) {
+	if state.value == nil {
+		return SecretInfoRecord{}, false, nil
+	}
+
+	record := SecretInfoRecord{Env: env, Key: key}
+	if err := json.Unmarshal(data, &record.SecretInfo); err != nil {
+		return SecretInfoRecord{}, false, fmt.Errorf("error unmarshalling metadata of secret '%s': %v", key, err)
+	}
+	if len(state.history) > 0 {
+		record.UpdatedAt = state.history[0].ChangedAt
+	}
+
+	reminded, err := decodeReminder(key, reminder)
+	if err != nil {
+		return SecretInfoRecord{}, false, err
+	}
+	record.Reminded = reminded
+
+	return record, true, nil
+}
+
+// decodeReminder decodes the last reminder of a secret, nil when none was sent
+func decodeReminder(key string, data []byte) (*SecretReminder, error) {
+	if len(data) < 1 {
+		return nil, nil
+	}
+
+	reminder := &SecretReminder{}
+	if err := json.Unmarshal(data, reminder); err != nil {
+		return nil, fmt.Errorf("error unmarshalling reminder of secret '%s': %v", key, err)
+	}
+	return reminder, nil
+}
+
+// validateInfoEntry checks the metadata of an imported secret
+func validateInfoEntry(entry SecretInfoEntry) error {
+	var info SecretInfo
+	if err := json.Unmarshal(entry.Info, &info); err != nil {
+		return fmt.Errorf("invalid metadata of secret '%s': %v", entry.Key, err)
+	}
+	return nil
+}
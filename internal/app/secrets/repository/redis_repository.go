@@ -7,22 +7,35 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisRepository struct {
 	*rds.Redis
-	encryptor Encryptor
+	encryptor   Encryptor
+	root        namespace.Namespace
+	ns          namespace.Namespace
+	historySize int
 }
 
-func NewRedisRepository(redis *rds.Redis, encryptor Encryptor) *RedisRepository {
-	return &RedisRepository{redis, encryptor}
+func NewRedisRepository(redis *rds.Redis, encryptor Encryptor, ns namespace.Namespace, historySize int) *RedisRepository {
+	return &RedisRepository{redis, encryptor, ns, ns, historySize}
+}
+
+// scoped returns a copy of the repository building its keys under the project carried by the context
+func (r *RedisRepository) scoped(ctx context.Context) *RedisRepository {
+	return &RedisRepository{r.Redis, r.encryptor, r.root, r.root.Project(project.FromContext(ctx)), r.historySize}
 }
 
 func (r *RedisRepository) GetSecret(ctx context.Context, env, fullKey string) (interface{}, error) {
+	r = r.scoped(ctx)
+
 	keyPath := strings.Split(fullKey, ".")
 	if len(keyPath) < 1 {
 		return nil, fmt.Errorf("the key '%s' is malformed", fullKey)
@@ -67,6 +80,8 @@ func (r *RedisRepository) GetSecret(ctx context.Context, env, fullKey string) (i
 }
 
 func (r *RedisRepository) UpsertSecrets(ctx context.Context, env string, secrets map[string]interface{}) error {
+	r = r.scoped(ctx)
+
 	if len(secrets) < 1 {
 		return errors.New("provided configuration is empty")
 	}
@@ -81,35 +96,45 @@ func (r *RedisRepository) UpsertSecrets(ctx context.Context, env string, secrets
 		}
 	}
 
-	pipeline := r.Client.TxPipeline()
-
+	changes := make(map[string]change, len(secrets))
+	keys := make([]string, 0, len(secrets))
 	for key, value := range secrets {
-		data, err := json.Marshal(value)
+		encrypted, err := r.encrypt(ctx, value)
 		if err != nil {
 			return err
 		}
 
-		encrypted, err := r.encryptor.Encrypt(ctx, data)
-		if err != nil {
-			return err
-		}
-
-		err = pipeline.Set(ctx, r.GetSecretKey(env, key), encrypted, 0).Err()
-		if err != nil {
-			return err
-		}
+		changes[key] = change{value: encrypted, action: ActionSet}
+		keys = append(keys, key)
 	}
 
-	_, err := pipeline.Exec(ctx)
+	err := r.transact(ctx, env, keys, func(map[string]secretState) (map[string]change, error) {
+		return changes, nil
+	})
 	if err != nil {
-		return fmt.Errorf("error executing Redis transaction on storing secrets config: %v", err)
+		return fmt.Errorf("error executing Redis transaction on storing secrets config: %w", err)
 	}
 
 	return nil
 }
 
 func (r *RedisRepository) GetSecretKey(env, key string) string {
-	return fmt.Sprintf("%s:%s:%s", SecretPrefix, env, key)
+	return fmt.Sprintf("%s:%s:%s", r.prefix(), env, key)
+}
+
+// GetSecretHistoryKey returns the key of the kept versions of a secret
+func (r *RedisRepository) GetSecretHistoryKey(env, key string) string {
+	return r.ns.Key(SecretHistorySegment, env, key)
+}
+
+// GetSecretInfoKey returns the key of the metadata of a secret
+func (r *RedisRepository) GetSecretInfoKey(env, key string) string {
+	return r.ns.Key(SecretInfoSegment, env, key)
+}
+
+// GetSecretReminderKey returns the key of the last reminder sent for a secret
+func (r *RedisRepository) GetSecretReminderKey(env, key string) string {
+	return r.ns.Key(SecretReminderSegment, env, key)
 }
 
 func (r *RedisRepository) getValueByNestedKeys(m map[string]interface{}, keys []string) (interface{}, bool) {
@@ -131,6 +156,8 @@ func (r *RedisRepository) getValueByNestedKeys(m map[string]interface{}, keys []
 }
 
 func (r *RedisRepository) CloneSecrets(ctx context.Context, cloneEnv, newEnv string) error {
+	r = r.scoped(ctx)
+
 	matchPattern := r.GetCloneSecretsPatternKey(cloneEnv)
 	oldSegment := fmt.Sprintf(":%s:", cloneEnv)
 	newSegment := fmt.Sprintf(":%s:", newEnv)
@@ -172,6 +199,8 @@ func (r *RedisRepository) CloneSecrets(ctx context.Context, cloneEnv, newEnv str
 }
 
 func (r *RedisRepository) DeleteSecrets(ctx context.Context, env string) error {
+	r = r.scoped(ctx)
+
 	luaScript := `
         local keys = redis.call('keys', ARGV[1])
         for i=1,#keys do
@@ -187,13 +216,563 @@ func (r *RedisRepository) DeleteSecrets(ctx context.Context, env string) error {
 	}
 
 	log.Printf("Deleted %d secret keys matching the pattern '%s'", result, keyPattern)
+
+	historyPattern := r.GetSecretHistoryKey(env, "*")
+	result, err = r.Client.Eval(ctx, luaScript, []string{}, historyPattern).Result()
+	if err != nil {
+		return fmt.Errorf("error executing Lua script for secret history deletion: %v", err)
+	}
+
+	log.Printf("Deleted %d secret history keys matching the pattern '%s'", result, historyPattern)
+
+	infoPattern := r.GetSecretInfoKey(env, "*")
+	result, err = r.Client.Eval(ctx, luaScript, []string{}, infoPattern).Result()
+	if err != nil {
+		return fmt.Errorf("error executing Lua script for secret metadata deletion: %v", err)
+	}
+
+	log.Printf("Deleted %d secret metadata keys matching the pattern '%s'", result, infoPattern)
+
+	reminderPattern := r.GetSecretReminderKey(env, "*")
+	result, err = r.Client.Eval(ctx, luaScript, []string{}, reminderPattern).Result()
+	if err != nil {
+		return fmt.Errorf("error executing Lua script for secret reminder deletion: %v", err)
+	}
+
+	log.Printf("Deleted %d secret reminder keys matching the pattern '%s'", result, reminderPattern)
+	return nil
+}
+
+// SetSecretInfo replaces the metadata of the secrets of the environment.
+func (r *RedisRepository) SetSecretInfo(ctx context.Context, env string, info map[string]SecretInfo) error {
+	r = r.scoped(ctx)
+
+	pipeline := r.Client.TxPipeline()
+	for key, i := range info {
+		data, err := json.Marshal(i)
+		if err != nil {
+			return fmt.Errorf("error marshalling metadata of secret '%s': %v", key, err)
+		}
+		pipeline.Set(ctx, r.GetSecretInfoKey(env, key), data, 0)
+	}
+
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on storing secrets metadata: %v", err)
+	}
+
+	return nil
+}
+
+// ListSecretInfo returns the metadata of the existing secrets of the environment, of every environment when empty,
+// sorted by environment and key.
+func (r *RedisRepository) ListSecretInfo(ctx context.Context, env string) ([]SecretInfoRecord, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.ns.Key(SecretInfoSegment)
+	pattern := prefix + ":*"
+	if env != "" {
+		pattern = r.GetSecretInfoKey(env, "*")
+	}
+
+	keys := []string{}
+	iter := r.Client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error listing secrets metadata: %v", err)
+	}
+	sort.Strings(keys)
+
+	records := make([]SecretInfoRecord, 0, len(keys))
+	for _, fullKey := range keys {
+		env, key, ok := parseSecretKey(prefix, fullKey)
+		if !ok {
+			continue
+		}
+
+		data, err := r.Client.Get(ctx, fullKey).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading metadata of secret '%s': %v", key, err)
+		}
+
+		state, err := r.readState(ctx, r.Client, env, key)
+		if err != nil {
+			return nil, err
+		}
+
+		reminder, err := r.Client.Get(ctx, r.GetSecretReminderKey(env, key)).Bytes()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading reminder of secret '%s': %v", key, err)
+		}
+
+		record, ok, err := infoRecord(env, key, data, reminder, state)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// SetSecretReminder records the last reminder sent for a secret.
+func (r *RedisRepository) SetSecretReminder(ctx context.Context, env, key string, reminder SecretReminder) error {
+	r = r.scoped(ctx)
+
+	data, err := json.Marshal(reminder)
+	if err != nil {
+		return fmt.Errorf("error marshalling reminder of secret '%s': %v", key, err)
+	}
+
+	err = r.Client.Set(ctx, r.GetSecretReminderKey(env, key), data, 0).Err()
+	if err != nil {
+		return fmt.Errorf("error storing reminder of secret '%s': %v", key, err)
+	}
+
 	return nil
 }
 
+// ListSecrets describes every secret of the environment, sorted by key.
+func (r *RedisRepository) ListSecrets(ctx context.Context, env string) ([]SecretMetadata, error) {
+	r = r.scoped(ctx)
+
+	prefix := fmt.Sprintf("%s:%s:", r.prefix(), env)
+
+	keys := []string{}
+	iter := r.Client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error listing secrets of '%s' environment: %v", env, err)
+	}
+	sort.Strings(keys)
+
+	secrets := make([]SecretMetadata, 0, len(keys))
+	for _, fullKey := range keys {
+		key := strings.TrimPrefix(fullKey, prefix)
+
+		encrypted, err := r.Client.Get(ctx, fullKey).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting secret '%s': %v", key, err)
+		}
+
+		value, err := r.decrypt(ctx, key, encrypted)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, secretMetadata(key, value))
+	}
+
+	return secrets, nil
+}
+
+// DeleteSecret deletes a secret of the environment, its history being kept so it can be rolled back.
+func (r *RedisRepository) DeleteSecret(ctx context.Context, env, key string) error {
+	r = r.scoped(ctx)
+
+	return r.transact(ctx, env, []string{key}, func(states map[string]secretState) (map[string]change, error) {
+		if states[key].value == nil {
+			return nil, SecretNotFoundError{Key: key}
+		}
+		return map[string]change{key: {action: ActionDelete}}, nil
+	})
+}
+
+// UpdateSecret replaces a secret of the environment with the value returned by update, reading it
+// again and retrying when it is modified concurrently.
+func (r *RedisRepository) UpdateSecret(ctx context.Context, env, key string, update UpdateFunc) error {
+	r = r.scoped(ctx)
+
+	return r.transact(ctx, env, []string{key}, func(states map[string]secretState) (map[string]change, error) {
+		return updateChange(ctx, r, key, states[key], update)
+	})
+}
+
+// ListSecretVersions describes the kept versions of a secret of the environment, newest first.
+func (r *RedisRepository) ListSecretVersions(ctx context.Context, env, key string) ([]SecretVersion, error) {
+	r = r.scoped(ctx)
+
+	state, err := r.readState(ctx, r.Client, env, key)
+	if err != nil {
+		return nil, err
+	}
+	if state.value == nil && len(state.history) < 1 {
+		return nil, SecretNotFoundError{Key: key}
+	}
+
+	return state.versions(), nil
+}
+
+// RollbackSecret brings a secret of the environment back to one of its kept versions, recorded as a new version.
+func (r *RedisRepository) RollbackSecret(ctx context.Context, env, key string, version int64) error {
+	r = r.scoped(ctx)
+
+	return r.transact(ctx, env, []string{key}, func(states map[string]secretState) (map[string]change, error) {
+		c, err := states[key].rollback(key, version)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]change{key: c}, nil
+	})
+}
+
+// transact applies the changes returned by fn to the secrets and records them in their history. The secrets and their
+// history are watched, so the changes are computed again from their new state when they are modified concurrently.
+func (r *RedisRepository) transact(ctx context.Context, env string, keys []string, fn transactFunc) error {
+	watched := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		watched = append(watched, r.GetSecretKey(env, key), r.GetSecretHistoryKey(env, key))
+	}
+
+	txf := func(tx *redis.Tx) error {
+		states := make(map[string]secretState, len(keys))
+		for _, key := range keys {
+			state, err := r.readState(ctx, tx, env, key)
+			if err != nil {
+				return err
+			}
+			states[key] = state
+		}
+
+		changes, err := fn(states)
+		if err != nil || len(changes) < 1 {
+			return err
+		}
+
+		histories := make(map[string][]byte, len(changes))
+		for key, c := range changes {
+			history, err := json.Marshal(states[key].record(ctx, c, r.historySize))
+			if err != nil {
+				return fmt.Errorf("error marshalling history of secret '%s': %v", key, err)
+			}
+			histories[key] = history
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for key, c := range changes {
+				if c.value == nil {
+					pipe.Del(ctx, r.GetSecretKey(env, key))
+				} else {
+					pipe.Set(ctx, r.GetSecretKey(env, key), c.value, 0)
+				}
+				pipe.Set(ctx, r.GetSecretHistoryKey(env, key), histories[key], 0)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := r.Client.Watch(ctx, txf, watched...)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return err
+	}
+
+	return SecretConflictError{Key: strings.Join(keys, ", ")}
+}
+
+// readState reads the encrypted value of a secret along with its history
+func (r *RedisRepository) readState(ctx context.Context, cmd redis.StringCmdable, env, key string) (secretState, error) {
+	value, err := cmd.Get(ctx, r.GetSecretKey(env, key)).Bytes()
+	if err != nil && err != redis.Nil {
+		return secretState{}, fmt.Errorf("error reading secret '%s': %v", key, err)
+	}
+
+	data, err := cmd.Get(ctx, r.GetSecretHistoryKey(env, key)).Bytes()
+	if err != nil && err != redis.Nil {
+		return secretState{}, fmt.Errorf("error reading history of secret '%s': %v", key, err)
+	}
+
+	history, err := decodeHistory(key, data)
+	if err != nil {
+		return secretState{}, err
+	}
+
+	return secretState{value: value, history: history}, nil
+}
+
+func (r *RedisRepository) encrypt(ctx context.Context, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return r.encryptor.Encrypt(ctx, data)
+}
+
+func (r *RedisRepository) decrypt(ctx context.Context, key string, encrypted []byte) (interface{}, error) {
+	decrypted, err := r.encryptor.Decrypt(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal(decrypted, &value)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling secret '%s': %v", key, err)
+	}
+	return value, nil
+}
+
 func (r *RedisRepository) GetCloneSecretsPatternKey(cloneEnv string) string {
-	return fmt.Sprintf("%s:%s:*", SecretPrefix, cloneEnv)
+	return fmt.Sprintf("%s:%s:*", r.prefix(), cloneEnv)
 }
 
 func (r *RedisRepository) GetCloneSecretsDeletePatternKey(clonedEnv string) string {
-	return fmt.Sprintf("%s:*:%s:*", SecretPrefix, clonedEnv)
+	return fmt.Sprintf("%s:*:%s:*", r.prefix(), clonedEnv)
+}
+
+// ReencryptSecrets wraps the data keys of every secret of the project, and of their kept versions, with the primary
+// master key, returning how many were rewrapped. A value modified meanwhile is left for the next run.
+func (r *RedisRepository) ReencryptSecrets(ctx context.Context) (int, error) {
+	r = r.scoped(ctx)
+
+	rewrapped := 0
+	for _, prefix := range []string{r.prefix() + ":", r.ns.Key(SecretHistorySegment) + ":"} {
+		isHistory := prefix != r.prefix()+":"
+
+		iter := r.Client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			fullKey := iter.Val()
+			key := strings.TrimPrefix(fullKey, prefix)
+
+			changed := false
+			err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+				current, err := tx.Get(ctx, fullKey).Bytes()
+				if err == redis.Nil {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				var value []byte
+				if isHistory {
+					value, changed, err = rewrapHistory(ctx, r.encryptor, key, current)
+				} else {
+					value, changed, err = r.encryptor.Rewrap(ctx, current)
+				}
+				if err != nil || !changed {
+					return err
+				}
+
+				_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					pipe.Set(ctx, fullKey, value, 0)
+					return nil
+				})
+				return err
+			}, fullKey)
+			if err == redis.TxFailedErr {
+				continue
+			}
+			if err != nil {
+				return rewrapped, fmt.Errorf("error re-encrypting secret '%s': %w", key, err)
+			}
+			if changed {
+				rewrapped++
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return rewrapped, fmt.Errorf("error scanning secrets to re-encrypt: %v", err)
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// ExportSecrets retrieves every stored secret of all environments without decrypting them.
+func (r *RedisRepository) ExportSecrets(ctx context.Context) ([]SecretRecord, error) {
+	r = r.scoped(ctx)
+
+	keys, err := r.Client.Keys(ctx, fmt.Sprintf("%s:*", r.prefix())).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all secret keys: %w", err)
+	}
+
+	records := make([]SecretRecord, 0, len(keys))
+	for _, key := range keys {
+		env, secretKey, ok := parseSecretKey(r.prefix(), key)
+		if !ok {
+			continue
+		}
+
+		value, err := r.Client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting secret '%s': %v", key, err)
+		}
+
+		records = append(records, SecretRecord{Env: env, Key: secretKey, Value: value})
+	}
+
+	return records, nil
+}
+
+// ImportSecrets stores the provided already encrypted secrets, overwriting the existing ones with the same key.
+func (r *RedisRepository) ImportSecrets(ctx context.Context, secrets []SecretRecord) error {
+	r = r.scoped(ctx)
+
+	if len(secrets) < 1 {
+		return nil
+	}
+
+	pipeline := r.Client.TxPipeline()
+	for _, secret := range secrets {
+		pipeline.Set(ctx, r.GetSecretKey(secret.Env, secret.Key), secret.Value, 0)
+	}
+
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on importing secrets: %v", err)
+	}
+
+	return nil
+}
+
+// ExportSecretHistory retrieves the kept versions of every secret of all environments, deleted secrets included,
+// without decrypting them.
+func (r *RedisRepository) ExportSecretHistory(ctx context.Context) ([]SecretHistoryRecord, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.ns.Key(SecretHistorySegment)
+	keys, err := r.Client.Keys(ctx, fmt.Sprintf("%s:*", prefix)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all secret history keys: %w", err)
+	}
+
+	records := make([]SecretHistoryRecord, 0, len(keys))
+	for _, key := range keys {
+		env, secretKey, ok := parseSecretKey(prefix, key)
+		if !ok {
+			continue
+		}
+
+		history, err := r.Client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting history of secret '%s': %v", key, err)
+		}
+
+		records = append(records, SecretHistoryRecord{Env: env, Key: secretKey, History: history})
+	}
+
+	return records, nil
+}
+
+// ImportSecretHistory stores the provided kept versions, overwriting the history of the secrets with the same key.
+func (r *RedisRepository) ImportSecretHistory(ctx context.Context, history []SecretHistoryRecord) error {
+	r = r.scoped(ctx)
+
+	if len(history) < 1 {
+		return nil
+	}
+
+	pipeline := r.Client.TxPipeline()
+	for _, record := range history {
+		if _, err := decodeHistory(record.Key, record.History); err != nil {
+			return err
+		}
+		pipeline.Set(ctx, r.GetSecretHistoryKey(record.Env, record.Key), []byte(record.History), 0)
+	}
+
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on importing secret history: %v", err)
+	}
+
+	return nil
+}
+
+// ExportSecretInfo retrieves the metadata of every secret of all environments, deleted secrets included,
+// along with their last reminder.
+func (r *RedisRepository) ExportSecretInfo(ctx context.Context) ([]SecretInfoEntry, error) {
+	r = r.scoped(ctx)
+
+	prefix := r.ns.Key(SecretInfoSegment)
+	keys, err := r.Client.Keys(ctx, fmt.Sprintf("%s:*", prefix)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all secret metadata keys: %w", err)
+	}
+
+	entries := make([]SecretInfoEntry, 0, len(keys))
+	for _, key := range keys {
+		env, secretKey, ok := parseSecretKey(prefix, key)
+		if !ok {
+			continue
+		}
+
+		info, err := r.Client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting metadata of secret '%s': %v", key, err)
+		}
+
+		data, err := r.Client.Get(ctx, r.GetSecretReminderKey(env, secretKey)).Bytes()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error getting reminder of secret '%s': %v", key, err)
+		}
+		reminded, err := decodeReminder(secretKey, data)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, SecretInfoEntry{Env: env, Key: secretKey, Info: info, Reminded: reminded})
+	}
+
+	return entries, nil
+}
+
+// ImportSecretInfo stores the provided metadata and reminders, overwriting the ones of the secrets with the same key.
+func (r *RedisRepository) ImportSecretInfo(ctx context.Context, info []SecretInfoEntry) error {
+	r = r.scoped(ctx)
+
+	if len(info) < 1 {
+		return nil
+	}
+
+	pipeline := r.Client.TxPipeline()
+	for _, entry := range info {
+		if err := validateInfoEntry(entry); err != nil {
+			return err
+		}
+		pipeline.Set(ctx, r.GetSecretInfoKey(entry.Env, entry.Key), []byte(entry.Info), 0)
+
+		if entry.Reminded != nil {
+			data, err := json.Marshal(entry.Reminded)
+			if err != nil {
+				return fmt.Errorf("error marshalling reminder of secret '%s': %v", entry.Key, err)
+			}
+			pipeline.Set(ctx, r.GetSecretReminderKey(entry.Env, entry.Key), data, 0)
+		}
+	}
+
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error executing Redis transaction on importing secret metadata: %v", err)
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) prefix() string {
+	return r.ns.Key(SecretSegment)
 }
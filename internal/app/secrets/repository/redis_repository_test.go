@@ -6,9 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
 	"github.com/raw-leak/configleam/internal/pkg/encryptor"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
@@ -38,7 +43,7 @@ func (suite *RedisSecretsRepositorySuite) SetupSuite() {
 	suite.encryptor, err = encryptor.NewEncryptor(suite.key)
 	suite.Require().NoError(err)
 
-	suite.repository = repository.NewRedisRepository(&rds.Redis{Client: suite.client}, suite.encryptor)
+	suite.repository = repository.NewRedisRepository(&rds.Redis{Client: suite.client}, suite.encryptor, namespace.Default, historySize)
 }
 
 func (suite *RedisSecretsRepositorySuite) TearDownSuite() {
@@ -663,3 +668,382 @@ func (suite *RedisSecretsRepositorySuite) TestDeleteSecrets() {
 		})
 	}
 }
+
+func (suite *RedisSecretsRepositorySuite) TestExportImportSecrets() {
+	ctx := context.Background()
+	suite.BeforeTest("TestExportImportSecrets")
+
+	secrets := map[string]interface{}{
+		"database": map[string]interface{}{"password": "db_password"},
+		"token":    "api_token",
+	}
+
+	err := suite.repository.UpsertSecrets(ctx, "develop", secrets)
+	suite.Require().NoError(err)
+
+	secrets["token"] = "rotated_token"
+	err = suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"token": "rotated_token"})
+	suite.Require().NoError(err)
+
+	exported, err := suite.repository.ExportSecrets(ctx)
+	suite.Require().NoError(err)
+	suite.Len(exported, len(secrets))
+
+	for _, record := range exported {
+		suite.Equal("develop", record.Env)
+		suite.NotContains(string(record.Value), "api_token", "exported secrets must stay encrypted")
+	}
+
+	history, err := suite.repository.ExportSecretHistory(ctx)
+	suite.Require().NoError(err)
+	suite.Len(history, len(secrets))
+
+	for _, record := range history {
+		suite.Equal("develop", record.Env)
+		suite.NotContains(string(record.History), "api_token", "exported versions must stay encrypted")
+	}
+
+	// restore into an empty storage
+	suite.BeforeTest("TestExportImportSecrets")
+
+	err = suite.repository.ImportSecrets(ctx, exported)
+	suite.Require().NoError(err)
+	err = suite.repository.ImportSecretHistory(ctx, history)
+	suite.Require().NoError(err)
+
+	for key, expected := range secrets {
+		value, err := suite.repository.GetSecret(ctx, "develop", key)
+		suite.Require().NoError(err)
+		suite.Equal(expected, value)
+	}
+
+	// the previous values can still be rolled back to
+	versions, err := suite.repository.ListSecretVersions(ctx, "develop", "token")
+	suite.Require().NoError(err)
+	suite.Require().Len(versions, 2)
+	suite.Equal(int64(2), versions[0].Version)
+
+	err = suite.repository.RollbackSecret(ctx, "develop", "token", 1)
+	suite.Require().NoError(err)
+
+	value, err := suite.repository.GetSecret(ctx, "develop", "token")
+	suite.Require().NoError(err)
+	suite.Equal("api_token", value)
+
+	err = suite.repository.ImportSecretHistory(ctx, []repository.SecretHistoryRecord{{Env: "develop", Key: "token", History: []byte("not json")}})
+	suite.Error(err)
+}
+
+func (suite *RedisSecretsRepositorySuite) TestProjectIsolation() {
+	ctx := context.Background()
+	suite.BeforeTest("TestProjectIsolation")
+
+	billingCtx := project.WithProject(ctx, "billing")
+
+	err := suite.repository.UpsertSecrets(billingCtx, "develop", map[string]interface{}{"token": "billing_token"})
+	suite.Require().NoError(err)
+
+	value, err := suite.repository.GetSecret(billingCtx, "develop", "token")
+	suite.Require().NoError(err)
+	suite.Equal("billing_token", value)
+
+	_, err = suite.repository.GetSecret(ctx, "develop", "token")
+	suite.ErrorAs(err, &repository.SecretNotFoundError{}, "secrets of a project must not be visible from the default project")
+
+	exported, err := suite.repository.ExportSecrets(ctx)
+	suite.Require().NoError(err)
+	suite.Empty(exported)
+}
+
+func (suite *RedisSecretsRepositorySuite) TestListSecrets() {
+	ctx := context.Background()
+	suite.BeforeTest("TestListSecrets")
+
+	err := suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{
+		"database": map[string]interface{}{"password": "db_password", "replica": map[string]interface{}{"password": "replica_password"}},
+		"token":    "api_token",
+		"port":     5432,
+	})
+	suite.Require().NoError(err)
+
+	err = suite.repository.UpsertSecrets(ctx, "develop2", map[string]interface{}{"other": "value"})
+	suite.Require().NoError(err)
+
+	secrets, err := suite.repository.ListSecrets(ctx, "develop")
+	suite.Require().NoError(err)
+	suite.Equal([]repository.SecretMetadata{
+		{Key: "database", Type: "object", Paths: []string{"database.password", "database.replica", "database.replica.password"}},
+		{Key: "port", Type: "number"},
+		{Key: "token", Type: "string"},
+	}, secrets)
+
+	secrets, err = suite.repository.ListSecrets(ctx, "missing")
+	suite.Require().NoError(err)
+	suite.Empty(secrets)
+}
+
+func (suite *RedisSecretsRepositorySuite) TestDeleteSecret() {
+	ctx := context.Background()
+	suite.BeforeTest("TestDeleteSecret")
+
+	err := suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"token": "api_token", "other": "value"})
+	suite.Require().NoError(err)
+
+	err = suite.repository.DeleteSecret(ctx, "develop", "token")
+	suite.Require().NoError(err)
+
+	_, err = suite.repository.GetSecret(ctx, "develop", "token")
+	suite.ErrorAs(err, &repository.SecretNotFoundError{})
+
+	value, err := suite.repository.GetSecret(ctx, "develop", "other")
+	suite.Require().NoError(err)
+	suite.Equal("value", value)
+
+	err = suite.repository.DeleteSecret(ctx, "develop", "token")
+	suite.Equal(repository.SecretNotFoundError{Key: "token"}, err)
+}
+
+func (suite *RedisSecretsRepositorySuite) TestUpdateSecret() {
+	ctx := context.Background()
+	suite.BeforeTest("TestUpdateSecret")
+
+	// creating a missing secret
+	err := suite.repository.UpdateSecret(ctx, "develop", "database", func(value interface{}, exists bool) (interface{}, error) {
+		suite.False(exists)
+		suite.Nil(value)
+		return map[string]interface{}{"password": "db_password"}, nil
+	})
+	suite.Require().NoError(err)
+
+	// updating it from its current value
+	err = suite.repository.UpdateSecret(ctx, "develop", "database", func(value interface{}, exists bool) (interface{}, error) {
+		suite.True(exists)
+		nested := value.(map[string]interface{})
+		nested["user"] = "app"
+		return nested, nil
+	})
+	suite.Require().NoError(err)
+
+	value, err := suite.repository.GetSecret(ctx, "develop", "database")
+	suite.Require().NoError(err)
+	suite.Equal(map[string]interface{}{"password": "db_password", "user": "app"}, value)
+
+	// an update error leaves the secret untouched
+	err = suite.repository.UpdateSecret(ctx, "develop", "database", func(value interface{}, exists bool) (interface{}, error) {
+		return nil, errors.New("update error")
+	})
+	suite.EqualError(err, "update error")
+
+	// a nil value deletes it
+	err = suite.repository.UpdateSecret(ctx, "develop", "database", func(value interface{}, exists bool) (interface{}, error) {
+		return nil, nil
+	})
+	suite.Require().NoError(err)
+
+	_, err = suite.repository.GetSecret(ctx, "develop", "database")
+	suite.ErrorAs(err, &repository.SecretNotFoundError{})
+}
+
+func (suite *RedisSecretsRepositorySuite) TestSecretVersions() {
+	suite.BeforeTest("TestSecretVersions")
+
+	perms := permissions.New().NewAccessKeyPermissions()
+	perms.Name = "deployer"
+	ctx := context.WithValue(context.Background(), auth.AccessKeyContextKey{}, *perms)
+
+	err := suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"password": "first_password"})
+	suite.Require().NoError(err)
+	err = suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"password": "second_password"})
+	suite.Require().NoError(err)
+	err = suite.repository.DeleteSecret(ctx, "develop", "password")
+	suite.Require().NoError(err)
+
+	versions, err := suite.repository.ListSecretVersions(ctx, "develop", "password")
+	suite.Require().NoError(err)
+	suite.Require().Len(versions, 3)
+	for i, expected := range []repository.SecretVersion{
+		{Version: 3, Action: repository.ActionDelete, ChangedBy: "deployer", Deleted: true},
+		{Version: 2, Action: repository.ActionSet, ChangedBy: "deployer"},
+		{Version: 1, Action: repository.ActionSet, ChangedBy: "deployer"},
+	} {
+		suite.False(versions[i].ChangedAt.IsZero())
+		versions[i].ChangedAt = expected.ChangedAt
+		suite.Equal(expected, versions[i])
+	}
+
+	raw, err := suite.client.Get(ctx, suite.repository.GetSecretHistoryKey("develop", "password")).Result()
+	suite.Require().NoError(err)
+	suite.NotContains(raw, "first_password", "kept versions must stay encrypted")
+
+	// a deleted secret is brought back by rolling it back
+	err = suite.repository.RollbackSecret(ctx, "develop", "password", 1)
+	suite.Require().NoError(err)
+
+	value, err := suite.repository.GetSecret(ctx, "develop", "password")
+	suite.Require().NoError(err)
+	suite.Equal("first_password", value)
+
+	// the rollback is a new version, the oldest one being pruned
+	versions, err = suite.repository.ListSecretVersions(ctx, "develop", "password")
+	suite.Require().NoError(err)
+	suite.Require().Len(versions, historySize)
+	suite.Equal(int64(4), versions[0].Version)
+	suite.Equal(repository.ActionRollback, versions[0].Action)
+	suite.Equal(int64(1), versions[0].Restores)
+	suite.Equal(int64(2), versions[historySize-1].Version)
+
+	err = suite.repository.RollbackSecret(ctx, "develop", "password", 1)
+	suite.Equal(repository.SecretVersionNotFoundError{Key: "password", Version: 1}, err)
+
+	_, err = suite.repository.ListSecretVersions(ctx, "develop", "missing")
+	suite.Equal(repository.SecretNotFoundError{Key: "missing"}, err)
+
+	// changes without an authenticated context are still recorded
+	err = suite.repository.UpsertSecrets(context.Background(), "develop", map[string]interface{}{"password": "third_password"})
+	suite.Require().NoError(err)
+
+	versions, err = suite.repository.ListSecretVersions(ctx, "develop", "password")
+	suite.Require().NoError(err)
+	suite.Equal(auth.UnknownActor, versions[0].ChangedBy)
+
+	// deleting the environment deletes the kept versions
+	err = suite.repository.DeleteSecrets(ctx, "develop")
+	suite.Require().NoError(err)
+
+	_, err = suite.repository.ListSecretVersions(ctx, "develop", "password")
+	suite.Equal(repository.SecretNotFoundError{Key: "password"}, err)
+}
+
+func (suite *RedisSecretsRepositorySuite) TestSecretInfo() {
+	suite.BeforeTest("TestSecretInfo")
+	ctx := context.Background()
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := repository.SecretInfo{
+		Description:      "payments API token",
+		Owner:            "payments-team",
+		ExpiresAt:        &expiresAt,
+		RotationInterval: repository.Duration(720 * time.Hour),
+	}
+
+	err := suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"token": "t0k3n", "password": "s3cr3t"})
+	suite.Require().NoError(err)
+	err = suite.repository.UpsertSecrets(ctx, "staging", map[string]interface{}{"token": "t0k3n"})
+	suite.Require().NoError(err)
+
+	err = suite.repository.SetSecretInfo(ctx, "develop", map[string]repository.SecretInfo{"token": info, "password": {Owner: "platform"}})
+	suite.Require().NoError(err)
+	err = suite.repository.SetSecretInfo(ctx, "staging", map[string]repository.SecretInfo{"token": info})
+	suite.Require().NoError(err)
+
+	records, err := suite.repository.ListSecretInfo(ctx, "develop")
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 2)
+	suite.Equal("password", records[0].Key)
+	suite.Equal("platform", records[0].Owner)
+	suite.Equal("token", records[1].Key)
+	suite.Equal(info.RotationInterval, records[1].RotationInterval)
+	suite.True(expiresAt.Equal(*records[1].ExpiresAt))
+	suite.False(records[1].UpdatedAt.IsZero())
+
+	records, err = suite.repository.ListSecretInfo(ctx, "")
+	suite.Require().NoError(err)
+	suite.Len(records, 3)
+	suite.Nil(records[0].Reminded)
+
+	// the last reminder survives the updates of the metadata
+	reminder := repository.SecretReminder{Status: "expiring", DueAt: expiresAt}
+	err = suite.repository.SetSecretReminder(ctx, "develop", "token", reminder)
+	suite.Require().NoError(err)
+	err = suite.repository.SetSecretInfo(ctx, "develop", map[string]repository.SecretInfo{"token": info})
+	suite.Require().NoError(err)
+
+	records, err = suite.repository.ListSecretInfo(ctx, "develop")
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 2)
+	suite.Require().NotNil(records[1].Reminded)
+	suite.Equal(reminder.Status, records[1].Reminded.Status)
+	suite.True(expiresAt.Equal(records[1].Reminded.DueAt))
+
+	// the metadata and reminders are exported and restored
+	entries, err := suite.repository.ExportSecretInfo(ctx)
+	suite.Require().NoError(err)
+	suite.Len(entries, 3)
+
+	err = suite.repository.DeleteSecrets(ctx, "develop")
+	suite.Require().NoError(err)
+	err = suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"token": "t0k3n", "password": "s3cr3t"})
+	suite.Require().NoError(err)
+	err = suite.repository.ImportSecretInfo(ctx, entries)
+	suite.Require().NoError(err)
+
+	restored, err := suite.repository.ListSecretInfo(ctx, "develop")
+	suite.Require().NoError(err)
+	suite.Require().Len(restored, 2)
+	suite.Equal("platform", restored[0].Owner)
+	suite.Require().NotNil(restored[1].Reminded)
+	suite.True(expiresAt.Equal(restored[1].Reminded.DueAt))
+
+	err = suite.repository.ImportSecretInfo(ctx, []repository.SecretInfoEntry{{Env: "develop", Key: "token", Info: []byte("not json")}})
+	suite.Error(err)
+
+	// deleted secrets are not listed, their metadata being kept for a rollback
+	err = suite.repository.DeleteSecret(ctx, "develop", "password")
+	suite.Require().NoError(err)
+
+	records, err = suite.repository.ListSecretInfo(ctx, "develop")
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 1)
+	suite.Equal("token", records[0].Key)
+
+	// deleting the environment deletes the metadata
+	err = suite.repository.DeleteSecrets(ctx, "develop")
+	suite.Require().NoError(err)
+
+	records, err = suite.repository.ListSecretInfo(ctx, "")
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 1)
+	suite.Equal("staging", records[0].Env)
+}
+
+func (suite *RedisSecretsRepositorySuite) TestReencryptSecrets() {
+	suite.BeforeTest("TestReencryptSecrets")
+	ctx := context.Background()
+
+	err := suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"password": "first_password", "token": "token"})
+	suite.Require().NoError(err)
+	err = suite.repository.UpsertSecrets(ctx, "develop", map[string]interface{}{"password": "second_password"})
+	suite.Require().NoError(err)
+
+	rotated, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{
+		{ID: "rotated", Key: "98765432109876543210987654321098"},
+		{ID: encryptor.DefaultKeyID, Key: suite.key},
+	}, suite.key, "")
+	suite.Require().NoError(err)
+	repo := repository.NewRedisRepository(&rds.Redis{Client: suite.client}, rotated, namespace.Default, historySize)
+
+	// two secrets and their two histories
+	rewrapped, err := repo.ReencryptSecrets(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(4, rewrapped)
+
+	raw, err := suite.client.Get(ctx, repo.GetSecretKey("develop", "token")).Bytes()
+	suite.Require().NoError(err)
+	suite.Equal("rotated", rotated.KeyID(raw))
+
+	value, err := repo.GetSecret(ctx, "develop", "password")
+	suite.Require().NoError(err)
+	suite.Equal("second_password", value)
+
+	err = repo.RollbackSecret(ctx, "develop", "password", 1)
+	suite.Require().NoError(err)
+
+	value, err = repo.GetSecret(ctx, "develop", "password")
+	suite.Require().NoError(err)
+	suite.Equal("first_password", value)
+
+	// the secrets wrapped by the primary master key are left untouched
+	rewrapped, err = repo.ReencryptSecrets(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(0, rewrapped)
+}
@@ -3,51 +3,123 @@ package repository
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/raw-leak/configleam/internal/pkg/etcd"
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
 	rds "github.com/raw-leak/configleam/internal/pkg/redis"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 )
 
 const (
-	SecretPrefix = "configleam:secret"
+	SecretSegment = "secret"
+	// the kept versions of the secrets, not sharing the prefix of the secrets so they are never listed as such
+	SecretHistorySegment = "revisions"
+	// the metadata of the secrets, neither sharing their prefix
+	SecretInfoSegment = "secretinfo"
+	// the last reminder sent for the secrets, kept apart from their metadata so it survives its updates
+	SecretReminderSegment = "secretreminder"
+
+	// prefix within the default namespace
+	SecretPrefix         = namespace.Default + ":" + SecretSegment
+	SecretHistoryPrefix  = namespace.Default + ":" + SecretHistorySegment
+	SecretInfoPrefix     = namespace.Default + ":" + SecretInfoSegment
+	SecretReminderPrefix = namespace.Default + ":" + SecretReminderSegment
 )
 
 type Encryptor interface {
 	Encrypt(ctx context.Context, b []byte) ([]byte, error)
 	Decrypt(ctx context.Context, b []byte) ([]byte, error)
+	Rewrap(ctx context.Context, b []byte) ([]byte, bool, error)
 }
 
 type Repository interface {
 	GetSecret(ctx context.Context, env string, key string) (interface{}, error)
 	UpsertSecrets(ctx context.Context, env string, secrets map[string]interface{}) error
 	CloneSecrets(ctx context.Context, cloneEnv, newEnv string) error
+
+	ListSecrets(ctx context.Context, env string) ([]SecretMetadata, error)
+	DeleteSecret(ctx context.Context, env, key string) error
+	UpdateSecret(ctx context.Context, env, key string, update UpdateFunc) error
+
+	ListSecretVersions(ctx context.Context, env, key string) ([]SecretVersion, error)
+	RollbackSecret(ctx context.Context, env, key string, version int64) error
+
+	SetSecretInfo(ctx context.Context, env string, info map[string]SecretInfo) error
+	ListSecretInfo(ctx context.Context, env string) ([]SecretInfoRecord, error)
+	SetSecretReminder(ctx context.Context, env, key string, reminder SecretReminder) error
+
+	ReencryptSecrets(ctx context.Context) (int, error)
+
+	ExportSecrets(ctx context.Context) ([]SecretRecord, error)
+	ImportSecrets(ctx context.Context, secrets []SecretRecord) error
+	ExportSecretHistory(ctx context.Context) ([]SecretHistoryRecord, error)
+	ImportSecretHistory(ctx context.Context, history []SecretHistoryRecord) error
+	ExportSecretInfo(ctx context.Context) ([]SecretInfoEntry, error)
+	ImportSecretInfo(ctx context.Context, info []SecretInfoEntry) error
+}
+
+// SecretRecord represents a single stored secret, its value is kept encrypted
+// so secrets never leave the storage in plain text when exported.
+type SecretRecord struct {
+	Env   string `json:"env"`
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
 }
 
+// SecretMetadata describes a stored secret without its value
+type SecretMetadata struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// Paths are the dot paths of the nested values of an object secret, usable in placeholders, e.g. "database.password"
+	Paths []string `json:"paths,omitempty"`
+}
+
+// UpdateFunc returns the new value of a secret from its current one, nil when it does not exist.
+// Returning a nil value deletes the secret.
+type UpdateFunc func(value interface{}, exists bool) (interface{}, error)
+
+// maxUpdateAttempts is how many times a secret is read and written again when it is concurrently modified
+const maxUpdateAttempts = 5
+
 type RepositoryConfig struct {
 	RedisAddrs    string
 	RedisUsername string
 	RedisPassword string
 	RedisTLS      bool
+	RedisTLSFiles tlsconfig.Files
 
 	EtcdAddrs    []string
 	EtcdUsername string
 	EtcdPassword string
 	EtcdTLS      bool
+	EtcdTLSFiles tlsconfig.Files
+
+	Namespace namespace.Namespace
+
+	// HistorySize is how many versions of each secret are kept
+	HistorySize int
 }
 
 func New(ctx context.Context, cfg RepositoryConfig, encryptor Encryptor) (Repository, error) {
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = HistorySizeDefault
+	}
+
 	if cfg.RedisAddrs != "" {
 		redisCli, err := rds.New(ctx, rds.RedisConfig{
 			Addr:     cfg.RedisAddrs,
 			Password: cfg.RedisPassword,
 			Username: cfg.RedisUsername,
 			TLS:      cfg.RedisTLS,
+			TLSFiles: cfg.RedisTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return NewRedisRepository(redisCli, encryptor), nil
+		return NewRedisRepository(redisCli, encryptor, cfg.Namespace, cfg.HistorySize), nil
 	}
 
 	if len(cfg.EtcdAddrs) > 0 {
@@ -56,12 +128,70 @@ func New(ctx context.Context, cfg RepositoryConfig, encryptor Encryptor) (Reposi
 			EtcdUsername: cfg.EtcdUsername,
 			EtcdPassword: cfg.EtcdPassword,
 			TLS:          cfg.EtcdTLS,
+			TLSFiles:     cfg.EtcdTLSFiles,
 		})
 		if err != nil {
 			return nil, err
 		}
-		return NewEtcdRepository(etcdCli, encryptor), nil
+		return NewEtcdRepository(etcdCli, encryptor, cfg.Namespace, cfg.HistorySize), nil
 	}
 
 	return nil, fmt.Errorf("'RedisAddress' nor 'EtcdAddrs' has been provided for 'secrets' repository")
 }
+
+// parseSecretKey splits a '<prefix>:<env>:<key>' key into its environment and secret key
+func parseSecretKey(prefix, key string) (env, secretKey string, ok bool) {
+	rest, found := strings.CutPrefix(key, prefix+":")
+	if !found {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(rest, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// secretMetadata describes the decrypted value of a secret
+func secretMetadata(key string, value interface{}) SecretMetadata {
+	metadata := SecretMetadata{Key: key, Type: valueType(value)}
+
+	if nested, ok := value.(map[string]interface{}); ok {
+		var walk func(prefix string, m map[string]interface{})
+		walk = func(prefix string, m map[string]interface{}) {
+			for k, v := range m {
+				path := prefix + "." + k
+				metadata.Paths = append(metadata.Paths, path)
+				if child, ok := v.(map[string]interface{}); ok {
+					walk(path, child)
+				}
+			}
+		}
+		walk(key, nested)
+		sort.Strings(metadata.Paths)
+	}
+
+	return metadata
+}
+
+// valueType names the JSON type of a value
+func valueType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+)
+
+// DueWithinDefault is how long ahead secrets expiring or due for rotation are listed when no notice is given
+const DueWithinDefault = 7 * 24 * time.Hour
+
+// Why a secret is due
+const (
+	DueExpired         = "expired"
+	DueExpiring        = "expiring"
+	DueRotation        = "rotationDue"
+	DueRotationOverdue = "rotationOverdue"
+)
+
+// DueSecret is a secret that expired, expires or is due for rotation within the notice
+type DueSecret struct {
+	repository.SecretInfoRecord
+	Status string `json:"status"`
+	// DueAt is when the secret expires, or has to be rotated when it is due for rotation
+	DueAt time.Time `json:"dueAt"`
+	// RotateBy is when the value of the secret has to change, from its rotation interval
+	RotateBy *time.Time `json:"rotateBy,omitempty"`
+}
+
+// ListDueSecrets lists the secrets of the environment, of every environment when empty, that expired or are due
+// for rotation, or will be within the notice, the most urgent first
+func (s SecretsService) ListDueSecrets(ctx context.Context, env string, within time.Duration) ([]DueSecret, error) {
+	records, err := s.repository.ListSecretInfo(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	due := []DueSecret{}
+	for _, record := range records {
+		if d, ok := dueSecret(record, now, within); ok {
+			due = append(due, d)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].DueAt.Before(due[j].DueAt)
+	})
+
+	return due, nil
+}
+
+// AlreadyReminded reports whether the last reminder sent for the secret had its current status and due time
+func (d DueSecret) AlreadyReminded() bool {
+	return d.Reminded != nil && d.Reminded.Status == d.Status && d.Reminded.DueAt.Equal(d.DueAt)
+}
+
+// MarkSecretReminded records the reminder sent for the due secret, so it is not sent again, restarts included,
+// while the secret keeps the same status and due time
+func (s SecretsService) MarkSecretReminded(ctx context.Context, secret DueSecret) error {
+	return s.repository.SetSecretReminder(ctx, secret.Env, secret.Key, repository.SecretReminder{Status: secret.Status, DueAt: secret.DueAt})
+}
+
+// dueSecret reports whether the secret is due at the time or within the notice, an expired secret being reported
+// as such and an expiry being reported before a later rotation
+func dueSecret(record repository.SecretInfoRecord, now time.Time, within time.Duration) (DueSecret, bool) {
+	d := DueSecret{SecretInfoRecord: record}
+	limit := now.Add(within)
+
+	if record.RotationInterval > 0 && !record.UpdatedAt.IsZero() {
+		rotateBy := record.UpdatedAt.Add(time.Duration(record.RotationInterval))
+		d.RotateBy = &rotateBy
+
+		if !rotateBy.After(limit) {
+			d.DueAt, d.Status = rotateBy, DueRotation
+			if !rotateBy.After(now) {
+				d.Status = DueRotationOverdue
+			}
+		}
+	}
+
+	if record.ExpiresAt != nil {
+		expiresAt := *record.ExpiresAt
+		switch {
+		case !expiresAt.After(now):
+			d.DueAt, d.Status = expiresAt, DueExpired
+		case !expiresAt.After(limit) && (d.Status == "" || !expiresAt.After(d.DueAt)):
+			d.DueAt, d.Status = expiresAt, DueExpiring
+		}
+	}
+
+	return d, d.Status != ""
+}
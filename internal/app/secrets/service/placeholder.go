@@ -0,0 +1,207 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/raw-leak/configleam/internal/app/secrets/provider"
+)
+
+const (
+	// PlaceholderEscape written right before a placeholder keeps it as it is, e.g. "\{{ secret.key }}"
+	PlaceholderEscape = `\`
+	FilterSeparator   = "|"
+	// SchemeSeparator splits the provider scheme from the reference it resolves, e.g. "{{ env.DB_PASS }}"
+	SchemeSeparator = "."
+)
+
+// filters transform the stringified value of a secret embedded in a string, e.g. "{{ secret.key | base64 }}"
+var filters = map[string]func(string) string{
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"urlencode": func(s string) string {
+		// spaces are written as '%20' so the value is valid in any part of a URL
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	},
+}
+
+// InvalidPlaceholderError is returned when a secret placeholder can not be resolved whatever the secret value is
+type InvalidPlaceholderError struct {
+	Placeholder string
+	Reason      string
+}
+
+func (e InvalidPlaceholderError) Error() string {
+	return fmt.Sprintf("invalid secret placeholder '%s': %s", e.Placeholder, e.Reason)
+}
+
+// placeholder is a reference to a secret of a provider, along with the filters its value goes through
+type placeholder struct {
+	raw    string
+	scheme string
+	// scope is the shared scope of a stored secret, empty for the secrets of the env
+	scope   string
+	key     string
+	filters []string
+}
+
+// name identifies the secret of the placeholder, its key for the stored secrets, '@<scope>.<key>' for the shared
+// ones and '<provider>.<reference>' otherwise
+func (ph *placeholder) name() string {
+	if ph.scope != "" {
+		return ScopeEnv(ph.scope) + SchemeSeparator + ph.key
+	}
+	if ph.scheme == provider.SecretScheme {
+		return ph.key
+	}
+	return ph.scheme + SchemeSeparator + ph.key
+}
+
+// env returns the environment the secret of the placeholder is read from, the one of its scope when it has one
+func (ph *placeholder) env(env string) string {
+	if ph.scope != "" {
+		return ScopeEnv(ph.scope)
+	}
+	return env
+}
+
+// part is either a literal text or a placeholder of a string
+type part struct {
+	text        string
+	placeholder *placeholder
+}
+
+// parsePlaceholders splits the string into literal texts and secret placeholders of the schemes having a provider,
+// unescaping the escaped ones. Any other text between '{{' and '}}' is kept as it is, as it may be meant for another
+// templating tool.
+func parsePlaceholders(str string, hasProvider func(scheme string) bool) ([]part, error) {
+	parts := []part{}
+	var text strings.Builder
+
+	for {
+		start := strings.Index(str, SecretPhStart)
+		if start < 0 {
+			text.WriteString(str)
+			break
+		}
+
+		if strings.HasSuffix(str[:start], PlaceholderEscape) {
+			text.WriteString(str[:start-len(PlaceholderEscape)])
+			text.WriteString(SecretPhStart)
+			str = str[start+len(SecretPhStart):]
+			continue
+		}
+
+		end := strings.Index(str[start+len(SecretPhStart):], SecretPhEnd)
+		if end < 0 {
+			text.WriteString(str)
+			break
+		}
+		end += start + len(SecretPhStart)
+
+		raw := str[start : end+len(SecretPhEnd)]
+		ph, err := parsePlaceholder(raw, hasProvider)
+		if err != nil {
+			return nil, err
+		}
+
+		if ph == nil {
+			text.WriteString(str[:end+len(SecretPhEnd)])
+		} else {
+			text.WriteString(str[:start])
+			if text.Len() > 0 {
+				parts = append(parts, part{text: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, part{placeholder: ph})
+		}
+
+		str = str[end+len(SecretPhEnd):]
+	}
+
+	if text.Len() > 0 {
+		parts = append(parts, part{text: text.String()})
+	}
+
+	return parts, nil
+}
+
+// parsePlaceholder parses a '{{ <scheme>.<reference> | <filter> ... }}' placeholder, returning nil when no provider
+// resolves its scheme. Stored secrets may be read from a shared scope, e.g. '{{ secret@shared.smtp.password }}'.
+func parsePlaceholder(raw string, hasProvider func(scheme string) bool) (*placeholder, error) {
+	body := raw[len(SecretPhStart) : len(raw)-len(SecretPhEnd)]
+	segments := strings.Split(body, FilterSeparator)
+
+	scheme, key, ok := strings.Cut(strings.TrimSpace(segments[0]), SchemeSeparator)
+	if !ok {
+		return nil, nil
+	}
+	scheme, scope, scoped := strings.Cut(scheme, ScopeSeparator)
+	if !hasProvider(scheme) {
+		return nil, nil
+	}
+
+	ph := &placeholder{raw: raw, scheme: scheme, scope: scope, key: key}
+	if scoped {
+		if scheme != provider.SecretScheme {
+			return nil, InvalidPlaceholderError{Placeholder: raw, Reason: fmt.Sprintf("only the '%s' placeholders have scopes", provider.SecretScheme)}
+		}
+		if err := ValidateScope(scope); err != nil {
+			return nil, InvalidPlaceholderError{Placeholder: raw, Reason: err.Error()}
+		}
+	}
+	if ph.key == "" {
+		return nil, InvalidPlaceholderError{Placeholder: raw, Reason: "secret key is empty"}
+	}
+
+	for _, segment := range segments[1:] {
+		name := strings.TrimSpace(segment)
+		if _, ok := filters[name]; !ok {
+			return nil, InvalidPlaceholderError{Placeholder: raw, Reason: fmt.Sprintf("unknown filter '%s', supported filters: base64, urlencode", name)}
+		}
+		ph.filters = append(ph.filters, name)
+	}
+
+	return ph, nil
+}
+
+// stringify returns the textual representation of a secret value embedded in a string,
+// maps and arrays being written as JSON and null as an empty string
+func stringify(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// applyFilters stringifies the value and passes it through the filters of the placeholder, in order
+func (ph *placeholder) applyFilters(value interface{}) (string, error) {
+	str, err := stringify(value)
+	if err != nil {
+		return "", fmt.Errorf("error stringifying secret '%s': %v", ph.key, err)
+	}
+
+	for _, name := range ph.filters {
+		str = filters[name](str)
+	}
+	return str, nil
+}
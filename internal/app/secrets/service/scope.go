@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/raw-leak/configleam/internal/pkg/secretscope"
+)
+
+const (
+	// ScopePrefix marks the environment a shared secret scope is stored, managed and granted as, e.g. "@shared"
+	ScopePrefix = secretscope.Prefix
+	// ScopeSeparator splits the scheme from the scope of the secrets it references, e.g. "{{ secret@shared.smtp.password }}"
+	ScopeSeparator = "@"
+)
+
+var scopePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// InvalidScopeError is returned when a secret scope can not be used
+type InvalidScopeError struct {
+	Scope  string
+	Reason string
+}
+
+func (e InvalidScopeError) Error() string {
+	return fmt.Sprintf("invalid secret scope '%s': %s", e.Scope, e.Reason)
+}
+
+// ScopeEnv returns the environment the secrets of the scope are stored as
+func ScopeEnv(scope string) string {
+	return secretscope.Env(scope)
+}
+
+// IsScope reports whether the environment is a shared secret scope rather than a configuration environment
+func IsScope(env string) bool {
+	return secretscope.Is(env)
+}
+
+// ValidateScope checks the name of a scope, which is made of letters, digits, '_' or '-'
+func ValidateScope(scope string) error {
+	if !scopePattern.MatchString(scope) {
+		return InvalidScopeError{Scope: scope, Reason: "scopes are made of letters, digits, '_' or '-'"}
+	}
+	return nil
+}
+
+// validateEnv checks the name of the scope when the secrets are written to one
+func validateEnv(env string) error {
+	if !IsScope(env) {
+		return nil
+	}
+	return ValidateScope(strings.TrimPrefix(env, ScopePrefix))
+}
@@ -1,44 +1,110 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/raw-leak/configleam/internal/app/secrets/provider"
 	"github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	"github.com/raw-leak/configleam/internal/pkg/envorigin"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
 )
 
 const (
 	SecretPhStart = "{{"
 	SecretPhEnd   = "}}"
-	SecretPattern = "secret."
 )
 
 type SecretsService struct {
 	repository repository.Repository
+	providers  *provider.Registry
 }
 
-func New(repository repository.Repository) *SecretsService {
-	return &SecretsService{repository: repository}
+// New creates the secrets service, the placeholders being resolved through the providers of the registry,
+// the repository resolving the 'secret' ones
+func New(repository repository.Repository, providers *provider.Registry) *SecretsService {
+	if providers == nil {
+		providers = provider.NewRegistry()
+	}
+	providers.Register(provider.SecretScheme, provider.NewRepositoryProvider(repository), provider.Options{})
+
+	return &SecretsService{repository: repository, providers: providers}
+}
+
+// resolution is how the placeholders of a configuration are replaced, along with those that could not be resolved
+type resolution struct {
+	env string
+	// reveal reports whether the secrets of an env, or of a shared scope, are populated
+	reveal     func(env string) bool
+	mode       secretmode.Mode
+	unresolved map[secretmode.Unresolved]bool
+}
+
+// unresolve records a placeholder that could not be resolved
+func (r *resolution) unresolve(ph *placeholder, reason string) {
+	r.unresolved[secretmode.Unresolved{Key: ph.name(), Reason: reason}] = true
 }
 
-// InsertSecrets insert secrets
-func (s *SecretsService) InsertSecrets(ctx context.Context, env string, cfg *map[string]interface{}, populate bool) error {
+// revealer returns reveal checking a clone against its original env, the way the requests on a clone are authorized,
+// so reading and checking the placeholders of a clone follow the same rule
+func revealer(ctx context.Context, reveal func(env string) bool) func(env string) bool {
+	return func(env string) bool {
+		return reveal(envorigin.Original(ctx, env))
+	}
+}
+
+// InsertSecrets insert secrets, populating those of the envs and shared scopes revealed, how missing and not populated
+// secrets are replaced depending on the secrets mode of the context. A clone is revealed as its original env.
+func (s *SecretsService) InsertSecrets(ctx context.Context, env string, cfg *map[string]interface{}, reveal func(env string) bool) error {
+	r := &resolution{env: env, reveal: revealer(ctx, reveal), mode: secretmode.FromContext(ctx), unresolved: map[secretmode.Unresolved]bool{}}
+
+	err := s.insertSecretsIntoMap(ctx, r, cfg)
+	if err != nil {
+		return err
+	}
+
+	if r.mode == secretmode.Strict && len(r.unresolved) > 0 {
+		unresolved := make([]secretmode.Unresolved, 0, len(r.unresolved))
+		for u := range r.unresolved {
+			unresolved = append(unresolved, u)
+		}
+		sort.Slice(unresolved, func(i, j int) bool {
+			if unresolved[i].Key != unresolved[j].Key {
+				return unresolved[i].Key < unresolved[j].Key
+			}
+			return unresolved[i].Reason < unresolved[j].Reason
+		})
+		return secretmode.UnresolvedError{Unresolved: unresolved}
+	}
+
+	return nil
+}
+
+// insertSecretsIntoMap inserts secrets into a map
+func (s *SecretsService) insertSecretsIntoMap(ctx context.Context, r *resolution, cfg *map[string]interface{}) error {
 	for key, value := range *cfg {
 		// handle nested maps
 		if nestedCfg, ok := value.(map[string]interface{}); ok {
-			err := s.InsertSecrets(ctx, env, &nestedCfg, populate)
+			err := s.insertSecretsIntoMap(ctx, r, &nestedCfg)
 			if err != nil {
 				return err
 			}
 		} else if arrVal, ok := value.([]interface{}); ok {
 			// handle arrays
-			err := s.insertSecretsIntoArray(ctx, env, &arrVal, populate)
+			err := s.insertSecretsIntoArray(ctx, r, &arrVal)
 			if err != nil {
 				return err
 			}
 		} else if strVal, ok := value.(string); ok {
 			// handle strings
-			updatedVal, err := s.replaceSecretPlaceholders(ctx, env, strVal, populate)
+			updatedVal, err := s.replaceSecretPlaceholders(ctx, r, strVal)
 			if err != nil {
 				return err
 			}
@@ -50,54 +116,104 @@ func (s *SecretsService) InsertSecrets(ctx context.Context, env string, cfg *map
 	return nil
 }
 
-// replaceSecretPlaceholders replaces secret placeholders in a string
-func (s *SecretsService) replaceSecretPlaceholders(ctx context.Context, env, str string, populate bool) (interface{}, error) {
-	if strings.HasPrefix(str, SecretPhStart) && strings.HasSuffix(str, SecretPhEnd) {
-		if populate {
-			intKey := str[len(SecretPhStart) : len(str)-len(SecretPhEnd)]
-			intKey = strings.Trim(intKey, " ")
+// replaceSecretPlaceholders replaces the secret placeholders of a string. A string that is a single placeholder
+// without filters is replaced by the secret value as it is, while placeholders embedded in a larger string, or with
+// filters, are replaced by the stringified value. Placeholders that are not resolved, missing or not populated, are
+// replaced depending on the mode, the rest of the string being kept.
+func (s *SecretsService) replaceSecretPlaceholders(ctx context.Context, r *resolution, str string) (interface{}, error) {
+	parts, err := parsePlaceholders(str, s.providers.Has)
+	if err != nil {
+		return nil, err
+	}
 
-			if strings.HasPrefix(intKey, SecretPattern) {
-				key := intKey[len(SecretPattern):]
+	if len(parts) == 1 && parts[0].placeholder != nil && len(parts[0].placeholder.filters) == 0 {
+		value, _, err := s.placeholderValue(ctx, r, parts[0].placeholder, true)
+		return value, err
+	}
 
-				secretValue, err := s.repository.GetSecret(ctx, env, key)
-				if err != nil {
-					if _, ok := err.(repository.SecretNotFoundError); ok {
-						return "", nil
-					} else {
-						return "", err
-					}
-				}
+	var b strings.Builder
+	for _, p := range parts {
+		if p.placeholder == nil {
+			b.WriteString(p.text)
+			continue
+		}
+
+		value, resolved, err := s.placeholderValue(ctx, r, p.placeholder, false)
+		if err != nil {
+			return nil, err
+		}
+		if !resolved {
+			b.WriteString(value.(string))
+			continue
+		}
+
+		filtered, err := p.placeholder.applyFilters(value)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(filtered)
+	}
+
+	return b.String(), nil
+}
+
+// placeholderValue returns the secret of a placeholder, or what replaces it when it is missing or not populated,
+// reporting whether it was resolved. Whole placeholders make up a value by themselves. The secrets of a shared scope
+// are populated when the scope is revealed, whatever the env being read is.
+func (s *SecretsService) placeholderValue(ctx context.Context, r *resolution, ph *placeholder, whole bool) (interface{}, bool, error) {
+	if !r.reveal(ph.env(r.env)) {
+		r.unresolve(ph, secretmode.ReasonRedacted)
 
-				return secretValue, nil
+		if r.mode == secretmode.Masked {
+			if whole {
+				return secretmode.Marker(ph.name()), false, nil
 			}
-		} else {
-			return "", nil
+			return secretmode.MaskedValue, false, nil
 		}
+		return "", false, nil
+	}
 
+	value, err := s.resolve(ctx, r.env, ph)
+	if err != nil {
+		if errors.As(err, &provider.NotFoundError{}) {
+			r.unresolve(ph, secretmode.ReasonMissing)
+			return "", false, nil
+		}
+		return nil, false, err
 	}
 
-	return str, nil
+	return value, true, nil
+}
+
+// resolve returns the value of a placeholder from the provider of its scheme, of its scope when it has one
+func (s *SecretsService) resolve(ctx context.Context, env string, ph *placeholder) (interface{}, error) {
+	value, err := s.providers.Resolve(ctx, ph.env(env), ph.scheme, ph.key)
+
+	var invalidRef provider.InvalidRefError
+	if errors.As(err, &invalidRef) {
+		return nil, InvalidPlaceholderError{Placeholder: ph.raw, Reason: invalidRef.Reason}
+	}
+	return value, err
 }
 
 // insertSecretsIntoArray inserts secrets into an array
-func (s *SecretsService) insertSecretsIntoArray(ctx context.Context, env string, arr *[]interface{}, populate bool) error {
+func (s *SecretsService) insertSecretsIntoArray(ctx context.Context, r *resolution, arr *[]interface{}) error {
 	for i, val := range *arr {
 		switch typedVal := val.(type) {
 		case map[string]interface{}:
-			err := s.InsertSecrets(ctx, env, &typedVal, populate)
+			err := s.insertSecretsIntoMap(ctx, r, &typedVal)
 			if err != nil {
 				return err
 			}
 			(*arr)[i] = typedVal
 		case []interface{}:
-			err := s.insertSecretsIntoArray(ctx, env, &typedVal, populate)
+			err := s.insertSecretsIntoArray(ctx, r, &typedVal)
 			if err != nil {
 				return err
 			}
 			(*arr)[i] = typedVal
 		case string:
-			updatedVal, err := s.replaceSecretPlaceholders(ctx, env, typedVal, populate)
+			updatedVal, err := s.replaceSecretPlaceholders(ctx, r, typedVal)
 			if err != nil {
 				return err
 			}
@@ -109,12 +225,358 @@ func (s *SecretsService) insertSecretsIntoArray(ctx context.Context, env string,
 	return nil
 }
 
-// UpsertSecrets upserts secrets for environment
-func (s SecretsService) UpsertSecrets(ctx context.Context, env string, cfg map[string]interface{}) error {
-	return s.repository.UpsertSecrets(ctx, env, cfg)
+// UpsertSecrets upserts secrets for environment, along with the metadata of some of them,
+// the secrets upserted without metadata keeping their current one
+func (s SecretsService) UpsertSecrets(ctx context.Context, env string, cfg map[string]interface{}, info map[string]repository.SecretInfo) error {
+	if err := validateEnv(env); err != nil {
+		return err
+	}
+
+	for key, i := range info {
+		if _, ok := cfg[key]; !ok {
+			return SecretPathError{Key: key, Reason: "metadata is upserted along with the value of the secret"}
+		}
+		if i.RotationInterval < 0 {
+			return SecretPathError{Key: key, Reason: "the rotation interval must not be negative"}
+		}
+	}
+
+	err := s.repository.UpsertSecrets(ctx, env, cfg)
+	if err != nil || len(info) == 0 {
+		return err
+	}
+	return s.repository.SetSecretInfo(ctx, env, info)
+}
+
+// ApplySecrets upserts the secrets of the environment whose values differ from the stored ones, returning how many
+// were written, so applying the same secrets again, e.g. when a tag is synchronized once more, keeps their history
+func (s SecretsService) ApplySecrets(ctx context.Context, env string, secrets map[string]interface{}) (int, error) {
+	changed := map[string]interface{}{}
+	for key, value := range secrets {
+		if strings.Contains(key, ".") {
+			return 0, SecretPathError{Key: key, Reason: "whole secrets are applied, keys must not contain dots"}
+		}
+
+		current, err := s.repository.GetSecret(ctx, env, key)
+		if err != nil && !errors.As(err, &repository.SecretNotFoundError{}) {
+			return 0, err
+		}
+		if err == nil && sameValue(current, value) {
+			continue
+		}
+		changed[key] = value
+	}
+
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	return len(changed), s.repository.UpsertSecrets(ctx, env, changed)
 }
 
-// CloneSecrets clones secrets for environment
+// PruneSecrets deletes the secrets of the environment that are no longer part of the applied ones and whose last
+// change was made by an actor managed reports as applying them, e.g. an earlier git tag, returning how many were
+// deleted. Secrets changed by anyone else since, or without versions, e.g. cloned or imported, are kept.
+func (s SecretsService) PruneSecrets(ctx context.Context, env string, applied map[string]interface{}, managed func(changedBy string) bool) (int, error) {
+	stored, err := s.repository.ListSecrets(ctx, env)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, secret := range stored {
+		if _, ok := applied[secret.Key]; ok {
+			continue
+		}
+
+		versions, err := s.repository.ListSecretVersions(ctx, env, secret.Key)
+		if errors.As(err, &repository.SecretNotFoundError{}) {
+			continue
+		}
+		if err != nil {
+			return pruned, err
+		}
+		if len(versions) == 0 || !managed(versions[0].ChangedBy) {
+			continue
+		}
+
+		if err := s.repository.DeleteSecret(ctx, env, secret.Key); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// sameValue reports whether both values are stored the same, as JSON
+func sameValue(a, b interface{}) bool {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aJSON, bJSON)
+}
+
+// CloneSecrets clones secrets for environment, the secrets of the shared scopes it references not being copied
+// so the clone keeps referencing them
 func (s SecretsService) CloneSecrets(ctx context.Context, cloneEnv, newEnv string) error {
+	for _, e := range []string{cloneEnv, newEnv} {
+		if IsScope(e) {
+			return InvalidScopeError{Scope: e, Reason: "shared scopes are not cloned"}
+		}
+	}
 	return s.repository.CloneSecrets(ctx, cloneEnv, newEnv)
 }
+
+// ListSecrets describes the secrets of the environment without their values
+func (s SecretsService) ListSecrets(ctx context.Context, env string) ([]repository.SecretMetadata, error) {
+	return s.repository.ListSecrets(ctx, env)
+}
+
+// DeleteSecret deletes a secret, or a nested value of a secret when the key is a dot path, e.g. "database.password"
+func (s SecretsService) DeleteSecret(ctx context.Context, env, key string) error {
+	path, err := splitSecretPath(key)
+	if err != nil {
+		return err
+	}
+
+	if len(path) == 1 {
+		return s.repository.DeleteSecret(ctx, env, key)
+	}
+
+	return s.repository.UpdateSecret(ctx, env, path[0], func(value interface{}, exists bool) (interface{}, error) {
+		parent, ok := lookupPath(value, path[1:len(path)-1]).(map[string]interface{})
+		if !ok {
+			return nil, repository.SecretNotFoundError{Key: key}
+		}
+		if _, ok := parent[path[len(path)-1]]; !ok {
+			return nil, repository.SecretNotFoundError{Key: key}
+		}
+
+		delete(parent, path[len(path)-1])
+		return value, nil
+	})
+}
+
+// ListSecretVersions describes the kept versions of a secret, newest first, without their values
+func (s SecretsService) ListSecretVersions(ctx context.Context, env, key string) ([]repository.SecretVersion, error) {
+	if err := validateSecretKey(key); err != nil {
+		return nil, err
+	}
+	return s.repository.ListSecretVersions(ctx, env, key)
+}
+
+// RollbackSecret brings a secret back to one of its kept versions, the rollback being kept as a new version
+func (s SecretsService) RollbackSecret(ctx context.Context, env, key string, version int64) error {
+	if err := validateSecretKey(key); err != nil {
+		return err
+	}
+	return s.repository.RollbackSecret(ctx, env, key, version)
+}
+
+// ReencryptSecrets wraps the secrets of the project, and their kept versions, with the primary master key
+func (s SecretsService) ReencryptSecrets(ctx context.Context) (int, error) {
+	return s.repository.ReencryptSecrets(ctx)
+}
+
+// PatchSecrets sets the values of the secrets, or of their nested values when the keys are dot paths,
+// creating the objects leading to them. A null value deletes the secret or the nested value.
+// The secrets are updated one by one, the values of a single secret being updated at once.
+func (s SecretsService) PatchSecrets(ctx context.Context, env string, patch map[string]interface{}) error {
+	if len(patch) < 1 {
+		return errors.New("provided patch is empty")
+	}
+	if err := validateEnv(env); err != nil {
+		return err
+	}
+
+	// the values are grouped by secret, the shortest paths being applied first
+	changes := map[string][]string{}
+	for key := range patch {
+		path, err := splitSecretPath(key)
+		if err != nil {
+			return err
+		}
+		changes[path[0]] = append(changes[path[0]], key)
+	}
+
+	secrets := make([]string, 0, len(changes))
+	for secret, keys := range changes {
+		sort.Strings(keys)
+		secrets = append(secrets, secret)
+	}
+	sort.Strings(secrets)
+
+	for _, secret := range secrets {
+		err := s.repository.UpdateSecret(ctx, env, secret, func(value interface{}, exists bool) (interface{}, error) {
+			var err error
+			for _, key := range changes[secret] {
+				path, _ := splitSecretPath(key)
+				value, err = setPath(value, path[1:], patch[key], key)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return value, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PlaceholderCheck reports whether a secret placeholder resolves to a value
+type PlaceholderCheck struct {
+	Placeholder string   `json:"placeholder"`
+	Provider    string   `json:"provider"`
+	Scope       string   `json:"scope,omitempty"`
+	Key         string   `json:"key"`
+	Filters     []string `json:"filters,omitempty"`
+	Resolves    bool     `json:"resolves"`
+}
+
+// RevealNotAllowedError is returned when the secrets of an env or scope are checked without being allowed to reveal them
+type RevealNotAllowedError struct {
+	Env string
+}
+
+func (e RevealNotAllowedError) Error() string {
+	return fmt.Sprintf("revealing the secrets of '%s' is not allowed", e.Env)
+}
+
+// CheckPlaceholders checks whether each secret placeholder of the value resolves to a value, without revealing it.
+// The access key must be allowed to reveal the secrets of the env, or of the scope, of every placeholder, which are
+// otherwise not resolved at all.
+func (s *SecretsService) CheckPlaceholders(ctx context.Context, env, value string) ([]PlaceholderCheck, error) {
+	parts, err := parsePlaceholders(value, s.providers.Has)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKeyPerms, ok := ctx.Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+	if !ok {
+		return nil, errors.New("permissions were not found")
+	}
+	reveal := revealer(ctx, accessKeyPerms.CanRevealSecrets)
+	for _, p := range parts {
+		if p.placeholder == nil {
+			continue
+		}
+		target := p.placeholder.env(env)
+		if !reveal(target) {
+			return nil, RevealNotAllowedError{Env: target}
+		}
+	}
+
+	checks := []PlaceholderCheck{}
+	for _, p := range parts {
+		if p.placeholder == nil {
+			continue
+		}
+
+		secret, err := s.resolve(ctx, env, p.placeholder)
+		if err != nil {
+			if !errors.As(err, &provider.NotFoundError{}) {
+				return nil, err
+			}
+		}
+
+		checks = append(checks, PlaceholderCheck{
+			Placeholder: p.placeholder.raw,
+			Provider:    p.placeholder.scheme,
+			Scope:       p.placeholder.scope,
+			Key:         p.placeholder.key,
+			Filters:     p.placeholder.filters,
+			Resolves:    err == nil && secret != nil,
+		})
+	}
+
+	return checks, nil
+}
+
+// SecretPathError is returned when a secret key or path can not be used
+type SecretPathError struct {
+	Key    string
+	Reason string
+}
+
+func (e SecretPathError) Error() string {
+	return fmt.Sprintf("invalid secret key '%s': %s", e.Key, e.Reason)
+}
+
+// splitSecretPath splits a dot path into the secret key and the keys of its nested values
+func splitSecretPath(key string) ([]string, error) {
+	path := strings.Split(key, ".")
+	for _, segment := range path {
+		if segment == "" {
+			return nil, SecretPathError{Key: key, Reason: "keys must not be empty"}
+		}
+	}
+	return path, nil
+}
+
+// validateSecretKey checks the key names a whole secret, the versions being kept per secret and not per nested value
+func validateSecretKey(key string) error {
+	path, err := splitSecretPath(key)
+	if err != nil {
+		return err
+	}
+	if len(path) > 1 {
+		return SecretPathError{Key: key, Reason: "versions are kept for whole secrets, not for their nested values"}
+	}
+	return nil
+}
+
+// lookupPath returns the nested value of the path, nil when it is not found
+func lookupPath(value interface{}, path []string) interface{} {
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// setPath sets the nested value of the path, creating the objects leading to it, a nil value deleting it
+func setPath(value interface{}, path []string, newValue interface{}, key string) (interface{}, error) {
+	if len(path) == 0 {
+		return newValue, nil
+	}
+
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		if value != nil {
+			return nil, SecretPathError{Key: key, Reason: "the value it is nested in is not an object"}
+		}
+		if newValue == nil {
+			return nil, nil
+		}
+		m = map[string]interface{}{}
+	}
+
+	if len(path) == 1 {
+		if newValue == nil {
+			delete(m, path[0])
+		} else {
+			m[path[0]] = newValue
+		}
+		return m, nil
+	}
+
+	child, err := setPath(m[path[0]], path[1:], newValue, key)
+	if err != nil {
+		return nil, err
+	}
+	if child != nil {
+		m[path[0]] = child
+	}
+	return m, nil
+}
@@ -2,13 +2,18 @@ package auth
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/raw-leak/configleam/internal/app/access/dto"
+	"github.com/raw-leak/configleam/internal/pkg/envorigin"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/secretscope"
 )
 
 const (
@@ -20,15 +25,41 @@ const (
 
 type AccessKeyContextKey struct{}
 
+// UnknownActor names who applied a change when the context was not authenticated, e.g. on internal operations
+const UnknownActor = "unknown"
+
+type actorContextKey struct{}
+
+// WithActor returns a copy of the context naming who applies its changes when they are not requested through an
+// access key, e.g. the git tag secrets are synchronized from
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// Actor returns the actor set on the context or else the name of the access key, or the subject of the client
+// certificate, the context was authenticated with
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	perms, ok := ctx.Value(AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+	if !ok || perms.Name == "" {
+		return UnknownActor
+	}
+	return perms.Name
+}
+
 type AccessService interface {
 	GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error)
 	GenerateAccessKey(ctx context.Context, accessKeyPerms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error)
-	DeleteAccessKeys(ctx context.Context, keys []string) error
+	DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error
 }
 
 type ConfigurationService interface {
 	GetEnvOriginal(ctx context.Context, env string) (string, bool, error)
 	IsEnvOriginal(ctx context.Context, env string) bool
+	ProjectExists(ctx context.Context, name string) bool
 }
 
 type Templates interface {
@@ -40,72 +71,238 @@ type PermissionsBuilder interface {
 	NewAccessKeyPermissions() *permissions.AccessKeyPermissions
 }
 
+type CertificateAuthorizer interface {
+	GetCertificatePermissions(cert *x509.Certificate) (*permissions.AccessKeyPermissions, bool)
+}
+
 // AuthMiddleware holds the service needed to validate permissions
 type AuthMiddleware struct {
 	configuration ConfigurationService
 	access        AccessService
 	perms         PermissionsBuilder
 	templates     Templates
+	certs         CertificateAuthorizer
 }
 
-// NewAuthMiddleware creates a new instance of AuthMiddleware
-func NewAuthMiddleware(access AccessService, configuration ConfigurationService, perms PermissionsBuilder, templates Templates) *AuthMiddleware {
+// NewAuthMiddleware creates a new instance of AuthMiddleware.
+// The certs authorizer is optional and only used for clients authenticated with a client certificate.
+func NewAuthMiddleware(access AccessService, configuration ConfigurationService, perms PermissionsBuilder, templates Templates, certs CertificateAuthorizer) *AuthMiddleware {
 	return &AuthMiddleware{
 		access:        access,
 		configuration: configuration,
 		perms:         perms,
 		templates:     templates,
+		certs:         certs,
 	}
 }
 
-// Guard creates a middleware that checks for the required permissions
+// Credentials identify the caller of a request, either by an access key or by a verified client certificate,
+// along with the project the request is addressed to
+type Credentials struct {
+	Project     string
+	AccessKey   string
+	Certificate *x509.Certificate
+}
+
+// AuthError is returned when a request can not be authorized, Status being the HTTP status it is answered with
+type AuthError struct {
+	Status  int
+	Message string
+}
+
+func (e AuthError) Error() string {
+	return e.Message
+}
+
+// Guard creates a middleware that checks for the required permissions within the requested project.
+// Access keys are scoped to the project they were generated in, except for the global admin keys of the
+// default project, which administrate the whole installation.
 func (m *AuthMiddleware) Guard(requiredPermission permissions.Operation) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			accessKey, query := r.Header.Get(AccessKeyHeader), r.URL.Query()
+			ctx, err := m.Authorize(r.Context(), m.requestCredentials(r), r.URL.Query().Get("env"), requiredPermission)
+			if err != nil {
+				writeAuthError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
 
-			if accessKey == "" {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+// GuardAuthenticated creates a middleware that only checks for a valid access key within the requested project,
+// leaving to the handler the permissions check of every environment it returns.
+func (m *AuthMiddleware) GuardAuthenticated() func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, _, err := m.Authenticate(r.Context(), m.requestCredentials(r))
+			if err != nil {
+				writeAuthError(w, err)
 				return
 			}
 
-			accessKeyPerms, ok, err := m.access.GetAccessKeyPermissions(r.Context(), accessKey)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// GuardGlobalAdmin creates a middleware that only lets global admins of the requested project through, for the
+// operations that are not bound to any environment.
+func (m *AuthMiddleware) GuardGlobalAdmin() func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, accessKeyPerms, err := m.Authenticate(r.Context(), m.requestCredentials(r))
 			if err != nil {
-				log.Println(w, "Error checking permissions")
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				writeAuthError(w, err)
 				return
 			}
-			if !ok {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+			if !accessKeyPerms.IsGlobalAdmin() {
+				writeAuthError(w, AuthError{Status: http.StatusForbidden, Message: "Forbidden"})
 				return
 			}
 
-			env := query.Get("env")
-			if !m.configuration.IsEnvOriginal(r.Context(), env) {
-				orgEnv, ok, err := m.configuration.GetEnvOriginal(r.Context(), env)
-				if err != nil {
-					log.Println(w, "Error reading original env:", err)
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-					return
-				}
-				if !ok {
-					http.Error(w, "Forbidden", http.StatusForbidden)
-					return
-				}
-				env = orgEnv
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// GuardOptional creates a middleware that lets anonymous requests through within the requested project, and checks
+// the credentials of the requests carrying some, leaving to the handler what they are allowed to see.
+func (m *AuthMiddleware) GuardOptional() func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			creds := m.requestCredentials(r)
+			if creds.AccessKey == "" && (creds.Certificate == nil || m.certs == nil) {
+				project.Resolve(next)(w, r)
+				return
 			}
 
-			if !accessKeyPerms.Can(env, requiredPermission) {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+			ctx, _, err := m.Authenticate(r.Context(), creds)
+			if err != nil {
+				writeAuthError(w, err)
 				return
 			}
 
-			ctxWithPermissions := context.WithValue(r.Context(), AccessKeyContextKey{}, *accessKeyPerms)
-			r = r.WithContext(ctxWithPermissions)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
 
-			next.ServeHTTP(w, r)
+// Authorize checks the credentials hold the required permission on the env within the project, a clone being
+// checked against the permissions of its original env and a shared secret scope against the permissions on its
+// '@<scope>' env. The returned context carries the project, the permissions and, for a clone, its original env.
+func (m *AuthMiddleware) Authorize(ctx context.Context, creds Credentials, env string, requiredPermission permissions.Operation) (context.Context, error) {
+	ctx, accessKeyPerms, err := m.Authenticate(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	// global admins administer the project without requesting an env, environment admins only the env they request
+	if requiredPermission == permissions.Admin && accessKeyPerms.IsGlobalAdmin() {
+		return ctx, nil
+	}
+
+	// shared secret scopes are granted as their own env, they are never clones
+	if !secretscope.Is(env) && !m.configuration.IsEnvOriginal(ctx, env) {
+		orgEnv, ok, err := m.configuration.GetEnvOriginal(ctx, env)
+		if err != nil {
+			log.Println("Error reading original env:", err)
+			return nil, AuthError{Status: http.StatusInternalServerError, Message: "Internal Server Error"}
+		}
+		if !ok {
+			return nil, AuthError{Status: http.StatusForbidden, Message: "Forbidden"}
 		}
+		ctx = envorigin.WithOriginal(ctx, env, orgEnv)
+		env = orgEnv
+	}
+
+	if !accessKeyPerms.Can(env, requiredPermission) {
+		return nil, AuthError{Status: http.StatusForbidden, Message: "Forbidden"}
 	}
+
+	return ctx, nil
+}
+
+// Authenticate resolves the requested project and the permissions of the access key or client certificate within it.
+// The returned context carries the project and the permissions.
+func (m *AuthMiddleware) Authenticate(ctx context.Context, creds Credentials) (context.Context, *permissions.AccessKeyPermissions, error) {
+	if err := project.Validate(creds.Project); err != nil {
+		return nil, nil, AuthError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	if creds.Project != project.Default && !m.configuration.ProjectExists(ctx, creds.Project) {
+		return nil, nil, AuthError{Status: http.StatusNotFound, Message: "Project not found"}
+	}
+	ctx = project.WithProject(ctx, creds.Project)
+
+	var accessKeyPerms *permissions.AccessKeyPermissions
+
+	if creds.AccessKey == "" {
+		if creds.Certificate == nil || m.certs == nil {
+			return nil, nil, AuthError{Status: http.StatusUnauthorized, Message: "Unauthorized"}
+		}
+
+		// certificates are granted permissions on the default project, only admins reach the others
+		certPerms, ok := m.certs.GetCertificatePermissions(creds.Certificate)
+		if !ok || (creds.Project != project.Default && !certPerms.IsGlobalAdmin()) {
+			return nil, nil, AuthError{Status: http.StatusForbidden, Message: "Forbidden"}
+		}
+		accessKeyPerms = certPerms
+	} else {
+		keyPerms, ok, err := m.access.GetAccessKeyPermissions(ctx, creds.AccessKey)
+		if err == nil && !ok && creds.Project != project.Default {
+			keyPerms, ok, err = m.installationAdminPermissions(ctx, creds.AccessKey)
+		}
+		if err != nil {
+			log.Println("Error checking permissions:", err)
+			return nil, nil, AuthError{Status: http.StatusInternalServerError, Message: "Internal Server Error"}
+		}
+		if !ok {
+			return nil, nil, AuthError{Status: http.StatusForbidden, Message: "Forbidden"}
+		}
+		accessKeyPerms = keyPerms
+	}
+
+	ctx = context.WithValue(ctx, AccessKeyContextKey{}, *accessKeyPerms)
+
+	return ctx, accessKeyPerms, nil
+}
+
+// requestCredentials reads the credentials from the access key header, or the verified client certificate, and the requested project
+func (m *AuthMiddleware) requestCredentials(r *http.Request) Credentials {
+	return Credentials{
+		Project:     project.FromRequest(r),
+		AccessKey:   r.Header.Get(AccessKeyHeader),
+		Certificate: m.verifiedClientCertificate(r),
+	}
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	var authErr AuthError
+	if errors.As(err, &authErr) {
+		http.Error(w, authErr.Message, authErr.Status)
+		return
+	}
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// installationAdminPermissions returns the permissions of an access key of the default project only if it is a global admin
+func (m *AuthMiddleware) installationAdminPermissions(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+	keyPerms, ok, err := m.access.GetAccessKeyPermissions(project.WithProject(ctx, project.Default), accessKey)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return keyPerms, keyPerms.IsGlobalAdmin(), nil
+}
+
+// verifiedClientCertificate returns the client certificate verified during the TLS handshake, if any
+func (m *AuthMiddleware) verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if m.certs == nil || r.TLS == nil || len(r.TLS.VerifiedChains) < 1 || len(r.TLS.VerifiedChains[0]) < 1 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
 }
 
 // GuardDashboard creates a middleware that checks for the required permissions for dashboard
@@ -226,7 +423,7 @@ func (m AuthMiddleware) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		MaxAge:  -1,
 	})
 
-	err = m.access.DeleteAccessKeys(r.Context(), []string{cookie.Value})
+	err = m.access.DeleteAccessKeysByPlaintext(r.Context(), []string{cookie.Value})
 	if err != nil {
 		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 		return
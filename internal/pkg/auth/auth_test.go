@@ -2,6 +2,9 @@ package auth_test
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +13,7 @@ import (
 	"github.com/raw-leak/configleam/internal/app/access/dto"
 	"github.com/raw-leak/configleam/internal/pkg/auth"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
 	"github.com/raw-leak/configleam/internal/pkg/transport/httpserver"
 	"github.com/stretchr/testify/suite"
 )
@@ -37,9 +41,9 @@ func (m *MockTemplates) LoginError(w http.ResponseWriter, errMsg string) {
 }
 
 type MockAccessService struct {
-	mockGetAccessKeyPermissions func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error)
-	mockGenerateAccessKey       func(ctx context.Context, perms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error)
-	mockDeleteAccessKeys        func(ctx context.Context, keys []string) error
+	mockGetAccessKeyPermissions     func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error)
+	mockGenerateAccessKey           func(ctx context.Context, perms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error)
+	mockDeleteAccessKeysByPlaintext func(ctx context.Context, keys []string) error
 }
 
 func (m *MockAccessService) GetAccessKeyPermissions(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
@@ -50,14 +54,15 @@ func (m *MockAccessService) GenerateAccessKey(ctx context.Context, perms dto.Acc
 	return m.mockGenerateAccessKey(ctx, perms)
 }
 
-func (m *MockAccessService) DeleteAccessKeys(ctx context.Context, keys []string) error {
-	return m.mockDeleteAccessKeys(ctx, keys)
+func (m *MockAccessService) DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error {
+	return m.mockDeleteAccessKeysByPlaintext(ctx, keys)
 }
 
 // ConfigurationService Mock
 type MockConfigurationService struct {
 	mockGetEnvOriginal func(ctx context.Context, env string) (string, bool, error)
 	mockIsEnvOriginal  func(ctx context.Context, env string) bool
+	mockProjectExists  func(ctx context.Context, name string) bool
 }
 
 func (m *MockConfigurationService) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
@@ -68,6 +73,10 @@ func (m *MockConfigurationService) IsEnvOriginal(ctx context.Context, env string
 	return m.mockIsEnvOriginal(ctx, env)
 }
 
+func (m *MockConfigurationService) ProjectExists(ctx context.Context, name string) bool {
+	return m.mockProjectExists(ctx, name)
+}
+
 func TestAuthMiddlewareTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthMiddlewareTestSuite))
 }
@@ -77,7 +86,7 @@ func (suite *AuthMiddlewareTestSuite) SetupTest() {
 	suite.access = &MockAccessService{}
 	suite.configuration = &MockConfigurationService{}
 	suite.perms = permissions.New()
-	suite.authMiddle = auth.NewAuthMiddleware(suite.access, suite.configuration, suite.perms, suite.templates)
+	suite.authMiddle = auth.NewAuthMiddleware(suite.access, suite.configuration, suite.perms, suite.templates, nil)
 }
 
 func (suite *AuthMiddlewareTestSuite) TestAuthMiddleware() {
@@ -386,6 +395,39 @@ func (suite *AuthMiddlewareTestSuite) TestAuthMiddleware() {
 			},
 			expectedStatus: http.StatusForbidden,
 		},
+		// environment admin operations, e.g. deleting access keys
+		{
+			name:               "Access granted for original environment with environment admin key and required admin",
+			accessKey:          "user-key",
+			requiredPermission: permissions.Admin,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.Grant("", permissions.EnvAdminAccess)
+					return perms, true, nil
+				}
+				suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+					return true
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Access denied for original environment with key without admin and required admin",
+			accessKey:          "user-key",
+			requiredPermission: permissions.Admin,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.Grant("", permissions.ReadConfig|permissions.CreateSecrets)
+					return perms, true, nil
+				}
+				suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+					return true
+				}
+			},
+			expectedStatus: http.StatusForbidden,
+		},
 		// Error handling
 		{
 			name:      "Error returned on getting cloned environment original",
@@ -437,3 +479,593 @@ func (suite *AuthMiddlewareTestSuite) TestAuthMiddleware() {
 		})
 	}
 }
+
+func (suite *AuthMiddlewareTestSuite) TestAuthMiddlewareProject() {
+	suite.configuration.mockProjectExists = func(ctx context.Context, name string) bool {
+		return name == "billing"
+	}
+	suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+		return project.FromContext(ctx) == "billing" && env == "develop"
+	}
+
+	testCases := []struct {
+		name               string
+		url                string
+		accessKey          string
+		requiredPermission permissions.Operation
+		prepareMock        func()
+		expectedStatus     int
+	}{
+		{
+			name:               "Access granted for project key within its project",
+			url:                "/?env=develop&project=billing",
+			accessKey:          "billing-key",
+			requiredPermission: permissions.ReadConfig,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					if project.FromContext(ctx) != "billing" {
+						return nil, false, nil
+					}
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.Grant("develop", permissions.ReadConfig)
+					return perms, true, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Access granted for project admin key and required admin",
+			url:                "/?env=develop&project=billing",
+			accessKey:          "billing-admin-key",
+			requiredPermission: permissions.Admin,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					if project.FromContext(ctx) != "billing" {
+						return nil, false, nil
+					}
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.SetAdmin()
+					return perms, true, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Access granted for installation admin key within a project",
+			url:                "/?env=develop&project=billing",
+			accessKey:          "admin-key",
+			requiredPermission: permissions.CreateSecrets,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					if project.FromContext(ctx) != project.Default {
+						return nil, false, nil
+					}
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.SetAdmin()
+					return perms, true, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Access denied for default project key without admin within a project",
+			url:                "/?env=develop&project=billing",
+			accessKey:          "user-key",
+			requiredPermission: permissions.ReadConfig,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					if project.FromContext(ctx) != project.Default {
+						return nil, false, nil
+					}
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.Grant("develop", permissions.ReadConfig)
+					return perms, true, nil
+				}
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:               "Access denied for project key within the default project",
+			url:                "/?env=develop",
+			accessKey:          "billing-key",
+			requiredPermission: permissions.ReadConfig,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					if project.FromContext(ctx) != "billing" {
+						return nil, false, nil
+					}
+					perms := suite.perms.NewAccessKeyPermissions()
+					perms.SetAdmin()
+					return perms, true, nil
+				}
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:               "Not found for unknown project",
+			url:                "/?env=develop&project=orders",
+			accessKey:          "admin-key",
+			requiredPermission: permissions.ReadConfig,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, errors.New("access key should not be resolved")
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:               "Bad request for invalid project",
+			url:                "/?env=develop&project=Billing",
+			accessKey:          "admin-key",
+			requiredPermission: permissions.ReadConfig,
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, errors.New("access key should not be resolved")
+				}
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			tc.prepareMock()
+
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			req.Header.Set("X-Access-Key", tc.accessKey)
+
+			rr := httptest.NewRecorder()
+
+			handler := suite.authMiddle.Guard(tc.requiredPermission)(func(w http.ResponseWriter, r *http.Request) {
+				suite.Equal(project.FromRequest(req), project.FromContext(r.Context()))
+				w.WriteHeader(http.StatusOK)
+			})
+
+			handler.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *AuthMiddlewareTestSuite) TestGuardGlobalAdmin() {
+	suite.configuration.mockProjectExists = func(ctx context.Context, name string) bool {
+		return name == "billing"
+	}
+	suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+		panic("global admin operations are not bound to any environment")
+	}
+
+	testCases := []struct {
+		name           string
+		url            string
+		perms          func() *permissions.AccessKeyPermissions
+		expectedStatus int
+	}{
+		{
+			name: "Access granted for global admin key",
+			url:  "/",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.SetAdmin()
+				return perms
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Access granted for project admin key within its project",
+			url:  "/?project=billing",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.SetAdmin()
+				return perms
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Access denied for environment admin key",
+			url:  "/?env=develop",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.Grant("develop", permissions.EnvAdminAccess|permissions.Admin)
+				return perms
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+				return tc.perms(), true, nil
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			req.Header.Set("X-Access-Key", "access-key")
+			rr := httptest.NewRecorder()
+
+			handler := suite.authMiddle.GuardGlobalAdmin()(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *AuthMiddlewareTestSuite) TestAuthMiddlewareSharedScope() {
+	// shared scopes are neither original envs nor clones
+	suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+		return env == "develop"
+	}
+	suite.configuration.mockGetEnvOriginal = func(ctx context.Context, env string) (string, bool, error) {
+		return "", false, nil
+	}
+
+	testCases := []struct {
+		name           string
+		perms          func() *permissions.AccessKeyPermissions
+		expectedStatus int
+	}{
+		{
+			name: "Access granted for global admin key",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.SetAdmin()
+				return perms
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Access granted for key with create secrets on the scope",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.Grant("@shared", permissions.CreateSecrets)
+				return perms
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Access denied for key with create secrets only on an env",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.Grant("develop", permissions.CreateSecrets)
+				return perms
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name: "Access denied for key with create secrets on another scope",
+			perms: func() *permissions.AccessKeyPermissions {
+				perms := suite.perms.NewAccessKeyPermissions()
+				perms.Grant("@payments", permissions.CreateSecrets)
+				return perms
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+				return tc.perms(), true, nil
+			}
+
+			req, _ := http.NewRequest("PUT", "/secrets?env=@shared", nil)
+			req.Header.Set("X-Access-Key", "access-key")
+
+			rr := httptest.NewRecorder()
+
+			handler := suite.authMiddle.Guard(permissions.CreateSecrets)(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			handler.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *AuthMiddlewareTestSuite) TestAuthMiddlewareClientCertificate() {
+	certs := auth.NewCertificatePermissions(map[string]permissions.AccessKeyPermissions{
+		"orders": {
+			Permissions: permissions.Permissions{"develop": permissions.ReadConfig},
+		},
+		"CN=billing,O=acme": {
+			Admin: true,
+		},
+	})
+	authMiddle := auth.NewAuthMiddleware(suite.access, suite.configuration, suite.perms, suite.templates, certs)
+
+	suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+		return true
+	}
+
+	testCases := []struct {
+		name               string
+		subject            *pkix.Name
+		verified           bool
+		requiredPermission permissions.Operation
+		expectedStatus     int
+	}{
+		{
+			name:               "Access granted for certificate common name with required permission",
+			subject:            &pkix.Name{CommonName: "orders"},
+			verified:           true,
+			requiredPermission: permissions.ReadConfig,
+			expectedStatus:     http.StatusOK,
+		},
+		{
+			name:               "Access granted for certificate full subject",
+			subject:            &pkix.Name{CommonName: "billing", Organization: []string{"acme"}},
+			verified:           true,
+			requiredPermission: permissions.CreateSecrets,
+			expectedStatus:     http.StatusOK,
+		},
+		{
+			name:               "Access denied for certificate without required permission",
+			subject:            &pkix.Name{CommonName: "orders"},
+			verified:           true,
+			requiredPermission: permissions.CreateSecrets,
+			expectedStatus:     http.StatusForbidden,
+		},
+		{
+			name:               "Access denied for unknown certificate subject",
+			subject:            &pkix.Name{CommonName: "unknown"},
+			verified:           true,
+			requiredPermission: permissions.ReadConfig,
+			expectedStatus:     http.StatusForbidden,
+		},
+		{
+			name:               "Unauthorized for a certificate that was not verified",
+			subject:            &pkix.Name{CommonName: "orders"},
+			verified:           false,
+			requiredPermission: permissions.ReadConfig,
+			expectedStatus:     http.StatusUnauthorized,
+		},
+		{
+			name:               "Unauthorized without access key nor certificate",
+			requiredPermission: permissions.ReadConfig,
+			expectedStatus:     http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			req, _ := http.NewRequest("GET", "/?env=develop", nil)
+
+			if tc.subject != nil {
+				cert := &x509.Certificate{Subject: *tc.subject}
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+				if tc.verified {
+					req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+				}
+			}
+
+			rr := httptest.NewRecorder()
+
+			handler := authMiddle.Guard(tc.requiredPermission)(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			handler.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *AuthMiddlewareTestSuite) TestGuardAuthenticated() {
+	suite.configuration.mockProjectExists = func(ctx context.Context, name string) bool {
+		return name == "billing"
+	}
+	suite.configuration.mockIsEnvOriginal = func(ctx context.Context, env string) bool {
+		suite.Fail("environments should not be checked")
+		return false
+	}
+
+	testCases := []struct {
+		name           string
+		url            string
+		accessKey      string
+		prepareMock    func()
+		expectedStatus int
+	}{
+		{
+			name:      "Access granted for a key without permissions on any environment",
+			url:       "/",
+			accessKey: "user-key",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return suite.perms.NewAccessKeyPermissions(), true, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:      "Access denied for an unknown key",
+			url:       "/",
+			accessKey: "unknown-key",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, nil
+				}
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:      "Unauthorized without key nor certificate",
+			url:       "/",
+			accessKey: "",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, errors.New("access key should not be resolved")
+				}
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "Not found for unknown project",
+			url:       "/?project=orders",
+			accessKey: "user-key",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, errors.New("access key should not be resolved")
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			tc.prepareMock()
+
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			req.Header.Set("X-Access-Key", tc.accessKey)
+
+			rr := httptest.NewRecorder()
+
+			handler := suite.authMiddle.GuardAuthenticated()(func(w http.ResponseWriter, r *http.Request) {
+				_, ok := r.Context().Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+				suite.True(ok)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			handler.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *AuthMiddlewareTestSuite) TestGuardOptional() {
+	suite.configuration.mockProjectExists = func(ctx context.Context, name string) bool {
+		return name == "billing"
+	}
+
+	testCases := []struct {
+		name            string
+		url             string
+		accessKey       string
+		prepareMock     func()
+		expectedStatus  int
+		expectedProject string
+		expectedPerms   bool
+	}{
+		{
+			name:      "Access granted without key nor certificate",
+			url:       "/?project=billing",
+			accessKey: "",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, errors.New("access key should not be resolved")
+				}
+			},
+			expectedStatus:  http.StatusOK,
+			expectedProject: "billing",
+		},
+		{
+			name:      "Access granted with the permissions of the key",
+			url:       "/",
+			accessKey: "user-key",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return suite.perms.NewAccessKeyPermissions(), true, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedPerms:  true,
+		},
+		{
+			name:      "Access denied for an unknown key",
+			url:       "/",
+			accessKey: "unknown-key",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, nil
+				}
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:      "Bad request for an invalid project",
+			url:       "/?project=billing:prod",
+			accessKey: "",
+			prepareMock: func() {
+				suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+					return nil, false, errors.New("access key should not be resolved")
+				}
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			tc.prepareMock()
+
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			req.Header.Set("X-Access-Key", tc.accessKey)
+
+			rr := httptest.NewRecorder()
+
+			handler := suite.authMiddle.GuardOptional()(func(w http.ResponseWriter, r *http.Request) {
+				_, ok := r.Context().Value(auth.AccessKeyContextKey{}).(permissions.AccessKeyPermissions)
+				suite.Equal(tc.expectedPerms, ok)
+				suite.Equal(tc.expectedProject, project.FromContext(r.Context()))
+				w.WriteHeader(http.StatusOK)
+			})
+
+			handler.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *AuthMiddlewareTestSuite) TestActor() {
+	certs := auth.NewCertificatePermissions(map[string]permissions.AccessKeyPermissions{
+		"orders": {Permissions: permissions.Permissions{"develop": permissions.ReadConfig}},
+	})
+	authMiddle := auth.NewAuthMiddleware(suite.access, suite.configuration, suite.perms, suite.templates, certs)
+
+	suite.access.mockGetAccessKeyPermissions = func(ctx context.Context, accessKey string) (*permissions.AccessKeyPermissions, bool, error) {
+		perms := suite.perms.NewAccessKeyPermissions()
+		perms.Name = "deployer"
+		return perms, true, nil
+	}
+
+	suite.Equal(auth.UnknownActor, auth.Actor(context.Background()))
+
+	ctx, _, err := authMiddle.Authenticate(context.Background(), auth.Credentials{AccessKey: "user-key"})
+	suite.Require().NoError(err)
+	suite.Equal("deployer", auth.Actor(ctx))
+
+	ctx, _, err = authMiddle.Authenticate(context.Background(), auth.Credentials{Certificate: &x509.Certificate{Subject: pkix.Name{CommonName: "orders"}}})
+	suite.Require().NoError(err)
+	suite.Equal("orders", auth.Actor(ctx))
+
+	suite.Equal("git:v1.2.0-prod", auth.Actor(auth.WithActor(ctx, "git:v1.2.0-prod")))
+}
+
+func (suite *AuthMiddlewareTestSuite) TestLogoutHandler() {
+	deleted := []string{}
+	suite.access.mockDeleteAccessKeysByPlaintext = func(ctx context.Context, keys []string) error {
+		deleted = append(deleted, keys...)
+		return nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard/logout", nil)
+	req.AddCookie(&http.Cookie{Name: auth.AccessKeyCookies, Value: "cfg_dashboard-key"})
+	rr := httptest.NewRecorder()
+
+	suite.authMiddle.LogoutHandler(rr, req)
+
+	// the cookie holds the access key as generated, which is hashed to find the key to remove
+	suite.Equal([]string{"cfg_dashboard-key"}, deleted)
+	suite.Equal(http.StatusSeeOther, rr.Code)
+	suite.Contains(rr.Header().Get("Set-Cookie"), "Max-Age=0")
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"crypto/x509"
+
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+)
+
+// CertificatePermissions maps client certificate subjects to access-key permissions, allowing
+// services authenticated with mTLS to be authorized without an access-key.
+type CertificatePermissions struct {
+	subjects map[string]permissions.AccessKeyPermissions
+}
+
+// NewCertificatePermissions creates CertificatePermissions from a subject to permissions map. The subject
+// can be either the full distinguished name (e.g. "CN=orders,O=acme") or just the common name.
+func NewCertificatePermissions(subjects map[string]permissions.AccessKeyPermissions) *CertificatePermissions {
+	cp := &CertificatePermissions{subjects: make(map[string]permissions.AccessKeyPermissions, len(subjects))}
+
+	for subject, perms := range subjects {
+		perms.Name = subject
+		cp.subjects[subject] = perms
+	}
+
+	return cp
+}
+
+// GetCertificatePermissions returns the permissions granted to the subject of a verified client certificate.
+func (cp *CertificatePermissions) GetCertificatePermissions(cert *x509.Certificate) (*permissions.AccessKeyPermissions, bool) {
+	if cert == nil {
+		return nil, false
+	}
+
+	for _, subject := range []string{cert.Subject.String(), cert.Subject.CommonName} {
+		if subject == "" {
+			continue
+		}
+
+		if perms, ok := cp.subjects[subject]; ok {
+			return &perms, true
+		}
+	}
+
+	return nil, false
+}
@@ -1,73 +1,357 @@
 package encryptor
 
 import (
+	"bytes"
 	"context"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"log"
 	"os"
+	"regexp"
+	"strings"
 
 	chacha20 "golang.org/x/crypto/chacha20poly1305"
 )
 
-// Encryptor holds the encryption key and pre-initialized AEAD instance
+const (
+	// KeysEnv lists the master keys as comma separated '<id>:<key>' pairs, the first one being the primary one
+	KeysEnv = "CG_ENCRYPTION_KEYS"
+	// KeyEnv is the single key used before master keys were introduced, it still decrypts the ciphertexts written with it
+	KeyEnv = "CG_ENCRYPTION_KEY"
+	// LookupKeyEnv is the key of the hashes the access keys are stored and looked up by, separate from the master keys
+	LookupKeyEnv = "CG_LOOKUP_KEY"
+
+	// DefaultKeyID identifies the master key provided with CG_ENCRYPTION_KEY alone
+	DefaultKeyID = "default"
+
+	maxKeyIDLength = 64
+
+	minLookupKeyLength = 32
+)
+
+// lookupKeyLabel derives the lookup key from CG_ENCRYPTION_KEY when CG_LOOKUP_KEY is not provided, a deprecated
+// fallback kept for the deployments whose access keys were hashed with it
+const lookupKeyLabel = "configleam access-key lookup"
+
+// envelopeMagic starts every envelope ciphertext, the ciphertexts without it were written before envelope encryption
+var envelopeMagic = []byte("cge1")
+
+// wrappedKeySize is the size of a data key sealed by a master key, along with its nonce
+const wrappedKeySize = chacha20.NonceSizeX + chacha20.KeySize + chacha20.Overhead
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// MasterKey wraps the data keys of the ciphertexts, the ID being written in their header
+type MasterKey struct {
+	ID  string
+	Key string
+}
+
+// UnknownKeyError is returned when a ciphertext was written with a master key that is not configured
+type UnknownKeyError struct {
+	ID string
+}
+
+func (e UnknownKeyError) Error() string {
+	return fmt.Sprintf("master key '%s' is not configured", e.ID)
+}
+
+// Encryptor encrypts every value with its own data key, wrapped by the primary master key and stored along with
+// the ciphertext. The other master keys only decrypt, so the primary one can be rotated without losing any data.
 type Encryptor struct {
-	aead     cipher.AEAD
-	detNonce []byte
+	primary string
+	masters map[string]cipher.AEAD
+
+	// legacy decrypts the ciphertexts written before envelope encryption, nil when CG_ENCRYPTION_KEY is not provided
+	legacy cipher.AEAD
+
+	lookup []byte
 }
 
-// NewEncryptor creates a new Encryptor instance with a given key.
+// NewEncryptor creates a new Encryptor instance with a given key, used as the only master key and to derive the lookup key.
 // The key must be exactly 32 bytes long for chacha20poly1305.
+// Without key, the master keys are read from CG_ENCRYPTION_KEYS, falling back to CG_ENCRYPTION_KEY, and the lookup key
+// from CG_LOOKUP_KEY.
 func NewEncryptor(key string) (*Encryptor, error) {
-	if key == "" {
-		key = os.Getenv("CG_ENCRYPTION_KEY")
+	if key != "" {
+		return NewEnvelopeEncryptor([]MasterKey{{ID: DefaultKeyID, Key: key}}, key, "")
 	}
 
-	if key == "" {
-		return nil, errors.New("CG_ENCRYPTION_KEY env is not provided")
+	legacyKey := os.Getenv(KeyEnv)
+
+	masterKeys, err := ParseMasterKeys(os.Getenv(KeysEnv))
+	if err != nil {
+		return nil, err
 	}
 
+	if len(masterKeys) == 0 {
+		if legacyKey == "" {
+			return nil, errors.New("CG_ENCRYPTION_KEYS nor CG_ENCRYPTION_KEY env is provided")
+		}
+		masterKeys = []MasterKey{{ID: DefaultKeyID, Key: legacyKey}}
+	}
+
+	return NewEnvelopeEncryptor(masterKeys, legacyKey, os.Getenv(LookupKeyEnv))
+}
+
+// NewEnvelopeEncryptor creates an Encryptor wrapping the data keys with the first master key, the others only
+// decrypting. The legacy key, when provided, decrypts the ciphertexts written before envelope encryption.
+// The lookup key keys the hashes of Hash. When not provided, it is derived from the legacy key, which is deprecated
+// and logged as such, since the access keys are then tied to an encryption key that can no longer be rotated.
+func NewEnvelopeEncryptor(masterKeys []MasterKey, legacyKey, lookupKey string) (*Encryptor, error) {
+	if len(masterKeys) == 0 {
+		return nil, errors.New("at least one master key must be provided")
+	}
+
+	e := &Encryptor{primary: masterKeys[0].ID, masters: make(map[string]cipher.AEAD, len(masterKeys))}
+
+	for _, masterKey := range masterKeys {
+		if len(masterKey.ID) > maxKeyIDLength || !keyIDPattern.MatchString(masterKey.ID) {
+			return nil, fmt.Errorf("master key id '%s' must be 1 to %d letters, digits, '_', '.' or '-'", masterKey.ID, maxKeyIDLength)
+		}
+		if _, ok := e.masters[masterKey.ID]; ok {
+			return nil, fmt.Errorf("master key id '%s' is duplicated", masterKey.ID)
+		}
+
+		aead, err := newAEAD(masterKey.Key)
+		if err != nil {
+			return nil, fmt.Errorf("master key '%s': %w", masterKey.ID, err)
+		}
+		e.masters[masterKey.ID] = aead
+	}
+
+	if legacyKey != "" {
+		legacy, err := newAEAD(legacyKey)
+		if err != nil {
+			return nil, err
+		}
+		e.legacy = legacy
+	}
+
+	switch {
+	case lookupKey != "":
+		if len(lookupKey) < minLookupKeyLength {
+			return nil, fmt.Errorf("lookup key must be at least %d bytes long", minLookupKeyLength)
+		}
+		e.lookup = []byte(lookupKey)
+	case legacyKey != "":
+		log.Printf("WARNING: %s env is not provided, the access-key lookup key is derived from %s. This fallback is deprecated "+
+			"and will be removed: %s can not be rotated while the access keys are hashed with it. Provide %s, of at least %d bytes, "+
+			"and generate the access keys again.", LookupKeyEnv, KeyEnv, KeyEnv, LookupKeyEnv, minLookupKeyLength)
+
+		mac := hmac.New(sha256.New, []byte(legacyKey))
+		mac.Write([]byte(lookupKeyLabel))
+		e.lookup = mac.Sum(nil)
+	default:
+		return nil, errors.New("CG_LOOKUP_KEY env must be provided when CG_ENCRYPTION_KEY is not")
+	}
+
+	return e, nil
+}
+
+// ParseMasterKeys parses comma separated '<id>:<key>' pairs, e.g. "2024-06:<32 bytes key>,2023-01:<32 bytes key>"
+func ParseMasterKeys(value string) ([]MasterKey, error) {
+	masterKeys := []MasterKey{}
+	if strings.TrimSpace(value) == "" {
+		return masterKeys, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		id, key, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found {
+			return nil, fmt.Errorf("master key '%s' must be formatted as '<id>:<key>'", id)
+		}
+		masterKeys = append(masterKeys, MasterKey{ID: id, Key: key})
+	}
+
+	return masterKeys, nil
+}
+
+func newAEAD(key string) (cipher.AEAD, error) {
 	if len(key) != chacha20.KeySize {
 		return nil, errors.New("key must be 32 bytes long")
 	}
+	return chacha20.NewX([]byte(key))
+}
 
-	aead, err := chacha20.NewX([]byte(key))
+// PrimaryKeyID returns the id of the master key wrapping the data keys of new ciphertexts
+func (e *Encryptor) PrimaryKeyID() string {
+	return e.primary
+}
+
+// Hash returns the HMAC-SHA256 of the plaintext keyed by the lookup key, so the same plaintext can be looked up
+// without being stored nor recoverable
+func (e *Encryptor) Hash(_ context.Context, plaintext []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, e.lookup)
+	mac.Write(plaintext)
+	return mac.Sum(nil), nil
+}
+
+// Encrypt encrypts the plaintext non-deterministically with a new data key, wrapped by the primary master key
+func (e *Encryptor) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, chacha20.KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := e.wrap(e.primary, dataKey)
 	if err != nil {
 		return nil, err
 	}
 
-	detNonce := []byte(key[:chacha20.NonceSizeX])
+	aead, err := chacha20.NewX(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, chacha20.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ciphertext = append(ciphertext, nonce...)
+	return aead.Seal(ciphertext, nonce, plaintext, envelopeMagic), nil
+}
+
+// Decrypt takes an encrypted string and returns the decrypted plaintext.
+func (e *Encryptor) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+	env, ok := parseEnvelope(ciphertext)
+	if !ok {
+		return e.decryptLegacy(ciphertext)
+	}
 
-	return &Encryptor{aead: aead, detNonce: detNonce}, nil
+	dataKey, err := e.unwrap(env)
+	if err != nil {
+		// a ciphertext written before envelope encryption may start as an envelope one by chance
+		if decrypted, legacyErr := e.decryptLegacy(ciphertext); legacyErr == nil {
+			return decrypted, nil
+		}
+		return nil, err
+	}
+
+	aead, err := chacha20.NewX(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, sealed := env.data[:chacha20.NonceSizeX], env.data[chacha20.NonceSizeX:]
+	return aead.Open(nil, nonce, sealed, envelopeMagic)
 }
 
-// EncryptDet encrypts the plaintext deterministically and returns it encrypted
-func (e *Encryptor) EncryptDet(_ context.Context, plaintext []byte) ([]byte, error) {
-	ciphertext := e.aead.Seal(e.detNonce, e.detNonce, []byte(plaintext), nil)
-	return ciphertext, nil
+// KeyID returns the id of the master key the ciphertext was written with, empty when written before envelope encryption
+func (e *Encryptor) KeyID(ciphertext []byte) string {
+	env, ok := parseEnvelope(ciphertext)
+	if !ok {
+		return ""
+	}
+	return env.keyID
 }
 
-// Encrypt encrypts the plaintext non-deterministically and returns it encrypted
-func (e *Encryptor) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
+// Rewrap returns the ciphertext with its data key wrapped by the primary master key, reporting whether it changed.
+// The data itself is not encrypted again, except for the ciphertexts written before envelope encryption.
+func (e *Encryptor) Rewrap(ctx context.Context, ciphertext []byte) ([]byte, bool, error) {
+	env, ok := parseEnvelope(ciphertext)
+	if ok && env.keyID == e.primary {
+		return ciphertext, false, nil
+	}
+
+	if ok {
+		dataKey, err := e.unwrap(env)
+		if err == nil {
+			rewrapped, err := e.wrap(e.primary, dataKey)
+			if err != nil {
+				return nil, false, err
+			}
+			return append(rewrapped, env.data...), true, nil
+		}
+	}
+
+	plaintext, err := e.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, false, err
+	}
+
+	encrypted, err := e.Encrypt(ctx, plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+	return encrypted, true, nil
+}
+
+// envelope is a parsed envelope ciphertext
+type envelope struct {
+	header     []byte
+	keyID      string
+	wrappedKey []byte
+	// nonce and sealed data
+	data []byte
+}
+
+// parseEnvelope splits a '<magic><id length><id><wrapped data key><nonce><sealed data>' ciphertext
+func parseEnvelope(ciphertext []byte) (envelope, bool) {
+	if len(ciphertext) < len(envelopeMagic)+1 || !bytes.HasPrefix(ciphertext, envelopeMagic) {
+		return envelope{}, false
+	}
+
+	idLength := int(ciphertext[len(envelopeMagic)])
+	headerLength := len(envelopeMagic) + 1 + idLength
+	if idLength == 0 || len(ciphertext) < headerLength+wrappedKeySize+chacha20.NonceSizeX+chacha20.Overhead {
+		return envelope{}, false
+	}
+
+	return envelope{
+		header:     ciphertext[:headerLength],
+		keyID:      string(ciphertext[len(envelopeMagic)+1 : headerLength]),
+		wrappedKey: ciphertext[headerLength : headerLength+wrappedKeySize],
+		data:       ciphertext[headerLength+wrappedKeySize:],
+	}, true
+}
+
+// wrap returns the header and the data key sealed by the master key, the header being authenticated along with it
+func (e *Encryptor) wrap(keyID string, dataKey []byte) ([]byte, error) {
+	header := make([]byte, 0, len(envelopeMagic)+1+len(keyID))
+	header = append(header, envelopeMagic...)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+
 	nonce := make([]byte, chacha20.NonceSizeX)
 	if _, err := rand.Read(nonce); err != nil {
 		return nil, err
 	}
 
-	ciphertext := e.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	ciphertext := append(header, nonce...)
+	return e.masters[keyID].Seal(ciphertext, nonce, dataKey, header), nil
+}
+
+// unwrap opens the data key of the envelope with the master key it was wrapped by
+func (e *Encryptor) unwrap(env envelope) ([]byte, error) {
+	master, ok := e.masters[env.keyID]
+	if !ok {
+		return nil, UnknownKeyError{ID: env.keyID}
+	}
 
-	return ciphertext, nil
+	nonce, sealed := env.wrappedKey[:chacha20.NonceSizeX], env.wrappedKey[chacha20.NonceSizeX:]
+	dataKey, err := master.Open(nil, nonce, sealed, env.header)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key with master key '%s': %w", env.keyID, err)
+	}
+	return dataKey, nil
 }
 
-// Decrypt takes an encrypted string and returns the decrypted plaintext.
-func (e *Encryptor) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+func (e *Encryptor) decryptLegacy(ciphertext []byte) ([]byte, error) {
+	if e.legacy == nil {
+		return nil, errors.New("ciphertext has no master key id and CG_ENCRYPTION_KEY is not provided to decrypt it")
+	}
 	if len(ciphertext) < chacha20.NonceSizeX {
 		return nil, errors.New("ciphertext too short")
 	}
 
 	nonce, encryptedMessage := ciphertext[:chacha20.NonceSizeX], ciphertext[chacha20.NonceSizeX:]
-
-	decrypted, err := e.aead.Open(nil, nonce, encryptedMessage, nil)
+	decrypted, err := e.legacy.Open(nil, nonce, encryptedMessage, nil)
 	if err != nil {
 		return nil, err
 	}
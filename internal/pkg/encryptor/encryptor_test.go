@@ -2,13 +2,16 @@ package encryptor_test
 
 import (
 	"context"
-	"encoding/base64"
+	"crypto/sha256"
 	"testing"
 
 	"github.com/raw-leak/configleam/internal/pkg/encryptor"
 	"github.com/stretchr/testify/suite"
+	chacha20 "golang.org/x/crypto/chacha20poly1305"
 )
 
+const lookupKey = "lookup-0123456789-0123456789-0123"
+
 type ConfigleamSecretsSuite struct {
 	suite.Suite
 	encryptor *encryptor.Encryptor
@@ -98,32 +101,11 @@ func (suite *ConfigleamSecretsSuite) TestEncryptDecrypt() {
 				}
 
 			}
-
-			// deterministic
-			encryptedData, err = suite.encryptor.EncryptDet(ctx, tc.plaintext)
-
-			if tc.expectedEncryptErr != nil {
-				suite.Require().EqualError(err, tc.expectedEncryptErr.Error())
-			} else {
-
-				suite.Require().NotEqual(tc.plaintext, encryptedData)
-
-				// Decrypt the encrypted data
-				decryptedData, err := suite.encryptor.Decrypt(ctx, encryptedData)
-
-				if tc.expectedDecryptErr != nil {
-					suite.Require().EqualError(err, tc.expectedDecryptErr.Error())
-				} else {
-					suite.Require().NoError(err)
-					suite.Require().Equal(string(tc.plaintext), string(decryptedData))
-				}
-
-			}
 		})
 	}
 }
 
-func (suite *ConfigleamSecretsSuite) TestEncryptDet() {
+func (suite *ConfigleamSecretsSuite) TestHash() {
 	testCases := []struct {
 		name      string
 		plaintext []byte
@@ -146,17 +128,167 @@ func (suite *ConfigleamSecretsSuite) TestEncryptDet() {
 		suite.Run(tc.name, func() {
 			ctx := context.Background()
 
-			encrypted1, err := suite.encryptor.EncryptDet(ctx, tc.plaintext)
+			hash1, err := suite.encryptor.Hash(ctx, tc.plaintext)
 			suite.NoError(err)
 
-			encrypted2, err := suite.encryptor.EncryptDet(ctx, tc.plaintext)
+			hash2, err := suite.encryptor.Hash(ctx, tc.plaintext)
 			suite.NoError(err)
 
-			base64Encoded1 := base64.StdEncoding.EncodeToString(encrypted1)
-			base64Encoded2 := base64.StdEncoding.EncodeToString(encrypted2)
+			suite.Equal(hash1, hash2)
+			suite.Len(hash1, sha256.Size)
+
+			other, err := suite.encryptor.Hash(ctx, append(tc.plaintext, '!'))
+			suite.NoError(err)
+			suite.NotEqual(hash1, other)
 
-			suite.Equal(encrypted1, encrypted2)
-			suite.Equal(base64Encoded1, base64Encoded2)
+			// neither the plaintext nor the key are part of the hash
+			suite.NotContains(string(hash1), string(tc.plaintext))
+			suite.NotContains(string(hash1), suite.key[:chacha20.NonceSizeX])
 		})
 	}
+
+	suite.Run("Hashes depend on the lookup key", func() {
+		ctx := context.Background()
+
+		keyed, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: suite.key}}, suite.key, lookupKey)
+		suite.Require().NoError(err)
+
+		hash1, err := suite.encryptor.Hash(ctx, []byte("cfg_key"))
+		suite.Require().NoError(err)
+		hash2, err := keyed.Hash(ctx, []byte("cfg_key"))
+		suite.Require().NoError(err)
+		suite.NotEqual(hash1, hash2)
+	})
+}
+
+func (suite *ConfigleamSecretsSuite) TestMasterKeyRotation() {
+	ctx := context.Background()
+
+	oldKey := encryptor.MasterKey{ID: "2023", Key: "abcdefghijklmnopqrstuvwxyz012345"}
+	newKey := encryptor.MasterKey{ID: "2024", Key: "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"}
+
+	before, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{oldKey}, "", lookupKey)
+	suite.Require().NoError(err)
+
+	ciphertext, err := before.Encrypt(ctx, []byte("db_password"))
+	suite.Require().NoError(err)
+	suite.Equal("2023", before.KeyID(ciphertext))
+
+	// the new key is the primary one, the old one still decrypting
+	during, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{newKey, oldKey}, "", lookupKey)
+	suite.Require().NoError(err)
+
+	decrypted, err := during.Decrypt(ctx, ciphertext)
+	suite.Require().NoError(err)
+	suite.Equal("db_password", string(decrypted))
+
+	rewrapped, changed, err := during.Rewrap(ctx, ciphertext)
+	suite.Require().NoError(err)
+	suite.True(changed)
+	suite.Equal("2024", during.KeyID(rewrapped))
+
+	_, changed, err = during.Rewrap(ctx, rewrapped)
+	suite.Require().NoError(err)
+	suite.False(changed)
+
+	// once rewrapped, the old key can be removed
+	after, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{newKey}, "", lookupKey)
+	suite.Require().NoError(err)
+
+	decrypted, err = after.Decrypt(ctx, rewrapped)
+	suite.Require().NoError(err)
+	suite.Equal("db_password", string(decrypted))
+
+	_, err = after.Decrypt(ctx, ciphertext)
+	suite.Equal(encryptor.UnknownKeyError{ID: "2023"}, err)
+}
+
+func (suite *ConfigleamSecretsSuite) TestLegacyCiphertexts() {
+	ctx := context.Background()
+
+	// ciphertexts written before envelope encryption are a nonce followed by the data sealed with the key itself
+	aead, err := chacha20.NewX([]byte(suite.key))
+	suite.Require().NoError(err)
+	nonce := make([]byte, chacha20.NonceSizeX)
+	legacy := aead.Seal(nonce, nonce, []byte("api_token"), nil)
+
+	rotated, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"}}, suite.key, "")
+	suite.Require().NoError(err)
+	suite.Empty(rotated.KeyID(legacy))
+
+	decrypted, err := rotated.Decrypt(ctx, legacy)
+	suite.Require().NoError(err)
+	suite.Equal("api_token", string(decrypted))
+
+	rewrapped, changed, err := rotated.Rewrap(ctx, legacy)
+	suite.Require().NoError(err)
+	suite.True(changed)
+	suite.Equal("2024", rotated.KeyID(rewrapped))
+
+	// the lookup key is derived from the legacy key, so the access keys are still found once master keys are added
+	hash, err := rotated.Hash(ctx, []byte("cfg_key"))
+	suite.Require().NoError(err)
+	expected, err := suite.encryptor.Hash(ctx, []byte("cfg_key"))
+	suite.Require().NoError(err)
+	suite.Equal(expected, hash)
+
+	withoutLegacy, err := encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"}}, "", lookupKey)
+	suite.Require().NoError(err)
+	_, err = withoutLegacy.Decrypt(ctx, legacy)
+	suite.Error(err)
+}
+
+func (suite *ConfigleamSecretsSuite) TestParseMasterKeys() {
+	testCases := []struct {
+		name        string
+		value       string
+		expected    []encryptor.MasterKey
+		expectedErr bool
+	}{
+		{name: "Empty value", value: "", expected: []encryptor.MasterKey{}},
+		{
+			name:  "Several keys, the key may hold the separator",
+			value: "2024:ABCDEFGHIJKLMNOPQRSTUVWXYZ012345, 2023:abc:defghijklmnopqrstuvwxyz012345",
+			expected: []encryptor.MasterKey{
+				{ID: "2024", Key: "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"},
+				{ID: "2023", Key: "abc:defghijklmnopqrstuvwxyz012345"},
+			},
+		},
+		{name: "Missing id", value: "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			keys, err := encryptor.ParseMasterKeys(tc.value)
+			if tc.expectedErr {
+				suite.Error(err)
+				return
+			}
+			suite.NoError(err)
+			suite.Equal(tc.expected, keys)
+		})
+	}
+}
+
+func (suite *ConfigleamSecretsSuite) TestInvalidMasterKeys() {
+	key := "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"
+
+	_, err := encryptor.NewEnvelopeEncryptor(nil, "", lookupKey)
+	suite.Error(err)
+
+	_, err = encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "a b", Key: key}}, "", lookupKey)
+	suite.Error(err)
+
+	_, err = encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: key}, {ID: "2024", Key: key}}, "", lookupKey)
+	suite.Error(err)
+
+	_, err = encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: "short"}}, "", lookupKey)
+	suite.Error(err)
+
+	// the lookup key can only be derived from the legacy key
+	_, err = encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: key}}, "", "")
+	suite.Error(err)
+
+	_, err = encryptor.NewEnvelopeEncryptor([]encryptor.MasterKey{{ID: "2024", Key: key}}, "", "short")
+	suite.Error(err)
 }
@@ -0,0 +1,25 @@
+package envorigin
+
+import "context"
+
+type ContextKey struct{}
+
+// clone is an env along with the original env it was cloned from
+type clone struct {
+	env      string
+	original string
+}
+
+// WithOriginal returns a copy of the context carrying the original env the env was cloned from
+func WithOriginal(ctx context.Context, env, original string) context.Context {
+	return context.WithValue(ctx, ContextKey{}, clone{env: env, original: original})
+}
+
+// Original returns the original env the env was cloned from when carried by the context, or the env itself
+func Original(ctx context.Context, env string) string {
+	c, ok := ctx.Value(ContextKey{}).(clone)
+	if !ok || c.env != env || c.original == "" {
+		return env
+	}
+	return c.original
+}
@@ -0,0 +1,26 @@
+package envorigin_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/raw-leak/configleam/internal/pkg/envorigin"
+)
+
+type EnvOriginSuite struct {
+	suite.Suite
+}
+
+func TestEnvOriginSuite(t *testing.T) {
+	suite.Run(t, new(EnvOriginSuite))
+}
+
+func (suite *EnvOriginSuite) TestOriginal() {
+	ctx := envorigin.WithOriginal(context.Background(), "develop-feature", "develop")
+
+	suite.Equal("develop", envorigin.Original(ctx, "develop-feature"))
+	suite.Equal("@shared", envorigin.Original(ctx, "@shared"), "other envs are their own original")
+	suite.Equal("develop-feature", envorigin.Original(context.Background(), "develop-feature"))
+}
@@ -5,9 +5,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -16,6 +17,7 @@ type EtcdConfig struct {
 	EtcdUsername string
 	EtcdPassword string
 	TLS          bool
+	TLSFiles     tlsconfig.Files
 }
 
 type Etcd struct {
@@ -26,18 +28,22 @@ func New(ctx context.Context, config EtcdConfig) (*Etcd, error) {
 	var tlsConfig *tls.Config
 
 	if config.TLS {
-		certPath := filepath.Join("certs", "etcd-cert.pem")
-		keyPath := filepath.Join("certs", "etcd-key.pem")
-
-		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		reloader, err := tlsconfig.NewReloader(config.TLSFiles, 0)
 		if err != nil {
 			log.Println("failed to load TLS certificate for etcd:", err)
 			return nil, fmt.Errorf("failed to load TLS certificate for etcd: %v", err)
 		}
 
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
+		// the members are verified against the host they are reached at, sent by the client as the server name,
+		// or any of the configured hosts when none is sent
+		serverNames := []string{config.TLSFiles.ServerName}
+		if config.TLSFiles.ServerName == "" {
+			serverNames = serverNames[:0]
+			for _, addr := range config.EtcdAddrs {
+				serverNames = append(serverNames, tlsconfig.Hostname(addr))
+			}
 		}
+		tlsConfig = reloader.ClientConfig(serverNames...)
 	}
 
 	client, err := clientv3.New(clientv3.Config{
@@ -68,3 +74,47 @@ func (e *Etcd) Disconnect(ctx context.Context) {
 		}
 	}
 }
+
+// RenamePrefix moves every key starting with '<from>:' under '<to>:' keeping its value and lease,
+// returning the amount of moved keys. Keys already under the destination prefix are left untouched.
+func (e *Etcd) RenamePrefix(ctx context.Context, from, to string) (int, error) {
+	res, err := e.Client.Get(ctx, from+":", clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("error fetching keys with prefix '%s': %v", from, err)
+	}
+
+	moved := 0
+	for _, kv := range res.Kvs {
+		key := string(kv.Key)
+		if strings.HasPrefix(key, to+":") {
+			continue
+		}
+
+		newKey := to + strings.TrimPrefix(key, from)
+
+		opts := []clientv3.OpOption{}
+		if kv.Lease != 0 {
+			opts = append(opts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+		}
+
+		// move only if neither the source changed nor the destination exists meanwhile
+		txnResp, err := e.Client.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision),
+				clientv3.Compare(clientv3.CreateRevision(newKey), "=", 0),
+			).
+			Then(clientv3.OpPut(newKey, string(kv.Value), opts...), clientv3.OpDelete(key)).
+			Commit()
+		if err != nil {
+			return moved, fmt.Errorf("error renaming key '%s': %v", key, err)
+		}
+		if !txnResp.Succeeded {
+			log.Printf("Skipped renaming key '%s' as it changed or '%s' already exists", key, newKey)
+			continue
+		}
+
+		moved++
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,48 @@
+package namespace
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Default is the root of every storage key when no namespace has been configured
+	Default = "configleam"
+
+	separator        = ":"
+	projectSeparator = "/"
+)
+
+// Namespace is the root under which all the storage keys of an installation are kept,
+// allowing several installations to share the same Redis or etcd.
+type Namespace string
+
+// Root returns the namespace root, falling back to the Default one when empty.
+func (n Namespace) Root() string {
+	if n == "" {
+		return Default
+	}
+	return string(n)
+}
+
+// Key joins the namespace root with the provided segments, e.g. Key("secret", "dev") returns "configleam:secret:dev".
+func (n Namespace) Key(segments ...string) string {
+	return strings.Join(append([]string{n.Root()}, segments...), separator)
+}
+
+// Project returns the namespace the keys of the provided project are kept under, e.g. Project("billing") returns "configleam/billing".
+// The default project, an empty name, is kept directly under the namespace root.
+func (n Namespace) Project(name string) Namespace {
+	if name == "" {
+		return n
+	}
+	return Namespace(n.Root() + projectSeparator + name)
+}
+
+// Validate checks the namespace can be used as the root of the storage keys
+func (n Namespace) Validate() error {
+	if strings.ContainsAny(string(n), separator+projectSeparator+"*?[] ") {
+		return fmt.Errorf("namespace '%s' must not contain separators, spaces or pattern characters", string(n))
+	}
+	return nil
+}
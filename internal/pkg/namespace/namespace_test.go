@@ -0,0 +1,72 @@
+package namespace_test
+
+import (
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/pkg/namespace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceKey(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace namespace.Namespace
+		segments  []string
+		expected  string
+	}{
+		{name: "Empty namespace falls back to the default one", namespace: "", segments: []string{"secret", "dev"}, expected: "configleam:secret:dev"},
+		{name: "Custom namespace is used as root", namespace: "payments", segments: []string{"config", "repo", "dev"}, expected: "payments:config:repo:dev"},
+		{name: "No segments returns the root", namespace: "payments", segments: nil, expected: "payments"},
+		{name: "Empty trailing segment keeps the separator", namespace: "payments", segments: []string{"env", ""}, expected: "payments:env:"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.namespace.Key(tc.segments...))
+		})
+	}
+}
+
+func TestNamespaceProject(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace namespace.Namespace
+		project   string
+		expected  string
+	}{
+		{name: "Default project keeps the namespace keys", namespace: "payments", project: "", expected: "payments:secret"},
+		{name: "Project is nested under the namespace root", namespace: "payments", project: "billing", expected: "payments/billing:secret"},
+		{name: "Project is nested under the default namespace", namespace: "", project: "billing", expected: "configleam/billing:secret"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.namespace.Project(tc.project).Key("secret"))
+		})
+	}
+}
+
+func TestNamespaceValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		namespace   namespace.Namespace
+		expectedErr bool
+	}{
+		{name: "Empty namespace is valid", namespace: "", expectedErr: false},
+		{name: "Plain namespace is valid", namespace: "payments-eu", expectedErr: false},
+		{name: "Namespace with separator is not valid", namespace: "payments:eu", expectedErr: true},
+		{name: "Namespace with project separator is not valid", namespace: "payments/eu", expectedErr: true},
+		{name: "Namespace with pattern characters is not valid", namespace: "payments*", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.namespace.Validate()
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package permissions
 
+import "github.com/raw-leak/configleam/internal/pkg/secretmode"
+
 type Operation uint
 
 const (
@@ -60,6 +62,10 @@ type Permissions map[string]Operation
 type AccessKeyPermissions struct {
 	Admin       bool
 	Permissions Permissions
+	// Name identifies who holds the permissions, recorded along with the changes they apply
+	Name string `json:",omitempty"`
+	// SecretMode is how the secrets are inserted into the configuration when a read does not request a mode
+	SecretMode secretmode.Mode `json:",omitempty"`
 }
 
 // Grant grants specified operations to a user for a given environment.
@@ -0,0 +1,67 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+const (
+	// Default is the project every request belongs to when none has been requested,
+	// its data is kept directly under the storage namespace
+	Default = ""
+
+	Header     = "X-Configleam-Project"
+	QueryParam = "project"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type ContextKey struct{}
+
+// WithProject returns a copy of the context carrying the provided project
+func WithProject(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ContextKey{}, name)
+}
+
+// FromContext returns the project carried by the context, or the Default one when there is none
+func FromContext(ctx context.Context) string {
+	name, ok := ctx.Value(ContextKey{}).(string)
+	if !ok {
+		return Default
+	}
+	return name
+}
+
+// FromRequest returns the project requested through the query parameter or, when missing, through the header
+func FromRequest(r *http.Request) string {
+	if name := r.URL.Query().Get(QueryParam); name != "" {
+		return name
+	}
+	return r.Header.Get(Header)
+}
+
+// Validate checks the project name can be used in URLs and as part of the storage keys
+func Validate(name string) error {
+	if name == Default {
+		return nil
+	}
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("project '%s' must consist of lower case alphanumeric characters or '-', and be at most 63 characters long", name)
+	}
+	return nil
+}
+
+// Resolve creates a middleware that validates the requested project and stores it in the request context
+func Resolve(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := FromRequest(r)
+		if err := Validate(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithProject(r.Context(), name)))
+	}
+}
@@ -0,0 +1,78 @@
+package project_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, project.Default, project.FromContext(context.Background()))
+	assert.Equal(t, "billing", project.FromContext(project.WithProject(context.Background(), "billing")))
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		project     string
+		expectedErr bool
+	}{
+		{name: "Default project is valid", project: "", expectedErr: false},
+		{name: "Plain project is valid", project: "billing-eu", expectedErr: false},
+		{name: "Project with upper case characters is not valid", project: "Billing", expectedErr: true},
+		{name: "Project starting with a dash is not valid", project: "-billing", expectedErr: true},
+		{name: "Project with separator is not valid", project: "billing:eu", expectedErr: true},
+		{name: "Project with path separator is not valid", project: "billing/eu", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := project.Validate(tc.project)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	testCases := []struct {
+		name            string
+		url             string
+		header          string
+		expectedStatus  int
+		expectedProject string
+	}{
+		{name: "Project is read from the query", url: "/?project=billing", header: "orders", expectedStatus: http.StatusOK, expectedProject: "billing"},
+		{name: "Project is read from the header", url: "/", header: "orders", expectedStatus: http.StatusOK, expectedProject: "orders"},
+		{name: "Default project is used when none is requested", url: "/", expectedStatus: http.StatusOK, expectedProject: project.Default},
+		{name: "Invalid project is rejected", url: "/?project=Billing", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			if tc.header != "" {
+				req.Header.Set(project.Header, tc.header)
+			}
+
+			var resolved string
+			handler := project.Resolve(func(w http.ResponseWriter, r *http.Request) {
+				resolved = project.FromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedProject, resolved)
+		})
+	}
+}
@@ -5,9 +5,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -17,6 +18,7 @@ type RedisConfig struct {
 	Username string
 	DB       int
 	TLS      bool
+	TLSFiles tlsconfig.Files
 }
 
 type Redis struct {
@@ -28,18 +30,17 @@ func New(ctx context.Context, config RedisConfig) (*Redis, error) {
 	var tlsConfig *tls.Config
 
 	if config.TLS {
-		certPath := filepath.Join("certs", "redis-cert.pem")
-		keyPath := filepath.Join("certs", "redis-key.pem")
-
-		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		reloader, err := tlsconfig.NewReloader(config.TLSFiles, 0)
 		if err != nil {
 			log.Println("failed to load TLS certificate for Redis:", err)
 			return nil, fmt.Errorf("failed to load TLS certificate for Redis: %v", err)
 		}
 
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
+		serverName := config.TLSFiles.ServerName
+		if serverName == "" {
+			serverName = tlsconfig.Hostname(config.Addr)
 		}
+		tlsConfig = reloader.ClientConfig(serverName)
 	}
 
 	rdb := redis.NewClient(&redis.Options{
@@ -71,3 +72,42 @@ func (r *Redis) Disconnect(ctx context.Context) {
 		}
 	}
 }
+
+// RenamePrefix moves every key starting with '<from>:' under '<to>:' keeping its value and TTL,
+// returning the amount of moved keys. Keys already under the destination prefix are left untouched.
+func (r *Redis) RenamePrefix(ctx context.Context, from, to string) (int, error) {
+	var (
+		cursor uint64
+		moved  int
+	)
+
+	for {
+		keys, next, err := r.Client.Scan(ctx, cursor, from+":*", 100).Result()
+		if err != nil {
+			return moved, fmt.Errorf("error scanning keys with prefix '%s': %v", from, err)
+		}
+
+		for _, key := range keys {
+			if strings.HasPrefix(key, to+":") {
+				continue
+			}
+
+			newKey := to + strings.TrimPrefix(key, from)
+
+			renamed, err := r.Client.RenameNX(ctx, key, newKey).Result()
+			if err != nil {
+				return moved, fmt.Errorf("error renaming key '%s': %v", key, err)
+			}
+			if !renamed {
+				log.Printf("Skipped renaming key '%s' as '%s' already exists", key, newKey)
+				continue
+			}
+			moved++
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return moved, nil
+		}
+	}
+}
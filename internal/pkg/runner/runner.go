@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"context"
+	"sync"
+)
+
+// Runner runs the background loop of a service, one at a time, until it is stopped.
+// Its zero value is ready to use.
+type Runner struct {
+	mux    sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start runs the loop in a goroutine with a context canceled by Stop, stopping the loop already running if any
+func (r *Runner) Start(ctx context.Context, loop func(ctx context.Context)) {
+	r.Stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	r.mux.Lock()
+	r.cancel, r.done = cancel, done
+	r.mux.Unlock()
+
+	go func() {
+		defer close(done)
+		loop(ctx)
+	}()
+}
+
+// Stop cancels the loop and waits for it to return, it does nothing when no loop is running
+func (r *Runner) Stop() {
+	r.mux.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mux.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,78 @@
+package runner_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/pkg/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerStop(t *testing.T) {
+	var r runner.Runner
+	var stopped atomic.Bool
+
+	started := make(chan struct{})
+	r.Start(context.Background(), func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		// Stop has to wait for the loop to return
+		time.Sleep(50 * time.Millisecond)
+		stopped.Store(true)
+	})
+
+	<-started
+	r.Stop()
+
+	assert.True(t, stopped.Load(), "Stop must wait for the loop to return")
+}
+
+func TestRunnerStopWithoutStart(t *testing.T) {
+	var r runner.Runner
+
+	assert.NotPanics(t, func() {
+		r.Stop()
+		r.Stop()
+	})
+}
+
+func TestRunnerStartStopsRunningLoop(t *testing.T) {
+	var r runner.Runner
+	var running atomic.Int32
+
+	loop := func(ctx context.Context) {
+		running.Add(1)
+		defer running.Add(-1)
+		<-ctx.Done()
+	}
+
+	r.Start(context.Background(), loop)
+	r.Start(context.Background(), loop)
+
+	assert.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	r.Stop()
+	assert.Equal(t, int32(0), running.Load())
+}
+
+func TestRunnerParentContext(t *testing.T) {
+	var r runner.Runner
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.Start(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		close(done)
+	})
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the loop must be canceled with its parent context")
+	}
+	r.Stop()
+}
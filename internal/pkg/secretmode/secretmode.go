@@ -0,0 +1,117 @@
+package secretmode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Mode tells how the secret placeholders of a configuration that cannot be resolved are replaced
+type Mode string
+
+const (
+	// Lenient replaces missing and redacted secrets with an empty string
+	Lenient Mode = "lenient"
+	// Strict fails the read, listing the placeholders that are missing or redacted
+	Strict Mode = "strict"
+	// Masked replaces redacted secrets with a marker, missing ones still being an empty string
+	Masked Mode = "masked"
+
+	// Header and QueryParam name the requested mode, unrelated to the 'strict' query parameter of the formats
+	Header     = "X-Configleam-Secret-Mode"
+	QueryParam = "secretMode"
+)
+
+const (
+	// MaskedValue replaces the redacted placeholders embedded in a string in Masked mode
+	MaskedValue = "****"
+	// MarkerKey names the secret a redacted value stands for, in the marker replacing whole placeholders in Masked mode
+	MarkerKey = "$secret"
+)
+
+// Reasons a placeholder is not resolved
+const (
+	ReasonMissing  = "missing"
+	ReasonRedacted = "redacted"
+)
+
+// InvalidModeError is returned when the requested mode is not supported
+type InvalidModeError struct {
+	Mode string
+}
+
+func (e InvalidModeError) Error() string {
+	return fmt.Sprintf("unknown secrets mode '%s', supported modes: %s, %s, %s", e.Mode, Lenient, Strict, Masked)
+}
+
+// Unresolved is a secret of the placeholders that could not be inserted, named as in the marker
+type Unresolved struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// UnresolvedError is returned in Strict mode when secrets of the placeholders of the configuration could not be resolved
+type UnresolvedError struct {
+	Unresolved []Unresolved
+}
+
+func (e UnresolvedError) Error() string {
+	keys := make([]string, 0, len(e.Unresolved))
+	for _, u := range e.Unresolved {
+		keys = append(keys, fmt.Sprintf("%s (%s)", u.Key, u.Reason))
+	}
+	return fmt.Sprintf("%d secrets could not be resolved: %s", len(e.Unresolved), strings.Join(keys, ", "))
+}
+
+// Marker returns the value replacing a redacted placeholder that makes up a whole value in Masked mode
+func Marker(name string) map[string]interface{} {
+	return map[string]interface{}{MarkerKey: name, "redacted": true}
+}
+
+// Parse returns the mode named by the string, the empty string being no mode at all
+func Parse(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(s)); mode {
+	case "", Lenient, Strict, Masked:
+		return mode, nil
+	default:
+		return "", InvalidModeError{Mode: s}
+	}
+}
+
+type ContextKey struct{}
+
+// WithMode returns a copy of the context carrying the provided mode
+func WithMode(ctx context.Context, mode Mode) context.Context {
+	return context.WithValue(ctx, ContextKey{}, mode)
+}
+
+// FromContext returns the mode carried by the context, the empty mode when there is none
+func FromContext(ctx context.Context) Mode {
+	mode, _ := ctx.Value(ContextKey{}).(Mode)
+	return mode
+}
+
+// FromRequest returns the mode requested through the query parameter or, when missing, through the header
+func FromRequest(r *http.Request) string {
+	if mode := r.URL.Query().Get(QueryParam); mode != "" {
+		return mode
+	}
+	return r.Header.Get(Header)
+}
+
+// Resolve creates a middleware that validates the requested mode and stores it in the request context
+func Resolve(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode, err := Parse(FromRequest(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if mode != "" {
+			r = r.WithContext(WithMode(r.Context(), mode))
+		}
+		next.ServeHTTP(w, r)
+	}
+}
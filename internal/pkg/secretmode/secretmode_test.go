@@ -0,0 +1,78 @@
+package secretmode_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mode        string
+		expected    secretmode.Mode
+		expectedErr bool
+	}{
+		{name: "No mode", mode: "", expected: ""},
+		{name: "Lenient", mode: "lenient", expected: secretmode.Lenient},
+		{name: "Strict", mode: "strict", expected: secretmode.Strict},
+		{name: "Masked in upper case", mode: "MASKED", expected: secretmode.Masked},
+		{name: "Unknown mode", mode: "quiet", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mode, err := secretmode.Parse(tc.mode)
+			if tc.expectedErr {
+				assert.ErrorAs(t, err, &secretmode.InvalidModeError{})
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, mode)
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, secretmode.Mode(""), secretmode.FromContext(context.Background()))
+	assert.Equal(t, secretmode.Strict, secretmode.FromContext(secretmode.WithMode(context.Background(), secretmode.Strict)))
+}
+
+func TestResolve(t *testing.T) {
+	testCases := []struct {
+		name           string
+		target         string
+		header         string
+		expectedStatus int
+		expectedMode   secretmode.Mode
+	}{
+		{name: "No mode requested", target: "/config", expectedStatus: http.StatusOK, expectedMode: ""},
+		{name: "Mode from the query", target: "/config?secretMode=strict", header: "masked", expectedStatus: http.StatusOK, expectedMode: secretmode.Strict},
+		{name: "Mode from the header", target: "/config", header: "masked", expectedStatus: http.StatusOK, expectedMode: secretmode.Masked},
+		{name: "Unknown mode", target: "/config?secretMode=quiet", expectedStatus: http.StatusBadRequest},
+		{name: "Strict formatting is not a mode", target: "/config?strict=true", expectedStatus: http.StatusOK, expectedMode: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mode secretmode.Mode
+			handler := secretmode.Resolve(func(w http.ResponseWriter, r *http.Request) {
+				mode = secretmode.FromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.header != "" {
+				req.Header.Set(secretmode.Header, tc.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedMode, mode)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package secretscope
+
+import "strings"
+
+// Prefix marks the environment a shared secret scope is stored, managed and granted as, e.g. "@shared"
+const Prefix = "@"
+
+// Env returns the environment the secrets of the scope are stored as
+func Env(scope string) string {
+	return Prefix + scope
+}
+
+// Is reports whether the environment is a shared secret scope rather than a configuration environment
+func Is(env string) bool {
+	return strings.HasPrefix(env, Prefix)
+}
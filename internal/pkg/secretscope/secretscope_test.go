@@ -0,0 +1,16 @@
+package secretscope_test
+
+import (
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/pkg/secretscope"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIs(t *testing.T) {
+	assert.True(t, secretscope.Is("@shared"))
+	assert.True(t, secretscope.Is(secretscope.Env("billing")))
+	assert.False(t, secretscope.Is("develop"))
+	assert.False(t, secretscope.Is("develop@shared"))
+	assert.False(t, secretscope.Is(""))
+}
@@ -0,0 +1,287 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultReloadInterval = 30 * time.Second
+)
+
+// Files holds the paths of the TLS material used by a single component, along with the name its server is verified against.
+type Files struct {
+	CertFile string // PEM encoded certificate (chain) presented by the component
+	KeyFile  string // PEM encoded private key of the certificate
+	CAFile   string // PEM encoded CA bundle used to verify the remote peer
+
+	// ServerName is the name the certificate of the remote server is verified against, the host it is reached at by default
+	ServerName string
+}
+
+// Reloader keeps a certificate key-pair and a CA bundle loaded from disk and reloads them
+// whenever the files change, so rotated certificates are picked up without a restart.
+type Reloader struct {
+	files    Files
+	interval time.Duration
+
+	mux       sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// NewReloader loads the provided files and returns a Reloader for them.
+// An empty CertFile/KeyFile or CAFile is allowed and simply means that part is not used.
+func NewReloader(files Files, interval time.Duration) (*Reloader, error) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, errors.New("both certificate and key files must be provided")
+	}
+
+	r := &Reloader{files: files, interval: interval, modTimes: map[string]time.Time{}}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ServerConfig builds a server side *tls.Config. When a CA bundle is configured, clients presenting a
+// certificate are verified against it (mTLS), while clients without a certificate are still accepted
+// so they can authenticate by other means.
+func (r *Reloader) ServerConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate()
+		},
+	}
+
+	if r.files.CAFile == "" {
+		return base
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = r.CertPool()
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		return cfg, nil
+	}
+
+	return base
+}
+
+// ClientConfig builds a client side *tls.Config that presents the client certificate (if any)
+// and verifies the server against the configured CA bundle (if any), both reloaded on rotation.
+// The server certificate must be valid for the server name sent by the connection or, when none is sent
+// (e.g. to an IP address), for one of the server names, a single one being sent as the server name.
+func (r *Reloader) ClientConfig(serverNames ...string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(serverNames) == 1 {
+		cfg.ServerName = serverNames[0]
+	}
+
+	if r.files.CertFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate()
+		}
+	}
+
+	if r.files.CAFile != "" {
+		// the default verification is replaced with one that uses the current CA bundle,
+		// as tls.Config.RootCAs can not be swapped once the config is in use
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return r.verifyServer(cs, serverNames)
+		}
+	}
+
+	return cfg
+}
+
+// Certificate returns the current certificate, reloading it first if it changed on disk.
+func (r *Reloader) Certificate() (*tls.Certificate, error) {
+	r.reloadIfChanged()
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	if r.cert == nil {
+		return nil, errors.New("no certificate has been configured")
+	}
+
+	return r.cert, nil
+}
+
+// CertPool returns the current CA bundle, reloading it first if it changed on disk.
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.reloadIfChanged()
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.pool
+}
+
+func (r *Reloader) verifyServer(cs tls.ConnectionState, serverNames []string) error {
+	if len(cs.PeerCertificates) < 1 {
+		return errors.New("server did not present any certificate")
+	}
+
+	if cs.ServerName != "" {
+		serverNames = []string{cs.ServerName}
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         r.CertPool(),
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	// any certificate signed by the CA would be accepted without a name to verify it against
+	err := errors.New("no server name to verify the server certificate against")
+	for _, serverName := range serverNames {
+		if serverName == "" {
+			continue
+		}
+
+		opts.DNSName = serverName
+		if _, err = cs.PeerCertificates[0].Verify(opts); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Hostname returns the host of an address, either 'host:port' or a URL, to verify the server certificate against
+func Hostname(addr string) string {
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (r *Reloader) reloadIfChanged() {
+	r.mux.RLock()
+	due := time.Since(r.lastCheck) >= r.interval
+	r.mux.RUnlock()
+
+	if !due {
+		return
+	}
+
+	r.mux.Lock()
+	r.lastCheck = time.Now()
+	changed := r.filesChanged()
+	r.mux.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		// keep serving with the previous material, the rotation may still be in progress
+		log.Printf("Error reloading TLS material, keeping the previous one: %v", err)
+		return
+	}
+
+	log.Printf("Reloaded TLS material from '%s'", r.describe())
+}
+
+// filesChanged must be called holding the lock.
+func (r *Reloader) filesChanged() bool {
+	for _, path := range r.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if !info.ModTime().Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Reloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, path := range r.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS file '%s': %v", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.files.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.files.CAFile != "" {
+		pem, err := os.ReadFile(r.files.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle '%s': %v", r.files.CAFile, err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificates found in CA bundle '%s'", r.files.CAFile)
+		}
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.cert = cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+
+	return nil
+}
+
+func (r *Reloader) paths() []string {
+	paths := []string{}
+	for _, path := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func (r *Reloader) describe() string {
+	if r.files.CertFile != "" {
+		return r.files.CertFile
+	}
+	return r.files.CAFile
+}
@@ -0,0 +1,281 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
+	"github.com/stretchr/testify/suite"
+)
+
+type TLSConfigSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestTLSConfigSuite(t *testing.T) {
+	suite.Run(t, new(TLSConfigSuite))
+}
+
+func (suite *TLSConfigSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+// writeCert generates a self-signed certificate with the provided common name and writes it to disk
+func (suite *TLSConfigSuite) writeCert(cn string, modTime time.Time) tlsconfig.Files {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Require().NoError(err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	suite.Require().NoError(err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	suite.Require().NoError(err)
+
+	files := tlsconfig.Files{
+		CertFile: filepath.Join(suite.dir, "cert.pem"),
+		KeyFile:  filepath.Join(suite.dir, "key.pem"),
+		CAFile:   filepath.Join(suite.dir, "ca.pem"),
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	suite.Require().NoError(os.WriteFile(files.CertFile, certPem, 0600))
+	suite.Require().NoError(os.WriteFile(files.KeyFile, keyPem, 0600))
+	suite.Require().NoError(os.WriteFile(files.CAFile, certPem, 0600))
+
+	for _, path := range []string{files.CertFile, files.KeyFile, files.CAFile} {
+		suite.Require().NoError(os.Chtimes(path, modTime, modTime))
+	}
+
+	return files
+}
+
+func (suite *TLSConfigSuite) TestNewReloader() {
+	testCases := []struct {
+		name        string
+		files       func() tlsconfig.Files
+		expectedErr bool
+	}{
+		{
+			name:        "Loads certificate, key and CA bundle",
+			files:       func() tlsconfig.Files { return suite.writeCert("configleam", time.Now()) },
+			expectedErr: false,
+		},
+		{
+			name: "Loads only a CA bundle",
+			files: func() tlsconfig.Files {
+				files := suite.writeCert("configleam", time.Now())
+				return tlsconfig.Files{CAFile: files.CAFile}
+			},
+			expectedErr: false,
+		},
+		{
+			name: "Fails when the key file is missing",
+			files: func() tlsconfig.Files {
+				files := suite.writeCert("configleam", time.Now())
+				return tlsconfig.Files{CertFile: files.CertFile}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Fails when the files do not exist",
+			files: func() tlsconfig.Files {
+				return tlsconfig.Files{CertFile: "missing-cert.pem", KeyFile: "missing-key.pem"}
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			_, err := tlsconfig.NewReloader(tc.files(), time.Minute)
+			if tc.expectedErr {
+				suite.Error(err)
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *TLSConfigSuite) TestReloadRotatedCertificate() {
+	files := suite.writeCert("first", time.Now().Add(-time.Hour))
+
+	reloader, err := tlsconfig.NewReloader(files, time.Nanosecond)
+	suite.Require().NoError(err)
+
+	cert, err := reloader.Certificate()
+	suite.Require().NoError(err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	suite.Require().NoError(err)
+	suite.Equal("first", leaf.Subject.CommonName)
+
+	// rotate
+	suite.writeCert("second", time.Now())
+
+	cert, err = reloader.Certificate()
+	suite.Require().NoError(err)
+
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	suite.Require().NoError(err)
+	suite.Equal("second", leaf.Subject.CommonName)
+
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: reloader.CertPool()})
+	suite.NoError(err, "the rotated CA bundle should verify the rotated certificate")
+}
+
+func (suite *TLSConfigSuite) TestKeepPreviousCertificateOnInvalidRotation() {
+	files := suite.writeCert("first", time.Now().Add(-time.Hour))
+
+	reloader, err := tlsconfig.NewReloader(files, time.Nanosecond)
+	suite.Require().NoError(err)
+
+	// half written rotation
+	suite.Require().NoError(os.WriteFile(files.CertFile, []byte("garbage"), 0600))
+
+	cert, err := reloader.Certificate()
+	suite.Require().NoError(err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	suite.Require().NoError(err)
+	suite.Equal("first", leaf.Subject.CommonName)
+}
+
+func (suite *TLSConfigSuite) TestServerConfigRequestsClientCertificates() {
+	files := suite.writeCert("configleam", time.Now())
+
+	reloader, err := tlsconfig.NewReloader(files, time.Minute)
+	suite.Require().NoError(err)
+
+	cfg := reloader.ServerConfig()
+	suite.Require().NotNil(cfg.GetConfigForClient)
+
+	clientCfg, err := cfg.GetConfigForClient(nil)
+	suite.Require().NoError(err)
+	suite.NotNil(clientCfg.ClientCAs)
+
+	withoutCA, err := tlsconfig.NewReloader(tlsconfig.Files{CertFile: files.CertFile, KeyFile: files.KeyFile}, time.Minute)
+	suite.Require().NoError(err)
+	suite.Nil(withoutCA.ServerConfig().GetConfigForClient)
+}
+
+// writeCA generates a CA, writes its certificate to disk as the CA bundle and returns it along with its key
+func (suite *TLSConfigSuite) writeCA() (tlsconfig.Files, *x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Require().NoError(err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "configleam-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	suite.Require().NoError(err)
+
+	ca, err := x509.ParseCertificate(der)
+	suite.Require().NoError(err)
+
+	files := tlsconfig.Files{CAFile: filepath.Join(suite.dir, "ca.pem")}
+	suite.Require().NoError(os.WriteFile(files.CAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	return files, ca, key
+}
+
+// issueCert issues a server certificate for the DNS name signed by the CA
+func (suite *TLSConfigSuite) issueCert(ca *x509.Certificate, caKey *ecdsa.PrivateKey, dnsName string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Require().NoError(err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	suite.Require().NoError(err)
+
+	cert, err := x509.ParseCertificate(der)
+	suite.Require().NoError(err)
+	return cert
+}
+
+func (suite *TLSConfigSuite) TestClientConfigVerifiesServerName() {
+	files, ca, caKey := suite.writeCA()
+
+	reloader, err := tlsconfig.NewReloader(files, time.Minute)
+	suite.Require().NoError(err)
+
+	redisCert := suite.issueCert(ca, caKey, "redis.internal")
+	otherCert := suite.issueCert(ca, caKey, "other.internal")
+
+	testCases := []struct {
+		name        string
+		serverNames []string
+		sent        string
+		cert        *x509.Certificate
+		expectedErr bool
+	}{
+		{name: "Accepts the certificate of the server name", serverNames: []string{"redis.internal"}, sent: "redis.internal", cert: redisCert},
+		{name: "Rejects a certificate of the same CA for another host", serverNames: []string{"redis.internal"}, sent: "redis.internal", cert: otherCert, expectedErr: true},
+		{name: "Accepts the certificate of any server name when none is sent", serverNames: []string{"etcd-0.internal", "redis.internal"}, cert: redisCert},
+		{name: "Rejects a certificate of none of the server names when none is sent", serverNames: []string{"etcd-0.internal", "etcd-1.internal"}, cert: otherCert, expectedErr: true},
+		{name: "Verifies the sent server name over the others", serverNames: []string{"other.internal", "redis.internal"}, sent: "redis.internal", cert: otherCert, expectedErr: true},
+		{name: "Rejects any certificate without server name", cert: redisCert, expectedErr: true},
+		{name: "Rejects any certificate with an empty server name", serverNames: []string{""}, cert: redisCert, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			cfg := reloader.ClientConfig(tc.serverNames...)
+			suite.Require().NotNil(cfg.VerifyConnection)
+
+			err := cfg.VerifyConnection(tls.ConnectionState{ServerName: tc.sent, PeerCertificates: []*x509.Certificate{tc.cert}})
+			if tc.expectedErr {
+				suite.Error(err)
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *TLSConfigSuite) TestHostname() {
+	suite.Equal("redis.internal", tlsconfig.Hostname("redis.internal:6379"))
+	suite.Equal("10.0.0.1", tlsconfig.Hostname("10.0.0.1:6379"))
+	suite.Equal("etcd-0.internal", tlsconfig.Hostname("https://etcd-0.internal:2379"))
+	suite.Equal("::1", tlsconfig.Hostname("[::1]:2379"))
+	suite.Equal("redis.internal", tlsconfig.Hostname("redis.internal"))
+}
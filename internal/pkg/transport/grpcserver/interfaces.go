@@ -0,0 +1,38 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/raw-leak/configleam/internal/app/access/dto"
+	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	notify "github.com/raw-leak/configleam/internal/app/notify/service"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+)
+
+// configuration
+type ConfigurationService interface {
+	IsEnvOriginal(ctx context.Context, env string) bool
+	GetEnvOriginal(ctx context.Context, env string) (string, bool, error)
+	ProjectExists(ctx context.Context, name string) bool
+	ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error)
+	ListEnvs(ctx context.Context) ([]repository.EnvParams, error)
+}
+
+// access
+type AccessService interface {
+	GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error)
+	GenerateAccessKey(ctx context.Context, accessKeyPerms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error)
+	DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error
+}
+
+// notify
+type NotifyService interface {
+	Subscribe(ctx context.Context, env string) *notify.Client
+	Unsubscribe(ctx context.Context, client *notify.Client)
+}
+
+// other
+type PermissionsBuilder interface {
+	NewAccessKeyPermissions() *permissions.AccessKeyPermissions
+}
@@ -0,0 +1,309 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	"github.com/raw-leak/configleam/internal/pkg/auth"
+	p "github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
+	configleamv1 "github.com/raw-leak/configleam/pkg/api/configleam/v1"
+)
+
+var (
+	// AccessKeyMetadata carries the access key of the caller, the metadata counterpart of the 'X-Access-Key' header
+	AccessKeyMetadata = strings.ToLower(auth.AccessKeyHeader)
+	// ProjectMetadata carries the project the call is addressed to, the metadata counterpart of the 'X-Configleam-Project' header
+	ProjectMetadata = strings.ToLower(project.Header)
+	// SecretsMetadata carries the secrets mode of the read, the metadata counterpart of the 'X-Configleam-Secret-Mode' header
+	SecretsMetadata = strings.ToLower(secretmode.Header)
+)
+
+type grpcServer struct {
+	configleamv1.UnimplementedConfigServiceServer
+
+	server *grpc.Server
+
+	configuration ConfigurationService
+	notify        NotifyService
+	auth          *auth.AuthMiddleware
+}
+
+// NewGrpcServer creates the gRPC server, certs is optional and only required to authorize clients by their certificate
+func NewGrpcServer(configuration ConfigurationService, notify NotifyService, access AccessService, permissions PermissionsBuilder, certs auth.CertificateAuthorizer) *grpcServer {
+	return &grpcServer{
+		configuration: configuration,
+		notify:        notify,
+		auth:          auth.NewAuthMiddleware(access, configuration, permissions, nil, certs),
+	}
+}
+
+func (s *grpcServer) ListenAndServe(grpcAddr string, enableTls bool, tlsFiles tlsconfig.Files) error {
+	opts := []grpc.ServerOption{}
+
+	if enableTls {
+		reloader, err := tlsconfig.NewReloader(tlsFiles, 0)
+		if err != nil {
+			log.Println("failed to load TLS certificate:", err)
+			return fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcAddr))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %v", grpcAddr, err)
+	}
+
+	if enableTls {
+		log.Printf("Starting gRPC server with TLS on port %s\n", grpcAddr)
+	} else {
+		log.Printf("Starting gRPC server on port %s\n", grpcAddr)
+	}
+
+	return s.Serve(lis, opts...)
+}
+
+// Serve accepts connections on the listener, which allows serving through an in-process listener
+func (s *grpcServer) Serve(lis net.Listener, opts ...grpc.ServerOption) error {
+	s.server = grpc.NewServer(opts...)
+	configleamv1.RegisterConfigServiceServer(s.server, s)
+
+	return s.server.Serve(lis)
+}
+
+// Shutdown waits for the ongoing calls to finish, cancelling them once the context is done
+func (s *grpcServer) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *grpcServer) GetConfig(ctx context.Context, req *configleamv1.GetConfigRequest) (*configleamv1.GetConfigResponse, error) {
+	fields, err := selector.ParseAll(req.GetSelect())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, err = withSecretMode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = s.auth.Authorize(ctx, s.credentials(ctx), req.GetEnv(), p.ReadConfig)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	cfg, version, err := s.readConfig(ctx, req.GetEnv(), req.GetVersion(), req.GetGroups(), req.GetGlobals(), fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &configleamv1.GetConfigResponse{Env: req.GetEnv(), Version: version, Config: cfg}, nil
+}
+
+func (s *grpcServer) ListEnvs(ctx context.Context, req *configleamv1.ListEnvsRequest) (*configleamv1.ListEnvsResponse, error) {
+	ctx, _, err := s.auth.Authenticate(ctx, s.credentials(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	envs, err := s.configuration.ListEnvs(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &configleamv1.ListEnvsResponse{Envs: make([]*configleamv1.Env, 0, len(envs))}
+	for _, env := range envs {
+		res.Envs = append(res.Envs, &configleamv1.Env{Name: env.Name, Version: env.Version, Clone: env.Clone, Original: env.Original})
+	}
+
+	return res, nil
+}
+
+// Watch sends the current configuration of the env and then the configuration of every version applied to it
+func (s *grpcServer) Watch(req *configleamv1.WatchRequest, stream configleamv1.ConfigService_WatchServer) error {
+	fields, err := selector.ParseAll(req.GetSelect())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, err := withSecretMode(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx, err = s.auth.Authorize(ctx, s.credentials(ctx), req.GetEnv(), p.ReadConfig)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	// subscribed before reading the current configuration so no update is missed in between
+	client := s.notify.Subscribe(ctx, req.GetEnv())
+	defer s.notify.Unsubscribe(ctx, client)
+
+	send := func() error {
+		cfg, version, err := s.readConfig(ctx, req.GetEnv(), "", req.GetGroups(), req.GetGlobals(), fields)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&configleamv1.ConfigUpdate{Env: req.GetEnv(), Version: version, Config: cfg})
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case update, ok := <-client.Send:
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			// only configuration updates are streamed, the other events not changing the configuration
+			if update.Event != "" {
+				continue
+			}
+			if err := send(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *grpcServer) readConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (*structpb.Struct, string, error) {
+	cfg, version, err := s.configuration.ReadConfig(ctx, env, version, groups, globals, fields)
+	if err != nil {
+		return nil, "", toStatus(err)
+	}
+
+	config, err := structpb.NewStruct(cfg)
+	if err != nil {
+		log.Println("Error encoding configuration:", err)
+		return nil, "", status.Error(codes.Internal, "Error encoding configuration")
+	}
+
+	return config, version, nil
+}
+
+// credentials reads the access key and the project from the call metadata, and the client certificate verified during the TLS handshake
+func (s *grpcServer) credentials(ctx context.Context) auth.Credentials {
+	creds := auth.Credentials{}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(AccessKeyMetadata); len(values) > 0 {
+			creds.AccessKey = values[0]
+		}
+		if values := md.Get(ProjectMetadata); len(values) > 0 {
+			creds.Project = values[0]
+		}
+	}
+
+	creds.Certificate = verifiedClientCertificate(ctx)
+
+	return creds
+}
+
+// withSecretMode validates the secrets mode requested in the call metadata and stores it in the context
+func withSecretMode(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+
+	values := md.Get(SecretsMetadata)
+	if len(values) == 0 {
+		return ctx, nil
+	}
+
+	mode, err := secretmode.Parse(values[0])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if mode == "" {
+		return ctx, nil
+	}
+
+	return secretmode.WithMode(ctx, mode), nil
+}
+
+func verifiedClientCertificate(ctx context.Context) *x509.Certificate {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	tlsInfo, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) < 1 || len(tlsInfo.State.VerifiedChains[0]) < 1 {
+		return nil
+	}
+
+	return tlsInfo.State.VerifiedChains[0][0]
+}
+
+// toStatus maps the authorization and configuration errors to the gRPC status codes
+func toStatus(err error) error {
+	var authErr auth.AuthError
+	if errors.As(err, &authErr) {
+		switch authErr.Status {
+		case http.StatusBadRequest:
+			return status.Error(codes.InvalidArgument, authErr.Message)
+		case http.StatusUnauthorized:
+			return status.Error(codes.Unauthenticated, authErr.Message)
+		case http.StatusForbidden:
+			return status.Error(codes.PermissionDenied, authErr.Message)
+		case http.StatusNotFound:
+			return status.Error(codes.NotFound, authErr.Message)
+		default:
+			return status.Error(codes.Internal, authErr.Message)
+		}
+	}
+
+	var notFound repository.ConfigVersionNotFoundError
+	if errors.As(err, &notFound) {
+		return status.Error(codes.NotFound, notFound.Error())
+	}
+
+	var unresolved secretmode.UnresolvedError
+	if errors.As(err, &unresolved) {
+		return status.Error(codes.FailedPrecondition, unresolved.Error())
+	}
+
+	log.Println("Error serving gRPC call:", err)
+	return status.Error(codes.Internal, "Internal Server Error")
+}
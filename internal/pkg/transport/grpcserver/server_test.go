@@ -0,0 +1,260 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/raw-leak/configleam/internal/app/access/dto"
+	"github.com/raw-leak/configleam/internal/app/configuration/repository"
+	"github.com/raw-leak/configleam/internal/app/configuration/selector"
+	notify "github.com/raw-leak/configleam/internal/app/notify/service"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+	"github.com/raw-leak/configleam/internal/pkg/transport/grpcserver"
+	configleamv1 "github.com/raw-leak/configleam/pkg/api/configleam/v1"
+)
+
+type MockConfigurationService struct {
+	mock.Mock
+}
+
+func (m *MockConfigurationService) IsEnvOriginal(ctx context.Context, env string) bool {
+	return env == "develop"
+}
+
+func (m *MockConfigurationService) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (m *MockConfigurationService) ProjectExists(ctx context.Context, name string) bool {
+	return name == "billing"
+}
+
+func (m *MockConfigurationService) ReadConfig(ctx context.Context, env, version string, groups, globals []string, fields []selector.Path) (map[string]interface{}, string, error) {
+	args := m.Called(project.FromContext(ctx), env, version, groups, globals, fields)
+	cfg, _ := args.Get(0).(map[string]interface{})
+	return cfg, args.String(1), args.Error(2)
+}
+
+func (m *MockConfigurationService) ListEnvs(ctx context.Context) ([]repository.EnvParams, error) {
+	args := m.Called(project.FromContext(ctx))
+	envs, _ := args.Get(0).([]repository.EnvParams)
+	return envs, args.Error(1)
+}
+
+type MockAccessService struct{}
+
+// GetAccessKeyPermissions grants readConfig on develop to 'read-key' of every project
+func (m *MockAccessService) GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error) {
+	perms := permissions.New().NewAccessKeyPermissions()
+	switch key {
+	case "read-key":
+		perms.Grant("develop", permissions.ReadConfig)
+	case "secrets-key":
+		perms.Grant("develop", permissions.CreateSecrets)
+	default:
+		return nil, false, nil
+	}
+	return perms, true, nil
+}
+
+func (m *MockAccessService) GenerateAccessKey(ctx context.Context, perms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error) {
+	return perms, nil
+}
+
+func (m *MockAccessService) DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error {
+	return nil
+}
+
+type GrpcServerSuite struct {
+	suite.Suite
+	configuration *MockConfigurationService
+	notify        *notify.NotifyService
+	cancelNotify  context.CancelFunc
+	conn          *grpc.ClientConn
+	client        configleamv1.ConfigServiceClient
+	shutdown      func()
+}
+
+func TestGrpcServerSuite(t *testing.T) {
+	suite.Run(t, new(GrpcServerSuite))
+}
+
+func (suite *GrpcServerSuite) SetupTest() {
+	suite.configuration = new(MockConfigurationService)
+
+	var ctx context.Context
+	ctx, suite.cancelNotify = context.WithCancel(context.Background())
+	suite.notify = notify.New(false)
+	suite.notify.RunLocal(ctx)
+
+	server := grpcserver.NewGrpcServer(suite.configuration, suite.notify, &MockAccessService{}, permissions.New(), nil)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	suite.Require().NoError(err)
+
+	suite.conn = conn
+	suite.client = configleamv1.NewConfigServiceClient(conn)
+	suite.shutdown = func() {
+		suite.notify.ShutdownLocal(ctx)
+		server.Shutdown(ctx)
+	}
+}
+
+func (suite *GrpcServerSuite) TearDownTest() {
+	suite.conn.Close()
+	suite.shutdown()
+	suite.cancelNotify()
+}
+
+func withCredentials(accessKey, projectName string) context.Context {
+	md := metadata.Pairs(grpcserver.AccessKeyMetadata, accessKey)
+	if projectName != "" {
+		md.Set(grpcserver.ProjectMetadata, projectName)
+	}
+	return metadata.NewOutgoingContext(context.Background(), md)
+}
+
+func withSecretMode(mode string) context.Context {
+	return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(grpcserver.AccessKeyMetadata, "read-key", grpcserver.SecretsMetadata, mode))
+}
+
+func (suite *GrpcServerSuite) TestGetConfig() {
+	suite.configuration.On("ReadConfig", "", "develop", "", []string{"billing"}, []string(nil), []selector.Path{}).
+		Return(map[string]interface{}{"billing": map[string]interface{}{"host": "localhost", "port": float64(8080)}}, "v1.0.0", nil)
+	suite.configuration.On("ReadConfig", "billing", "develop", "", []string(nil), []string(nil), []selector.Path{{"billing", "host"}}).
+		Return(map[string]interface{}{"billing": map[string]interface{}{"host": "billing-host"}}, "v2.0.0", nil)
+	suite.configuration.On("ReadConfig", "", "develop", "v0.1.0", []string(nil), []string(nil), []selector.Path{}).
+		Return(nil, "", repository.ConfigVersionNotFoundError{Env: "develop", Version: "v0.1.0"})
+	suite.configuration.On("ReadConfig", "", "develop", "v0.2.0", []string(nil), []string(nil), []selector.Path{}).
+		Return(nil, "", secretmode.UnresolvedError{Unresolved: []secretmode.Unresolved{{Key: "db.password", Reason: secretmode.ReasonRedacted}}})
+
+	testCases := []struct {
+		name            string
+		ctx             context.Context
+		req             *configleamv1.GetConfigRequest
+		expectedCode    codes.Code
+		expectedConfig  map[string]interface{}
+		expectedVersion string
+	}{
+		{
+			name:            "Reads the configuration with an access key",
+			ctx:             withCredentials("read-key", ""),
+			req:             &configleamv1.GetConfigRequest{Env: "develop", Groups: []string{"billing"}},
+			expectedCode:    codes.OK,
+			expectedConfig:  map[string]interface{}{"billing": map[string]interface{}{"host": "localhost", "port": float64(8080)}},
+			expectedVersion: "v1.0.0",
+		},
+		{
+			name:            "Reads the selected fields within a project",
+			ctx:             withCredentials("read-key", "billing"),
+			req:             &configleamv1.GetConfigRequest{Env: "develop", Select: []string{"billing.host"}},
+			expectedCode:    codes.OK,
+			expectedConfig:  map[string]interface{}{"billing": map[string]interface{}{"host": "billing-host"}},
+			expectedVersion: "v2.0.0",
+		},
+		{name: "Unauthenticated without access key", ctx: context.Background(), req: &configleamv1.GetConfigRequest{Env: "develop"}, expectedCode: codes.Unauthenticated},
+		{name: "Permission denied without readConfig", ctx: withCredentials("secrets-key", ""), req: &configleamv1.GetConfigRequest{Env: "develop"}, expectedCode: codes.PermissionDenied},
+		{name: "Permission denied for unknown access key", ctx: withCredentials("unknown-key", ""), req: &configleamv1.GetConfigRequest{Env: "develop"}, expectedCode: codes.PermissionDenied},
+		{name: "Not found for unknown project", ctx: withCredentials("read-key", "orders"), req: &configleamv1.GetConfigRequest{Env: "develop"}, expectedCode: codes.NotFound},
+		{name: "Invalid argument for invalid selector", ctx: withCredentials("read-key", ""), req: &configleamv1.GetConfigRequest{Env: "develop", Select: []string{"a..b"}}, expectedCode: codes.InvalidArgument},
+		{name: "Not found for version not kept", ctx: withCredentials("read-key", ""), req: &configleamv1.GetConfigRequest{Env: "develop", Version: "v0.1.0"}, expectedCode: codes.NotFound},
+		{name: "Failed precondition for unresolved secrets", ctx: withSecretMode("strict"), req: &configleamv1.GetConfigRequest{Env: "develop", Version: "v0.2.0"}, expectedCode: codes.FailedPrecondition},
+		{name: "Invalid argument for unknown secrets mode", ctx: withSecretMode("quiet"), req: &configleamv1.GetConfigRequest{Env: "develop"}, expectedCode: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			res, err := suite.client.GetConfig(tc.ctx, tc.req)
+			suite.Equal(tc.expectedCode, status.Code(err))
+			if tc.expectedCode != codes.OK {
+				return
+			}
+			suite.Equal(tc.expectedConfig, res.GetConfig().AsMap())
+			suite.Equal(tc.expectedVersion, res.GetVersion())
+		})
+	}
+}
+
+func (suite *GrpcServerSuite) TestListEnvs() {
+	suite.configuration.On("ListEnvs", "").Return([]repository.EnvParams{
+		{Name: "develop", Version: "v1.0.0"},
+		{Name: "develop-feature", Version: "v1.0.0", Clone: true, Original: "develop"},
+	}, nil)
+
+	res, err := suite.client.ListEnvs(withCredentials("read-key", ""), &configleamv1.ListEnvsRequest{})
+	suite.Require().NoError(err)
+	suite.Len(res.GetEnvs(), 2)
+	suite.Equal("develop", res.GetEnvs()[0].GetName())
+	suite.Equal("develop", res.GetEnvs()[1].GetOriginal())
+	suite.True(res.GetEnvs()[1].GetClone())
+
+	_, err = suite.client.ListEnvs(context.Background(), &configleamv1.ListEnvsRequest{})
+	suite.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (suite *GrpcServerSuite) TestWatch() {
+	suite.configuration.On("ReadConfig", "billing", "develop", "", []string(nil), []string{"region"}, []selector.Path{}).
+		Return(map[string]interface{}{"region": "eu-west-1"}, "v1.0.0", nil).Once()
+	suite.configuration.On("ReadConfig", "billing", "develop", "", []string(nil), []string{"region"}, []selector.Path{}).
+		Return(map[string]interface{}{"region": "eu-west-2"}, "v1.1.0", nil)
+
+	ctx, cancel := context.WithCancel(withCredentials("read-key", "billing"))
+	defer cancel()
+
+	stream, err := suite.client.Watch(ctx, &configleamv1.WatchRequest{Env: "develop", Globals: []string{"region"}})
+	suite.Require().NoError(err)
+
+	update, err := stream.Recv()
+	suite.Require().NoError(err)
+	suite.Equal("v1.0.0", update.GetVersion())
+	suite.Equal(map[string]interface{}{"region": "eu-west-1"}, update.GetConfig().AsMap())
+
+	// updates reaching the broker before the stream waits for them are dropped, so they are sent until one arrives
+	received := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-received:
+				return
+			case <-ticker.C:
+				suite.notify.NotifyConfigUpdate(project.WithProject(context.Background(), "billing"), "config", "develop", "v1.1.0")
+			}
+		}
+	}()
+
+	update, err = stream.Recv()
+	close(received)
+	suite.Require().NoError(err)
+	suite.Equal("develop", update.GetEnv())
+	suite.Equal("v1.1.0", update.GetVersion())
+	suite.Equal(map[string]interface{}{"region": "eu-west-2"}, update.GetConfig().AsMap())
+}
+
+func (suite *GrpcServerSuite) TestWatchPermissionDenied() {
+	stream, err := suite.client.Watch(withCredentials("secrets-key", ""), &configleamv1.WatchRequest{Env: "develop"})
+	suite.Require().NoError(err)
+
+	_, err = stream.Recv()
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+}
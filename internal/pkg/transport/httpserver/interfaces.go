@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/raw-leak/configleam/internal/app/access/dto"
+	secretsRepository "github.com/raw-leak/configleam/internal/app/secrets/repository"
 	"github.com/raw-leak/configleam/internal/pkg/permissions"
 )
 
@@ -17,12 +18,18 @@ type ConfigurationSet interface {
 type ConfigurationService interface {
 	IsEnvOriginal(ctx context.Context, env string) bool
 	GetEnvOriginal(ctx context.Context, env string) (string, bool, error)
+	ProjectExists(ctx context.Context, name string) bool
 }
 
 type ConfigurationEndpoints interface {
 	CloneConfigHandler(w http.ResponseWriter, r *http.Request)
 	ReadConfigHandler(w http.ResponseWriter, r *http.Request)
 	DeleteConfigHandler(w http.ResponseWriter, r *http.Request)
+	ReadEnvConfigHandler(w http.ResponseWriter, r *http.Request)
+	ListEnvsHandler(w http.ResponseWriter, r *http.Request)
+	ListConfigKeysHandler(w http.ResponseWriter, r *http.Request)
+	SyncHandler(w http.ResponseWriter, r *http.Request)
+	SyncStatusHandler(w http.ResponseWriter, r *http.Request)
 }
 
 // secrets
@@ -33,10 +40,17 @@ type SecretsSet interface {
 
 type SecretsEndpoints interface {
 	UpsertSecretsHandler(w http.ResponseWriter, r *http.Request)
+	ListSecretsHandler(w http.ResponseWriter, r *http.Request)
+	DeleteSecretHandler(w http.ResponseWriter, r *http.Request)
+	PatchSecretsHandler(w http.ResponseWriter, r *http.Request)
+	CheckSecretsHandler(w http.ResponseWriter, r *http.Request)
+	ListSecretVersionsHandler(w http.ResponseWriter, r *http.Request)
+	RollbackSecretHandler(w http.ResponseWriter, r *http.Request)
+	ListDueSecretsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type SecretsService interface {
-	UpsertSecrets(ctx context.Context, env string, secrets map[string]interface{}) error
+	UpsertSecrets(ctx context.Context, env string, secrets map[string]interface{}, info map[string]secretsRepository.SecretInfo) error
 }
 
 // access
@@ -47,12 +61,23 @@ type AccessSet interface {
 type AccessEndpoints interface {
 	GenerateAccessKeyHandler(w http.ResponseWriter, r *http.Request)
 	DeleteAccessKeysHandler(w http.ResponseWriter, r *http.Request)
+	PaginateAccessKeysHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type AccessService interface {
 	GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error)
 	GenerateAccessKey(ctx context.Context, accessKeyPerms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error)
-	DeleteAccessKeys(ctx context.Context, keys []string) error
+	DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error
+}
+
+// backup
+type BackupSet interface {
+	BackupEndpoints
+}
+
+type BackupEndpoints interface {
+	ExportHandler(w http.ResponseWriter, r *http.Request)
+	ImportHandler(w http.ResponseWriter, r *http.Request)
 }
 
 // dashboard
@@ -67,6 +92,7 @@ type DashboardEndpoints interface {
 	CreateAccessKeyParamsHandler(w http.ResponseWriter, r *http.Request)
 	CreateAccessKeyHandler(w http.ResponseWriter, r *http.Request)
 	DeleteAccessKeyHandler(w http.ResponseWriter, r *http.Request)
+	SecretsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 // notif
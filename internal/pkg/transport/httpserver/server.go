@@ -2,15 +2,16 @@ package httpserver
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
-	"path/filepath"
 
 	"github.com/raw-leak/configleam/internal/pkg/auth"
 	"github.com/raw-leak/configleam/internal/pkg/auth/templates"
 	p "github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/raw-leak/configleam/internal/pkg/project"
+	"github.com/raw-leak/configleam/internal/pkg/secretmode"
+	"github.com/raw-leak/configleam/internal/pkg/tlsconfig"
 )
 
 type httpServer struct {
@@ -21,11 +22,14 @@ type httpServer struct {
 	access        AccessSet
 	dashboard     DashboardSet
 	notify        NotifSet
+	backup        BackupSet
 
 	permissions PermissionsBuilder
+	certs       auth.CertificateAuthorizer
 }
 
-func NewHttpServer(configuration ConfigurationSet, secrets SecretsSet, access AccessSet, dashboard DashboardSet, notify NotifSet, permissions PermissionsBuilder) *httpServer {
+// NewHttpServer creates the http server, certs is optional and only required to authorize clients by their certificate
+func NewHttpServer(configuration ConfigurationSet, secrets SecretsSet, access AccessSet, dashboard DashboardSet, notify NotifSet, backup BackupSet, permissions PermissionsBuilder, certs auth.CertificateAuthorizer) *httpServer {
 	return &httpServer{
 		configuration: configuration,
 		secrets:       secrets,
@@ -33,10 +37,18 @@ func NewHttpServer(configuration ConfigurationSet, secrets SecretsSet, access Ac
 		dashboard:     dashboard,
 		permissions:   permissions,
 		notify:        notify,
+		backup:        backup,
+		certs:         certs,
 	}
 }
 
-func (s *httpServer) ListenAndServe(httpAddr string, enableTls bool) error {
+func (s *httpServer) ListenAndServe(httpAddr string, enableTls bool, tlsFiles tlsconfig.Files) error {
+	return s.startServer(httpAddr, s.routes(templates.New()), enableTls, tlsFiles)
+}
+
+// routes registers the handlers of every endpoint behind the permissions they require, the dashboard login being
+// rendered with the templates
+func (s *httpServer) routes(loginTemplates auth.Templates) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	endpoints := newHandlers(s.configuration)
@@ -46,25 +58,48 @@ func (s *httpServer) ListenAndServe(httpAddr string, enableTls bool) error {
 	mux.HandleFunc("/ready", endpoints.ReadinessCheckHandler)
 
 	// middlewares
-	auth := auth.NewAuthMiddleware(s.access, s.configuration, s.permissions, templates.New())
+	auth := auth.NewAuthMiddleware(s.access, s.configuration, s.permissions, loginTemplates, s.certs)
 
 	// configuration business handlers
-	mux.HandleFunc("GET /config", auth.Guard(p.ReadConfig)(s.configuration.ReadConfigHandler))
+	mux.HandleFunc("GET /config", auth.Guard(p.ReadConfig)(secretmode.Resolve(s.configuration.ReadConfigHandler)))
+
+	// configuration discovery business handlers, environments are filtered by the read permissions of the key
+	mux.HandleFunc("GET /config/envs", auth.GuardAuthenticated()(s.configuration.ListEnvsHandler))
+	mux.HandleFunc("GET /config/keys", auth.Guard(p.ReadConfig)(s.configuration.ListConfigKeysHandler))
+	mux.HandleFunc("GET /config/env", auth.Guard(p.ReadConfig)(secretmode.Resolve(s.configuration.ReadEnvConfigHandler)))
 
 	// configuration clone environment business handlers
 	mux.HandleFunc("POST /config/clone", auth.Guard(p.CloneEnvironment)(s.configuration.CloneConfigHandler))
 	mux.HandleFunc("DELETE /config/clone", auth.Guard(p.CloneEnvironment)(s.configuration.DeleteConfigHandler))
 
-	// configuration notify business handlers
-	mux.HandleFunc("GET /config/sse", s.notify.NotifyHandler)
+	// configuration git repository synchronization business handlers
+	mux.HandleFunc("POST /config/sync", auth.GuardGlobalAdmin()(s.configuration.SyncHandler))
+	mux.HandleFunc("GET /config/sync", auth.GuardGlobalAdmin()(s.configuration.SyncStatusHandler))
+
+	// configuration notify business handlers, the secrets reminded of are only sent to keys allowed to reveal them
+	mux.HandleFunc("GET /config/sse", auth.GuardOptional()(s.notify.NotifyHandler))
 
 	// secrets business handlers
 	mux.HandleFunc("PUT /secrets", auth.Guard(p.CreateSecrets)(s.secrets.UpsertSecretsHandler))
+	mux.HandleFunc("PATCH /secrets", auth.Guard(p.CreateSecrets)(s.secrets.PatchSecretsHandler))
+	mux.HandleFunc("DELETE /secrets", auth.Guard(p.CreateSecrets)(s.secrets.DeleteSecretHandler))
+	mux.HandleFunc("GET /secrets", auth.Guard(p.CreateSecrets)(s.secrets.ListSecretsHandler))
+	mux.HandleFunc("GET /secrets/versions", auth.Guard(p.CreateSecrets)(s.secrets.ListSecretVersionsHandler))
+	mux.HandleFunc("GET /secrets/due", auth.Guard(p.CreateSecrets)(s.secrets.ListDueSecretsHandler))
+	mux.HandleFunc("POST /secrets/rollback", auth.Guard(p.CreateSecrets)(s.secrets.RollbackSecretHandler))
+
+	// the check never reveals a secret, it only tells whether placeholders of the configuration resolve
+	mux.HandleFunc("GET /secrets/check", auth.Guard(p.ReadConfig)(s.secrets.CheckSecretsHandler))
 
 	// access business handlers
-	mux.HandleFunc("POST /access", s.access.GenerateAccessKeyHandler)
+	mux.HandleFunc("POST /access", project.Resolve(s.access.GenerateAccessKeyHandler))
+	mux.HandleFunc("GET /access", auth.GuardGlobalAdmin()(s.access.PaginateAccessKeysHandler))
 	mux.HandleFunc("DELETE /access", auth.Guard(p.Admin)(s.access.DeleteAccessKeysHandler))
 
+	// backup business handlers
+	mux.HandleFunc("GET /backup/export", auth.GuardGlobalAdmin()(s.backup.ExportHandler))
+	mux.HandleFunc("POST /backup/import", auth.GuardGlobalAdmin()(s.backup.ImportHandler))
+
 	// dashboard security business handlers
 	mux.HandleFunc("/dashboard/login", auth.LoginHandler)
 	mux.HandleFunc("/dashboard/logout", auth.GuardDashboard()(auth.LogoutHandler))
@@ -76,13 +111,14 @@ func (s *httpServer) ListenAndServe(httpAddr string, enableTls bool) error {
 	mux.HandleFunc("GET /dashboard/access/create", auth.GuardDashboard()(s.dashboard.CreateAccessKeyParamsHandler))
 	mux.HandleFunc("POST /dashboard/access/create", auth.GuardDashboard()(s.dashboard.CreateAccessKeyHandler))
 	mux.HandleFunc("POST /dashboard/access/delete", auth.GuardDashboard()(s.dashboard.DeleteAccessKeyHandler))
+	mux.HandleFunc("GET /dashboard/secrets", auth.GuardDashboard()(s.dashboard.SecretsHandler))
 
 	// serve static
 	staticDir := http.Dir("static")
 	staticHandler := http.StripPrefix("/static/", http.FileServer(staticDir))
 	mux.Handle("/static/", staticHandler)
 
-	return s.startServer(httpAddr, mux, enableTls)
+	return mux
 }
 
 func (t *httpServer) Shutdown(ctx context.Context) error {
@@ -92,25 +128,24 @@ func (t *httpServer) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-func (s *httpServer) startServer(httpAddr string, mux http.Handler, enableTls bool) error {
+func (s *httpServer) startServer(httpAddr string, mux http.Handler, enableTls bool, tlsFiles tlsconfig.Files) error {
 	if enableTls {
-		certPath := filepath.Join("certs", "cert.pem")
-		keyPath := filepath.Join("certs", "key.pem")
-
-		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		reloader, err := tlsconfig.NewReloader(tlsFiles, 0)
 		if err != nil {
 			log.Println("failed to load TLS certificate:", err)
 			return fmt.Errorf("failed to load TLS certificate: %v", err)
 		}
 
-		tlsConfig := &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		}
+		s.server = &http.Server{Addr: fmt.Sprintf(":%s", httpAddr), Handler: mux, TLSConfig: reloader.ServerConfig()}
 
-		s.server = &http.Server{Addr: fmt.Sprintf(":%s", httpAddr), Handler: mux, TLSConfig: tlsConfig}
+		if tlsFiles.CAFile != "" {
+			log.Printf("Starting HTTPS server on port %s with client certificates verification\n", httpAddr)
+		} else {
+			log.Printf("Starting HTTPS server on port %s\n", httpAddr)
+		}
 
-		log.Printf("Starting HTTPS server on port %s\n", httpAddr)
-		return s.server.ListenAndServeTLS(certPath, keyPath)
+		// certificates are served by the reloader through the TLS config
+		return s.server.ListenAndServeTLS("", "")
 	} else {
 		s.server = &http.Server{Addr: fmt.Sprintf(":%s", httpAddr), Handler: mux}
 
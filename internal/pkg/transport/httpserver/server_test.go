@@ -0,0 +1,178 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raw-leak/configleam/internal/app/access/dto"
+	secretsRepository "github.com/raw-leak/configleam/internal/app/secrets/repository"
+	"github.com/raw-leak/configleam/internal/pkg/permissions"
+	"github.com/stretchr/testify/suite"
+)
+
+// stubServer answers every endpoint with 200 and resolves access keys from a map, only 'develop' being an original env
+type stubServer struct {
+	keys map[string]*permissions.AccessKeyPermissions
+}
+
+func (s *stubServer) IsEnvOriginal(ctx context.Context, env string) bool { return env == "develop" }
+func (s *stubServer) GetEnvOriginal(ctx context.Context, env string) (string, bool, error) {
+	return "", false, nil
+}
+func (s *stubServer) ProjectExists(ctx context.Context, name string) bool { return false }
+
+func (s *stubServer) GetAccessKeyPermissions(ctx context.Context, key string) (*permissions.AccessKeyPermissions, bool, error) {
+	perms, ok := s.keys[key]
+	return perms, ok, nil
+}
+func (s *stubServer) GenerateAccessKey(ctx context.Context, perms dto.AccessKeyPermissionsDto) (dto.AccessKeyPermissionsDto, error) {
+	return perms, nil
+}
+func (s *stubServer) DeleteAccessKeysByPlaintext(ctx context.Context, keys []string) error {
+	return nil
+}
+
+func (s *stubServer) UpsertSecrets(ctx context.Context, env string, secrets map[string]interface{}, info map[string]secretsRepository.SecretInfo) error {
+	return nil
+}
+
+func (s *stubServer) ok(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+func (s *stubServer) CloneConfigHandler(w http.ResponseWriter, r *http.Request)           { s.ok(w, r) }
+func (s *stubServer) ReadConfigHandler(w http.ResponseWriter, r *http.Request)            { s.ok(w, r) }
+func (s *stubServer) DeleteConfigHandler(w http.ResponseWriter, r *http.Request)          { s.ok(w, r) }
+func (s *stubServer) ReadEnvConfigHandler(w http.ResponseWriter, r *http.Request)         { s.ok(w, r) }
+func (s *stubServer) ListEnvsHandler(w http.ResponseWriter, r *http.Request)              { s.ok(w, r) }
+func (s *stubServer) ListConfigKeysHandler(w http.ResponseWriter, r *http.Request)        { s.ok(w, r) }
+func (s *stubServer) SyncHandler(w http.ResponseWriter, r *http.Request)                  { s.ok(w, r) }
+func (s *stubServer) SyncStatusHandler(w http.ResponseWriter, r *http.Request)            { s.ok(w, r) }
+func (s *stubServer) UpsertSecretsHandler(w http.ResponseWriter, r *http.Request)         { s.ok(w, r) }
+func (s *stubServer) ListSecretsHandler(w http.ResponseWriter, r *http.Request)           { s.ok(w, r) }
+func (s *stubServer) DeleteSecretHandler(w http.ResponseWriter, r *http.Request)          { s.ok(w, r) }
+func (s *stubServer) PatchSecretsHandler(w http.ResponseWriter, r *http.Request)          { s.ok(w, r) }
+func (s *stubServer) CheckSecretsHandler(w http.ResponseWriter, r *http.Request)          { s.ok(w, r) }
+func (s *stubServer) ListSecretVersionsHandler(w http.ResponseWriter, r *http.Request)    { s.ok(w, r) }
+func (s *stubServer) RollbackSecretHandler(w http.ResponseWriter, r *http.Request)        { s.ok(w, r) }
+func (s *stubServer) ListDueSecretsHandler(w http.ResponseWriter, r *http.Request)        { s.ok(w, r) }
+func (s *stubServer) GenerateAccessKeyHandler(w http.ResponseWriter, r *http.Request)     { s.ok(w, r) }
+func (s *stubServer) DeleteAccessKeysHandler(w http.ResponseWriter, r *http.Request)      { s.ok(w, r) }
+func (s *stubServer) PaginateAccessKeysHandler(w http.ResponseWriter, r *http.Request)    { s.ok(w, r) }
+func (s *stubServer) ExportHandler(w http.ResponseWriter, r *http.Request)                { s.ok(w, r) }
+func (s *stubServer) ImportHandler(w http.ResponseWriter, r *http.Request)                { s.ok(w, r) }
+func (s *stubServer) HomeHandler(w http.ResponseWriter, r *http.Request)                  { s.ok(w, r) }
+func (s *stubServer) ConfigHandler(w http.ResponseWriter, r *http.Request)                { s.ok(w, r) }
+func (s *stubServer) AccessHandler(w http.ResponseWriter, r *http.Request)                { s.ok(w, r) }
+func (s *stubServer) CreateAccessKeyParamsHandler(w http.ResponseWriter, r *http.Request) { s.ok(w, r) }
+func (s *stubServer) CreateAccessKeyHandler(w http.ResponseWriter, r *http.Request)       { s.ok(w, r) }
+func (s *stubServer) DeleteAccessKeyHandler(w http.ResponseWriter, r *http.Request)       { s.ok(w, r) }
+func (s *stubServer) SecretsHandler(w http.ResponseWriter, r *http.Request)               { s.ok(w, r) }
+func (s *stubServer) NotifyHandler(w http.ResponseWriter, r *http.Request)                { s.ok(w, r) }
+
+type stubTemplates struct{}
+
+func (t stubTemplates) Login(w http.ResponseWriter, errMsg string)      {}
+func (t stubTemplates) LoginError(w http.ResponseWriter, errMsg string) {}
+
+type HttpServerSuite struct {
+	suite.Suite
+	mux *http.ServeMux
+}
+
+func TestHttpServerSuite(t *testing.T) {
+	suite.Run(t, new(HttpServerSuite))
+}
+
+func (suite *HttpServerSuite) SetupTest() {
+	perms := permissions.New()
+
+	admin := perms.NewAccessKeyPermissions()
+	admin.SetAdmin()
+
+	shared := perms.NewAccessKeyPermissions()
+	shared.Grant("@shared", permissions.CreateSecrets)
+
+	develop := perms.NewAccessKeyPermissions()
+	develop.Grant("develop", permissions.CreateSecrets)
+
+	envAdmin := perms.NewAccessKeyPermissions()
+	envAdmin.Grant("develop", permissions.EnvAdminAccess)
+
+	stub := &stubServer{keys: map[string]*permissions.AccessKeyPermissions{
+		"admin-key":     admin,
+		"shared-key":    shared,
+		"develop-key":   develop,
+		"env-admin-key": envAdmin,
+	}}
+
+	suite.mux = NewHttpServer(stub, stub, stub, stub, stub, stub, perms, nil).routes(stubTemplates{})
+}
+
+func (suite *HttpServerSuite) TestSharedScopeSecrets() {
+	testCases := []struct {
+		name           string
+		method         string
+		url            string
+		accessKey      string
+		expectedStatus int
+	}{
+		{name: "Upsert allowed for global admin", method: http.MethodPut, url: "/secrets?env=@shared", accessKey: "admin-key", expectedStatus: http.StatusOK},
+		{name: "Upsert allowed with create secrets on the scope", method: http.MethodPut, url: "/secrets?env=@shared", accessKey: "shared-key", expectedStatus: http.StatusOK},
+		{name: "Patch allowed with create secrets on the scope", method: http.MethodPatch, url: "/secrets?env=@shared", accessKey: "shared-key", expectedStatus: http.StatusOK},
+		{name: "List allowed with create secrets on the scope", method: http.MethodGet, url: "/secrets?env=@shared", accessKey: "shared-key", expectedStatus: http.StatusOK},
+		{name: "Delete allowed with create secrets on the scope", method: http.MethodDelete, url: "/secrets?env=@shared&key=smtp", accessKey: "shared-key", expectedStatus: http.StatusOK},
+		{name: "Upsert denied with create secrets only on develop", method: http.MethodPut, url: "/secrets?env=@shared", accessKey: "develop-key", expectedStatus: http.StatusForbidden},
+		{name: "List denied with create secrets only on develop", method: http.MethodGet, url: "/secrets?env=@shared", accessKey: "develop-key", expectedStatus: http.StatusForbidden},
+		{name: "Upsert denied on another scope", method: http.MethodPut, url: "/secrets?env=@payments", accessKey: "shared-key", expectedStatus: http.StatusForbidden},
+		{name: "Upsert allowed on develop with create secrets on develop", method: http.MethodPut, url: "/secrets?env=develop", accessKey: "develop-key", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.Header.Set("X-Access-Key", tc.accessKey)
+
+			rr := httptest.NewRecorder()
+			suite.mux.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func (suite *HttpServerSuite) TestAdminRoutes() {
+	testCases := []struct {
+		name           string
+		method         string
+		url            string
+		accessKey      string
+		expectedStatus int
+	}{
+		{name: "Access keys deleted by an environment admin of the env", method: http.MethodDelete, url: "/access?env=develop&key=id", accessKey: "env-admin-key", expectedStatus: http.StatusOK},
+		{name: "Access keys not deleted by an environment admin of another env", method: http.MethodDelete, url: "/access?env=production&key=id", accessKey: "env-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Access keys not deleted without admin", method: http.MethodDelete, url: "/access?env=develop&key=id", accessKey: "develop-key", expectedStatus: http.StatusForbidden},
+		{name: "Access keys deleted by a global admin", method: http.MethodDelete, url: "/access?env=develop&key=id", accessKey: "admin-key", expectedStatus: http.StatusOK},
+		{name: "Access keys deleted by a global admin without env", method: http.MethodDelete, url: "/access?key=id", accessKey: "admin-key", expectedStatus: http.StatusOK},
+		{name: "Access keys not deleted by an environment admin without env", method: http.MethodDelete, url: "/access?key=id", accessKey: "env-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Access keys listed by a global admin", method: http.MethodGet, url: "/access?page=1&size=10", accessKey: "admin-key", expectedStatus: http.StatusOK},
+		{name: "Access keys not listed by an environment admin", method: http.MethodGet, url: "/access?env=develop&page=1&size=10", accessKey: "env-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Export allowed for a global admin", method: http.MethodGet, url: "/backup/export", accessKey: "admin-key", expectedStatus: http.StatusOK},
+		{name: "Export denied for an environment admin", method: http.MethodGet, url: "/backup/export?env=develop", accessKey: "env-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Import denied for an environment admin", method: http.MethodPost, url: "/backup/import?env=develop", accessKey: "env-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Sync denied for an environment admin", method: http.MethodPost, url: "/config/sync?env=develop", accessKey: "env-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Sync allowed for a global admin", method: http.MethodPost, url: "/config/sync", accessKey: "admin-key", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.Header.Set("X-Access-Key", tc.accessKey)
+
+			rr := httptest.NewRecorder()
+			suite.mux.ServeHTTP(rr, req)
+
+			suite.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Env is an environment the access key can read
+type Env struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Clone    bool   `json:"clone"`
+	Original string `json:"original"`
+}
+
+// EnvPermissions are the operations an access key is granted on an environment
+type EnvPermissions struct {
+	EnvAdminAccess   bool `json:"envAdminAccess"`
+	ReadConfig       bool `json:"readConfig"`
+	RevealSecrets    bool `json:"revealSecrets"`
+	CloneEnvironment bool `json:"cloneEnvironment"`
+	CreateSecrets    bool `json:"createSecrets"`
+	AccessDashboard  bool `json:"accessDashboard"`
+}
+
+// AccessKeyRequest describes the access key to create, AccessKey holding the generated key in the response
+type AccessKeyRequest struct {
+	Name        string                    `json:"name,omitempty"`
+	GlobalAdmin bool                      `json:"globalAdmin"`
+	Envs        map[string]EnvPermissions `json:"environments"`
+	ExpDate     time.Time                 `json:"expDate,omitempty"`
+	AccessKey   string                    `json:"accessKey,omitempty"`
+}
+
+// AccessKeyMetadata describes an existing access key
+type AccessKeyMetadata struct {
+	Key            string    `json:"Key"`
+	Name           string    `json:"name"`
+	MaskedKey      string    `json:"masked_key"`
+	ExpirationDate time.Time `json:"expiration_date"`
+	CreationDate   time.Time `json:"creation_date"`
+}
+
+// AccessKeysPage is a page of the access keys of the project, the newest first
+type AccessKeysPage struct {
+	Total int                 `json:"total"`
+	Pages int                 `json:"pages"`
+	Page  int                 `json:"page"`
+	Size  int                 `json:"size"`
+	Items []AccessKeyMetadata `json:"items"`
+}
+
+// SyncStatus is the outcome of the last synchronization of the project with its git repository
+type SyncStatus struct {
+	Active    bool              `json:"active"`
+	LastSync  *time.Time        `json:"lastSync,omitempty"`
+	LastError string            `json:"lastError,omitempty"`
+	Envs      map[string]string `json:"envs"`
+}
+
+// ListEnvs returns the environments the access key can read
+func (c *Client) ListEnvs(ctx context.Context) ([]Env, error) {
+	var res struct {
+		Envs []Env `json:"envs"`
+	}
+	err := c.do(ctx, http.MethodGet, "/config/envs", nil, nil, &res)
+	return res.Envs, err
+}
+
+// Format reads the configuration of the environment encoded in one of the formats served by Configleam,
+// e.g. "yaml" or "dotenv", a version kept in the history being read when not empty
+func (c *Client) Format(ctx context.Context, format, version string) ([]byte, error) {
+	if c.opts.Env == "" {
+		return nil, ErrNoEnv
+	}
+
+	query := url.Values{"env": {c.opts.Env}, "format": {format}}
+	query["groups"] = c.opts.Groups
+	query["globals"] = c.opts.Globals
+	query["select"] = c.opts.Select
+	if version != "" {
+		query.Set("version", version)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/config", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, statusError(res)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// CreateAccessKey creates an access key with the requested permissions, returning it with the generated key
+func (c *Client) CreateAccessKey(ctx context.Context, req AccessKeyRequest) (AccessKeyRequest, error) {
+	if req.Envs == nil {
+		req.Envs = map[string]EnvPermissions{}
+	}
+
+	var created AccessKeyRequest
+	err := c.do(ctx, http.MethodPost, "/access", nil, req, &created)
+	return created, err
+}
+
+// ListAccessKeys returns a page of the access keys of the project, pages starting at 1
+func (c *Client) ListAccessKeys(ctx context.Context, page, size int) (AccessKeysPage, error) {
+	query := url.Values{"page": {strconv.Itoa(page)}, "size": {strconv.Itoa(size)}}
+
+	var res AccessKeysPage
+	err := c.do(ctx, http.MethodGet, "/access", query, nil, &res)
+	return res, err
+}
+
+// RevokeAccessKeys deletes the access keys by their id, the Key listed by ListAccessKeys
+func (c *Client) RevokeAccessKeys(ctx context.Context, ids ...string) error {
+	return c.send(ctx, http.MethodDelete, "/access", url.Values{"key": ids}, nil)
+}
+
+// Sync synchronizes the project with its git repository right away, returning the outcome
+func (c *Client) Sync(ctx context.Context) (SyncStatus, error) {
+	var status SyncStatus
+	err := c.do(ctx, http.MethodPost, "/config/sync", nil, nil, &status)
+	return status, err
+}
+
+// SyncStatus returns the outcome of the last synchronization of the project with its git repository
+func (c *Client) SyncStatus(ctx context.Context) (SyncStatus, error) {
+	var status SyncStatus
+	err := c.do(ctx, http.MethodGet, "/config/sync", nil, nil, &status)
+	return status, err
+}
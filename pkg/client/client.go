@@ -0,0 +1,362 @@
+// Package client is the Go client of the Configleam HTTP API.
+//
+// It reads the configuration of an environment, keeping the last one read in memory and,
+// optionally, in a fallback file used while Configleam is unreachable. It watches the
+// environment for updates, reconnecting with backoff, and decodes the configuration into structs.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AccessKeyHeader is the header carrying the access key of every request
+	AccessKeyHeader = "X-Access-Key"
+	// ProjectHeader is the header selecting the project of every request
+	ProjectHeader = "X-Configleam-Project"
+	// VersionHeader is the header carrying the version of the returned configuration
+	VersionHeader = "X-Configleam-Version"
+)
+
+// ErrNoConfig is returned when Configleam is unreachable and no configuration was read or cached before
+var ErrNoConfig = errors.New("configuration is not available")
+
+// ErrNoEnv is returned by the operations on the environment of the client when none was configured
+var ErrNoEnv = errors.New("env is required")
+
+// StatusError is returned when Configleam answers with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("configleam responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Options configure the client, only the URL and access key are required,
+// the environment being required to read, watch and clone it and to upsert its secrets
+type Options struct {
+	// URL is the base URL of Configleam, e.g. "https://configleam.internal:8080"
+	URL       string
+	AccessKey string
+	// Project is the project to read from, the default one when empty
+	Project string
+	Env     string
+	Groups  []string
+	Globals []string
+	// Select holds the fields to read, as dot paths or JSON Pointers
+	Select []string
+	// WholeEnv reads the whole environment instead of the requested groups, globals and fields,
+	// every group resolved under "groups" and every global key under "globals"
+	WholeEnv bool
+	// SecretMode is how secrets that cannot be revealed are read, "lenient", "strict" or "masked",
+	// the mode of the access key when empty
+	SecretMode string
+
+	// FallbackFile is where the last configuration read is kept to be served while Configleam is unreachable
+	FallbackFile string
+	// HTTPClient defaults to a client with a 10s timeout, watching uses it without its timeout
+	HTTPClient *http.Client
+
+	// MinBackoff and MaxBackoff bound the delay between reconnections when watching, 1s and 1m by default
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// ChangeFunc is called with the new configuration and its version when it changes
+type ChangeFunc func(cfg map[string]interface{}, version string)
+
+// Snapshot is a configuration read from Configleam, or from the fallback file while it is unreachable
+type Snapshot struct {
+	// Key identifies the URL, project, env, groups, globals, fields and secret mode the configuration was read with
+	Key     string                 `json:"key"`
+	Version string                 `json:"version"`
+	ETag    string                 `json:"etag"`
+	Config  map[string]interface{} `json:"config"`
+}
+
+type Client struct {
+	opts   Options
+	http   *http.Client
+	stream *http.Client
+
+	// key identifies what the client reads, a snapshot read with other options is never served
+	key string
+
+	mu        sync.RWMutex
+	snapshot  *Snapshot
+	callbacks []ChangeFunc
+}
+
+// New creates a client, loading the fallback file when it exists
+func New(opts Options) (*Client, error) {
+	if opts.URL == "" || opts.AccessKey == "" {
+		return nil, errors.New("url and access key are required")
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(time.Minute, opts.MinBackoff)
+	}
+
+	// the stream is long lived, it shares the transport of the client but not its timeout
+	stream := *opts.HTTPClient
+	stream.Timeout = 0
+
+	c := &Client{opts: opts, http: opts.HTTPClient, stream: &stream, key: snapshotKey(opts)}
+
+	if opts.FallbackFile != "" {
+		snapshot, err := readFallback(opts.FallbackFile)
+		if err != nil {
+			return nil, err
+		}
+		// a file kept for another env, project or selection is ignored, and replaced on the first read
+		if snapshot != nil && snapshot.Key == c.key {
+			c.snapshot = snapshot
+		}
+	}
+
+	return c, nil
+}
+
+// OnChange registers a callback called whenever a refresh reads a configuration different from the previous one
+func (c *Client) OnChange(fn ChangeFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callbacks = append(c.callbacks, fn)
+}
+
+// Config returns the configuration, reading it from Configleam and falling back to the last one read,
+// or to the fallback file, when Configleam is unreachable or answers with a server error
+func (c *Client) Config(ctx context.Context) (map[string]interface{}, string, error) {
+	snapshot, err := c.Refresh(ctx)
+	if err == nil {
+		return snapshot.Config, snapshot.Version, nil
+	}
+
+	var statusErr StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError {
+		return nil, "", err
+	}
+
+	if snapshot := c.Snapshot(); snapshot != nil {
+		return snapshot.Config, snapshot.Version, nil
+	}
+	return nil, "", fmt.Errorf("%w: %v", ErrNoConfig, err)
+}
+
+// Decode reads the configuration, as Config does, and decodes it into v following its json tags
+func (c *Client) Decode(ctx context.Context, v interface{}) error {
+	cfg, _, err := c.Config(ctx)
+	if err != nil {
+		return err
+	}
+	return decode(cfg, v)
+}
+
+// Snapshot returns the last configuration read, nil when none was read nor loaded from the fallback file
+func (c *Client) Snapshot() *Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot
+}
+
+// Refresh reads the configuration from Configleam, sending the entity tag of the last one read so an
+// unchanged configuration is not transferred again. The change callbacks are called when it changed.
+func (c *Client) Refresh(ctx context.Context) (*Snapshot, error) {
+	if c.opts.Env == "" {
+		return nil, ErrNoEnv
+	}
+
+	path, query := "/config", url.Values{"env": {c.opts.Env}}
+	if c.opts.WholeEnv {
+		path = "/config/env"
+	} else {
+		query["groups"] = c.opts.Groups
+		query["globals"] = c.opts.Globals
+		query["select"] = c.opts.Select
+	}
+	if c.opts.SecretMode != "" {
+		query.Set("secretMode", c.opts.SecretMode)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	previous := c.Snapshot()
+	if previous != nil && previous.ETag != "" {
+		req.Header.Set("If-None-Match", previous.ETag)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && previous != nil {
+		return previous, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, statusError(res)
+	}
+
+	snapshot := &Snapshot{
+		Key:     c.key,
+		Version: res.Header.Get(VersionHeader),
+		ETag:    res.Header.Get("ETag"),
+		Config:  map[string]interface{}{},
+	}
+	err = json.NewDecoder(res.Body).Decode(&snapshot.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding configuration: %w", err)
+	}
+
+	c.mu.Lock()
+	changed := previous == nil || previous.Version != snapshot.Version || !reflect.DeepEqual(previous.Config, snapshot.Config)
+	c.snapshot = snapshot
+	callbacks := append([]ChangeFunc{}, c.callbacks...)
+	c.mu.Unlock()
+
+	if changed {
+		for _, fn := range callbacks {
+			fn(snapshot.Config, snapshot.Version)
+		}
+	}
+
+	if changed && c.opts.FallbackFile != "" {
+		err = writeFallback(c.opts.FallbackFile, snapshot)
+		if err != nil {
+			return snapshot, err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Clone clones the environment of the client into newEnv, overriding the provided global keys
+func (c *Client) Clone(ctx context.Context, newEnv string, updateGlobals map[string]interface{}) error {
+	if c.opts.Env == "" {
+		return ErrNoEnv
+	}
+	if updateGlobals == nil {
+		updateGlobals = map[string]interface{}{}
+	}
+	return c.send(ctx, http.MethodPost, "/config/clone", url.Values{"env": {c.opts.Env}, "newEnv": {newEnv}}, updateGlobals)
+}
+
+// DeleteClone deletes a cloned environment
+func (c *Client) DeleteClone(ctx context.Context, env string) error {
+	return c.send(ctx, http.MethodDelete, "/config/clone", url.Values{"env": {env}}, nil)
+}
+
+// UpsertSecrets creates or updates the secrets of the environment of the client
+func (c *Client) UpsertSecrets(ctx context.Context, secrets map[string]interface{}) error {
+	if c.opts.Env == "" {
+		return ErrNoEnv
+	}
+	return c.send(ctx, http.MethodPut, "/secrets", url.Values{"env": {c.opts.Env}}, secrets)
+}
+
+// send sends a request with an optional JSON body, expecting a successful status code
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}) error {
+	return c.do(ctx, method, path, query, body, nil)
+}
+
+// do sends a request with an optional JSON body, expecting a successful status code,
+// and decodes the JSON response into out when it is not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request %s %s: %w", method, path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return statusError(res)
+	}
+
+	if out != nil {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			return fmt.Errorf("error decoding response of %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.opts.URL + path
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request %s %s: %w", method, path, err)
+	}
+
+	req.Header.Set(AccessKeyHeader, c.opts.AccessKey)
+	if c.opts.Project != "" {
+		req.Header.Set(ProjectHeader, c.opts.Project)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+func statusError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	return StatusError{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(body))}
+}
+
+// decode converts the configuration into v through its JSON representation
+func decode(cfg map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("error encoding configuration: %w", err)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("error decoding configuration: %w", err)
+	}
+	return nil
+}
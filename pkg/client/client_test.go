@@ -0,0 +1,337 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/raw-leak/configleam/pkg/client"
+)
+
+// fakeConfigleam serves GET /config and GET /config/sse the way Configleam does
+type fakeConfigleam struct {
+	mu       sync.Mutex
+	version  string
+	config   map[string]interface{}
+	requests []*http.Request
+	bodies   []map[string]interface{}
+	updates  chan string
+	down     bool
+}
+
+func (f *fakeConfigleam) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	down, version := f.down, f.version
+	cfg, _ := json.Marshal(f.config)
+	f.mu.Unlock()
+
+	if down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get(client.AccessKeyHeader) != "read-key" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /config":
+		etag := fmt.Sprintf(`"%s-%d"`, version, len(cfg))
+		w.Header().Set("ETag", etag)
+		w.Header().Set(client.VersionHeader, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(cfg)
+	case "GET /config/sse":
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: Connection established\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case env := <-f.updates:
+				fmt.Fprintf(w, "data: %s\n\n", env)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		body := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.bodies = append(f.bodies, body)
+		f.mu.Unlock()
+		w.Write([]byte(`{"message":"ok"}`))
+	}
+}
+
+func (f *fakeConfigleam) set(version string, cfg map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version, f.config = version, cfg
+}
+
+func (f *fakeConfigleam) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+type ClientSuite struct {
+	suite.Suite
+	fake   *fakeConfigleam
+	server *httptest.Server
+}
+
+func TestClientSuite(t *testing.T) {
+	suite.Run(t, new(ClientSuite))
+}
+
+func (suite *ClientSuite) SetupTest() {
+	suite.fake = &fakeConfigleam{
+		version: "v1.0.0",
+		config:  map[string]interface{}{"database": map[string]interface{}{"host": "localhost", "port": float64(5432)}},
+		updates: make(chan string),
+	}
+	suite.server = httptest.NewServer(suite.fake)
+}
+
+func (suite *ClientSuite) TearDownTest() {
+	suite.server.CloseClientConnections()
+	suite.server.Close()
+}
+
+func (suite *ClientSuite) newClient(opts client.Options) *client.Client {
+	opts.URL = suite.server.URL + "/"
+	opts.Env = "develop"
+	if opts.AccessKey == "" {
+		opts.AccessKey = "read-key"
+	}
+	c, err := client.New(opts)
+	suite.Require().NoError(err)
+	return c
+}
+
+func (suite *ClientSuite) TestConfig() {
+	c := suite.newClient(client.Options{Project: "billing", Groups: []string{"database"}, Select: []string{"database.host"}, SecretMode: "masked"})
+
+	cfg, version, err := c.Config(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal("v1.0.0", version)
+	suite.Equal(suite.fake.config, cfg)
+
+	req := suite.fake.requests[0]
+	suite.Equal("billing", req.Header.Get(client.ProjectHeader))
+	suite.Equal("develop", req.URL.Query().Get("env"))
+	suite.Equal([]string{"database"}, req.URL.Query()["groups"])
+	suite.Equal([]string{"database.host"}, req.URL.Query()["select"])
+	suite.Equal("masked", req.URL.Query().Get("secretMode"))
+
+	// the second read revalidates the entity tag of the first one
+	cfg, _, err = c.Config(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal(suite.fake.config, cfg)
+	suite.NotEmpty(suite.fake.requests[1].Header.Get("If-None-Match"))
+}
+
+func (suite *ClientSuite) TestConfigErrors() {
+	c := suite.newClient(client.Options{AccessKey: "other-key"})
+
+	_, _, err := c.Config(context.Background())
+	var statusErr client.StatusError
+	suite.ErrorAs(err, &statusErr)
+	suite.Equal(http.StatusForbidden, statusErr.StatusCode)
+
+	suite.fake.setDown(true)
+	_, _, err = suite.newClient(client.Options{}).Config(context.Background())
+	suite.ErrorIs(err, client.ErrNoConfig)
+}
+
+func (suite *ClientSuite) TestFallbackFile() {
+	file := filepath.Join(suite.T().TempDir(), "configleam.json")
+
+	_, _, err := suite.newClient(client.Options{FallbackFile: file}).Config(context.Background())
+	suite.Require().NoError(err)
+
+	suite.fake.setDown(true)
+
+	// a new client, e.g. after a restart, serves the configuration kept in the file while configleam is unreachable
+	c := suite.newClient(client.Options{FallbackFile: file})
+	cfg, version, err := c.Config(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal("v1.0.0", version)
+	suite.Equal(suite.fake.config, cfg)
+}
+
+func (suite *ClientSuite) TestFallbackFileOfAnotherEnv() {
+	file := filepath.Join(suite.T().TempDir(), "configleam.json")
+
+	_, _, err := suite.newClient(client.Options{FallbackFile: file}).Config(context.Background())
+	suite.Require().NoError(err)
+
+	suite.fake.setDown(true)
+
+	// the file kept for develop is never served, nor revalidated, for production
+	opts := client.Options{URL: suite.server.URL, AccessKey: "read-key", Env: "production", FallbackFile: file}
+	c, err := client.New(opts)
+	suite.Require().NoError(err)
+	suite.Nil(c.Snapshot())
+
+	_, _, err = c.Config(context.Background())
+	suite.ErrorIs(err, client.ErrNoConfig)
+	suite.Empty(suite.fake.requests[len(suite.fake.requests)-1].Header.Get("If-None-Match"))
+
+	// nor for another selection of the same env
+	_, _, err = suite.newClient(client.Options{FallbackFile: file, Groups: []string{"database"}}).Config(context.Background())
+	suite.ErrorIs(err, client.ErrNoConfig)
+}
+
+func (suite *ClientSuite) TestDecode() {
+	var cfg struct {
+		Database struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		} `json:"database"`
+	}
+
+	err := suite.newClient(client.Options{}).Decode(context.Background(), &cfg)
+	suite.Require().NoError(err)
+	suite.Equal("localhost", cfg.Database.Host)
+	suite.Equal(5432, cfg.Database.Port)
+}
+
+func (suite *ClientSuite) TestWatch() {
+	c := suite.newClient(client.Options{MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+
+	changes := make(chan string, 10)
+	c.OnChange(func(cfg map[string]interface{}, version string) { changes <- version })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- c.Watch(ctx, nil) }()
+
+	// the configuration is read on connection
+	suite.Equal("v1.0.0", <-changes)
+
+	suite.fake.set("v1.1.0", map[string]interface{}{"database": map[string]interface{}{"host": "db.internal"}})
+	suite.fake.updates <- "develop"
+	suite.Equal("v1.1.0", <-changes)
+	suite.Equal("db.internal", c.Snapshot().Config["database"].(map[string]interface{})["host"])
+
+	// it reconnects once the stream is closed, reading the configuration updated meanwhile
+	suite.fake.set("v1.2.0", map[string]interface{}{"database": map[string]interface{}{"host": "db2.internal"}})
+	suite.server.CloseClientConnections()
+
+	select {
+	case version := <-changes:
+		suite.Equal("v1.2.0", version)
+	case <-time.After(5 * time.Second):
+		suite.Fail("configuration not refreshed after reconnecting")
+	}
+
+	cancel()
+	suite.ErrorIs(<-done, context.Canceled)
+}
+
+func (suite *ClientSuite) TestCloneAndSecrets() {
+	c := suite.newClient(client.Options{})
+
+	suite.Require().NoError(c.Clone(context.Background(), "develop-feature", map[string]interface{}{"region": "eu"}))
+	suite.Require().NoError(c.DeleteClone(context.Background(), "develop-feature"))
+	suite.Require().NoError(c.UpsertSecrets(context.Background(), map[string]interface{}{"password": "secret"}))
+
+	suite.Equal("POST", suite.fake.requests[0].Method)
+	suite.Equal("develop-feature", suite.fake.requests[0].URL.Query().Get("newEnv"))
+	suite.Equal(map[string]interface{}{"region": "eu"}, suite.fake.bodies[0])
+	suite.Equal("DELETE", suite.fake.requests[1].Method)
+	suite.Equal("develop-feature", suite.fake.requests[1].URL.Query().Get("env"))
+	suite.Equal("/secrets", suite.fake.requests[2].URL.Path)
+	suite.Equal(map[string]interface{}{"password": "secret"}, suite.fake.bodies[2])
+}
+
+func (suite *ClientSuite) TestAdmin() {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /config/envs":
+			w.Write([]byte(`{"envs":[{"name":"develop","version":"v1.0.0","clone":false,"original":""}]}`))
+		case "GET /config":
+			w.Write([]byte("database:\n    host: localhost\n"))
+		case "GET /config/env":
+			w.Write([]byte(`{"groups":{"billing":{"host":"localhost"}},"globals":{"region":"eu"}}`))
+		case "POST /access":
+			w.Write([]byte(`{"globalAdmin":false,"environments":{"develop":{"readConfig":true}},"accessKey":"new-key","name":"ci"}`))
+		case "GET /access":
+			w.Write([]byte(`{"total":1,"pages":1,"page":1,"size":100,"items":[{"Key":"new-key","name":"ci","masked_key":"new-****-key"}]}`))
+		case "POST /config/sync", "GET /config/sync":
+			w.Write([]byte(`{"active":true,"lastSync":"2024-03-01T10:00:00Z","envs":{"develop":"v1.0.0"}}`))
+		default:
+			w.Write([]byte(`{"message":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	c, err := client.New(client.Options{URL: server.URL, AccessKey: "admin-key", Env: "develop"})
+	suite.Require().NoError(err)
+	ctx := context.Background()
+
+	envs, err := c.ListEnvs(ctx)
+	suite.Require().NoError(err)
+	suite.Equal([]client.Env{{Name: "develop", Version: "v1.0.0"}}, envs)
+
+	body, err := c.Format(ctx, "yaml", "v1.0.0")
+	suite.Require().NoError(err)
+	suite.Equal("database:\n    host: localhost\n", string(body))
+	suite.Equal("yaml", requests[1].URL.Query().Get("format"))
+	suite.Equal("v1.0.0", requests[1].URL.Query().Get("version"))
+
+	created, err := c.CreateAccessKey(ctx, client.AccessKeyRequest{Name: "ci", Envs: map[string]client.EnvPermissions{"develop": {ReadConfig: true}}})
+	suite.Require().NoError(err)
+	suite.Equal("new-key", created.AccessKey)
+
+	page, err := c.ListAccessKeys(ctx, 1, 100)
+	suite.Require().NoError(err)
+	suite.Equal("new-key", page.Items[0].Key)
+	suite.Equal("1", requests[3].URL.Query().Get("page"))
+
+	suite.Require().NoError(c.RevokeAccessKeys(ctx, "new-key"))
+	suite.Equal([]string{"new-key"}, requests[4].URL.Query()["key"])
+
+	status, err := c.Sync(ctx)
+	suite.Require().NoError(err)
+	suite.True(status.Active)
+	suite.Equal(map[string]string{"develop": "v1.0.0"}, status.Envs)
+	suite.Equal(http.MethodPost, requests[5].Method)
+
+	_, err = c.SyncStatus(ctx)
+	suite.Require().NoError(err)
+
+	// the whole environment is read instead of the requested groups
+	c, err = client.New(client.Options{URL: server.URL, AccessKey: "admin-key", Env: "develop", Groups: []string{"billing"}, WholeEnv: true})
+	suite.Require().NoError(err)
+	cfg, _, err := c.Config(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(map[string]interface{}{"region": "eu"}, cfg["globals"])
+	suite.Empty(requests[len(requests)-1].URL.Query()["groups"])
+
+	// operations on the environment require one
+	c, err = client.New(client.Options{URL: server.URL, AccessKey: "admin-key"})
+	suite.Require().NoError(err)
+	_, err = c.Format(ctx, "json", "")
+	suite.ErrorIs(err, client.ErrNoEnv)
+	suite.ErrorIs(c.Watch(ctx, nil), client.ErrNoEnv)
+}
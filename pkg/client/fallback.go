@@ -0,0 +1,82 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// snapshotKey identifies the configuration read with the options, the groups, globals and fields in any order
+func snapshotKey(opts Options) string {
+	sorted := func(values []string) string {
+		values = append([]string{}, values...)
+		sort.Strings(values)
+		return strings.Join(values, ",")
+	}
+
+	h := sha256.New()
+	for _, part := range []string{opts.URL, opts.Project, opts.Env, sorted(opts.Groups), sorted(opts.Globals), sorted(opts.Select),
+		strconv.FormatBool(opts.WholeEnv), opts.SecretMode} {
+		fmt.Fprintf(h, "%s\x00", part)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// readFallback loads the snapshot kept in the fallback file, nil when the file does not exist yet
+func readFallback(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading fallback file '%s': %w", path, err)
+	}
+
+	var snapshot Snapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding fallback file '%s': %w", path, err)
+	}
+
+	return &snapshot, nil
+}
+
+// writeFallback replaces the fallback file atomically, so a crash never leaves a partial configuration behind.
+// It is only readable by its owner as the configuration may hold revealed secrets.
+func writeFallback(path string, snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("error encoding fallback file '%s': %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating fallback file '%s': %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing fallback file '%s': %w", path, err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("error replacing fallback file '%s': %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Watch listens to the updates of the environment until the context is done, refreshing the configuration
+// on every update and calling the change callbacks when it changed. The configuration is also refreshed on
+// every connection, so updates missed while disconnected are not lost. When the connection fails or is
+// closed it reconnects, waiting an exponential backoff with jitter between failed attempts.
+//
+// The errors of every attempt are passed to onError when it is not nil. Watch returns the context error,
+// or ErrNoEnv when the client has no environment.
+func (c *Client) Watch(ctx context.Context, onError func(error)) error {
+	if c.opts.Env == "" {
+		return ErrNoEnv
+	}
+
+	backoff := c.opts.MinBackoff
+
+	for {
+		connected, err := c.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+
+		if connected {
+			backoff = c.opts.MinBackoff
+		}
+
+		// full jitter over the upper half of the backoff, so clients disconnected together do not reconnect together
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		backoff = min(backoff*2, c.opts.MaxBackoff)
+	}
+}
+
+// watchOnce opens the event stream and handles its events until it is closed,
+// reporting whether the connection was established
+func (c *Client) watchOnce(ctx context.Context) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/config/sse", url.Values{"env": {c.opts.Env}}, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := c.stream.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error connecting to update events: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, statusError(res)
+	}
+
+	if _, err := c.Refresh(ctx); err != nil {
+		return true, err
+	}
+
+	// configuration updates are unnamed events whose data is the updated env, other events are ignored
+	err = readEvents(res, func(event, data string) error {
+		if event != "message" || data != c.opts.Env {
+			return nil
+		}
+
+		_, err := c.Refresh(ctx)
+		return err
+	})
+	if err != nil {
+		return true, err
+	}
+
+	return true, fmt.Errorf("update events stream closed")
+}
+
+// readEvents parses the server-sent events of the response, calling handle with every event
+// name, "message" when not named, and data. It returns the first error returned by handle.
+func readEvents(res *http.Response, handle func(event, data string) error) error {
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	event, data := "", []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if len(data) > 0 {
+				if event == "" {
+					event = "message"
+				}
+				if err := handle(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event, data = "", []string{}
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading update events: %w", err)
+	}
+	return nil
+}